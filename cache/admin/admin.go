@@ -0,0 +1,134 @@
+// Package admin implements the `imposm cache` command to inspect and
+// maintain an existing cache.
+package admin
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/omniscale/imposm3/cache"
+	"github.com/omniscale/imposm3/log"
+)
+
+var flags = flag.NewFlagSet("cache", flag.ExitOnError)
+
+var (
+	cachedir    = flags.String("cachedir", "/tmp/imposm", "cache directory")
+	maxDangling = flags.Int("limit", 100, "max number of dangling refs to list with verify")
+
+	exportOut     = flags.String("o", "", "output PBF file for export")
+	limitTo       = flags.String("limitto", "", "only export or keep elements within this GeoJSON polygon")
+	pruneBuffer   = flags.Float64("buffer", 0, "keep elements within this buffer around -limitto with prune (in degrees)")
+	exportBBox    = flags.String("bbox", "", "only export elements within minx,miny,maxx,maxy (EPSG:4326)")
+	exportDiffDir = flags.String("diffdir", "", "diff directory with last.state.txt for export (defaults to -cachedir)")
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s cache COMMAND [args]\n\n", os.Args[0])
+	fmt.Fprintln(os.Stderr, "Available commands:")
+	fmt.Fprintln(os.Stderr, "\tstats    show number of entries and size of each cache")
+	fmt.Fprintln(os.Stderr, "\tverify   check diff cache indexes for dangling refs")
+	fmt.Fprintln(os.Stderr, "\tcompact  compact all caches")
+	fmt.Fprintln(os.Stderr, "\texport   export cache as PBF file")
+	fmt.Fprintln(os.Stderr, "\tmigrate  update cache manifest and rewrite coords for a changed BunchSize")
+	fmt.Fprintln(os.Stderr, "\tprune    remove elements outside of -limitto from the cache")
+	fmt.Fprintln(os.Stderr, "\nArgs:")
+	flags.PrintDefaults()
+}
+
+// Cache runs the `imposm cache` command. The cache is locked during the
+// command and it fails if the cache is in use by another imposm process.
+func Cache(args []string) {
+	flags.Usage = usage
+	if len(args) == 0 {
+		usage()
+		os.Exit(1)
+	}
+	cmd := args[0]
+	if err := flags.Parse(args[1:]); err != nil {
+		log.Fatal(err)
+	}
+	switch cmd {
+	case "stats", "verify", "compact", "export", "migrate", "prune":
+	default:
+		usage()
+		log.Fatalf("invalid cache command: '%s'", cmd)
+	}
+
+	osmCache := cache.NewOSMCache(*cachedir)
+	if !osmCache.Exists() {
+		log.Fatalf("[error] no cache found in %s", *cachedir)
+	}
+
+	lock, err := cache.LockDir(*cachedir)
+	if errors.Is(err, cache.ErrLocked) {
+		log.Fatalf("[error] %v, stop it before running cache %s", err, cmd)
+	} else if err != nil {
+		log.Fatal("[error] ", err)
+	}
+	defer lock.Unlock()
+
+	if cmd == "migrate" {
+		if err := cache.Migrate(*cachedir); err != nil {
+			log.Fatal("[error] ", err)
+		}
+		return
+	}
+
+	if err := osmCache.Open(); err != nil {
+		log.Fatal("[error] opening cache files: ", err)
+	}
+	defer osmCache.Close()
+
+	var diffCache *cache.DiffCache
+	if dc := cache.NewDiffCache(*cachedir); dc.Exists() {
+		if err := dc.Open(); err != nil {
+			log.Fatal("[error] opening diff cache files: ", err)
+		}
+		defer dc.Close()
+		diffCache = dc
+	}
+
+	switch cmd {
+	case "stats":
+		err = printStats(os.Stdout, *cachedir, osmCache, diffCache)
+	case "verify":
+		err = runVerify(os.Stdout, osmCache, diffCache, *maxDangling)
+	case "compact":
+		err = compact(*cachedir, osmCache, diffCache)
+	case "export":
+		err = runExport(osmCache)
+	case "prune":
+		err = runPrune(*cachedir, osmCache, diffCache)
+	}
+	if err != nil {
+		log.Fatal("[error] ", err)
+	}
+}
+
+func compact(dir string, osmCache *cache.OSMCache, diffCache *cache.DiffCache) error {
+	before, err := cacheUsage(dir)
+	if err != nil {
+		return err
+	}
+	step := log.Step("Compacting OSM cache")
+	if err := osmCache.Compact(); err != nil {
+		return err
+	}
+	step()
+	if diffCache != nil {
+		step := log.Step("Compacting diff cache")
+		if err := diffCache.Compact(); err != nil {
+			return err
+		}
+		step()
+	}
+	after, err := cacheUsage(dir)
+	if err != nil {
+		return err
+	}
+	log.Printf("[info] Cache size %s (before %s)", formatSize(after), formatSize(before))
+	return nil
+}
@@ -0,0 +1,177 @@
+package admin
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	osm "github.com/omniscale/go-osm"
+	"github.com/omniscale/imposm3/cache"
+)
+
+func node(id int64) osm.Node {
+	return osm.Node{Element: osm.Element{ID: id}, Long: 8, Lat: 53}
+}
+
+func createCache(t *testing.T, dir string) (*cache.OSMCache, *cache.DiffCache) {
+	t.Helper()
+	osmCache := cache.NewOSMCache(dir)
+	if err := osmCache.Open(); err != nil {
+		t.Fatal(err)
+	}
+	diffCache := cache.NewDiffCache(dir)
+	if err := diffCache.Open(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := osmCache.Coords.PutCoords([]osm.Node{node(1), node(2), node(3), node(4)}); err != nil {
+		t.Fatal(err)
+	}
+	ways := []osm.Way{
+		{Element: osm.Element{ID: 10}, Refs: []int64{1, 2}},
+		{Element: osm.Element{ID: 11}, Refs: []int64{2, 3, 4}},
+	}
+	for _, w := range ways {
+		for _, ref := range w.Refs {
+			diffCache.Coords.Add(ref, w.ID)
+		}
+	}
+	// PutWays delta encodes the refs in-place
+	if err := osmCache.Ways.PutWays(ways); err != nil {
+		t.Fatal(err)
+	}
+	rel := osm.Relation{
+		Element: osm.Element{ID: 100},
+		Members: []osm.Member{
+			{ID: 10, Type: osm.WayMember},
+			{ID: 4, Type: osm.NodeMember},
+		},
+	}
+	if err := osmCache.Relations.PutRelation(&rel); err != nil {
+		t.Fatal(err)
+	}
+
+	diffCache.Ways.Add(10, 100)
+	diffCache.CoordsRel.Add(4, 100)
+	return osmCache, diffCache
+}
+
+func TestVerify(t *testing.T) {
+	dir, err := ioutil.TempDir("", "imposm_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	osmCache, diffCache := createCache(t, dir)
+	defer osmCache.Close()
+	defer diffCache.Close()
+
+	var dangling []danglingRef
+	report := func(d danglingRef) { dangling = append(dangling, d) }
+
+	result, err := verify(osmCache, diffCache, report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := result.total(); n != 0 {
+		t.Fatal("unexpected dangling refs", dangling)
+	}
+	if result.Checked["coords_index"] != 5 || result.Checked["ways_index"] != 1 || result.Checked["coords_rel_index"] != 1 {
+		t.Error("unexpected number of checked refs", result.Checked)
+	}
+
+	diffCache.Coords.Add(3, 10)     // node not in way
+	diffCache.Coords.Add(5, 12)     // missing way
+	diffCache.Ways.Add(11, 100)     // way not a member
+	diffCache.CoordsRel.Add(4, 101) // missing relation
+
+	result, err = verify(osmCache, diffCache, report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []danglingRef{
+		{"coords_index", 3, 10, "node not in way"},
+		{"coords_index", 5, 12, "way not found"},
+		{"coords_rel_index", 4, 101, "relation not found"},
+		{"ways_index", 11, 100, "not a member of relation"},
+	}
+	if len(dangling) != len(expected) {
+		t.Fatal("unexpected dangling refs", dangling)
+	}
+	for i := range expected {
+		if dangling[i] != expected[i] {
+			t.Errorf("%v != %v", dangling[i], expected[i])
+		}
+	}
+	if result.total() != 4 || result.Dangling["coords_index"] != 2 {
+		t.Error("unexpected dangling counts", result.Dangling)
+	}
+}
+
+func TestStats(t *testing.T) {
+	dir, err := ioutil.TempDir("", "imposm_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	osmCache, diffCache := createCache(t, dir)
+	defer osmCache.Close()
+	defer diffCache.Close()
+	if err := osmCache.Coords.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := collectStats(dir, osmCache, diffCache)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]int64{
+		"coords":           4,
+		"nodes":            0,
+		"ways":             2,
+		"relations":        1,
+		"coords_index":     4,
+		"coords_rel_index": 1,
+		"ways_index":       1,
+	}
+	if len(stats) != len(expected) {
+		t.Fatal(stats)
+	}
+	for _, s := range stats {
+		if s.Entries != expected[s.Name] {
+			t.Errorf("%s: %d entries, expected %d", s.Name, s.Entries, expected[s.Name])
+		}
+		if s.Entries > 0 && s.Size <= 0 {
+			t.Errorf("%s: no size", s.Name)
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	if err := printStats(buf, dir, osmCache, nil); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "no diff cache found") || strings.Contains(buf.String(), "ways_index") {
+		t.Error(buf.String())
+	}
+}
+
+func TestFormatSize(t *testing.T) {
+	for _, tc := range []struct {
+		size     int64
+		expected string
+	}{
+		{0, "0 B"},
+		{1023, "1023 B"},
+		{1024, "1.0 KiB"},
+		{1536 * 1024, "1.5 MiB"},
+		{5 * 1024 * 1024 * 1024, "5.0 GiB"},
+	} {
+		if got := formatSize(tc.size); got != tc.expected {
+			t.Errorf("formatSize(%d) = %q, expected %q", tc.size, got, tc.expected)
+		}
+	}
+}
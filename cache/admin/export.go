@@ -0,0 +1,88 @@
+package admin
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/omniscale/go-osm/state"
+	"github.com/omniscale/imposm3"
+	"github.com/omniscale/imposm3/cache"
+	"github.com/omniscale/imposm3/cache/export"
+	"github.com/omniscale/imposm3/geom/geos"
+	"github.com/omniscale/imposm3/geom/limit"
+	"github.com/omniscale/imposm3/log"
+	updatestate "github.com/omniscale/imposm3/update/state"
+)
+
+func runExport(osmCache *cache.OSMCache) error {
+	if *exportOut == "" {
+		return errors.New("missing -o option for export")
+	}
+	if *limitTo != "" && *exportBBox != "" {
+		return errors.New("-limitto and -bbox are exclusive")
+	}
+
+	opts := export.Options{Program: "imposm " + imposm3.Version, TempDir: *cachedir}
+	if *exportBBox != "" {
+		bbox, err := export.ParseBBox(*exportBBox)
+		if err != nil {
+			return err
+		}
+		opts.Filter = export.BBoxFilter(bbox[0], bbox[1], bbox[2], bbox[3])
+	}
+	if *limitTo != "" {
+		step := log.Step("Reading limitto geometries")
+		limiter, err := limit.NewFromGeoJSON(*limitTo, 0, 4326)
+		if err != nil {
+			return fmt.Errorf("reading limitto: %w", err)
+		}
+		step()
+		g := geos.NewGeos()
+		defer g.Finish()
+		opts.Filter = func(long, lat float64) bool {
+			return limiter.Intersects(g, long, lat)
+		}
+	}
+
+	diffDir := *exportDiffDir
+	if diffDir == "" {
+		diffDir = *cachedir
+	}
+	if s, err := state.ParseFile(filepath.Join(diffDir, updatestate.LastStateFilename)); err == nil {
+		opts.Header = export.Header{Time: s.Time, Sequence: s.Sequence, URL: s.URL}
+	} else if !os.IsNotExist(err) {
+		log.Printf("[warn] Unable to read last state, exporting without replication state: %v", err)
+	}
+
+	// write to temporary file to not leave incomplete files
+	tmpName := *exportOut + ".tmp"
+	f, err := os.Create(tmpName)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpName)
+	defer f.Close()
+	w := bufio.NewWriterSize(f, 1024*1024)
+
+	step := log.Step("Exporting cache")
+	stats, err := export.Export(osmCache, w, opts)
+	if err != nil {
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpName, *exportOut); err != nil {
+		return err
+	}
+	step()
+	log.Printf("[info] Exported %d nodes, %d ways and %d relations to %s",
+		stats.Nodes, stats.Ways, stats.Relations, *exportOut)
+	return nil
+}
@@ -0,0 +1,61 @@
+package admin
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/omniscale/imposm3/cache"
+	"github.com/omniscale/imposm3/geom/geos"
+	"github.com/omniscale/imposm3/geom/limit"
+	"github.com/omniscale/imposm3/log"
+)
+
+func runPrune(dir string, osmCache *cache.OSMCache, diffCache *cache.DiffCache) error {
+	if *limitTo == "" {
+		return errors.New("missing -limitto option for prune")
+	}
+	if *pruneBuffer < 0 {
+		return errors.New("-buffer needs to be positive")
+	}
+
+	step := log.Step("Reading limitto geometries")
+	limiter, err := limit.NewFromGeoJSON(*limitTo, *pruneBuffer, 4326)
+	if err != nil {
+		return fmt.Errorf("reading limitto: %w", err)
+	}
+	step()
+	g := geos.NewGeos()
+	defer g.Finish()
+	area := &pruneArea{limiter: limiter, g: g, buffered: *pruneBuffer > 0}
+
+	stats, err := cache.Prune(osmCache, diffCache, area)
+	if err != nil {
+		return err
+	}
+	log.Printf("[info] Removed %d coords, %d nodes, %d ways, %d relations and %d index refs",
+		stats.Coords, stats.Nodes, stats.Ways, stats.Relations, stats.IndexRefs)
+
+	return compact(dir, osmCache, diffCache)
+}
+
+// pruneArea is the (buffered) limitto geometry for cache.Prune.
+type pruneArea struct {
+	limiter  *limit.Limiter
+	g        *geos.Geos
+	buffered bool
+}
+
+func (a *pruneArea) Intersects(long, lat float64) bool {
+	if a.buffered {
+		return a.limiter.IntersectsBuffer(a.g, long, lat)
+	}
+	return a.limiter.Intersects(a.g, long, lat)
+}
+
+func (a *pruneArea) IntersectsBBox(minLong, minLat, maxLong, maxLat float64) bool {
+	bounds := geos.MakeBounds(minLong, minLat, maxLong, maxLat)
+	if a.buffered {
+		return a.limiter.IntersectsBoundsBuffer(a.g, bounds)
+	}
+	return a.limiter.IntersectsBounds(a.g, bounds)
+}
@@ -0,0 +1,9 @@
+//go:build !unix
+
+package admin
+
+import "os"
+
+func allocatedSize(fi os.FileInfo) int64 {
+	return fi.Size()
+}
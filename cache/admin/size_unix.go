@@ -0,0 +1,17 @@
+//go:build unix
+
+package admin
+
+import (
+	"os"
+	"syscall"
+)
+
+// allocatedSize returns the number of bytes allocated on disk, which is
+// less then the file size for sparse files (e.g. coords.flat).
+func allocatedSize(fi os.FileInfo) int64 {
+	if st, ok := fi.Sys().(*syscall.Stat_t); ok {
+		return int64(st.Blocks) * 512
+	}
+	return fi.Size()
+}
@@ -0,0 +1,146 @@
+package admin
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+
+	"github.com/omniscale/imposm3/cache"
+)
+
+// storeStats are the statistics of a single sub-cache.
+type storeStats struct {
+	Name    string
+	Entries int64
+	Size    int64 // bytes on disk
+}
+
+type counter interface {
+	Count() (int64, error)
+}
+
+type namedStore struct {
+	name string
+	c    counter
+}
+
+func osmStores(osmCache *cache.OSMCache) []namedStore {
+	coords := "coords"
+	if _, ok := osmCache.Coords.(*cache.FlatCoordsCache); ok {
+		coords = "coords.flat"
+	}
+	return []namedStore{
+		{coords, osmCache.Coords},
+		{"nodes", osmCache.Nodes},
+		{"ways", osmCache.Ways},
+		{"relations", osmCache.Relations},
+	}
+}
+
+func diffStores(diffCache *cache.DiffCache) []namedStore {
+	if diffCache == nil {
+		return nil
+	}
+	return []namedStore{
+		{"coords_index", diffCache.Coords},
+		{"coords_rel_index", diffCache.CoordsRel},
+		{"ways_index", diffCache.Ways},
+	}
+}
+
+// collectStats counts the entries of all sub-caches. diffCache is optional.
+func collectStats(dir string, osmCache *cache.OSMCache, diffCache *cache.DiffCache) ([]storeStats, error) {
+	var result []storeStats
+	for _, s := range append(osmStores(osmCache), diffStores(diffCache)...) {
+		n, err := s.c.Count()
+		if err != nil {
+			return nil, fmt.Errorf("counting %s: %w", s.name, err)
+		}
+		size, err := diskUsage(filepath.Join(dir, s.name))
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, storeStats{Name: s.name, Entries: n, Size: size})
+	}
+	return result, nil
+}
+
+func printStats(w io.Writer, dir string, osmCache *cache.OSMCache, diffCache *cache.DiffCache) error {
+	stats, err := collectStats(dir, osmCache, diffCache)
+	if err != nil {
+		return err
+	}
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "cache\tentries\tsize\t")
+	var total int64
+	for _, s := range stats {
+		fmt.Fprintf(tw, "%s\t%d\t%s\t\n", s.Name, s.Entries, formatSize(s.Size))
+		total += s.Size
+	}
+	fmt.Fprintf(tw, "total\t\t%s\t\n", formatSize(total))
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	if m, err := cache.ReadManifest(dir); err != nil {
+		return err
+	} else if m != nil {
+		fmt.Fprintf(w, "cache format %d, created %s by imposm %s\n", m.Format, m.Created.Format("2006-01-02 15:04:05"), m.Version)
+	} else {
+		fmt.Fprintln(w, "cache format 1, created before cache versioning")
+	}
+	if osmCache.TagDict != nil {
+		fmt.Fprintf(w, "tag dictionary with %d tags and %d keys\n", osmCache.TagDict.NumTags(), osmCache.TagDict.NumKeys())
+	}
+	if diffCache == nil {
+		fmt.Fprintln(w, "no diff cache found")
+	}
+	return nil
+}
+
+// cacheUsage returns the disk usage of all sub-caches.
+func cacheUsage(dir string) (int64, error) {
+	var total int64
+	for _, name := range []string{"coords", "coords.flat", "nodes", "ways", "relations",
+		"coords_index", "coords_rel_index", "ways_index"} {
+		size, err := diskUsage(filepath.Join(dir, name))
+		if err != nil {
+			return 0, err
+		}
+		total += size
+	}
+	return total, nil
+}
+
+// diskUsage returns the allocated size of the file or of all files in the
+// directory. Returns 0 if path does not exist.
+func diskUsage(path string) (int64, error) {
+	var total int64
+	err := filepath.Walk(path, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && p == path {
+				return nil
+			}
+			return err
+		}
+		if fi.Mode().IsRegular() {
+			total += allocatedSize(fi)
+		}
+		return nil
+	})
+	return total, err
+}
+
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit && exp < 4; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTP"[exp])
+}
@@ -0,0 +1,140 @@
+package admin
+
+import (
+	"fmt"
+	"io"
+
+	osm "github.com/omniscale/go-osm"
+	"github.com/omniscale/imposm3/cache"
+	"github.com/omniscale/imposm3/element"
+)
+
+// danglingRef is an entry of a diff cache index that does not match the
+// OSM cache.
+type danglingRef struct {
+	Index  string
+	ID     int64 // ID of the node or way
+	Ref    int64 // ID of the referencing way or relation
+	Reason string
+}
+
+func (d danglingRef) String() string {
+	return fmt.Sprintf("%s: %d -> %d %s", d.Index, d.ID, d.Ref, d.Reason)
+}
+
+type verifyResult struct {
+	// number of checked refs for each index
+	Checked map[string]int64
+	// number of dangling refs for each index
+	Dangling map[string]int64
+}
+
+func (r verifyResult) total() int64 {
+	var n int64
+	for _, d := range r.Dangling {
+		n += d
+	}
+	return n
+}
+
+// verify checks that all ways and relations referenced by the diff cache
+// indexes exist and that they contain the referencing node or way. It calls
+// report for each dangling ref.
+func verify(osmCache *cache.OSMCache, diffCache *cache.DiffCache, report func(danglingRef)) (verifyResult, error) {
+	result := verifyResult{
+		Checked:  make(map[string]int64),
+		Dangling: make(map[string]int64),
+	}
+	dangling := func(d danglingRef) {
+		result.Dangling[d.Index]++
+		report(d)
+	}
+
+	wayHasNode := func(wayID, nodeID int64) (string, error) {
+		way, err := osmCache.Ways.GetWay(wayID)
+		if err == cache.NotFound {
+			return "way not found", nil
+		} else if err != nil {
+			return "", err
+		}
+		for _, ref := range way.Refs {
+			if ref == nodeID {
+				return "", nil
+			}
+		}
+		return "node not in way", nil
+	}
+	relHasMember := func(relID, memberID int64, typ osm.MemberType) (string, error) {
+		rel, err := osmCache.Relations.GetRelation(relID)
+		if err == cache.NotFound {
+			return "relation not found", nil
+		} else if err != nil {
+			return "", err
+		}
+		for _, m := range rel.Members {
+			if m.ID == memberID && m.Type == typ {
+				return "", nil
+			}
+		}
+		return "not a member of relation", nil
+	}
+
+	checks := []struct {
+		name  string
+		iter  func() chan element.IDRefs
+		check func(id, ref int64) (string, error)
+	}{
+		{"coords_index", diffCache.Coords.Iter, func(id, ref int64) (string, error) {
+			return wayHasNode(ref, id)
+		}},
+		{"coords_rel_index", diffCache.CoordsRel.Iter, func(id, ref int64) (string, error) {
+			return relHasMember(ref, id, osm.NodeMember)
+		}},
+		{"ways_index", diffCache.Ways.Iter, func(id, ref int64) (string, error) {
+			return relHasMember(ref, id, osm.WayMember)
+		}},
+	}
+
+	for _, c := range checks {
+		idRefs := c.iter()
+		for idRef := range idRefs {
+			for _, ref := range idRef.Refs {
+				result.Checked[c.name]++
+				reason, err := c.check(idRef.ID, ref)
+				if err != nil {
+					// drain iterator before returning
+					for range idRefs {
+					}
+					return result, err
+				}
+				if reason != "" {
+					dangling(danglingRef{Index: c.name, ID: idRef.ID, Ref: ref, Reason: reason})
+				}
+			}
+		}
+	}
+	return result, nil
+}
+
+func runVerify(w io.Writer, osmCache *cache.OSMCache, diffCache *cache.DiffCache, limit int) error {
+	if diffCache == nil {
+		return fmt.Errorf("no diff cache found, import with -diff to create the diff cache")
+	}
+	listed := 0
+	result, err := verify(osmCache, diffCache, func(d danglingRef) {
+		if listed < limit {
+			fmt.Fprintln(w, d)
+		}
+		listed++
+	})
+	if err != nil {
+		return err
+	}
+	for _, name := range []string{"coords_index", "coords_rel_index", "ways_index"} {
+		fmt.Fprintf(w, "%s: %d refs checked, %d dangling\n", name, result.Checked[name], result.Dangling[name])
+	}
+	if n := result.total(); n > 0 {
+		return fmt.Errorf("found %d dangling refs", n)
+	}
+	return nil
+}
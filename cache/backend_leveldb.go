@@ -0,0 +1,7 @@
+//go:build cgo && !noleveldb
+
+package cache
+
+import (
+	_ "github.com/omniscale/imposm3/cache/kv/leveldb"
+)
@@ -0,0 +1,6 @@
+package cache
+
+import (
+	// register kv stores
+	_ "github.com/omniscale/imposm3/cache/kv/logstore"
+)
@@ -1,25 +1,16 @@
 // Code generated by protoc-gen-gogo. DO NOT EDIT.
 // source: cache/binary/messages.proto
 
-/*
-	Package binary is a generated protocol buffer package.
-
-	It is generated from these files:
-		cache/binary/messages.proto
-
-	It has these top-level messages:
-		Node
-		Way
-		Relation
-		DeltaCoords
-*/
 package binary
 
-import proto "github.com/gogo/protobuf/proto"
-import fmt "fmt"
-import math "math"
-
-import io "io"
+import (
+	fmt "fmt"
+	github_com_gogo_protobuf_proto "github.com/gogo/protobuf/proto"
+	proto "github.com/gogo/protobuf/proto"
+	io "io"
+	math "math"
+	math_bits "math/bits"
+)
 
 // Reference imports to suppress errors if they are not otherwise used.
 var _ = proto.Marshal
@@ -30,25 +21,26 @@ var _ = math.Inf
 // is compatible with the proto package it is being compiled against.
 // A compilation error at this line likely means your copy of the
 // proto package needs to be updated.
-const _ = proto.GoGoProtoPackageIsVersion2 // please upgrade the proto package
+const _ = proto.GoGoProtoPackageIsVersion3 // please upgrade the proto package
 
 type Relation_MemberType int32
 
 const (
-	Relation_NodeMember     Relation_MemberType = 0
-	Relation_WayMember      Relation_MemberType = 1
-	Relation_RelationMember Relation_MemberType = 2
+	Relation_NODE     Relation_MemberType = 0
+	Relation_WAY      Relation_MemberType = 1
+	Relation_RELATION Relation_MemberType = 2
 )
 
 var Relation_MemberType_name = map[int32]string{
-	0: "NodeMember",
-	1: "WayMember",
-	2: "RelationMember",
+	0: "NODE",
+	1: "WAY",
+	2: "RELATION",
 }
+
 var Relation_MemberType_value = map[string]int32{
-	"NodeMember":     0,
-	"WayMember":      1,
-	"RelationMember": 2,
+	"NODE":     0,
+	"WAY":      1,
+	"RELATION": 2,
 }
 
 func (x Relation_MemberType) Enum() *Relation_MemberType {
@@ -56,9 +48,11 @@ func (x Relation_MemberType) Enum() *Relation_MemberType {
 	*p = x
 	return p
 }
+
 func (x Relation_MemberType) String() string {
 	return proto.EnumName(Relation_MemberType_name, int32(x))
 }
+
 func (x *Relation_MemberType) UnmarshalJSON(data []byte) error {
 	value, err := proto.UnmarshalJSONEnum(Relation_MemberType_value, data, "Relation_MemberType")
 	if err != nil {
@@ -67,20 +61,50 @@ func (x *Relation_MemberType) UnmarshalJSON(data []byte) error {
 	*x = Relation_MemberType(value)
 	return nil
 }
+
 func (Relation_MemberType) EnumDescriptor() ([]byte, []int) {
-	return fileDescriptorMessages, []int{2, 0}
+	return fileDescriptor_39c60bd67071a5c2, []int{2, 0}
 }
 
 type Node struct {
-	Long uint32   `protobuf:"varint,1,req,name=long" json:"long"`
-	Lat  uint32   `protobuf:"varint,2,req,name=lat" json:"lat"`
-	Tags []string `protobuf:"bytes,3,rep,name=tags" json:"tags,omitempty"`
+	Long     uint32    `protobuf:"varint,1,req,name=long" json:"long"`
+	Lat      uint32    `protobuf:"varint,2,req,name=lat" json:"lat"`
+	Tags     []string  `protobuf:"bytes,3,rep,name=tags" json:"tags,omitempty"`
+	Metadata *Metadata `protobuf:"bytes,4,opt,name=metadata" json:"metadata,omitempty"`
+}
+
+func (m *Node) Reset()         { *m = Node{} }
+func (m *Node) String() string { return proto.CompactTextString(m) }
+func (*Node) ProtoMessage()    {}
+func (*Node) Descriptor() ([]byte, []int) {
+	return fileDescriptor_39c60bd67071a5c2, []int{0}
+}
+func (m *Node) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *Node) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_Node.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *Node) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Node.Merge(m, src)
+}
+func (m *Node) XXX_Size() int {
+	return m.Size()
+}
+func (m *Node) XXX_DiscardUnknown() {
+	xxx_messageInfo_Node.DiscardUnknown(m)
 }
 
-func (m *Node) Reset()                    { *m = Node{} }
-func (m *Node) String() string            { return proto.CompactTextString(m) }
-func (*Node) ProtoMessage()               {}
-func (*Node) Descriptor() ([]byte, []int) { return fileDescriptorMessages, []int{0} }
+var xxx_messageInfo_Node proto.InternalMessageInfo
 
 func (m *Node) GetLong() uint32 {
 	if m != nil {
@@ -103,15 +127,53 @@ func (m *Node) GetTags() []string {
 	return nil
 }
 
+func (m *Node) GetMetadata() *Metadata {
+	if m != nil {
+		return m.Metadata
+	}
+	return nil
+}
+
 type Way struct {
-	Tags []string `protobuf:"bytes,1,rep,name=tags" json:"tags,omitempty"`
-	Refs []int64  `protobuf:"varint,2,rep,packed,name=refs" json:"refs,omitempty"`
+	Tags     []string  `protobuf:"bytes,1,rep,name=tags" json:"tags,omitempty"`
+	Refs     []int64   `protobuf:"varint,2,rep,packed,name=refs" json:"refs,omitempty"`
+	Lats     []int64   `protobuf:"zigzag64,3,rep,packed,name=lats" json:"lats,omitempty"`
+	Lons     []int64   `protobuf:"zigzag64,4,rep,packed,name=lons" json:"lons,omitempty"`
+	Metadata *Metadata `protobuf:"bytes,5,opt,name=metadata" json:"metadata,omitempty"`
+}
+
+func (m *Way) Reset()         { *m = Way{} }
+func (m *Way) String() string { return proto.CompactTextString(m) }
+func (*Way) ProtoMessage()    {}
+func (*Way) Descriptor() ([]byte, []int) {
+	return fileDescriptor_39c60bd67071a5c2, []int{1}
+}
+func (m *Way) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *Way) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_Way.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *Way) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Way.Merge(m, src)
+}
+func (m *Way) XXX_Size() int {
+	return m.Size()
+}
+func (m *Way) XXX_DiscardUnknown() {
+	xxx_messageInfo_Way.DiscardUnknown(m)
 }
 
-func (m *Way) Reset()                    { *m = Way{} }
-func (m *Way) String() string            { return proto.CompactTextString(m) }
-func (*Way) ProtoMessage()               {}
-func (*Way) Descriptor() ([]byte, []int) { return fileDescriptorMessages, []int{1} }
+var xxx_messageInfo_Way proto.InternalMessageInfo
 
 func (m *Way) GetTags() []string {
 	if m != nil {
@@ -127,17 +189,67 @@ func (m *Way) GetRefs() []int64 {
 	return nil
 }
 
+func (m *Way) GetLats() []int64 {
+	if m != nil {
+		return m.Lats
+	}
+	return nil
+}
+
+func (m *Way) GetLons() []int64 {
+	if m != nil {
+		return m.Lons
+	}
+	return nil
+}
+
+func (m *Way) GetMetadata() *Metadata {
+	if m != nil {
+		return m.Metadata
+	}
+	return nil
+}
+
 type Relation struct {
 	Tags        []string              `protobuf:"bytes,1,rep,name=tags" json:"tags,omitempty"`
 	MemberIds   []int64               `protobuf:"varint,2,rep,name=member_ids,json=memberIds" json:"member_ids,omitempty"`
 	MemberTypes []Relation_MemberType `protobuf:"varint,3,rep,name=member_types,json=memberTypes,enum=binary.Relation_MemberType" json:"member_types,omitempty"`
 	MemberRoles []string              `protobuf:"bytes,4,rep,name=member_roles,json=memberRoles" json:"member_roles,omitempty"`
+	Metadata    *Metadata             `protobuf:"bytes,5,opt,name=metadata" json:"metadata,omitempty"`
+}
+
+func (m *Relation) Reset()         { *m = Relation{} }
+func (m *Relation) String() string { return proto.CompactTextString(m) }
+func (*Relation) ProtoMessage()    {}
+func (*Relation) Descriptor() ([]byte, []int) {
+	return fileDescriptor_39c60bd67071a5c2, []int{2}
+}
+func (m *Relation) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *Relation) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_Relation.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *Relation) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Relation.Merge(m, src)
+}
+func (m *Relation) XXX_Size() int {
+	return m.Size()
+}
+func (m *Relation) XXX_DiscardUnknown() {
+	xxx_messageInfo_Relation.DiscardUnknown(m)
 }
 
-func (m *Relation) Reset()                    { *m = Relation{} }
-func (m *Relation) String() string            { return proto.CompactTextString(m) }
-func (*Relation) ProtoMessage()               {}
-func (*Relation) Descriptor() ([]byte, []int) { return fileDescriptorMessages, []int{2} }
+var xxx_messageInfo_Relation proto.InternalMessageInfo
 
 func (m *Relation) GetTags() []string {
 	if m != nil {
@@ -167,16 +279,51 @@ func (m *Relation) GetMemberRoles() []string {
 	return nil
 }
 
+func (m *Relation) GetMetadata() *Metadata {
+	if m != nil {
+		return m.Metadata
+	}
+	return nil
+}
+
 type DeltaCoords struct {
 	Ids  []int64 `protobuf:"zigzag64,1,rep,packed,name=ids" json:"ids,omitempty"`
 	Lats []int64 `protobuf:"zigzag64,2,rep,packed,name=lats" json:"lats,omitempty"`
 	Lons []int64 `protobuf:"zigzag64,3,rep,packed,name=lons" json:"lons,omitempty"`
 }
 
-func (m *DeltaCoords) Reset()                    { *m = DeltaCoords{} }
-func (m *DeltaCoords) String() string            { return proto.CompactTextString(m) }
-func (*DeltaCoords) ProtoMessage()               {}
-func (*DeltaCoords) Descriptor() ([]byte, []int) { return fileDescriptorMessages, []int{3} }
+func (m *DeltaCoords) Reset()         { *m = DeltaCoords{} }
+func (m *DeltaCoords) String() string { return proto.CompactTextString(m) }
+func (*DeltaCoords) ProtoMessage()    {}
+func (*DeltaCoords) Descriptor() ([]byte, []int) {
+	return fileDescriptor_39c60bd67071a5c2, []int{3}
+}
+func (m *DeltaCoords) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *DeltaCoords) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_DeltaCoords.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *DeltaCoords) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_DeltaCoords.Merge(m, src)
+}
+func (m *DeltaCoords) XXX_Size() int {
+	return m.Size()
+}
+func (m *DeltaCoords) XXX_DiscardUnknown() {
+	xxx_messageInfo_DeltaCoords.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_DeltaCoords proto.InternalMessageInfo
 
 func (m *DeltaCoords) GetIds() []int64 {
 	if m != nil {
@@ -199,17 +346,131 @@ func (m *DeltaCoords) GetLons() []int64 {
 	return nil
 }
 
+type Metadata struct {
+	Version   int32  `protobuf:"varint,1,opt,name=version" json:"version"`
+	Timestamp int64  `protobuf:"varint,2,opt,name=timestamp" json:"timestamp"`
+	Changeset int64  `protobuf:"varint,3,opt,name=changeset" json:"changeset"`
+	Uid       int32  `protobuf:"varint,4,opt,name=uid" json:"uid"`
+	User      string `protobuf:"bytes,5,opt,name=user" json:"user"`
+}
+
+func (m *Metadata) Reset()         { *m = Metadata{} }
+func (m *Metadata) String() string { return proto.CompactTextString(m) }
+func (*Metadata) ProtoMessage()    {}
+func (*Metadata) Descriptor() ([]byte, []int) {
+	return fileDescriptor_39c60bd67071a5c2, []int{4}
+}
+func (m *Metadata) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *Metadata) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_Metadata.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *Metadata) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Metadata.Merge(m, src)
+}
+func (m *Metadata) XXX_Size() int {
+	return m.Size()
+}
+func (m *Metadata) XXX_DiscardUnknown() {
+	xxx_messageInfo_Metadata.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Metadata proto.InternalMessageInfo
+
+func (m *Metadata) GetVersion() int32 {
+	if m != nil {
+		return m.Version
+	}
+	return 0
+}
+
+func (m *Metadata) GetTimestamp() int64 {
+	if m != nil {
+		return m.Timestamp
+	}
+	return 0
+}
+
+func (m *Metadata) GetChangeset() int64 {
+	if m != nil {
+		return m.Changeset
+	}
+	return 0
+}
+
+func (m *Metadata) GetUid() int32 {
+	if m != nil {
+		return m.Uid
+	}
+	return 0
+}
+
+func (m *Metadata) GetUser() string {
+	if m != nil {
+		return m.User
+	}
+	return ""
+}
+
 func init() {
+	proto.RegisterEnum("binary.Relation_MemberType", Relation_MemberType_name, Relation_MemberType_value)
 	proto.RegisterType((*Node)(nil), "binary.Node")
 	proto.RegisterType((*Way)(nil), "binary.Way")
 	proto.RegisterType((*Relation)(nil), "binary.Relation")
 	proto.RegisterType((*DeltaCoords)(nil), "binary.DeltaCoords")
-	proto.RegisterEnum("binary.Relation_MemberType", Relation_MemberType_name, Relation_MemberType_value)
+	proto.RegisterType((*Metadata)(nil), "binary.Metadata")
 }
+
+func init() { proto.RegisterFile("cache/binary/messages.proto", fileDescriptor_39c60bd67071a5c2) }
+
+var fileDescriptor_39c60bd67071a5c2 = []byte{
+	// 465 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x94, 0x93, 0x41, 0x6b, 0xd4, 0x40,
+	0x14, 0xc7, 0x33, 0x99, 0xb4, 0x4d, 0xde, 0x56, 0x09, 0x83, 0x94, 0x81, 0x62, 0x8c, 0x39, 0xe5,
+	0xa0, 0x5b, 0xd8, 0x0f, 0x20, 0x74, 0x6d, 0x0f, 0x05, 0xbb, 0x85, 0x58, 0x28, 0x9e, 0x64, 0x76,
+	0xf3, 0xdc, 0x06, 0x92, 0xcc, 0x92, 0x99, 0x0a, 0xeb, 0x97, 0xd0, 0x0f, 0xe1, 0x87, 0xe9, 0xb1,
+	0x47, 0x4f, 0x22, 0xbb, 0x9f, 0x43, 0x90, 0x64, 0x92, 0x34, 0x05, 0x45, 0x7a, 0x9b, 0xf9, 0xbd,
+	0x7f, 0xde, 0xfb, 0xcf, 0x7b, 0x2f, 0x70, 0xb8, 0x10, 0x8b, 0x6b, 0x3c, 0x9a, 0x67, 0xa5, 0xa8,
+	0xd6, 0x47, 0x05, 0x2a, 0x25, 0x96, 0xa8, 0xc6, 0xab, 0x4a, 0x6a, 0xc9, 0x76, 0x0d, 0x8e, 0xbe,
+	0x80, 0x33, 0x93, 0x29, 0x32, 0x0e, 0x4e, 0x2e, 0xcb, 0x25, 0x27, 0xa1, 0x1d, 0x3f, 0x99, 0x3a,
+	0xb7, 0x3f, 0x5f, 0x58, 0x49, 0x43, 0xd8, 0x01, 0xd0, 0x5c, 0x68, 0x6e, 0x0f, 0x02, 0x35, 0x60,
+	0x0c, 0x1c, 0x2d, 0x96, 0x8a, 0xd3, 0x90, 0xc6, 0x5e, 0xd2, 0x9c, 0xd9, 0x2b, 0x70, 0x0b, 0xd4,
+	0x22, 0x15, 0x5a, 0x70, 0x27, 0x24, 0xf1, 0x68, 0xe2, 0x8f, 0x4d, 0xa1, 0xf1, 0x79, 0xcb, 0x93,
+	0x5e, 0x11, 0x7d, 0x25, 0x40, 0xaf, 0xc4, 0xba, 0xcf, 0x44, 0x06, 0x99, 0x0e, 0xc0, 0xa9, 0xf0,
+	0x93, 0xe2, 0x76, 0x48, 0x63, 0x3a, 0xb5, 0x7d, 0x92, 0x34, 0xf7, 0x9a, 0xe7, 0x42, 0x9b, 0xaa,
+	0xcc, 0xf0, 0xfa, 0xde, 0x70, 0x59, 0x2a, 0xee, 0x0c, 0xb8, 0x2c, 0x1f, 0x3a, 0xda, 0xf9, 0xaf,
+	0xa3, 0xdf, 0x04, 0xdc, 0x04, 0x73, 0xa1, 0x33, 0x59, 0xfe, 0xd5, 0xd6, 0x73, 0x80, 0x02, 0x8b,
+	0x39, 0x56, 0x1f, 0xb3, 0xb4, 0x35, 0x97, 0x78, 0x86, 0x9c, 0xa5, 0x8a, 0xbd, 0x81, 0xfd, 0x36,
+	0xac, 0xd7, 0x2b, 0x34, 0x2e, 0x9f, 0x4e, 0x0e, 0xbb, 0x8a, 0x5d, 0xea, 0xf1, 0x79, 0x23, 0xba,
+	0x5c, 0xaf, 0x30, 0x19, 0x15, 0xfd, 0x59, 0xb1, 0x97, 0xfd, 0xf7, 0x95, 0xcc, 0xd1, 0xbc, 0xc6,
+	0xeb, 0x24, 0x49, 0x8d, 0x1e, 0xf9, 0xa0, 0xd7, 0x00, 0xf7, 0xb5, 0x98, 0x0b, 0xce, 0xec, 0xe2,
+	0xe4, 0xd4, 0xb7, 0xd8, 0x1e, 0xd0, 0xab, 0xe3, 0x0f, 0x3e, 0x61, 0xfb, 0xe0, 0x26, 0xa7, 0xef,
+	0x8e, 0x2f, 0xcf, 0x2e, 0x66, 0xbe, 0x1d, 0xbd, 0x87, 0xd1, 0x09, 0xe6, 0x5a, 0xbc, 0x95, 0xb2,
+	0x4a, 0x15, 0x7b, 0x06, 0x34, 0x4b, 0x4d, 0x03, 0x4c, 0x4f, 0xeb, 0x6b, 0x3f, 0x02, 0xfb, 0x1f,
+	0x23, 0xa0, 0x0f, 0x47, 0x10, 0x7d, 0x27, 0xe0, 0x76, 0xd6, 0x58, 0x00, 0x7b, 0x9f, 0xb1, 0x52,
+	0x99, 0x2c, 0x39, 0x09, 0x49, 0xbc, 0xd3, 0x6e, 0x54, 0x07, 0x59, 0x04, 0x9e, 0xce, 0x0a, 0x54,
+	0x5a, 0x14, 0x2b, 0x6e, 0x87, 0x24, 0xa6, 0xad, 0xe2, 0x1e, 0xd7, 0x9a, 0xc5, 0xb5, 0x28, 0x97,
+	0xa8, 0x50, 0x73, 0x3a, 0xd4, 0xf4, 0xb8, 0xde, 0xda, 0x9b, 0x2c, 0xe5, 0xce, 0xa0, 0x46, 0x0d,
+	0xea, 0x3d, 0xbf, 0x51, 0x58, 0x35, 0xad, 0xf3, 0xda, 0x40, 0x43, 0xa6, 0xfc, 0x76, 0x13, 0x90,
+	0xbb, 0x4d, 0x40, 0x7e, 0x6d, 0x02, 0xf2, 0x6d, 0x1b, 0x58, 0x77, 0xdb, 0xc0, 0xfa, 0xb1, 0x0d,
+	0xac, 0xf9, 0x6e, 0xf3, 0xcb, 0x4c, 0xfe, 0x0c, 0x00, 0x69, 0x6c, 0x58, 0xa4, 0x51, 0x03, 0x00,
+	0x00,
+}
+
 func (m *Node) Marshal() (dAtA []byte, err error) {
 	size := m.Size()
 	dAtA = make([]byte, size)
-	n, err := m.MarshalTo(dAtA)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
 	if err != nil {
 		return nil, err
 	}
@@ -217,38 +478,49 @@ func (m *Node) Marshal() (dAtA []byte, err error) {
 }
 
 func (m *Node) MarshalTo(dAtA []byte) (int, error) {
-	var i int
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *Node) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
 	_ = i
 	var l int
 	_ = l
-	dAtA[i] = 0x8
-	i++
-	i = encodeVarintMessages(dAtA, i, uint64(m.Long))
-	dAtA[i] = 0x10
-	i++
-	i = encodeVarintMessages(dAtA, i, uint64(m.Lat))
+	if m.Metadata != nil {
+		{
+			size, err := m.Metadata.MarshalToSizedBuffer(dAtA[:i])
+			if err != nil {
+				return 0, err
+			}
+			i -= size
+			i = encodeVarintMessages(dAtA, i, uint64(size))
+		}
+		i--
+		dAtA[i] = 0x22
+	}
 	if len(m.Tags) > 0 {
-		for _, s := range m.Tags {
+		for iNdEx := len(m.Tags) - 1; iNdEx >= 0; iNdEx-- {
+			i -= len(m.Tags[iNdEx])
+			copy(dAtA[i:], m.Tags[iNdEx])
+			i = encodeVarintMessages(dAtA, i, uint64(len(m.Tags[iNdEx])))
+			i--
 			dAtA[i] = 0x1a
-			i++
-			l = len(s)
-			for l >= 1<<7 {
-				dAtA[i] = uint8(uint64(l)&0x7f | 0x80)
-				l >>= 7
-				i++
-			}
-			dAtA[i] = uint8(l)
-			i++
-			i += copy(dAtA[i:], s)
 		}
 	}
-	return i, nil
+	i = encodeVarintMessages(dAtA, i, uint64(m.Lat))
+	i--
+	dAtA[i] = 0x10
+	i = encodeVarintMessages(dAtA, i, uint64(m.Long))
+	i--
+	dAtA[i] = 0x8
+	return len(dAtA) - i, nil
 }
 
 func (m *Way) Marshal() (dAtA []byte, err error) {
 	size := m.Size()
 	dAtA = make([]byte, size)
-	n, err := m.MarshalTo(dAtA)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
 	if err != nil {
 		return nil, err
 	}
@@ -256,50 +528,100 @@ func (m *Way) Marshal() (dAtA []byte, err error) {
 }
 
 func (m *Way) MarshalTo(dAtA []byte) (int, error) {
-	var i int
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *Way) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
 	_ = i
 	var l int
 	_ = l
-	if len(m.Tags) > 0 {
-		for _, s := range m.Tags {
-			dAtA[i] = 0xa
-			i++
-			l = len(s)
-			for l >= 1<<7 {
-				dAtA[i] = uint8(uint64(l)&0x7f | 0x80)
-				l >>= 7
-				i++
-			}
-			dAtA[i] = uint8(l)
-			i++
-			i += copy(dAtA[i:], s)
+	if m.Metadata != nil {
+		{
+			size, err := m.Metadata.MarshalToSizedBuffer(dAtA[:i])
+			if err != nil {
+				return 0, err
+			}
+			i -= size
+			i = encodeVarintMessages(dAtA, i, uint64(size))
 		}
+		i--
+		dAtA[i] = 0x2a
+	}
+	if len(m.Lons) > 0 {
+		var j3 int
+		dAtA5 := make([]byte, len(m.Lons)*10)
+		for _, num := range m.Lons {
+			x4 := (uint64(num) << 1) ^ uint64((num >> 63))
+			for x4 >= 1<<7 {
+				dAtA5[j3] = uint8(uint64(x4)&0x7f | 0x80)
+				j3++
+				x4 >>= 7
+			}
+			dAtA5[j3] = uint8(x4)
+			j3++
+		}
+		i -= j3
+		copy(dAtA[i:], dAtA5[:j3])
+		i = encodeVarintMessages(dAtA, i, uint64(j3))
+		i--
+		dAtA[i] = 0x22
+	}
+	if len(m.Lats) > 0 {
+		var j6 int
+		dAtA8 := make([]byte, len(m.Lats)*10)
+		for _, num := range m.Lats {
+			x7 := (uint64(num) << 1) ^ uint64((num >> 63))
+			for x7 >= 1<<7 {
+				dAtA8[j6] = uint8(uint64(x7)&0x7f | 0x80)
+				j6++
+				x7 >>= 7
+			}
+			dAtA8[j6] = uint8(x7)
+			j6++
+		}
+		i -= j6
+		copy(dAtA[i:], dAtA8[:j6])
+		i = encodeVarintMessages(dAtA, i, uint64(j6))
+		i--
+		dAtA[i] = 0x1a
 	}
 	if len(m.Refs) > 0 {
-		dAtA2 := make([]byte, len(m.Refs)*10)
-		var j1 int
+		dAtA10 := make([]byte, len(m.Refs)*10)
+		var j9 int
 		for _, num1 := range m.Refs {
 			num := uint64(num1)
 			for num >= 1<<7 {
-				dAtA2[j1] = uint8(uint64(num)&0x7f | 0x80)
+				dAtA10[j9] = uint8(uint64(num)&0x7f | 0x80)
 				num >>= 7
-				j1++
+				j9++
 			}
-			dAtA2[j1] = uint8(num)
-			j1++
+			dAtA10[j9] = uint8(num)
+			j9++
 		}
+		i -= j9
+		copy(dAtA[i:], dAtA10[:j9])
+		i = encodeVarintMessages(dAtA, i, uint64(j9))
+		i--
 		dAtA[i] = 0x12
-		i++
-		i = encodeVarintMessages(dAtA, i, uint64(j1))
-		i += copy(dAtA[i:], dAtA2[:j1])
 	}
-	return i, nil
+	if len(m.Tags) > 0 {
+		for iNdEx := len(m.Tags) - 1; iNdEx >= 0; iNdEx-- {
+			i -= len(m.Tags[iNdEx])
+			copy(dAtA[i:], m.Tags[iNdEx])
+			i = encodeVarintMessages(dAtA, i, uint64(len(m.Tags[iNdEx])))
+			i--
+			dAtA[i] = 0xa
+		}
+	}
+	return len(dAtA) - i, nil
 }
 
 func (m *Relation) Marshal() (dAtA []byte, err error) {
 	size := m.Size()
 	dAtA = make([]byte, size)
-	n, err := m.MarshalTo(dAtA)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
 	if err != nil {
 		return nil, err
 	}
@@ -307,61 +629,66 @@ func (m *Relation) Marshal() (dAtA []byte, err error) {
 }
 
 func (m *Relation) MarshalTo(dAtA []byte) (int, error) {
-	var i int
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *Relation) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
 	_ = i
 	var l int
 	_ = l
-	if len(m.Tags) > 0 {
-		for _, s := range m.Tags {
-			dAtA[i] = 0xa
-			i++
-			l = len(s)
-			for l >= 1<<7 {
-				dAtA[i] = uint8(uint64(l)&0x7f | 0x80)
-				l >>= 7
-				i++
-			}
-			dAtA[i] = uint8(l)
-			i++
-			i += copy(dAtA[i:], s)
+	if m.Metadata != nil {
+		{
+			size, err := m.Metadata.MarshalToSizedBuffer(dAtA[:i])
+			if err != nil {
+				return 0, err
+			}
+			i -= size
+			i = encodeVarintMessages(dAtA, i, uint64(size))
 		}
+		i--
+		dAtA[i] = 0x2a
 	}
-	if len(m.MemberIds) > 0 {
-		for _, num := range m.MemberIds {
-			dAtA[i] = 0x10
-			i++
-			i = encodeVarintMessages(dAtA, i, uint64(num))
+	if len(m.MemberRoles) > 0 {
+		for iNdEx := len(m.MemberRoles) - 1; iNdEx >= 0; iNdEx-- {
+			i -= len(m.MemberRoles[iNdEx])
+			copy(dAtA[i:], m.MemberRoles[iNdEx])
+			i = encodeVarintMessages(dAtA, i, uint64(len(m.MemberRoles[iNdEx])))
+			i--
+			dAtA[i] = 0x22
 		}
 	}
 	if len(m.MemberTypes) > 0 {
-		for _, num := range m.MemberTypes {
+		for iNdEx := len(m.MemberTypes) - 1; iNdEx >= 0; iNdEx-- {
+			i = encodeVarintMessages(dAtA, i, uint64(m.MemberTypes[iNdEx]))
+			i--
 			dAtA[i] = 0x18
-			i++
-			i = encodeVarintMessages(dAtA, i, uint64(num))
 		}
 	}
-	if len(m.MemberRoles) > 0 {
-		for _, s := range m.MemberRoles {
-			dAtA[i] = 0x22
-			i++
-			l = len(s)
-			for l >= 1<<7 {
-				dAtA[i] = uint8(uint64(l)&0x7f | 0x80)
-				l >>= 7
-				i++
-			}
-			dAtA[i] = uint8(l)
-			i++
-			i += copy(dAtA[i:], s)
+	if len(m.MemberIds) > 0 {
+		for iNdEx := len(m.MemberIds) - 1; iNdEx >= 0; iNdEx-- {
+			i = encodeVarintMessages(dAtA, i, uint64(m.MemberIds[iNdEx]))
+			i--
+			dAtA[i] = 0x10
 		}
 	}
-	return i, nil
+	if len(m.Tags) > 0 {
+		for iNdEx := len(m.Tags) - 1; iNdEx >= 0; iNdEx-- {
+			i -= len(m.Tags[iNdEx])
+			copy(dAtA[i:], m.Tags[iNdEx])
+			i = encodeVarintMessages(dAtA, i, uint64(len(m.Tags[iNdEx])))
+			i--
+			dAtA[i] = 0xa
+		}
+	}
+	return len(dAtA) - i, nil
 }
 
 func (m *DeltaCoords) Marshal() (dAtA []byte, err error) {
 	size := m.Size()
 	dAtA = make([]byte, size)
-	n, err := m.MarshalTo(dAtA)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
 	if err != nil {
 		return nil, err
 	}
@@ -369,77 +696,130 @@ func (m *DeltaCoords) Marshal() (dAtA []byte, err error) {
 }
 
 func (m *DeltaCoords) MarshalTo(dAtA []byte) (int, error) {
-	var i int
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *DeltaCoords) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
 	_ = i
 	var l int
 	_ = l
-	if len(m.Ids) > 0 {
-		var j3 int
-		dAtA5 := make([]byte, len(m.Ids)*10)
-		for _, num := range m.Ids {
-			x4 := (uint64(num) << 1) ^ uint64((num >> 63))
-			for x4 >= 1<<7 {
-				dAtA5[j3] = uint8(uint64(x4)&0x7f | 0x80)
-				j3++
-				x4 >>= 7
-			}
-			dAtA5[j3] = uint8(x4)
-			j3++
+	if len(m.Lons) > 0 {
+		var j12 int
+		dAtA14 := make([]byte, len(m.Lons)*10)
+		for _, num := range m.Lons {
+			x13 := (uint64(num) << 1) ^ uint64((num >> 63))
+			for x13 >= 1<<7 {
+				dAtA14[j12] = uint8(uint64(x13)&0x7f | 0x80)
+				j12++
+				x13 >>= 7
+			}
+			dAtA14[j12] = uint8(x13)
+			j12++
 		}
-		dAtA[i] = 0xa
-		i++
-		i = encodeVarintMessages(dAtA, i, uint64(j3))
-		i += copy(dAtA[i:], dAtA5[:j3])
+		i -= j12
+		copy(dAtA[i:], dAtA14[:j12])
+		i = encodeVarintMessages(dAtA, i, uint64(j12))
+		i--
+		dAtA[i] = 0x1a
 	}
 	if len(m.Lats) > 0 {
-		var j6 int
-		dAtA8 := make([]byte, len(m.Lats)*10)
+		var j15 int
+		dAtA17 := make([]byte, len(m.Lats)*10)
 		for _, num := range m.Lats {
-			x7 := (uint64(num) << 1) ^ uint64((num >> 63))
-			for x7 >= 1<<7 {
-				dAtA8[j6] = uint8(uint64(x7)&0x7f | 0x80)
-				j6++
-				x7 >>= 7
-			}
-			dAtA8[j6] = uint8(x7)
-			j6++
+			x16 := (uint64(num) << 1) ^ uint64((num >> 63))
+			for x16 >= 1<<7 {
+				dAtA17[j15] = uint8(uint64(x16)&0x7f | 0x80)
+				j15++
+				x16 >>= 7
+			}
+			dAtA17[j15] = uint8(x16)
+			j15++
 		}
+		i -= j15
+		copy(dAtA[i:], dAtA17[:j15])
+		i = encodeVarintMessages(dAtA, i, uint64(j15))
+		i--
 		dAtA[i] = 0x12
-		i++
-		i = encodeVarintMessages(dAtA, i, uint64(j6))
-		i += copy(dAtA[i:], dAtA8[:j6])
 	}
-	if len(m.Lons) > 0 {
-		var j9 int
-		dAtA11 := make([]byte, len(m.Lons)*10)
-		for _, num := range m.Lons {
-			x10 := (uint64(num) << 1) ^ uint64((num >> 63))
-			for x10 >= 1<<7 {
-				dAtA11[j9] = uint8(uint64(x10)&0x7f | 0x80)
-				j9++
-				x10 >>= 7
-			}
-			dAtA11[j9] = uint8(x10)
-			j9++
+	if len(m.Ids) > 0 {
+		var j18 int
+		dAtA20 := make([]byte, len(m.Ids)*10)
+		for _, num := range m.Ids {
+			x19 := (uint64(num) << 1) ^ uint64((num >> 63))
+			for x19 >= 1<<7 {
+				dAtA20[j18] = uint8(uint64(x19)&0x7f | 0x80)
+				j18++
+				x19 >>= 7
+			}
+			dAtA20[j18] = uint8(x19)
+			j18++
 		}
-		dAtA[i] = 0x1a
-		i++
-		i = encodeVarintMessages(dAtA, i, uint64(j9))
-		i += copy(dAtA[i:], dAtA11[:j9])
+		i -= j18
+		copy(dAtA[i:], dAtA20[:j18])
+		i = encodeVarintMessages(dAtA, i, uint64(j18))
+		i--
+		dAtA[i] = 0xa
 	}
-	return i, nil
+	return len(dAtA) - i, nil
+}
+
+func (m *Metadata) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *Metadata) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *Metadata) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	i -= len(m.User)
+	copy(dAtA[i:], m.User)
+	i = encodeVarintMessages(dAtA, i, uint64(len(m.User)))
+	i--
+	dAtA[i] = 0x2a
+	i = encodeVarintMessages(dAtA, i, uint64(m.Uid))
+	i--
+	dAtA[i] = 0x20
+	i = encodeVarintMessages(dAtA, i, uint64(m.Changeset))
+	i--
+	dAtA[i] = 0x18
+	i = encodeVarintMessages(dAtA, i, uint64(m.Timestamp))
+	i--
+	dAtA[i] = 0x10
+	i = encodeVarintMessages(dAtA, i, uint64(m.Version))
+	i--
+	dAtA[i] = 0x8
+	return len(dAtA) - i, nil
 }
 
 func encodeVarintMessages(dAtA []byte, offset int, v uint64) int {
+	offset -= sovMessages(v)
+	base := offset
 	for v >= 1<<7 {
 		dAtA[offset] = uint8(v&0x7f | 0x80)
 		v >>= 7
 		offset++
 	}
 	dAtA[offset] = uint8(v)
-	return offset + 1
+	return base
 }
 func (m *Node) Size() (n int) {
+	if m == nil {
+		return 0
+	}
 	var l int
 	_ = l
 	n += 1 + sovMessages(uint64(m.Long))
@@ -450,10 +830,17 @@ func (m *Node) Size() (n int) {
 			n += 1 + l + sovMessages(uint64(l))
 		}
 	}
+	if m.Metadata != nil {
+		l = m.Metadata.Size()
+		n += 1 + l + sovMessages(uint64(l))
+	}
 	return n
 }
 
 func (m *Way) Size() (n int) {
+	if m == nil {
+		return 0
+	}
 	var l int
 	_ = l
 	if len(m.Tags) > 0 {
@@ -469,10 +856,31 @@ func (m *Way) Size() (n int) {
 		}
 		n += 1 + sovMessages(uint64(l)) + l
 	}
+	if len(m.Lats) > 0 {
+		l = 0
+		for _, e := range m.Lats {
+			l += sozMessages(uint64(e))
+		}
+		n += 1 + sovMessages(uint64(l)) + l
+	}
+	if len(m.Lons) > 0 {
+		l = 0
+		for _, e := range m.Lons {
+			l += sozMessages(uint64(e))
+		}
+		n += 1 + sovMessages(uint64(l)) + l
+	}
+	if m.Metadata != nil {
+		l = m.Metadata.Size()
+		n += 1 + l + sovMessages(uint64(l))
+	}
 	return n
 }
 
 func (m *Relation) Size() (n int) {
+	if m == nil {
+		return 0
+	}
 	var l int
 	_ = l
 	if len(m.Tags) > 0 {
@@ -497,10 +905,17 @@ func (m *Relation) Size() (n int) {
 			n += 1 + l + sovMessages(uint64(l))
 		}
 	}
+	if m.Metadata != nil {
+		l = m.Metadata.Size()
+		n += 1 + l + sovMessages(uint64(l))
+	}
 	return n
 }
 
 func (m *DeltaCoords) Size() (n int) {
+	if m == nil {
+		return 0
+	}
 	var l int
 	_ = l
 	if len(m.Ids) > 0 {
@@ -527,16 +942,24 @@ func (m *DeltaCoords) Size() (n int) {
 	return n
 }
 
-func sovMessages(x uint64) (n int) {
-	for {
-		n++
-		x >>= 7
-		if x == 0 {
-			break
-		}
+func (m *Metadata) Size() (n int) {
+	if m == nil {
+		return 0
 	}
+	var l int
+	_ = l
+	n += 1 + sovMessages(uint64(m.Version))
+	n += 1 + sovMessages(uint64(m.Timestamp))
+	n += 1 + sovMessages(uint64(m.Changeset))
+	n += 1 + sovMessages(uint64(m.Uid))
+	l = len(m.User)
+	n += 1 + l + sovMessages(uint64(l))
 	return n
 }
+
+func sovMessages(x uint64) (n int) {
+	return (math_bits.Len64(x|1) + 6) / 7
+}
 func sozMessages(x uint64) (n int) {
 	return sovMessages(uint64((x << 1) ^ uint64((int64(x) >> 63))))
 }
@@ -556,7 +979,7 @@ func (m *Node) Unmarshal(dAtA []byte) error {
 			}
 			b := dAtA[iNdEx]
 			iNdEx++
-			wire |= (uint64(b) & 0x7F) << shift
+			wire |= uint64(b&0x7F) << shift
 			if b < 0x80 {
 				break
 			}
@@ -584,7 +1007,7 @@ func (m *Node) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				m.Long |= (uint32(b) & 0x7F) << shift
+				m.Long |= uint32(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
@@ -604,7 +1027,7 @@ func (m *Node) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				m.Lat |= (uint32(b) & 0x7F) << shift
+				m.Lat |= uint32(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
@@ -624,7 +1047,7 @@ func (m *Node) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				stringLen |= (uint64(b) & 0x7F) << shift
+				stringLen |= uint64(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
@@ -634,18 +1057,57 @@ func (m *Node) Unmarshal(dAtA []byte) error {
 				return ErrInvalidLengthMessages
 			}
 			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthMessages
+			}
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
 			m.Tags = append(m.Tags, string(dAtA[iNdEx:postIndex]))
 			iNdEx = postIndex
+		case 4:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Metadata", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowMessages
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthMessages
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthMessages
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.Metadata == nil {
+				m.Metadata = &Metadata{}
+			}
+			if err := m.Metadata.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
 			skippy, err := skipMessages(dAtA[iNdEx:])
 			if err != nil {
 				return err
 			}
-			if skippy < 0 {
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
 				return ErrInvalidLengthMessages
 			}
 			if (iNdEx + skippy) > l {
@@ -655,10 +1117,10 @@ func (m *Node) Unmarshal(dAtA []byte) error {
 		}
 	}
 	if hasFields[0]&uint64(0x00000001) == 0 {
-		return proto.NewRequiredNotSetError("long")
+		return github_com_gogo_protobuf_proto.NewRequiredNotSetError("long")
 	}
 	if hasFields[0]&uint64(0x00000002) == 0 {
-		return proto.NewRequiredNotSetError("lat")
+		return github_com_gogo_protobuf_proto.NewRequiredNotSetError("lat")
 	}
 
 	if iNdEx > l {
@@ -681,7 +1143,7 @@ func (m *Way) Unmarshal(dAtA []byte) error {
 			}
 			b := dAtA[iNdEx]
 			iNdEx++
-			wire |= (uint64(b) & 0x7F) << shift
+			wire |= uint64(b&0x7F) << shift
 			if b < 0x80 {
 				break
 			}
@@ -709,7 +1171,7 @@ func (m *Way) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				stringLen |= (uint64(b) & 0x7F) << shift
+				stringLen |= uint64(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
@@ -719,6 +1181,9 @@ func (m *Way) Unmarshal(dAtA []byte) error {
 				return ErrInvalidLengthMessages
 			}
 			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthMessages
+			}
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
@@ -736,7 +1201,7 @@ func (m *Way) Unmarshal(dAtA []byte) error {
 					}
 					b := dAtA[iNdEx]
 					iNdEx++
-					v |= (int64(b) & 0x7F) << shift
+					v |= int64(b&0x7F) << shift
 					if b < 0x80 {
 						break
 					}
@@ -753,7 +1218,7 @@ func (m *Way) Unmarshal(dAtA []byte) error {
 					}
 					b := dAtA[iNdEx]
 					iNdEx++
-					packedLen |= (int(b) & 0x7F) << shift
+					packedLen |= int(b&0x7F) << shift
 					if b < 0x80 {
 						break
 					}
@@ -762,9 +1227,23 @@ func (m *Way) Unmarshal(dAtA []byte) error {
 					return ErrInvalidLengthMessages
 				}
 				postIndex := iNdEx + packedLen
+				if postIndex < 0 {
+					return ErrInvalidLengthMessages
+				}
 				if postIndex > l {
 					return io.ErrUnexpectedEOF
 				}
+				var elementCount int
+				var count int
+				for _, integer := range dAtA[iNdEx:postIndex] {
+					if integer < 128 {
+						count++
+					}
+				}
+				elementCount = count
+				if elementCount != 0 && len(m.Refs) == 0 {
+					m.Refs = make([]int64, 0, elementCount)
+				}
 				for iNdEx < postIndex {
 					var v int64
 					for shift := uint(0); ; shift += 7 {
@@ -776,23 +1255,215 @@ func (m *Way) Unmarshal(dAtA []byte) error {
 						}
 						b := dAtA[iNdEx]
 						iNdEx++
-						v |= (int64(b) & 0x7F) << shift
+						v |= int64(b&0x7F) << shift
+						if b < 0x80 {
+							break
+						}
+					}
+					m.Refs = append(m.Refs, v)
+				}
+			} else {
+				return fmt.Errorf("proto: wrong wireType = %d for field Refs", wireType)
+			}
+		case 3:
+			if wireType == 0 {
+				var v uint64
+				for shift := uint(0); ; shift += 7 {
+					if shift >= 64 {
+						return ErrIntOverflowMessages
+					}
+					if iNdEx >= l {
+						return io.ErrUnexpectedEOF
+					}
+					b := dAtA[iNdEx]
+					iNdEx++
+					v |= uint64(b&0x7F) << shift
+					if b < 0x80 {
+						break
+					}
+				}
+				v = (v >> 1) ^ uint64((int64(v&1)<<63)>>63)
+				m.Lats = append(m.Lats, int64(v))
+			} else if wireType == 2 {
+				var packedLen int
+				for shift := uint(0); ; shift += 7 {
+					if shift >= 64 {
+						return ErrIntOverflowMessages
+					}
+					if iNdEx >= l {
+						return io.ErrUnexpectedEOF
+					}
+					b := dAtA[iNdEx]
+					iNdEx++
+					packedLen |= int(b&0x7F) << shift
+					if b < 0x80 {
+						break
+					}
+				}
+				if packedLen < 0 {
+					return ErrInvalidLengthMessages
+				}
+				postIndex := iNdEx + packedLen
+				if postIndex < 0 {
+					return ErrInvalidLengthMessages
+				}
+				if postIndex > l {
+					return io.ErrUnexpectedEOF
+				}
+				var elementCount int
+				var count int
+				for _, integer := range dAtA[iNdEx:postIndex] {
+					if integer < 128 {
+						count++
+					}
+				}
+				elementCount = count
+				if elementCount != 0 && len(m.Lats) == 0 {
+					m.Lats = make([]int64, 0, elementCount)
+				}
+				for iNdEx < postIndex {
+					var v uint64
+					for shift := uint(0); ; shift += 7 {
+						if shift >= 64 {
+							return ErrIntOverflowMessages
+						}
+						if iNdEx >= l {
+							return io.ErrUnexpectedEOF
+						}
+						b := dAtA[iNdEx]
+						iNdEx++
+						v |= uint64(b&0x7F) << shift
+						if b < 0x80 {
+							break
+						}
+					}
+					v = (v >> 1) ^ uint64((int64(v&1)<<63)>>63)
+					m.Lats = append(m.Lats, int64(v))
+				}
+			} else {
+				return fmt.Errorf("proto: wrong wireType = %d for field Lats", wireType)
+			}
+		case 4:
+			if wireType == 0 {
+				var v uint64
+				for shift := uint(0); ; shift += 7 {
+					if shift >= 64 {
+						return ErrIntOverflowMessages
+					}
+					if iNdEx >= l {
+						return io.ErrUnexpectedEOF
+					}
+					b := dAtA[iNdEx]
+					iNdEx++
+					v |= uint64(b&0x7F) << shift
+					if b < 0x80 {
+						break
+					}
+				}
+				v = (v >> 1) ^ uint64((int64(v&1)<<63)>>63)
+				m.Lons = append(m.Lons, int64(v))
+			} else if wireType == 2 {
+				var packedLen int
+				for shift := uint(0); ; shift += 7 {
+					if shift >= 64 {
+						return ErrIntOverflowMessages
+					}
+					if iNdEx >= l {
+						return io.ErrUnexpectedEOF
+					}
+					b := dAtA[iNdEx]
+					iNdEx++
+					packedLen |= int(b&0x7F) << shift
+					if b < 0x80 {
+						break
+					}
+				}
+				if packedLen < 0 {
+					return ErrInvalidLengthMessages
+				}
+				postIndex := iNdEx + packedLen
+				if postIndex < 0 {
+					return ErrInvalidLengthMessages
+				}
+				if postIndex > l {
+					return io.ErrUnexpectedEOF
+				}
+				var elementCount int
+				var count int
+				for _, integer := range dAtA[iNdEx:postIndex] {
+					if integer < 128 {
+						count++
+					}
+				}
+				elementCount = count
+				if elementCount != 0 && len(m.Lons) == 0 {
+					m.Lons = make([]int64, 0, elementCount)
+				}
+				for iNdEx < postIndex {
+					var v uint64
+					for shift := uint(0); ; shift += 7 {
+						if shift >= 64 {
+							return ErrIntOverflowMessages
+						}
+						if iNdEx >= l {
+							return io.ErrUnexpectedEOF
+						}
+						b := dAtA[iNdEx]
+						iNdEx++
+						v |= uint64(b&0x7F) << shift
 						if b < 0x80 {
 							break
 						}
 					}
-					m.Refs = append(m.Refs, v)
+					v = (v >> 1) ^ uint64((int64(v&1)<<63)>>63)
+					m.Lons = append(m.Lons, int64(v))
 				}
 			} else {
-				return fmt.Errorf("proto: wrong wireType = %d for field Refs", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Lons", wireType)
+			}
+		case 5:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Metadata", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowMessages
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthMessages
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthMessages
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.Metadata == nil {
+				m.Metadata = &Metadata{}
+			}
+			if err := m.Metadata.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
 			}
+			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
 			skippy, err := skipMessages(dAtA[iNdEx:])
 			if err != nil {
 				return err
 			}
-			if skippy < 0 {
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
 				return ErrInvalidLengthMessages
 			}
 			if (iNdEx + skippy) > l {
@@ -822,7 +1493,7 @@ func (m *Relation) Unmarshal(dAtA []byte) error {
 			}
 			b := dAtA[iNdEx]
 			iNdEx++
-			wire |= (uint64(b) & 0x7F) << shift
+			wire |= uint64(b&0x7F) << shift
 			if b < 0x80 {
 				break
 			}
@@ -850,7 +1521,7 @@ func (m *Relation) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				stringLen |= (uint64(b) & 0x7F) << shift
+				stringLen |= uint64(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
@@ -860,6 +1531,9 @@ func (m *Relation) Unmarshal(dAtA []byte) error {
 				return ErrInvalidLengthMessages
 			}
 			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthMessages
+			}
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
@@ -877,7 +1551,7 @@ func (m *Relation) Unmarshal(dAtA []byte) error {
 					}
 					b := dAtA[iNdEx]
 					iNdEx++
-					v |= (int64(b) & 0x7F) << shift
+					v |= int64(b&0x7F) << shift
 					if b < 0x80 {
 						break
 					}
@@ -894,7 +1568,7 @@ func (m *Relation) Unmarshal(dAtA []byte) error {
 					}
 					b := dAtA[iNdEx]
 					iNdEx++
-					packedLen |= (int(b) & 0x7F) << shift
+					packedLen |= int(b&0x7F) << shift
 					if b < 0x80 {
 						break
 					}
@@ -903,9 +1577,23 @@ func (m *Relation) Unmarshal(dAtA []byte) error {
 					return ErrInvalidLengthMessages
 				}
 				postIndex := iNdEx + packedLen
+				if postIndex < 0 {
+					return ErrInvalidLengthMessages
+				}
 				if postIndex > l {
 					return io.ErrUnexpectedEOF
 				}
+				var elementCount int
+				var count int
+				for _, integer := range dAtA[iNdEx:postIndex] {
+					if integer < 128 {
+						count++
+					}
+				}
+				elementCount = count
+				if elementCount != 0 && len(m.MemberIds) == 0 {
+					m.MemberIds = make([]int64, 0, elementCount)
+				}
 				for iNdEx < postIndex {
 					var v int64
 					for shift := uint(0); ; shift += 7 {
@@ -917,7 +1605,7 @@ func (m *Relation) Unmarshal(dAtA []byte) error {
 						}
 						b := dAtA[iNdEx]
 						iNdEx++
-						v |= (int64(b) & 0x7F) << shift
+						v |= int64(b&0x7F) << shift
 						if b < 0x80 {
 							break
 						}
@@ -939,7 +1627,7 @@ func (m *Relation) Unmarshal(dAtA []byte) error {
 					}
 					b := dAtA[iNdEx]
 					iNdEx++
-					v |= (Relation_MemberType(b) & 0x7F) << shift
+					v |= Relation_MemberType(b&0x7F) << shift
 					if b < 0x80 {
 						break
 					}
@@ -956,7 +1644,7 @@ func (m *Relation) Unmarshal(dAtA []byte) error {
 					}
 					b := dAtA[iNdEx]
 					iNdEx++
-					packedLen |= (int(b) & 0x7F) << shift
+					packedLen |= int(b&0x7F) << shift
 					if b < 0x80 {
 						break
 					}
@@ -965,9 +1653,16 @@ func (m *Relation) Unmarshal(dAtA []byte) error {
 					return ErrInvalidLengthMessages
 				}
 				postIndex := iNdEx + packedLen
+				if postIndex < 0 {
+					return ErrInvalidLengthMessages
+				}
 				if postIndex > l {
 					return io.ErrUnexpectedEOF
 				}
+				var elementCount int
+				if elementCount != 0 && len(m.MemberTypes) == 0 {
+					m.MemberTypes = make([]Relation_MemberType, 0, elementCount)
+				}
 				for iNdEx < postIndex {
 					var v Relation_MemberType
 					for shift := uint(0); ; shift += 7 {
@@ -979,7 +1674,7 @@ func (m *Relation) Unmarshal(dAtA []byte) error {
 						}
 						b := dAtA[iNdEx]
 						iNdEx++
-						v |= (Relation_MemberType(b) & 0x7F) << shift
+						v |= Relation_MemberType(b&0x7F) << shift
 						if b < 0x80 {
 							break
 						}
@@ -1003,7 +1698,7 @@ func (m *Relation) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				stringLen |= (uint64(b) & 0x7F) << shift
+				stringLen |= uint64(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
@@ -1013,18 +1708,57 @@ func (m *Relation) Unmarshal(dAtA []byte) error {
 				return ErrInvalidLengthMessages
 			}
 			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthMessages
+			}
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
 			m.MemberRoles = append(m.MemberRoles, string(dAtA[iNdEx:postIndex]))
 			iNdEx = postIndex
+		case 5:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Metadata", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowMessages
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthMessages
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthMessages
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.Metadata == nil {
+				m.Metadata = &Metadata{}
+			}
+			if err := m.Metadata.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
 			skippy, err := skipMessages(dAtA[iNdEx:])
 			if err != nil {
 				return err
 			}
-			if skippy < 0 {
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
 				return ErrInvalidLengthMessages
 			}
 			if (iNdEx + skippy) > l {
@@ -1054,7 +1788,7 @@ func (m *DeltaCoords) Unmarshal(dAtA []byte) error {
 			}
 			b := dAtA[iNdEx]
 			iNdEx++
-			wire |= (uint64(b) & 0x7F) << shift
+			wire |= uint64(b&0x7F) << shift
 			if b < 0x80 {
 				break
 			}
@@ -1080,7 +1814,7 @@ func (m *DeltaCoords) Unmarshal(dAtA []byte) error {
 					}
 					b := dAtA[iNdEx]
 					iNdEx++
-					v |= (uint64(b) & 0x7F) << shift
+					v |= uint64(b&0x7F) << shift
 					if b < 0x80 {
 						break
 					}
@@ -1098,7 +1832,7 @@ func (m *DeltaCoords) Unmarshal(dAtA []byte) error {
 					}
 					b := dAtA[iNdEx]
 					iNdEx++
-					packedLen |= (int(b) & 0x7F) << shift
+					packedLen |= int(b&0x7F) << shift
 					if b < 0x80 {
 						break
 					}
@@ -1107,9 +1841,23 @@ func (m *DeltaCoords) Unmarshal(dAtA []byte) error {
 					return ErrInvalidLengthMessages
 				}
 				postIndex := iNdEx + packedLen
+				if postIndex < 0 {
+					return ErrInvalidLengthMessages
+				}
 				if postIndex > l {
 					return io.ErrUnexpectedEOF
 				}
+				var elementCount int
+				var count int
+				for _, integer := range dAtA[iNdEx:postIndex] {
+					if integer < 128 {
+						count++
+					}
+				}
+				elementCount = count
+				if elementCount != 0 && len(m.Ids) == 0 {
+					m.Ids = make([]int64, 0, elementCount)
+				}
 				for iNdEx < postIndex {
 					var v uint64
 					for shift := uint(0); ; shift += 7 {
@@ -1121,7 +1869,7 @@ func (m *DeltaCoords) Unmarshal(dAtA []byte) error {
 						}
 						b := dAtA[iNdEx]
 						iNdEx++
-						v |= (uint64(b) & 0x7F) << shift
+						v |= uint64(b&0x7F) << shift
 						if b < 0x80 {
 							break
 						}
@@ -1144,7 +1892,7 @@ func (m *DeltaCoords) Unmarshal(dAtA []byte) error {
 					}
 					b := dAtA[iNdEx]
 					iNdEx++
-					v |= (uint64(b) & 0x7F) << shift
+					v |= uint64(b&0x7F) << shift
 					if b < 0x80 {
 						break
 					}
@@ -1162,7 +1910,7 @@ func (m *DeltaCoords) Unmarshal(dAtA []byte) error {
 					}
 					b := dAtA[iNdEx]
 					iNdEx++
-					packedLen |= (int(b) & 0x7F) << shift
+					packedLen |= int(b&0x7F) << shift
 					if b < 0x80 {
 						break
 					}
@@ -1171,9 +1919,23 @@ func (m *DeltaCoords) Unmarshal(dAtA []byte) error {
 					return ErrInvalidLengthMessages
 				}
 				postIndex := iNdEx + packedLen
+				if postIndex < 0 {
+					return ErrInvalidLengthMessages
+				}
 				if postIndex > l {
 					return io.ErrUnexpectedEOF
 				}
+				var elementCount int
+				var count int
+				for _, integer := range dAtA[iNdEx:postIndex] {
+					if integer < 128 {
+						count++
+					}
+				}
+				elementCount = count
+				if elementCount != 0 && len(m.Lats) == 0 {
+					m.Lats = make([]int64, 0, elementCount)
+				}
 				for iNdEx < postIndex {
 					var v uint64
 					for shift := uint(0); ; shift += 7 {
@@ -1185,7 +1947,7 @@ func (m *DeltaCoords) Unmarshal(dAtA []byte) error {
 						}
 						b := dAtA[iNdEx]
 						iNdEx++
-						v |= (uint64(b) & 0x7F) << shift
+						v |= uint64(b&0x7F) << shift
 						if b < 0x80 {
 							break
 						}
@@ -1208,7 +1970,7 @@ func (m *DeltaCoords) Unmarshal(dAtA []byte) error {
 					}
 					b := dAtA[iNdEx]
 					iNdEx++
-					v |= (uint64(b) & 0x7F) << shift
+					v |= uint64(b&0x7F) << shift
 					if b < 0x80 {
 						break
 					}
@@ -1226,7 +1988,7 @@ func (m *DeltaCoords) Unmarshal(dAtA []byte) error {
 					}
 					b := dAtA[iNdEx]
 					iNdEx++
-					packedLen |= (int(b) & 0x7F) << shift
+					packedLen |= int(b&0x7F) << shift
 					if b < 0x80 {
 						break
 					}
@@ -1235,9 +1997,23 @@ func (m *DeltaCoords) Unmarshal(dAtA []byte) error {
 					return ErrInvalidLengthMessages
 				}
 				postIndex := iNdEx + packedLen
+				if postIndex < 0 {
+					return ErrInvalidLengthMessages
+				}
 				if postIndex > l {
 					return io.ErrUnexpectedEOF
 				}
+				var elementCount int
+				var count int
+				for _, integer := range dAtA[iNdEx:postIndex] {
+					if integer < 128 {
+						count++
+					}
+				}
+				elementCount = count
+				if elementCount != 0 && len(m.Lons) == 0 {
+					m.Lons = make([]int64, 0, elementCount)
+				}
 				for iNdEx < postIndex {
 					var v uint64
 					for shift := uint(0); ; shift += 7 {
@@ -1249,7 +2025,7 @@ func (m *DeltaCoords) Unmarshal(dAtA []byte) error {
 						}
 						b := dAtA[iNdEx]
 						iNdEx++
-						v |= (uint64(b) & 0x7F) << shift
+						v |= uint64(b&0x7F) << shift
 						if b < 0x80 {
 							break
 						}
@@ -1266,7 +2042,165 @@ func (m *DeltaCoords) Unmarshal(dAtA []byte) error {
 			if err != nil {
 				return err
 			}
-			if skippy < 0 {
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
+				return ErrInvalidLengthMessages
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *Metadata) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowMessages
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: Metadata: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: Metadata: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Version", wireType)
+			}
+			m.Version = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowMessages
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Version |= int32(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Timestamp", wireType)
+			}
+			m.Timestamp = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowMessages
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Timestamp |= int64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 3:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Changeset", wireType)
+			}
+			m.Changeset = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowMessages
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Changeset |= int64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 4:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Uid", wireType)
+			}
+			m.Uid = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowMessages
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Uid |= int32(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 5:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field User", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowMessages
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthMessages
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthMessages
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.User = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipMessages(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
 				return ErrInvalidLengthMessages
 			}
 			if (iNdEx + skippy) > l {
@@ -1284,6 +2218,7 @@ func (m *DeltaCoords) Unmarshal(dAtA []byte) error {
 func skipMessages(dAtA []byte) (n int, err error) {
 	l := len(dAtA)
 	iNdEx := 0
+	depth := 0
 	for iNdEx < l {
 		var wire uint64
 		for shift := uint(0); ; shift += 7 {
@@ -1315,10 +2250,8 @@ func skipMessages(dAtA []byte) (n int, err error) {
 					break
 				}
 			}
-			return iNdEx, nil
 		case 1:
 			iNdEx += 8
-			return iNdEx, nil
 		case 2:
 			var length int
 			for shift := uint(0); ; shift += 7 {
@@ -1335,81 +2268,34 @@ func skipMessages(dAtA []byte) (n int, err error) {
 					break
 				}
 			}
-			iNdEx += length
 			if length < 0 {
 				return 0, ErrInvalidLengthMessages
 			}
-			return iNdEx, nil
+			iNdEx += length
 		case 3:
-			for {
-				var innerWire uint64
-				var start int = iNdEx
-				for shift := uint(0); ; shift += 7 {
-					if shift >= 64 {
-						return 0, ErrIntOverflowMessages
-					}
-					if iNdEx >= l {
-						return 0, io.ErrUnexpectedEOF
-					}
-					b := dAtA[iNdEx]
-					iNdEx++
-					innerWire |= (uint64(b) & 0x7F) << shift
-					if b < 0x80 {
-						break
-					}
-				}
-				innerWireType := int(innerWire & 0x7)
-				if innerWireType == 4 {
-					break
-				}
-				next, err := skipMessages(dAtA[start:])
-				if err != nil {
-					return 0, err
-				}
-				iNdEx = start + next
-			}
-			return iNdEx, nil
+			depth++
 		case 4:
-			return iNdEx, nil
+			if depth == 0 {
+				return 0, ErrUnexpectedEndOfGroupMessages
+			}
+			depth--
 		case 5:
 			iNdEx += 4
-			return iNdEx, nil
 		default:
 			return 0, fmt.Errorf("proto: illegal wireType %d", wireType)
 		}
+		if iNdEx < 0 {
+			return 0, ErrInvalidLengthMessages
+		}
+		if depth == 0 {
+			return iNdEx, nil
+		}
 	}
-	panic("unreachable")
+	return 0, io.ErrUnexpectedEOF
 }
 
 var (
-	ErrInvalidLengthMessages = fmt.Errorf("proto: negative length found during unmarshaling")
-	ErrIntOverflowMessages   = fmt.Errorf("proto: integer overflow")
+	ErrInvalidLengthMessages        = fmt.Errorf("proto: negative length found during unmarshaling")
+	ErrIntOverflowMessages          = fmt.Errorf("proto: integer overflow")
+	ErrUnexpectedEndOfGroupMessages = fmt.Errorf("proto: unexpected end of group")
 )
-
-func init() { proto.RegisterFile("cache/binary/messages.proto", fileDescriptorMessages) }
-
-var fileDescriptorMessages = []byte{
-	// 334 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x6c, 0x90, 0xd1, 0x4e, 0xc2, 0x30,
-	0x14, 0x86, 0xe9, 0xda, 0x28, 0x1c, 0xd0, 0x2c, 0x8d, 0x21, 0x4b, 0x88, 0x13, 0x77, 0xc5, 0x8d,
-	0x23, 0xfa, 0x00, 0x26, 0x20, 0x5c, 0x90, 0x20, 0x24, 0x95, 0x84, 0x78, 0x65, 0x0a, 0xab, 0x48,
-	0xb2, 0xad, 0x64, 0xed, 0xcd, 0xde, 0xc2, 0xc7, 0xe2, 0x4e, 0x9f, 0xc0, 0x18, 0x7c, 0x11, 0xb3,
-	0x16, 0x86, 0x17, 0xde, 0x9d, 0xff, 0xfb, 0xcf, 0xbf, 0x9d, 0xbf, 0xd0, 0x5a, 0xf2, 0xe5, 0x9b,
-	0xe8, 0x2e, 0xd6, 0x29, 0xcf, 0xf2, 0x6e, 0x22, 0x94, 0xe2, 0x2b, 0xa1, 0xc2, 0x4d, 0x26, 0xb5,
-	0xa4, 0x27, 0x16, 0x07, 0x63, 0x20, 0x13, 0x19, 0x09, 0xea, 0x01, 0x89, 0x65, 0xba, 0xf2, 0x50,
-	0xdb, 0xe9, 0x9c, 0xf5, 0xc9, 0xf6, 0xeb, 0xaa, 0xc2, 0x0c, 0xa1, 0x4d, 0xc0, 0x31, 0xd7, 0x9e,
-	0xf3, 0xc7, 0x28, 0x00, 0xa5, 0x40, 0x34, 0x5f, 0x29, 0x0f, 0xb7, 0x71, 0xa7, 0xc6, 0xcc, 0x1c,
-	0xdc, 0x02, 0x9e, 0xf3, 0xbc, 0xb4, 0xd0, 0xd1, 0xa2, 0x4d, 0x20, 0x99, 0x78, 0x55, 0x9e, 0xd3,
-	0xc6, 0x1d, 0xdc, 0x77, 0x5c, 0xc4, 0x8c, 0x0e, 0x3e, 0x10, 0x54, 0x99, 0x88, 0xb9, 0x5e, 0xcb,
-	0xf4, 0xdf, 0xe0, 0x25, 0x40, 0x22, 0x92, 0x85, 0xc8, 0x5e, 0xd6, 0xd1, 0x3e, 0xce, 0x6a, 0x96,
-	0x8c, 0x22, 0x45, 0xef, 0xa1, 0xb1, 0xb7, 0x75, 0xbe, 0x11, 0xf6, 0x9c, 0xf3, 0xbb, 0x56, 0x68,
-	0xfb, 0x85, 0x87, 0x4f, 0x87, 0x8f, 0x66, 0x69, 0x96, 0x6f, 0x04, 0xab, 0x27, 0xe5, 0xac, 0xe8,
-	0x75, 0x99, 0xcf, 0x64, 0x2c, 0x94, 0x47, 0xcc, 0xaf, 0xf7, 0x2b, 0xac, 0x40, 0xc1, 0x0d, 0xc0,
-	0x31, 0x4d, 0xab, 0x40, 0x26, 0xd3, 0xc1, 0xd0, 0xad, 0xd0, 0x53, 0xc0, 0xf3, 0xde, 0xb3, 0x8b,
-	0x68, 0x03, 0xaa, 0x6c, 0x38, 0xee, 0xcd, 0x46, 0xd3, 0x89, 0xeb, 0x04, 0x4f, 0x50, 0x1f, 0x88,
-	0x58, 0xf3, 0x07, 0x29, 0xb3, 0x48, 0xd1, 0x0b, 0xc0, 0xc5, 0xe1, 0x45, 0x25, 0x6a, 0x7a, 0x17,
-	0xb2, 0x78, 0x8e, 0x98, 0x6b, 0xdb, 0xc7, 0x62, 0xa3, 0x0d, 0x97, 0xa9, 0xad, 0x71, 0xe0, 0x32,
-	0x55, 0x7d, 0x77, 0xbb, 0xf3, 0xd1, 0xe7, 0xce, 0x47, 0xdf, 0x3b, 0x1f, 0xbd, 0xff, 0xf8, 0x95,
-	0xdf, 0x00, 0x00, 0x00, 0xff, 0xff, 0x7f, 0x85, 0x01, 0xea, 0xdf, 0x01, 0x00, 0x00,
-}
-
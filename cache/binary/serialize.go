@@ -1,6 +1,10 @@
 package binary
 
-import osm "github.com/omniscale/go-osm"
+import (
+	"time"
+
+	osm "github.com/omniscale/go-osm"
+)
 
 const coordFactor float64 = 11930464.7083 // ((2<<31)-1)/360.0
 
@@ -12,14 +16,15 @@ func IntToCoord(coord uint32) float64 {
 	return float64((float64(coord) / coordFactor) - 180.0)
 }
 
-func MarshalNode(node *osm.Node) ([]byte, error) {
+func MarshalNode(node *osm.Node, dict *TagDict) ([]byte, error) {
 	pbfNode := &Node{}
 	pbfNode.fromWgsCoord(node.Long, node.Lat)
-	pbfNode.Tags = tagsAsArray(node.Tags)
+	pbfNode.Tags = tagsAsArray(node.Tags, dict)
+	pbfNode.Metadata = metadataToPbf(node.Metadata)
 	return pbfNode.Marshal()
 }
 
-func UnmarshalNode(data []byte) (node *osm.Node, err error) {
+func UnmarshalNode(data []byte, dict *TagDict) (node *osm.Node, err error) {
 	pbfNode := &Node{}
 	err = pbfNode.Unmarshal(data)
 	if err != nil {
@@ -28,10 +33,45 @@ func UnmarshalNode(data []byte) (node *osm.Node, err error) {
 
 	node = &osm.Node{}
 	node.Long, node.Lat = pbfNode.wgsCoord()
-	node.Tags = tagsFromArray(pbfNode.Tags)
+	node.Tags = tagsFromArray(pbfNode.Tags, dict)
+	node.Metadata = metadataFromPbf(pbfNode.Metadata)
 	return node, nil
 }
 
+// metadataToPbf returns the metadata for the cache. Elements are only read
+// with metadata if the mapping requires it, nil metadata is not stored.
+func metadataToPbf(md *osm.Metadata) *Metadata {
+	if md == nil {
+		return nil
+	}
+	pbfMd := &Metadata{
+		Version:   md.Version,
+		Changeset: md.Changeset,
+		Uid:       md.UserID,
+		User:      md.UserName,
+	}
+	if !md.Timestamp.IsZero() {
+		pbfMd.Timestamp = md.Timestamp.Unix()
+	}
+	return pbfMd
+}
+
+func metadataFromPbf(pbfMd *Metadata) *osm.Metadata {
+	if pbfMd == nil {
+		return nil
+	}
+	md := &osm.Metadata{
+		Version:   pbfMd.Version,
+		Changeset: pbfMd.Changeset,
+		UserID:    pbfMd.Uid,
+		UserName:  pbfMd.User,
+	}
+	if pbfMd.Timestamp != 0 {
+		md.Timestamp = time.Unix(pbfMd.Timestamp, 0).UTC()
+	}
+	return md
+}
+
 func deltaPack(data []int64) {
 	if len(data) < 2 {
 		return
@@ -51,16 +91,28 @@ func deltaUnpack(data []int64) {
 	}
 }
 
-func MarshalWay(way *osm.Way) ([]byte, error) {
+func MarshalWay(way *osm.Way, dict *TagDict) ([]byte, error) {
 	// TODO reuse Way to avoid make(Tags) for each way in tagsAsArray
 	pbfWay := &Way{}
 	deltaPack(way.Refs)
 	pbfWay.Refs = way.Refs
-	pbfWay.Tags = tagsAsArray(way.Tags)
+	pbfWay.Tags = tagsAsArray(way.Tags, dict)
+	pbfWay.Metadata = metadataToPbf(way.Metadata)
+	if len(way.Nodes) > 0 && len(way.Nodes) == len(way.Refs) {
+		// way from a PBF with locations on ways, store coords as well
+		pbfWay.Lats = make([]int64, len(way.Nodes))
+		pbfWay.Lons = make([]int64, len(way.Nodes))
+		for i, nd := range way.Nodes {
+			pbfWay.Lats[i] = int64(CoordToInt(nd.Lat))
+			pbfWay.Lons[i] = int64(CoordToInt(nd.Long))
+		}
+		deltaPack(pbfWay.Lats)
+		deltaPack(pbfWay.Lons)
+	}
 	return pbfWay.Marshal()
 }
 
-func UnmarshalWay(data []byte) (way *osm.Way, err error) {
+func UnmarshalWay(data []byte, dict *TagDict) (way *osm.Way, err error) {
 	pbfWay := &Way{}
 	err = pbfWay.Unmarshal(data)
 	if err != nil {
@@ -70,11 +122,22 @@ func UnmarshalWay(data []byte) (way *osm.Way, err error) {
 	way = &osm.Way{}
 	deltaUnpack(pbfWay.Refs)
 	way.Refs = pbfWay.Refs
-	way.Tags = tagsFromArray(pbfWay.Tags)
+	way.Tags = tagsFromArray(pbfWay.Tags, dict)
+	way.Metadata = metadataFromPbf(pbfWay.Metadata)
+	if len(pbfWay.Lats) == len(way.Refs) && len(pbfWay.Lons) == len(way.Refs) && len(way.Refs) > 0 {
+		deltaUnpack(pbfWay.Lats)
+		deltaUnpack(pbfWay.Lons)
+		way.Nodes = make([]osm.Node, len(way.Refs))
+		for i, id := range way.Refs {
+			way.Nodes[i].ID = id
+			way.Nodes[i].Lat = IntToCoord(uint32(pbfWay.Lats[i]))
+			way.Nodes[i].Long = IntToCoord(uint32(pbfWay.Lons[i]))
+		}
+	}
 	return way, nil
 }
 
-func MarshalRelation(relation *osm.Relation) ([]byte, error) {
+func MarshalRelation(relation *osm.Relation, dict *TagDict) ([]byte, error) {
 	pbfRelation := &Relation{}
 	pbfRelation.MemberIds = make([]int64, len(relation.Members))
 	pbfRelation.MemberTypes = make([]Relation_MemberType, len(relation.Members))
@@ -84,11 +147,12 @@ func MarshalRelation(relation *osm.Relation) ([]byte, error) {
 		pbfRelation.MemberTypes[i] = Relation_MemberType(m.Type)
 		pbfRelation.MemberRoles[i] = m.Role
 	}
-	pbfRelation.Tags = tagsAsArray(relation.Tags)
+	pbfRelation.Tags = tagsAsArray(relation.Tags, dict)
+	pbfRelation.Metadata = metadataToPbf(relation.Metadata)
 	return pbfRelation.Marshal()
 }
 
-func UnmarshalRelation(data []byte) (relation *osm.Relation, err error) {
+func UnmarshalRelation(data []byte, dict *TagDict) (relation *osm.Relation, err error) {
 	pbfRelation := &Relation{}
 	err = pbfRelation.Unmarshal(data)
 	if err != nil {
@@ -103,6 +167,7 @@ func UnmarshalRelation(data []byte) (relation *osm.Relation, err error) {
 		relation.Members[i].Role = pbfRelation.MemberRoles[i]
 	}
 	//relation.Nodes = pbfRelation.Node
-	relation.Tags = tagsFromArray(pbfRelation.Tags)
+	relation.Tags = tagsFromArray(pbfRelation.Tags, dict)
+	relation.Metadata = metadataFromPbf(pbfRelation.Metadata)
 	return relation, nil
 }
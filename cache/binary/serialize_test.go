@@ -1,7 +1,10 @@
 package binary
 
 import (
+	"math"
+	"reflect"
 	"testing"
+	"time"
 
 	osm "github.com/omniscale/go-osm"
 )
@@ -25,8 +28,8 @@ func TestMarshalNode(t *testing.T) {
 	node.Tags["name"] = "test"
 	node.Tags["place"] = "city"
 
-	data, _ := MarshalNode(node)
-	node, _ = UnmarshalNode(data)
+	data, _ := MarshalNode(node, nil)
+	node, _ = UnmarshalNode(data, nil)
 
 	if node.Tags["name"] != "test" {
 		t.Error("name tag does not match")
@@ -49,8 +52,8 @@ func TestMarshalWay(t *testing.T) {
 	way.Tags["highway"] = "trunk"
 	way.Refs = append(way.Refs, 1, 2, 3, 4)
 
-	data, _ := MarshalWay(way)
-	way, _ = UnmarshalWay(data)
+	data, _ := MarshalWay(way, nil)
+	way, _ = UnmarshalWay(data, nil)
 
 	if way.Tags["name"] != "test" {
 		t.Error("name tag does not match")
@@ -69,6 +72,37 @@ func TestMarshalWay(t *testing.T) {
 
 }
 
+func TestMarshalWayWithCoords(t *testing.T) {
+	way := &osm.Way{}
+	way.ID = 12345
+	way.Refs = append(way.Refs, 1, 2, 3)
+	way.Nodes = []osm.Node{
+		{Element: osm.Element{ID: 1}, Lat: 53.1, Long: 8.2},
+		{Element: osm.Element{ID: 2}, Lat: 53.2, Long: 8.1},
+		{Element: osm.Element{ID: 3}, Lat: -53.3, Long: -179.9},
+	}
+
+	data, _ := MarshalWay(way, nil)
+	way, _ = UnmarshalWay(data, nil)
+
+	if !compareRefs(way.Refs, []int64{1, 2, 3}) {
+		t.Error("nodes do not match")
+	}
+	if len(way.Nodes) != 3 {
+		t.Fatal("coords missing", way.Nodes)
+	}
+	for i, expected := range []osm.Node{
+		{Element: osm.Element{ID: 1}, Lat: 53.1, Long: 8.2},
+		{Element: osm.Element{ID: 2}, Lat: 53.2, Long: 8.1},
+		{Element: osm.Element{ID: 3}, Lat: -53.3, Long: -179.9},
+	} {
+		nd := way.Nodes[i]
+		if nd.ID != expected.ID || math.Abs(nd.Lat-expected.Lat) > 1e-6 || math.Abs(nd.Long-expected.Long) > 1e-6 {
+			t.Error("coords do not match", nd, expected)
+		}
+	}
+}
+
 func BenchmarkMarshalWay(b *testing.B) {
 	b.ReportAllocs()
 	way := &osm.Way{}
@@ -79,7 +113,7 @@ func BenchmarkMarshalWay(b *testing.B) {
 	way.Refs = append(way.Refs, 1, 2, 3, 4)
 
 	for i := 0; i < b.N; i++ {
-		_, _ = MarshalWay(way)
+		_, _ = MarshalWay(way, nil)
 	}
 }
 
@@ -92,9 +126,9 @@ func BenchmarkUnmarshalWay(b *testing.B) {
 	way.Tags["highway"] = "trunk"
 	way.Refs = append(way.Refs, 1, 2, 3, 4)
 
-	data, _ := MarshalWay(way)
+	data, _ := MarshalWay(way, nil)
 	for i := 0; i < b.N; i++ {
-		_, _ = UnmarshalWay(data)
+		_, _ = UnmarshalWay(data, nil)
 	}
 }
 
@@ -107,8 +141,8 @@ func TestMarshalRelation(t *testing.T) {
 	rel.Members = append(rel.Members, osm.Member{ID: 123, Type: osm.WayMember, Role: "outer"})
 	rel.Members = append(rel.Members, osm.Member{ID: 124, Type: osm.WayMember, Role: "inner"})
 
-	data, _ := MarshalRelation(rel)
-	rel, _ = UnmarshalRelation(data)
+	data, _ := MarshalRelation(rel, nil)
+	rel, _ = UnmarshalRelation(data, nil)
 
 	if rel.Tags["name"] != "test" {
 		t.Error("name tag does not match")
@@ -134,6 +168,45 @@ func TestMarshalRelation(t *testing.T) {
 	}
 }
 
+func TestMarshalMetadata(t *testing.T) {
+	md := &osm.Metadata{
+		Version:   3,
+		Timestamp: time.Date(2024, 6, 1, 12, 30, 0, 0, time.UTC),
+		Changeset: 123456789,
+		UserID:    42,
+		UserName:  "mapper",
+	}
+
+	node := &osm.Node{Element: osm.Element{ID: 1, Metadata: md}}
+	data, _ := MarshalNode(node, nil)
+	node, _ = UnmarshalNode(data, nil)
+	if !reflect.DeepEqual(node.Metadata, md) {
+		t.Error("node metadata does not match", node.Metadata)
+	}
+
+	way := &osm.Way{Element: osm.Element{ID: 1, Metadata: md}, Refs: []int64{1, 2}}
+	data, _ = MarshalWay(way, nil)
+	way, _ = UnmarshalWay(data, nil)
+	if !reflect.DeepEqual(way.Metadata, md) {
+		t.Error("way metadata does not match", way.Metadata)
+	}
+
+	rel := &osm.Relation{Element: osm.Element{ID: 1, Metadata: md}}
+	data, _ = MarshalRelation(rel, nil)
+	rel, _ = UnmarshalRelation(data, nil)
+	if !reflect.DeepEqual(rel.Metadata, md) {
+		t.Error("relation metadata does not match", rel.Metadata)
+	}
+
+	// elements without metadata
+	node = &osm.Node{Element: osm.Element{ID: 1}}
+	data, _ = MarshalNode(node, nil)
+	node, _ = UnmarshalNode(data, nil)
+	if node.Metadata != nil {
+		t.Error("unexpected metadata", node.Metadata)
+	}
+}
+
 func TestDeltaPack(t *testing.T) {
 	ids := []int64{1000, 999, 1001, -8, 1234}
 	deltaPack(ids)
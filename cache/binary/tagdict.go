@@ -0,0 +1,303 @@
+package binary
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	osm "github.com/omniscale/go-osm"
+)
+
+// A TagDict is a dictionary of common tags and keys, built from the data of
+// a single import. It complements the static table of common tags (see
+// tags.go), which only contains tags that are common world wide.
+//
+// Tags and keys of the dictionary are encoded with code points that are not
+// used by the static table. The first tags use the remaining code points of
+// the Private Use Area (3 bytes in UTF-8), all other tags use the
+// Supplementary Private Use Area-A (4 bytes). The first keys use the
+// remaining ASCII control chars (1 byte), all other keys use the
+// Supplementary Private Use Area-B (4 bytes). These code points are always
+// escaped if they are the first char of a key, so they can't appear
+// unescaped in caches without a dictionary.
+//
+// The (un)marshal functions only use the static table if the TagDict is nil.
+type TagDict struct {
+	tags          map[string]map[string]codepoint
+	codePointTags []tag
+	keys          map[string]codepoint
+	codePointKeys []string
+}
+
+const (
+	// first code points of the dictionary, keep a few code points for
+	// additional entries in the static table
+	minDictCodePoint    = codepoint('\uE400')
+	minDictKeyCodePoint = codepoint(16)
+	maxDictKeyCodePoint = codepoint(31)
+
+	// Supplementary Private Use Area-A and -B
+	minSupplementaryCodePoint = codepoint(0xF0000)
+	minDictTagSupCodePoint    = codepoint(0xF0000)
+	maxDictTagSupCodePoint    = codepoint(0xFFFFD)
+	minDictKeySupCodePoint    = codepoint(0x100000)
+	maxDictKeySupCodePoint    = codepoint(0x10FFFD)
+
+	numDictTagBMPCodePoints  = int(maxCodePoint - minDictCodePoint + 1)
+	numDictKeyCtrlCodePoints = int(maxDictKeyCodePoint - minDictKeyCodePoint + 1)
+
+	// MaxTagDictTags is the max number of tags of a TagDict.
+	MaxTagDictTags = numDictTagBMPCodePoints + int(maxDictTagSupCodePoint-minDictTagSupCodePoint+1)
+	// MaxTagDictKeys is the max number of keys of a TagDict.
+	MaxTagDictKeys = numDictKeyCtrlCodePoints + int(maxDictKeySupCodePoint-minDictKeySupCodePoint+1)
+)
+
+func dictTagCodePoint(i int) codepoint {
+	if i < numDictTagBMPCodePoints {
+		return minDictCodePoint + codepoint(i)
+	}
+	return minDictTagSupCodePoint + codepoint(i-numDictTagBMPCodePoints)
+}
+
+func dictKeyCodePoint(i int) codepoint {
+	if i < numDictKeyCtrlCodePoints {
+		return minDictKeyCodePoint + codepoint(i)
+	}
+	return minDictKeySupCodePoint + codepoint(i-numDictKeyCtrlCodePoints)
+}
+
+// NewTagDict returns a dictionary for the tags and keys. The order of the
+// tags and keys defines the encoding and it needs to be the same for all
+// elements of a cache. Tags and keys that are already in the static table
+// are not allowed.
+func NewTagDict(tags [][2]string, keys []string) (*TagDict, error) {
+	if len(tags) > MaxTagDictTags {
+		return nil, fmt.Errorf("too many tags for dictionary: %d", len(tags))
+	}
+	if len(keys) > MaxTagDictKeys {
+		return nil, fmt.Errorf("too many keys for dictionary: %d", len(keys))
+	}
+	d := &TagDict{
+		tags:          make(map[string]map[string]codepoint),
+		codePointTags: make([]tag, len(tags)),
+		keys:          make(map[string]codepoint, len(keys)),
+		codePointKeys: make([]string, len(keys)),
+	}
+	for i, t := range tags {
+		if _, ok := tagsToCodePoint[t[0]][t[1]]; ok {
+			return nil, fmt.Errorf("tag %s=%s already in static table", t[0], t[1])
+		}
+		if _, ok := d.tags[t[0]][t[1]]; ok {
+			return nil, fmt.Errorf("duplicate tag %s=%s in dictionary", t[0], t[1])
+		}
+		valMap, ok := d.tags[t[0]]
+		if !ok {
+			valMap = make(map[string]codepoint)
+			d.tags[t[0]] = valMap
+		}
+		valMap[t[1]] = dictTagCodePoint(i)
+		d.codePointTags[i] = tag{t[0], t[1]}
+	}
+	for i, k := range keys {
+		if _, ok := commonKeys[k]; ok {
+			return nil, fmt.Errorf("key %s already in static table", k)
+		}
+		if _, ok := d.keys[k]; ok {
+			return nil, fmt.Errorf("duplicate key %s in dictionary", k)
+		}
+		d.keys[k] = dictKeyCodePoint(i)
+		d.codePointKeys[i] = k
+	}
+	return d, nil
+}
+
+// NumTags returns the number of tags in the dictionary.
+func (d *TagDict) NumTags() int { return len(d.codePointTags) }
+
+// NumKeys returns the number of keys in the dictionary.
+func (d *TagDict) NumKeys() int { return len(d.codePointKeys) }
+
+func (d *TagDict) tag(c codepoint) (tag, bool) {
+	var i int
+	switch {
+	case c >= minDictCodePoint && c <= maxCodePoint:
+		i = int(c - minDictCodePoint)
+	case c >= minDictTagSupCodePoint && c <= maxDictTagSupCodePoint:
+		i = numDictTagBMPCodePoints + int(c-minDictTagSupCodePoint)
+	default:
+		return tag{}, false
+	}
+	if i >= len(d.codePointTags) {
+		panic("missing tag for codepoint")
+	}
+	return d.codePointTags[i], true
+}
+
+func (d *TagDict) key(c codepoint) (string, bool) {
+	var i int
+	switch {
+	case c >= minDictKeyCodePoint && c <= maxDictKeyCodePoint:
+		i = int(c - minDictKeyCodePoint)
+	case c >= minDictKeySupCodePoint && c <= maxDictKeySupCodePoint:
+		i = numDictKeyCtrlCodePoints + int(c-minDictKeySupCodePoint)
+	default:
+		return "", false
+	}
+	if i >= len(d.codePointKeys) {
+		panic("missing key for codepoint")
+	}
+	return d.codePointKeys[i], true
+}
+
+// tagDictVersion is the version of the serialized dictionary. It needs to
+// change with every incompatible change of the encoding.
+const tagDictVersion = 1
+
+type tagDictFile struct {
+	Version int         `json:"version"`
+	Tags    [][2]string `json:"tags"`
+	Keys    []string    `json:"keys"`
+}
+
+// ReadTagDict reads a dictionary written by TagDict.Write.
+func ReadTagDict(r io.Reader) (*TagDict, error) {
+	var f tagDictFile
+	if err := json.NewDecoder(r).Decode(&f); err != nil {
+		return nil, fmt.Errorf("decoding tag dictionary: %w", err)
+	}
+	if f.Version != tagDictVersion {
+		return nil, fmt.Errorf("unsupported tag dictionary version %d, expected %d", f.Version, tagDictVersion)
+	}
+	return NewTagDict(f.Tags, f.Keys)
+}
+
+// Write writes the dictionary as JSON.
+func (d *TagDict) Write(w io.Writer) error {
+	f := tagDictFile{
+		Version: tagDictVersion,
+		Tags:    make([][2]string, len(d.codePointTags)),
+		Keys:    d.codePointKeys,
+	}
+	for i, t := range d.codePointTags {
+		f.Tags[i] = [2]string{t.Key, t.Value}
+	}
+	return json.NewEncoder(w).Encode(f)
+}
+
+const (
+	// number of distinct tags/keys the builder keeps in memory before
+	// dropping rare entries
+	maxTagDictCandidates = 1 << 20
+	// min number of bytes an entry needs to save to be included
+	minTagDictSavings = 1024
+)
+
+// TagDictBuilder counts the tags of the imported elements to build a
+// TagDict with the tags and keys that save the most space.
+type TagDictBuilder struct {
+	tags      map[tag]int64
+	keys      map[string]int64
+	threshold int64
+}
+
+func NewTagDictBuilder() *TagDictBuilder {
+	return &TagDictBuilder{
+		tags:      make(map[tag]int64),
+		keys:      make(map[string]int64),
+		threshold: 2,
+	}
+}
+
+// Add counts all tags that are not already in the static table.
+func (b *TagDictBuilder) Add(tags osm.Tags) {
+	for k, v := range tags {
+		if _, ok := tagsToCodePoint[k][v]; ok {
+			continue
+		}
+		b.tags[tag{k, v}]++
+		if _, ok := commonKeys[k]; !ok {
+			b.keys[k]++
+		}
+	}
+	if len(b.tags) > maxTagDictCandidates || len(b.keys) > maxTagDictCandidates {
+		b.prune()
+	}
+}
+
+// prune drops rare tags and keys to limit the memory usage. Frequent
+// entries are counted slightly too low afterwards, but they are still
+// frequent.
+func (b *TagDictBuilder) prune() {
+	for len(b.tags) > maxTagDictCandidates/2 || len(b.keys) > maxTagDictCandidates/2 {
+		for t, n := range b.tags {
+			if n < b.threshold {
+				delete(b.tags, t)
+			}
+		}
+		for k, n := range b.keys {
+			if n < b.threshold {
+				delete(b.keys, k)
+			}
+		}
+		b.threshold *= 2
+	}
+}
+
+// TagDict returns a dictionary with the tags and keys that save the most
+// space. Tags and keys are only included if they save at least 1KiB.
+func (b *TagDictBuilder) TagDict() *TagDict {
+	type candidate struct {
+		tag     tag
+		savings int64
+	}
+	var tagCandidates []candidate
+	for t, n := range b.tags {
+		// key and value with length prefix, vs. 3-4 bytes for the code point
+		savings := n * int64(len(t.Key)+len(t.Value)+2-4)
+		if savings >= minTagDictSavings {
+			tagCandidates = append(tagCandidates, candidate{t, savings})
+		}
+	}
+	var keyCandidates []candidate
+	for k, n := range b.keys {
+		// key with length prefix, vs. 1-4 bytes for the code point
+		savings := n * int64(len(k)+1-4)
+		if savings >= minTagDictSavings {
+			keyCandidates = append(keyCandidates, candidate{tag{Key: k}, savings})
+		}
+	}
+	// entries with the largest savings get the shortest code points
+	for _, cs := range [][]candidate{tagCandidates, keyCandidates} {
+		sort.Slice(cs, func(i, j int) bool {
+			if cs[i].savings != cs[j].savings {
+				return cs[i].savings > cs[j].savings
+			}
+			if cs[i].tag.Key != cs[j].tag.Key {
+				return cs[i].tag.Key < cs[j].tag.Key
+			}
+			return cs[i].tag.Value < cs[j].tag.Value
+		})
+	}
+	if len(tagCandidates) > MaxTagDictTags {
+		tagCandidates = tagCandidates[:MaxTagDictTags]
+	}
+	if len(keyCandidates) > MaxTagDictKeys {
+		keyCandidates = keyCandidates[:MaxTagDictKeys]
+	}
+
+	tags := make([][2]string, len(tagCandidates))
+	for i, c := range tagCandidates {
+		tags[i] = [2]string{c.tag.Key, c.tag.Value}
+	}
+	keys := make([]string, len(keyCandidates))
+	for i, c := range keyCandidates {
+		keys[i] = c.tag.Key
+	}
+	d, err := NewTagDict(tags, keys)
+	if err != nil {
+		// candidates are unique and not in the static table
+		panic(err)
+	}
+	return d
+}
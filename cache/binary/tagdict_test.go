@@ -0,0 +1,179 @@
+package binary
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	osm "github.com/omniscale/go-osm"
+)
+
+func TestTagDictEncoding(t *testing.T) {
+	// fill all BMP code points to check the supplementary code points
+	var dictTags [][2]string
+	for i := 0; i < numDictTagBMPCodePoints+10; i++ {
+		dictTags = append(dictTags, [2]string{"ref", fmt.Sprint(i)})
+	}
+	var dictKeys []string
+	for i := 0; i < numDictKeyCtrlCodePoints+10; i++ {
+		dictKeys = append(dictKeys, fmt.Sprintf("key%d", i))
+	}
+	dict, err := NewTagDict(dictTags, dictKeys)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, check := range []struct {
+		tags osm.Tags
+		size int // size of the first encoded string
+	}{
+		{osm.Tags{"ref": "0"}, 3},
+		{osm.Tags{"ref": fmt.Sprint(numDictTagBMPCodePoints - 1)}, 3},
+		{osm.Tags{"ref": fmt.Sprint(numDictTagBMPCodePoints)}, 4},
+		{osm.Tags{"ref": fmt.Sprint(numDictTagBMPCodePoints + 9)}, 4},
+		{osm.Tags{"key0": "foo"}, 1 + 3},
+		{osm.Tags{"key15": "foo"}, 1 + 3},
+		{osm.Tags{"key16": "foo"}, 4 + 3},
+		// static table is used first
+		{osm.Tags{"building": "yes"}, 3},
+		{osm.Tags{"name": "foo"}, 1 + 3},
+		// not in any table
+		{osm.Tags{"ref": "unknown"}, 3},
+		// escaped supplementary code points
+		{osm.Tags{"\U000F0000": "foo"}, 3 + 4},
+		{osm.Tags{"\U00100000": "foo"}, 3 + 4},
+		{osm.Tags{"\x10": "foo"}, 3 + 1},
+	} {
+		arr := tagsAsArray(check.tags, dict)
+		if len(arr[0]) != check.size {
+			t.Errorf("case %d: unexpected size of %q: %d", i, arr[0], len(arr[0]))
+		}
+		if actual := tagsFromArray(arr, dict); !reflect.DeepEqual(actual, check.tags) {
+			t.Errorf("case %d: unexpected tags %#v != %#v", i, actual, check.tags)
+		}
+	}
+}
+
+func TestTagDictStaticCompatible(t *testing.T) {
+	// caches without dictionary are decoded the same with a dictionary
+	dict, err := NewTagDict([][2]string{{"highway", "foo"}}, []string{"ref"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tags := range []osm.Tags{
+		{"name": "foo", "highway": "residential"},
+		{"": "foo", "\x10": "bar"},
+		{"ref": "1", "highway": "foo"},
+	} {
+		arr := tagsAsArray(tags, nil)
+		if actual := tagsFromArray(arr, dict); !reflect.DeepEqual(actual, tags) {
+			t.Errorf("unexpected tags %#v != %#v", actual, tags)
+		}
+	}
+}
+
+func TestNewTagDictErrors(t *testing.T) {
+	for _, check := range []struct {
+		tags [][2]string
+		keys []string
+		err  string
+	}{
+		{[][2]string{{"building", "yes"}}, nil, "already in static table"},
+		{[][2]string{{"a", "b"}, {"a", "b"}}, nil, "duplicate tag"},
+		{nil, []string{"name"}, "already in static table"},
+		{nil, []string{"a", "a"}, "duplicate key"},
+	} {
+		_, err := NewTagDict(check.tags, check.keys)
+		if err == nil || !strings.Contains(err.Error(), check.err) {
+			t.Errorf("expected error %q, got %v", check.err, err)
+		}
+	}
+}
+
+func TestTagDictReadWrite(t *testing.T) {
+	dict, err := NewTagDict([][2]string{{"highway", "foo"}, {"ref", "A1"}}, []string{"ref", "operator"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := &bytes.Buffer{}
+	if err := dict.Write(buf); err != nil {
+		t.Fatal(err)
+	}
+	actual, err := ReadTagDict(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(actual, dict) {
+		t.Errorf("unexpected dictionary %#v != %#v", actual, dict)
+	}
+
+	_, err = ReadTagDict(strings.NewReader(`{"version": 2, "tags": [], "keys": []}`))
+	if err == nil || !strings.Contains(err.Error(), "unsupported tag dictionary version 2") {
+		t.Error("unexpected error", err)
+	}
+}
+
+func TestTagDictBuilder(t *testing.T) {
+	b := NewTagDictBuilder()
+	for i := 0; i < 1000; i++ {
+		b.Add(osm.Tags{
+			"highway":        "residential",   // in static table
+			"name":           fmt.Sprint(i),   // common key in static table
+			"cuisine":        "mediterranean", // frequent tag
+			"operator":       fmt.Sprint(i),   // frequent key
+			"ref":            fmt.Sprint(i),   // frequent key, but too short
+			"lanes:forward":  fmt.Sprint(i % 3),
+			"rare_long_key!": "rare_long_value!",
+		})
+		if i < 20 {
+			b.Add(osm.Tags{"rare_long_key": "rare_long_value", "building": "school"})
+		}
+	}
+	dict := b.TagDict()
+
+	expectedTags := [][2]string{
+		{"rare_long_key!", "rare_long_value!"},
+		{"cuisine", "mediterranean"},
+		{"lanes:forward", "0"},
+		{"lanes:forward", "1"},
+		{"lanes:forward", "2"},
+	}
+	if dict.NumTags() != len(expectedTags) {
+		t.Fatal("unexpected tags", dict.codePointTags)
+	}
+	for i, tag := range expectedTags {
+		if dict.codePointTags[i].Key != tag[0] || dict.codePointTags[i].Value != tag[1] {
+			t.Error("unexpected tags", dict.codePointTags)
+			break
+		}
+	}
+	expectedKeys := []string{"rare_long_key!", "lanes:forward", "operator", "cuisine"}
+	if !reflect.DeepEqual(dict.codePointKeys, expectedKeys) {
+		t.Error("unexpected keys", dict.codePointKeys)
+	}
+
+	// frequent tags use the shortest code points
+	arr := tagsAsArray(osm.Tags{"rare_long_key!": "rare_long_value!"}, dict)
+	if r, _ := utf8.DecodeRuneInString(arr[0]); codepoint(r) != minDictCodePoint {
+		t.Errorf("unexpected code point %x", r)
+	}
+}
+
+func TestTagDictBuilderPrune(t *testing.T) {
+	b := NewTagDictBuilder()
+	for i := 0; i < 100; i++ {
+		b.Add(osm.Tags{"cuisine": "mediterranean"})
+	}
+	for i := 0; i < maxTagDictCandidates+1; i++ {
+		b.Add(osm.Tags{"ref": fmt.Sprint(i)})
+	}
+	if len(b.tags) > maxTagDictCandidates/2 {
+		t.Error("tags not pruned", len(b.tags))
+	}
+	if b.tags[tag{"cuisine", "mediterranean"}] != 100 {
+		t.Error("frequent tag pruned")
+	}
+}
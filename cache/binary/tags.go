@@ -32,7 +32,9 @@ var codePointToTag = map[codepoint]tag{}
 var commonKeys = map[string]codepoint{}
 var codePointToCommonKey = map[uint8]string{}
 var nextKeyCodePoint = codepoint(1)
-var maxKeyCodePoint = codepoint(31)
+
+// the remaining code points are reserved for tag dictionaries (see TagDict)
+var maxKeyCodePoint = minDictKeyCodePoint - 1
 
 const minCodePoint = codepoint('\uE000')
 const maxCodePoint = codepoint('\uF8FF')
@@ -42,7 +44,7 @@ var nextCodePoint = codepoint('\uE000')
 const escapeRune = '\ufffd' // unicode replacement char
 
 func addTagCodePoint(key, value string) {
-	if nextCodePoint > maxCodePoint {
+	if nextCodePoint >= minDictCodePoint {
 		panic("all codepoints used!")
 	}
 	valMap, ok := tagsToCodePoint[key]
@@ -68,7 +70,7 @@ func addCommonKey(key string) {
 	nextKeyCodePoint++
 }
 
-func tagsFromArray(arr []string) osm.Tags {
+func tagsFromArray(arr []string, dict *TagDict) osm.Tags {
 	if len(arr) == 0 {
 		return osm.Tags{}
 	}
@@ -88,8 +90,23 @@ func tagsFromArray(arr []string) osm.Tags {
 				}
 				result[tag.Key] = tag.Value
 				continue
+			} else if dict != nil {
+				if tag, ok := dict.tag(codepoint(r)); ok {
+					result[tag.Key] = tag.Value
+					continue
+				}
+				if key, ok := dict.key(codepoint(r)); ok {
+					result[key] = arr[i][size:]
+					continue
+				}
 			}
 		} else if len(arr[i]) > 0 && arr[i][0] < 32 {
+			if dict != nil {
+				if key, ok := dict.key(codepoint(arr[i][0])); ok {
+					result[key] = arr[i][1:]
+					continue
+				}
+			}
 			result[codePointToCommonKey[arr[i][0]]] = arr[i][1:]
 			continue
 		}
@@ -105,32 +122,45 @@ func tagsFromArray(arr []string) osm.Tags {
 	return result
 }
 
-func tagsAsArray(tags osm.Tags) []string {
+func tagsAsArray(tags osm.Tags, dict *TagDict) []string {
 	if len(tags) == 0 {
 		return nil
 	}
 	result := make([]string, 0, 2*len(tags))
 	for key, val := range tags {
-		result = appendTag(result, key, val)
+		result = appendTag(result, key, val, dict)
 	}
 	return result
 }
 
-func appendTag(arr []string, key, val string) []string {
+func appendTag(arr []string, key, val string, dict *TagDict) []string {
 	if valMap, ok := tagsToCodePoint[key]; ok {
 		if codePoint, ok := valMap[val]; ok {
 			return append(arr, string(codePoint))
 		}
 	}
+	if dict != nil {
+		if valMap, ok := dict.tags[key]; ok {
+			if codePoint, ok := valMap[val]; ok {
+				return append(arr, string(codePoint))
+			}
+		}
+	}
 	if codepoint, ok := commonKeys[key]; ok {
 		return append(arr, string(codepoint)+val)
 	}
+	if dict != nil {
+		if codepoint, ok := dict.keys[key]; ok {
+			return append(arr, string(codepoint)+val)
+		}
+	}
 	// escape first char/rune if it is a commonKey/tagCodePoint
 	if len(key) > 0 && key[0] < 32 {
 		key = string(escapeRune) + key
 	} else if r, size := utf8.DecodeRuneInString(key); size >= 3 &&
 		((codepoint(r) >= minCodePoint &&
 			codepoint(r) <= maxCodePoint) ||
+			codepoint(r) >= minSupplementaryCodePoint ||
 			(r == escapeRune)) {
 		key = string(escapeRune) + key
 	}
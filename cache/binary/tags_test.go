@@ -10,7 +10,7 @@ import (
 
 func TestTagsAsAndFromArray(t *testing.T) {
 	tags := osm.Tags{"name": "foo", "highway": "residential", "oneway": "yes"}
-	array := tagsAsArray(tags)
+	array := tagsAsArray(tags, nil)
 
 	if len(array) != 3 {
 		t.Fatal("invalid length", array)
@@ -27,7 +27,7 @@ func TestTagsAsAndFromArray(t *testing.T) {
 		}
 	}
 
-	tags = tagsFromArray(array)
+	tags = tagsFromArray(array, nil)
 	if len(tags) != 3 {
 		t.Fatal("invalid length", tags)
 	}
@@ -83,7 +83,7 @@ func TestTagsArrayEncoding(t *testing.T) {
 	} {
 		var actual []string
 		for i := 0; i < len(check.tags); i += 2 {
-			actual = appendTag(actual, check.tags[i], check.tags[i+1])
+			actual = appendTag(actual, check.tags[i], check.tags[i+1], nil)
 		}
 		if len(check.expected) != len(actual) {
 			t.Errorf("case %d: unexpected tag array %#v != %#v", i, actual, check.expected)
@@ -94,7 +94,7 @@ func TestTagsArrayEncoding(t *testing.T) {
 				t.Errorf("case %d: encoded string %d does not match array %#v != %#v", i, j, actual[j], check.expected[j])
 			}
 		}
-		actualTags := tagsFromArray(actual)
+		actualTags := tagsFromArray(actual, nil)
 		expectedTags := make(osm.Tags)
 		for i := 0; i < len(check.tags); i += 2 {
 			expectedTags[check.tags[i]] = check.tags[i+1]
@@ -106,14 +106,14 @@ func TestTagsArrayEncoding(t *testing.T) {
 }
 
 func TestTagsArrayIssue122Panic(t *testing.T) {
-	tagsFromArray([]string{"foo", "bar"})
+	tagsFromArray([]string{"foo", "bar"}, nil)
 	defer func() {
 		p := recover()
 		if p == nil {
 			t.Fatal("did not panic")
 		}
 	}()
-	tagsFromArray([]string{"foo"})
+	tagsFromArray([]string{"foo"}, nil)
 }
 
 func TestCodePoints(t *testing.T) {
@@ -23,6 +23,9 @@ type coordsCacheOptions struct {
 	BunchCacheCapacity int
 }
 type osmCacheOptions struct {
+	// Backend is the name of the kv store (leveldb or logstore). Existing
+	// caches are opened with their backend if it is empty.
+	Backend     string
 	Coords      coordsCacheOptions
 	Ways        cacheOptions
 	Nodes       cacheOptions
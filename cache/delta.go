@@ -163,6 +163,10 @@ func (c *DeltaCoordsCache) FillWay(way *osm.Way) error {
 	if way == nil {
 		return nil
 	}
+	if len(way.Refs) > 0 && len(way.Nodes) == len(way.Refs) {
+		// already filled, e.g. way was cached with locations from the PBF
+		return nil
+	}
 	way.Nodes = make([]osm.Node, len(way.Refs))
 
 	var err error
@@ -275,13 +279,13 @@ func (c *DeltaCoordsCache) putCoordsPacked(bunchID int64, nodes []osm.Node) erro
 	keyBuf := idToKeyBuf(bunchID)
 
 	if len(nodes) == 0 {
-		return c.db.Delete(c.wo, keyBuf)
+		return c.db.Delete(keyBuf)
 	}
 
 	data := make([]byte, 512)
 	data = binary.MarshalDeltaNodes(nodes, data)
 
-	err := c.db.Put(c.wo, keyBuf, data)
+	err := c.db.Put(keyBuf, data)
 	if err != nil {
 		return err
 	}
@@ -292,7 +296,7 @@ func (c *DeltaCoordsCache) putCoordsPacked(bunchID int64, nodes []osm.Node) erro
 func (c *DeltaCoordsCache) getCoordsPacked(bunchID int64, nodes []osm.Node) ([]osm.Node, error) {
 	keyBuf := idToKeyBuf(bunchID)
 
-	data, err := c.db.Get(c.ro, keyBuf)
+	data, err := c.db.Get(keyBuf)
 	if err != nil {
 		return nil, err
 	}
@@ -308,6 +312,24 @@ func (c *DeltaCoordsCache) getCoordsPacked(bunchID int64, nodes []osm.Node) ([]o
 	return nodes, nil
 }
 
+func (c *DeltaCoordsCache) Iter() chan []osm.Node {
+	coords := make(chan []osm.Node, 8)
+	go func() {
+		it := c.db.NewIterator()
+		// close iterator before closing the chan, see WaysCache.Iter
+		defer close(coords)
+		defer it.Close()
+		for it.SeekToFirst(); it.Valid(); it.Next() {
+			nodes, err := binary.UnmarshalDeltaNodes(it.Value(), nil)
+			if err != nil {
+				panic(err)
+			}
+			coords <- nodes
+		}
+	}()
+	return coords
+}
+
 func (c *DeltaCoordsCache) getBunchID(nodeID int64) int64 {
 	return nodeID / c.bunchSize
 }
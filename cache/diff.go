@@ -7,8 +7,6 @@ import (
 	"sort"
 	"sync"
 
-	"github.com/jmhodges/levigo"
-
 	osm "github.com/omniscale/go-osm"
 	"github.com/omniscale/imposm3/cache/binary"
 	"github.com/omniscale/imposm3/element"
@@ -56,6 +54,14 @@ func (c *DiffCache) Flush() {
 }
 
 func (c *DiffCache) Open() error {
+	// the manifest is shared with the OSMCache in the same directory
+	exists := c.Exists() || NewOSMCache(c.Dir).Exists()
+	if err := os.MkdirAll(c.Dir, 0755); err != nil {
+		return err
+	}
+	if _, err := openManifest(c.Dir, exists); err != nil {
+		return err
+	}
 	var err error
 	c.Coords, err = newCoordsRefIndex(filepath.Join(c.Dir, "coords_index"))
 	if err != nil {
@@ -268,7 +274,7 @@ func (index *bunchRefCache) Get(id int64) []int64 {
 	}
 	keyBuf := idToKeyBuf(index.getBunchID(id))
 
-	data, err := index.db.Get(index.ro, keyBuf)
+	data, err := index.db.Get(keyBuf)
 	if err != nil {
 		panic(err)
 	}
@@ -288,7 +294,7 @@ func (index *bunchRefCache) Get(id int64) []int64 {
 func (index *bunchRefCache) Add(id, ref int64) error {
 	keyBuf := idToKeyBuf(index.getBunchID(id))
 
-	data, err := index.db.Get(index.ro, keyBuf)
+	data, err := index.db.Get(keyBuf)
 	if err != nil {
 		return err
 	}
@@ -308,7 +314,7 @@ func (index *bunchRefCache) Add(id, ref int64) error {
 	defer bytePool.release(data)
 	data = binary.MarshalIDRefsBunch2(idRefBunch.idRefs, data)
 
-	return index.db.Put(index.wo, keyBuf, data)
+	return index.db.Put(keyBuf, data)
 }
 
 func (index *bunchRefCache) DeleteRef(id, ref int64) error {
@@ -318,7 +324,7 @@ func (index *bunchRefCache) DeleteRef(id, ref int64) error {
 
 	keyBuf := idToKeyBuf(index.getBunchID(id))
 
-	data, err := index.db.Get(index.ro, keyBuf)
+	data, err := index.db.Get(keyBuf)
 	if err != nil {
 		return err
 	}
@@ -334,7 +340,7 @@ func (index *bunchRefCache) DeleteRef(id, ref int64) error {
 			data := bytePool.get()
 			defer bytePool.release(data)
 			data = binary.MarshalIDRefsBunch2(idRefs, data)
-			return index.db.Put(index.wo, keyBuf, data)
+			return index.db.Put(keyBuf, data)
 		}
 	}
 	return nil
@@ -347,7 +353,7 @@ func (index *bunchRefCache) Delete(id int64) error {
 
 	keyBuf := idToKeyBuf(index.getBunchID(id))
 
-	data, err := index.db.Get(index.ro, keyBuf)
+	data, err := index.db.Get(keyBuf)
 	if err != nil {
 		return err
 	}
@@ -363,7 +369,7 @@ func (index *bunchRefCache) Delete(id int64) error {
 			data := bytePool.get()
 			defer bytePool.release(data)
 			data = binary.MarshalIDRefsBunch2(idRefs, data)
-			return index.db.Put(index.wo, keyBuf, data)
+			return index.db.Put(keyBuf, data)
 		}
 	}
 	return nil
@@ -476,7 +482,7 @@ type writeBunchItem struct {
 }
 
 func (index *bunchRefCache) writeRefs(idRefs idRefBunches) error {
-	batch := levigo.NewWriteBatch()
+	batch := index.db.NewBatch()
 	defer batch.Close()
 
 	wg := sync.WaitGroup{}
@@ -519,7 +525,7 @@ func (index *bunchRefCache) writeRefs(idRefs idRefBunches) error {
 		case idRefBunchesPool <- idRefs:
 		}
 	}()
-	return index.db.Write(index.wo, batch)
+	return index.db.Write(batch)
 }
 
 func mergeBunch(bunch, newBunch []element.IDRefs) []element.IDRefs {
@@ -566,7 +572,7 @@ NextIDRef:
 // loadMergeMarshal loads an existing bunch, merges the IDRefs and
 // marshals the result again.
 func (index *bunchRefCache) loadMergeMarshal(keyBuf []byte, newBunch []element.IDRefs) []byte {
-	data, err := index.db.Get(index.ro, keyBuf)
+	data, err := index.db.Get(keyBuf)
 	if err != nil {
 		panic(err)
 	}
@@ -0,0 +1,463 @@
+/*
+Package export writes the contents of an OSM cache into a PBF file.
+
+The cache only contains the tags that are required by the mapping of the
+import. Metadata (version, timestamp, etc.) is not exported, even if the
+cache contains it.
+*/
+package export
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	osm "github.com/omniscale/go-osm"
+	"github.com/omniscale/imposm3/cache"
+)
+
+// Filter returns true if a node at this location should be exported.
+type Filter func(long, lat float64) bool
+
+// BBoxFilter returns a filter for all nodes within the bounding box.
+func BBoxFilter(minx, miny, maxx, maxy float64) Filter {
+	return func(long, lat float64) bool {
+		return long >= minx && long <= maxx && lat >= miny && lat <= maxy
+	}
+}
+
+// ParseBBox parses a bounding box in the form of minx,miny,maxx,maxy.
+func ParseBBox(s string) ([4]float64, error) {
+	var bbox [4]float64
+	parts := strings.Split(s, ",")
+	if len(parts) != 4 {
+		return bbox, fmt.Errorf("invalid bbox %q, expected minx,miny,maxx,maxy", s)
+	}
+	for i, p := range parts {
+		v, err := strconv.ParseFloat(strings.TrimSpace(p), 64)
+		if err != nil {
+			return bbox, fmt.Errorf("invalid bbox %q: %w", s, err)
+		}
+		bbox[i] = v
+	}
+	if bbox[0] > bbox[2] || bbox[1] > bbox[3] {
+		return bbox, fmt.Errorf("invalid bbox %q, min is larger then max", s)
+	}
+	return bbox, nil
+}
+
+// Options for Export.
+type Options struct {
+	// Filter for the exported nodes. All elements are exported if nil.
+	Filter Filter
+	// Header is the replication state written into the file header.
+	Header Header
+	// Program is the name of the writing program in the file header.
+	Program string
+	// TempDir is the directory for temporary files. Uses the default
+	// directory for temporary files if empty.
+	TempDir string
+}
+
+// Stats are the number of exported elements.
+type Stats struct {
+	Nodes     int64
+	Ways      int64
+	Relations int64
+}
+
+// Export writes all nodes, ways and relations of the cache as a PBF into w.
+//
+// Nodes include the locations that are stored with the ways of caches that
+// were imported from PBF files with locations on ways.
+//
+// Elements are written in the order of the cache keys: by type and ID, with
+// negative IDs after all positive IDs.
+//
+// With a Filter, Export writes all nodes that match the filter, all ways
+// with at least one of these nodes and all relations with at least one
+// exported node, way or relation as member. The export is referentially
+// complete: Exported ways include all their nodes and exported relations
+// include all their member nodes and ways (with all their nodes), even if
+// the nodes do not match the filter. Relation members that are not
+// exported, e.g. relations that do not have any member within the filter,
+// are removed from the exported relations.
+func Export(osmCache *cache.OSMCache, w io.Writer, opts Options) (Stats, error) {
+	wayCoords, err := wayLocations(osmCache, opts.TempDir)
+	if err != nil {
+		return Stats{}, fmt.Errorf("collecting locations of ways: %w", err)
+	}
+	if wayCoords != nil {
+		defer wayCoords.Remove()
+	}
+
+	var sel *selection
+	if opts.Filter != nil {
+		sel, err = selectElements(osmCache, wayCoords, opts.Filter)
+		if err != nil {
+			return Stats{}, err
+		}
+	}
+
+	pw, err := newPBFWriter(w, opts.Program, opts.Header)
+	if err != nil {
+		return Stats{}, err
+	}
+
+	var stats Stats
+	err = iterNodes(osmCache, wayCoords, func(nd *osm.Node) error {
+		if sel != nil {
+			if !sel.nodes.Has(nd.ID) && !sel.wayNodes.Has(nd.ID) {
+				return nil
+			}
+			sel.exportedNodes.Add(nd.ID)
+		}
+		stats.Nodes++
+		return pw.WriteNode(nd)
+	})
+	if err != nil {
+		return stats, fmt.Errorf("exporting nodes: %w", err)
+	}
+
+	ways := osmCache.Ways.Iter()
+	for way := range ways {
+		if sel != nil {
+			if !sel.ways.Has(way.ID) {
+				continue
+			}
+			sel.exportedWays.Add(way.ID)
+		}
+		stats.Ways++
+		if err := pw.WriteWay(way); err != nil {
+			drainWays(ways)
+			return stats, fmt.Errorf("exporting ways: %w", err)
+		}
+	}
+
+	rels := osmCache.Relations.Iter()
+	for rel := range rels {
+		if sel != nil {
+			if !sel.relations.Has(rel.ID) {
+				continue
+			}
+			rel.Members = sel.exportedMembers(rel.Members)
+		}
+		stats.Relations++
+		if err := pw.WriteRelation(rel); err != nil {
+			drainRelations(rels)
+			return stats, fmt.Errorf("exporting relations: %w", err)
+		}
+	}
+
+	return stats, pw.Close()
+}
+
+// wayCache is a temporary cache for the locations of ways.
+type wayCache struct {
+	*cache.OSMCache
+	dir string
+}
+
+func (c *wayCache) Remove() {
+	c.Close()
+	os.RemoveAll(c.dir)
+}
+
+// wayLocations returns a temporary cache with the coords of all ways that
+// were cached with their node locations (from PBF files with locations on
+// ways). The coords cache of these caches does not contain the nodes of the
+// ways. Returns nil if no way contains locations.
+func wayLocations(osmCache *cache.OSMCache, tempDir string) (*wayCache, error) {
+	var c *wayCache
+	ways := osmCache.Ways.Iter()
+	defer drainWays(ways)
+	for way := range ways {
+		if len(way.Refs) == 0 || len(way.Nodes) != len(way.Refs) {
+			continue
+		}
+		if c == nil {
+			dir, err := ioutil.TempDir(tempDir, "imposm-export")
+			if err != nil {
+				return nil, err
+			}
+			c = &wayCache{OSMCache: cache.NewOSMCache(dir), dir: dir}
+			if err := c.Open(); err != nil {
+				os.RemoveAll(dir)
+				return nil, err
+			}
+		}
+		if err := c.Coords.PutCoords(way.Nodes); err != nil {
+			c.Remove()
+			return nil, err
+		}
+	}
+	if c == nil {
+		return nil, nil
+	}
+	if err := c.Coords.Flush(); err != nil {
+		c.Remove()
+		return nil, err
+	}
+	return c, nil
+}
+
+// keyLess returns true if a is before b in the order of the cache keys.
+// Keys are big-endian uint64, negative IDs are sorted after all positive
+// IDs.
+func keyLess(a, b int64) bool {
+	return uint64(a) < uint64(b)
+}
+
+// coordsStream returns the coords of a coords iterator one by one, in the
+// order of the cache keys. The DeltaCoordsCache stores small negative IDs in
+// the same bunch as the first positive IDs, all negative IDs are returned
+// after the iterator is done.
+type coordsStream struct {
+	c        chan []osm.Node
+	batch    []osm.Node
+	negative []osm.Node
+	done     bool
+}
+
+// next returns the next coord, or false if the iterator is done. Returns
+// false for a nil stream.
+func (s *coordsStream) next() (*osm.Node, bool) {
+	if s == nil {
+		return nil, false
+	}
+	for {
+		for len(s.batch) == 0 {
+			if s.done {
+				return nil, false
+			}
+			var ok bool
+			s.batch, ok = <-s.c
+			if !ok {
+				s.done = true
+				sort.Slice(s.negative, func(i, j int) bool {
+					return keyLess(s.negative[i].ID, s.negative[j].ID)
+				})
+				s.batch, s.negative = s.negative, nil
+			}
+		}
+		nd := &s.batch[0]
+		s.batch = s.batch[1:]
+		if nd.ID < 0 && !s.done {
+			s.negative = append(s.negative, *nd)
+			continue
+		}
+		return nd, true
+	}
+}
+
+// iterNodes calls fn for all nodes, in the order of the cache keys (see
+// keyLess). It merges the coords and the coords of wayCoords (optional)
+// with the tagged nodes of the nodes cache. Each node is only returned once.
+func iterNodes(osmCache *cache.OSMCache, wayCoords *wayCache, fn func(*osm.Node) error) error {
+	coords := &coordsStream{c: osmCache.Coords.Iter()}
+	defer drainCoords(coords.c)
+	var extra *coordsStream
+	if wayCoords != nil {
+		extra = &coordsStream{c: wayCoords.Coords.Iter()}
+		defer drainCoords(extra.c)
+	}
+	nodes := osmCache.Nodes.Iter()
+	defer drainNodes(nodes)
+
+	nd, ndOk := coords.next()
+	wnd, wndOk := extra.next()
+	tagged, taggedOk := <-nodes
+	for ndOk || wndOk || taggedOk {
+		var id int64
+		found := false
+		for _, c := range []struct {
+			nd *osm.Node
+			ok bool
+		}{{nd, ndOk}, {wnd, wndOk}, {tagged, taggedOk}} {
+			if c.ok && (!found || keyLess(c.nd.ID, id)) {
+				id = c.nd.ID
+				found = true
+			}
+		}
+
+		// prefer tagged nodes, they contain the tags and metadata
+		var next *osm.Node
+		if taggedOk && tagged.ID == id {
+			next = tagged
+			tagged, taggedOk = <-nodes
+		}
+		if ndOk && nd.ID == id {
+			if next == nil {
+				next = nd
+			}
+			nd, ndOk = coords.next()
+		}
+		if wndOk && wnd.ID == id {
+			if next == nil {
+				next = wnd
+			}
+			wnd, wndOk = extra.next()
+		}
+		if err := fn(next); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// selection contains the IDs of all elements that are exported.
+type selection struct {
+	// nodes that match the filter
+	nodes cache.IDSet
+	// nodes of exported ways and member nodes of exported relations
+	wayNodes  cache.IDSet
+	ways      cache.IDSet
+	relations cache.IDSet
+	// all nodes and ways that were exported, as some selected members can
+	// be missing in the cache
+	exportedNodes cache.IDSet
+	exportedWays  cache.IDSet
+}
+
+// exportedMembers returns all members that are exported.
+func (sel *selection) exportedMembers(members []osm.Member) []osm.Member {
+	result := make([]osm.Member, 0, len(members))
+	for _, m := range members {
+		var exported bool
+		switch m.Type {
+		case osm.NodeMember:
+			exported = sel.exportedNodes.Has(m.ID)
+		case osm.WayMember:
+			exported = sel.exportedWays.Has(m.ID)
+		case osm.RelationMember:
+			exported = sel.relations.Has(m.ID)
+		}
+		if exported {
+			result = append(result, m)
+		}
+	}
+	return result
+}
+
+func selectElements(osmCache *cache.OSMCache, wayCoords *wayCache, filter Filter) (*selection, error) {
+	sel := &selection{
+		nodes:         make(cache.IDSet),
+		wayNodes:      make(cache.IDSet),
+		ways:          make(cache.IDSet),
+		relations:     make(cache.IDSet),
+		exportedNodes: make(cache.IDSet),
+		exportedWays:  make(cache.IDSet),
+	}
+
+	err := iterNodes(osmCache, wayCoords, func(nd *osm.Node) error {
+		if filter(nd.Long, nd.Lat) {
+			sel.nodes.Add(nd.ID)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for way := range osmCache.Ways.Iter() {
+		for _, ref := range way.Refs {
+			if sel.nodes.Has(ref) {
+				sel.ways.Add(way.ID)
+				for _, ref := range way.Refs {
+					sel.wayNodes.Add(ref)
+				}
+				break
+			}
+		}
+	}
+
+	// parent relations of each relation, to add relations that contain
+	// exported relations
+	parents := make(map[int64][]int64)
+	for rel := range osmCache.Relations.Iter() {
+		for _, m := range rel.Members {
+			switch m.Type {
+			case osm.NodeMember:
+				if sel.nodes.Has(m.ID) || sel.wayNodes.Has(m.ID) {
+					sel.relations.Add(rel.ID)
+				}
+			case osm.WayMember:
+				if sel.ways.Has(m.ID) {
+					sel.relations.Add(rel.ID)
+				}
+			case osm.RelationMember:
+				parents[m.ID] = append(parents[m.ID], rel.ID)
+			}
+		}
+	}
+	var queue []int64
+	for id := range parents {
+		if sel.relations.Has(id) {
+			queue = append(queue, id)
+		}
+	}
+	for len(queue) > 0 {
+		id := queue[len(queue)-1]
+		queue = queue[:len(queue)-1]
+		for _, parent := range parents[id] {
+			if !sel.relations.Has(parent) {
+				sel.relations.Add(parent)
+				queue = append(queue, parent)
+			}
+		}
+	}
+
+	// add member nodes and ways of the selected relations
+	memberWays := make(cache.IDSet)
+	for rel := range osmCache.Relations.Iter() {
+		if !sel.relations.Has(rel.ID) {
+			continue
+		}
+		for _, m := range rel.Members {
+			switch m.Type {
+			case osm.NodeMember:
+				sel.wayNodes.Add(m.ID)
+			case osm.WayMember:
+				if !sel.ways.Has(m.ID) {
+					memberWays.Add(m.ID)
+				}
+			}
+		}
+	}
+	if len(memberWays) > 0 {
+		for way := range osmCache.Ways.Iter() {
+			if !memberWays.Has(way.ID) {
+				continue
+			}
+			sel.ways.Add(way.ID)
+			for _, ref := range way.Refs {
+				sel.wayNodes.Add(ref)
+			}
+		}
+	}
+	return sel, nil
+}
+
+func drainCoords(c chan []osm.Node) {
+	for range c {
+	}
+}
+
+func drainNodes(c chan *osm.Node) {
+	for range c {
+	}
+}
+
+func drainWays(c chan *osm.Way) {
+	for range c {
+	}
+}
+
+func drainRelations(c chan *osm.Relation) {
+	for range c {
+	}
+}
@@ -0,0 +1,449 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	osm "github.com/omniscale/go-osm"
+	"github.com/omniscale/go-osm/parser/pbf"
+	"github.com/omniscale/imposm3/cache"
+)
+
+type parsed struct {
+	header    *pbf.Header
+	nodes     []osm.Node
+	ways      []osm.Way
+	relations []osm.Relation
+}
+
+func parsePBF(t *testing.T, data []byte) parsed {
+	t.Helper()
+	nodes := make(chan []osm.Node)
+	ways := make(chan []osm.Way)
+	relations := make(chan []osm.Relation)
+	p := pbf.New(bytes.NewReader(data), pbf.Config{
+		Nodes:       nodes,
+		Ways:        ways,
+		Relations:   relations,
+		Concurrency: 1,
+	})
+	var result parsed
+	var err error
+	result.header, err = p.Header()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		for nodes != nil || ways != nil || relations != nil {
+			select {
+			case nds, ok := <-nodes:
+				if !ok {
+					nodes = nil
+				}
+				result.nodes = append(result.nodes, nds...)
+			case ws, ok := <-ways:
+				if !ok {
+					ways = nil
+				}
+				result.ways = append(result.ways, ws...)
+			case rels, ok := <-relations:
+				if !ok {
+					relations = nil
+				}
+				result.relations = append(result.relations, rels...)
+			}
+		}
+		close(done)
+	}()
+	if err := p.Parse(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	<-done
+	return result
+}
+
+func nodeIDs(nodes []osm.Node) []int64 {
+	var ids []int64
+	for _, n := range nodes {
+		ids = append(ids, n.ID)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+func wayIDs(ways []osm.Way) []int64 {
+	var ids []int64
+	for _, w := range ways {
+		ids = append(ids, w.ID)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+func relIDs(rels []osm.Relation) []int64 {
+	var ids []int64
+	for _, r := range rels {
+		ids = append(ids, r.ID)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+func node(id int64, long, lat float64, tags osm.Tags) osm.Node {
+	return osm.Node{Element: osm.Element{ID: id, Tags: tags}, Long: long, Lat: lat}
+}
+
+// createCache creates a cache with nodes 1-10 along the equator (long 1-10),
+// way 100 with nodes 1-3, way 101 with nodes 8-10, way 102 with nodes
+// 3-8, relation 200 with way 100, relation 201 with node 9 and relation 202
+// with relation 201.
+func createCache(t *testing.T, dir string) *cache.OSMCache {
+	t.Helper()
+	osmCache := cache.NewOSMCache(dir)
+	if err := osmCache.Open(); err != nil {
+		t.Fatal(err)
+	}
+	var nodes []osm.Node
+	for i := int64(1); i <= 10; i++ {
+		nodes = append(nodes, node(i, float64(i), 0, nil))
+	}
+	if err := osmCache.Coords.PutCoords(nodes); err != nil {
+		t.Fatal(err)
+	}
+	if err := osmCache.Coords.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	tagged := []osm.Node{
+		node(5, 5, 0, osm.Tags{"amenity": "cafe", "name": "Café"}),
+		node(9, 9, 0, osm.Tags{"highway": "bus_stop"}),
+	}
+	if _, err := osmCache.Nodes.PutNodes(tagged); err != nil {
+		t.Fatal(err)
+	}
+	ways := []osm.Way{
+		{Element: osm.Element{ID: 100, Tags: osm.Tags{"highway": "residential"}}, Refs: []int64{1, 2, 3}},
+		{Element: osm.Element{ID: 101, Tags: osm.Tags{"highway": "track"}}, Refs: []int64{10, 9, 8}},
+		{Element: osm.Element{ID: 102, Tags: osm.Tags{"building": "yes"}}, Refs: []int64{3, 4, 5, 6, 7, 8, 3}},
+	}
+	if err := osmCache.Ways.PutWays(ways); err != nil {
+		t.Fatal(err)
+	}
+	rels := []osm.Relation{
+		{Element: osm.Element{ID: 200, Tags: osm.Tags{"type": "route"}}, Members: []osm.Member{
+			{ID: 100, Type: osm.WayMember, Role: "forward"},
+		}},
+		{Element: osm.Element{ID: 201, Tags: osm.Tags{"type": "route"}}, Members: []osm.Member{
+			{ID: 9, Type: osm.NodeMember, Role: "stop"},
+		}},
+		{Element: osm.Element{ID: 202, Tags: osm.Tags{"type": "route_master"}}, Members: []osm.Member{
+			{ID: 201, Type: osm.RelationMember},
+		}},
+	}
+	if err := osmCache.Relations.PutRelations(rels); err != nil {
+		t.Fatal(err)
+	}
+	return osmCache
+}
+
+func TestExport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "imposm_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	osmCache := createCache(t, dir)
+	defer osmCache.Close()
+
+	buf := &bytes.Buffer{}
+	header := Header{Time: time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC), Sequence: 1234}
+	stats, err := Export(osmCache, buf, Options{Header: header, Program: "imposm test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats != (Stats{Nodes: 10, Ways: 3, Relations: 3}) {
+		t.Error("unexpected stats", stats)
+	}
+
+	result := parsePBF(t, buf.Bytes())
+	if !result.header.Time.Equal(header.Time) || result.header.Sequence != 1234 {
+		t.Error("unexpected header", result.header)
+	}
+
+	if ids := nodeIDs(result.nodes); !reflect.DeepEqual(ids, []int64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}) {
+		t.Error("unexpected nodes", ids)
+	}
+	for _, n := range result.nodes {
+		if math.Abs(n.Long-float64(n.ID)) > 1e-7 || math.Abs(n.Lat) > 1e-7 {
+			t.Errorf("unexpected coord for node %d: %f %f", n.ID, n.Long, n.Lat)
+		}
+		switch n.ID {
+		case 5:
+			if !reflect.DeepEqual(n.Tags, osm.Tags{"amenity": "cafe", "name": "Café"}) {
+				t.Error("unexpected tags", n.Tags)
+			}
+		case 9:
+			if !reflect.DeepEqual(n.Tags, osm.Tags{"highway": "bus_stop"}) {
+				t.Error("unexpected tags", n.Tags)
+			}
+		default:
+			if len(n.Tags) != 0 {
+				t.Errorf("unexpected tags for node %d: %v", n.ID, n.Tags)
+			}
+		}
+	}
+
+	if ids := wayIDs(result.ways); !reflect.DeepEqual(ids, []int64{100, 101, 102}) {
+		t.Error("unexpected ways", ids)
+	}
+	for _, w := range result.ways {
+		if w.ID == 101 {
+			if !reflect.DeepEqual(w.Refs, []int64{10, 9, 8}) || w.Tags["highway"] != "track" {
+				t.Error("unexpected way", w)
+			}
+		}
+	}
+
+	if ids := relIDs(result.relations); !reflect.DeepEqual(ids, []int64{200, 201, 202}) {
+		t.Error("unexpected relations", ids)
+	}
+	for _, r := range result.relations {
+		if r.ID == 201 {
+			expected := []osm.Member{{ID: 9, Type: osm.NodeMember, Role: "stop"}}
+			if !reflect.DeepEqual(r.Members, expected) {
+				t.Error("unexpected members", r.Members)
+			}
+		}
+	}
+}
+
+func TestExportFilter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "imposm_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	osmCache := createCache(t, dir)
+	defer osmCache.Close()
+
+	for _, tc := range []struct {
+		name      string
+		filter    Filter
+		nodes     []int64
+		ways      []int64
+		relations []int64
+	}{
+		{"none", BBoxFilter(20, -1, 30, 1), nil, nil, nil},
+		// way 100 and relation 200
+		{"node 2", BBoxFilter(1.5, -1, 2.5, 1), []int64{1, 2, 3}, []int64{100}, []int64{200}},
+		// way 100 and 102 (shares node 3), relation 200
+		{"node 3", BBoxFilter(2.5, -1, 3.5, 1), []int64{1, 2, 3, 4, 5, 6, 7, 8}, []int64{100, 102}, []int64{200}},
+		// way 101, relation 201 with node 9 and parent relation 202
+		{"node 9", BBoxFilter(8.5, -1, 9.5, 1), []int64{8, 9, 10}, []int64{101}, []int64{201, 202}},
+		// relation 201 with node 9 as node of way 101
+		{"node 10", BBoxFilter(9.5, -1, 10.5, 1), []int64{8, 9, 10}, []int64{101}, []int64{201, 202}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			stats, err := Export(osmCache, buf, Options{Filter: tc.filter})
+			if err != nil {
+				t.Fatal(err)
+			}
+			result := parsePBF(t, buf.Bytes())
+			if ids := nodeIDs(result.nodes); !reflect.DeepEqual(ids, tc.nodes) {
+				t.Error("unexpected nodes", ids)
+			}
+			if ids := wayIDs(result.ways); !reflect.DeepEqual(ids, tc.ways) {
+				t.Error("unexpected ways", ids)
+			}
+			if ids := relIDs(result.relations); !reflect.DeepEqual(ids, tc.relations) {
+				t.Error("unexpected relations", ids)
+			}
+			if stats.Nodes != int64(len(tc.nodes)) || stats.Ways != int64(len(tc.ways)) || stats.Relations != int64(len(tc.relations)) {
+				t.Error("unexpected stats", stats)
+			}
+		})
+	}
+}
+
+func TestExportCompleteRelations(t *testing.T) {
+	dir, err := ioutil.TempDir("", "imposm_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	osmCache := createCache(t, dir)
+	defer osmCache.Close()
+	// relation with node 2 and way 101 far away, and a missing relation
+	rels := []osm.Relation{
+		{Element: osm.Element{ID: 203, Tags: osm.Tags{"type": "site"}}, Members: []osm.Member{
+			{ID: 2, Type: osm.NodeMember, Role: "entrance"},
+			{ID: 101, Type: osm.WayMember},
+			{ID: 299, Type: osm.RelationMember},
+			{ID: 1234, Type: osm.NodeMember},
+		}},
+	}
+	if err := osmCache.Relations.PutRelations(rels); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := &bytes.Buffer{}
+	if _, err := Export(osmCache, buf, Options{Filter: BBoxFilter(1.5, -1, 2.5, 1)}); err != nil {
+		t.Fatal(err)
+	}
+	result := parsePBF(t, buf.Bytes())
+	if ids := nodeIDs(result.nodes); !reflect.DeepEqual(ids, []int64{1, 2, 3, 8, 9, 10}) {
+		t.Error("unexpected nodes", ids)
+	}
+	if ids := wayIDs(result.ways); !reflect.DeepEqual(ids, []int64{100, 101}) {
+		t.Error("unexpected ways", ids)
+	}
+	if ids := relIDs(result.relations); !reflect.DeepEqual(ids, []int64{200, 203}) {
+		t.Error("unexpected relations", ids)
+	}
+	for _, r := range result.relations {
+		if r.ID == 203 {
+			expected := []osm.Member{
+				{ID: 2, Type: osm.NodeMember, Role: "entrance"},
+				{ID: 101, Type: osm.WayMember},
+			}
+			if !reflect.DeepEqual(r.Members, expected) {
+				t.Error("unexpected members", r.Members)
+			}
+		}
+	}
+}
+
+func TestExportLocationsOnWays(t *testing.T) {
+	dir, err := ioutil.TempDir("", "imposm_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// cache without coords, as imported from a PBF with locations on ways
+	osmCache := cache.NewOSMCache(dir)
+	if err := osmCache.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer osmCache.Close()
+	tagged := []osm.Node{node(2, 2, 0, osm.Tags{"amenity": "cafe"})}
+	if _, err := osmCache.Nodes.PutNodes(tagged); err != nil {
+		t.Fatal(err)
+	}
+	ways := []osm.Way{
+		{Element: osm.Element{ID: 100}, Refs: []int64{1, 2, 3},
+			Nodes: []osm.Node{node(1, 1, 0, nil), node(2, 2, 0, nil), node(3, 3, 0, nil)}},
+		{Element: osm.Element{ID: 101}, Refs: []int64{3, 10},
+			Nodes: []osm.Node{node(3, 3, 0, nil), node(10, 10, 0, nil)}},
+	}
+	if err := osmCache.Ways.PutWays(ways); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name   string
+		filter Filter
+		nodes  []int64
+		ways   []int64
+	}{
+		{"all", nil, []int64{1, 2, 3, 10}, []int64{100, 101}},
+		{"node 10", BBoxFilter(9.5, -1, 10.5, 1), []int64{3, 10}, []int64{101}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			if _, err := Export(osmCache, buf, Options{Filter: tc.filter, TempDir: dir}); err != nil {
+				t.Fatal(err)
+			}
+			result := parsePBF(t, buf.Bytes())
+			if ids := nodeIDs(result.nodes); !reflect.DeepEqual(ids, tc.nodes) {
+				t.Error("unexpected nodes", ids)
+			}
+			if ids := wayIDs(result.ways); !reflect.DeepEqual(ids, tc.ways) {
+				t.Error("unexpected ways", ids)
+			}
+			for _, n := range result.nodes {
+				if math.Abs(n.Long-float64(n.ID)) > 1e-7 {
+					t.Errorf("unexpected coord for node %d: %f", n.ID, n.Long)
+				}
+				if n.ID == 2 && n.Tags["amenity"] != "cafe" {
+					t.Error("missing tags", n)
+				}
+			}
+		})
+	}
+	// temporary caches are removed
+	if tmp, _ := filepath.Glob(filepath.Join(dir, "imposm-export*")); len(tmp) != 0 {
+		t.Error("temporary cache not removed", tmp)
+	}
+}
+
+func TestExportNegativeIDs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "imposm_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	osmCache := cache.NewOSMCache(dir)
+	if err := osmCache.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer osmCache.Close()
+
+	// -100 is in another coords bunch, -2 in the same bunch as 1 and 2
+	coords := []osm.Node{node(-100, 1, 0, nil), node(-2, 2, 0, nil), node(1, 3, 0, nil), node(2, 4, 0, nil)}
+	if err := osmCache.Coords.PutCoords(coords); err != nil {
+		t.Fatal(err)
+	}
+	if err := osmCache.Coords.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := osmCache.Nodes.PutNodes([]osm.Node{node(-100, 1, 0, osm.Tags{"amenity": "cafe"})}); err != nil {
+		t.Fatal(err)
+	}
+	ways := []osm.Way{
+		{Element: osm.Element{ID: -5, Tags: osm.Tags{"highway": "path"}}, Refs: []int64{-100, -2}},
+		{Element: osm.Element{ID: 10, Tags: osm.Tags{"highway": "track"}}, Refs: []int64{1, 2}},
+	}
+	if err := osmCache.Ways.PutWays(ways); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := &bytes.Buffer{}
+	if _, err := Export(osmCache, buf, Options{}); err != nil {
+		t.Fatal(err)
+	}
+	result := parsePBF(t, buf.Bytes())
+
+	// each node once, in the order of the cache keys
+	var ids []int64
+	for _, n := range result.nodes {
+		ids = append(ids, n.ID)
+		if n.ID == -100 && n.Tags["amenity"] != "cafe" {
+			t.Error("unexpected tags", n.Tags)
+		}
+	}
+	if !reflect.DeepEqual(ids, []int64{1, 2, -100, -2}) {
+		t.Error("unexpected nodes", ids)
+	}
+	ids = nil
+	for _, w := range result.ways {
+		ids = append(ids, w.ID)
+	}
+	if !reflect.DeepEqual(ids, []int64{10, -5}) {
+		t.Error("unexpected ways", ids)
+	}
+}
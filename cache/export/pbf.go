@@ -0,0 +1,324 @@
+package export
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"time"
+
+	osm "github.com/omniscale/go-osm"
+)
+
+const (
+	// max number of elements and approx. max size of the uncompressed
+	// data of a single block, recommended by the PBF spec.
+	maxBlockElements = 8000
+	maxBlockSize     = 8 * 1024 * 1024
+
+	// coordinates are stored in units of 100 nanodegrees (the default
+	// granularity)
+	coordFactor = 1e7
+)
+
+// protobuf wire types
+const (
+	wireVarint = 0
+	wireBytes  = 2
+)
+
+func appendTag(b []byte, field int, wireType int) []byte {
+	return binary.AppendUvarint(b, uint64(field)<<3|uint64(wireType))
+}
+
+func appendVarintField(b []byte, field int, v uint64) []byte {
+	b = appendTag(b, field, wireVarint)
+	return binary.AppendUvarint(b, v)
+}
+
+func appendBytesField(b []byte, field int, data []byte) []byte {
+	b = appendTag(b, field, wireBytes)
+	b = binary.AppendUvarint(b, uint64(len(data)))
+	return append(b, data...)
+}
+
+func zigzag(v int64) uint64 {
+	return uint64(v<<1) ^ uint64(v>>63)
+}
+
+// packed collects the values of a packed repeated field.
+type packed []byte
+
+func (p *packed) uvarint(v uint64) { *p = binary.AppendUvarint(*p, v) }
+func (p *packed) sint(v int64)     { *p = binary.AppendUvarint(*p, zigzag(v)) }
+
+func appendPacked(b []byte, field int, p packed) []byte {
+	if len(p) == 0 {
+		return b
+	}
+	return appendBytesField(b, field, p)
+}
+
+type blockType int
+
+const (
+	noBlock blockType = iota
+	nodeBlock
+	wayBlock
+	relationBlock
+)
+
+// Header contains the optional replication state of the exported data.
+type Header struct {
+	Time     time.Time
+	Sequence int
+	URL      string
+}
+
+// pbfWriter writes OSM elements into a PBF file. Elements need to be
+// written sorted by type (nodes, ways, relations) and ID.
+type pbfWriter struct {
+	w       io.Writer
+	program string
+
+	typ     blockType
+	count   int
+	strings map[string]uint32
+	table   [][]byte
+	// encoded elements of the current block
+	group []byte
+
+	// dense node columns
+	ids, lats, lons, keysVals packed
+	lastID, lastLat, lastLon  int64
+	hasTags                   bool
+
+	zbuf bytes.Buffer
+}
+
+func newPBFWriter(w io.Writer, program string, header Header) (*pbfWriter, error) {
+	pw := &pbfWriter{w: w, program: program}
+	pw.reset()
+	if err := pw.writeHeader(header); err != nil {
+		return nil, err
+	}
+	return pw, nil
+}
+
+func (pw *pbfWriter) reset() {
+	pw.typ = noBlock
+	pw.count = 0
+	pw.strings = make(map[string]uint32)
+	// string 0 is reserved as delimiter
+	pw.table = [][]byte{{}}
+	pw.group = pw.group[:0]
+	pw.ids, pw.lats, pw.lons, pw.keysVals = pw.ids[:0], pw.lats[:0], pw.lons[:0], pw.keysVals[:0]
+	pw.lastID, pw.lastLat, pw.lastLon = 0, 0, 0
+	pw.hasTags = false
+}
+
+func (pw *pbfWriter) writeHeader(header Header) error {
+	var b []byte
+	b = appendBytesField(b, 4, []byte("OsmSchema-V0.6"))
+	b = appendBytesField(b, 4, []byte("DenseNodes"))
+	// negative IDs are written after the positive IDs of each type
+	b = appendBytesField(b, 5, []byte("Sort.Type_then_ID"))
+	b = appendBytesField(b, 16, []byte(pw.program))
+	if !header.Time.IsZero() {
+		b = appendVarintField(b, 32, uint64(header.Time.Unix()))
+	}
+	if header.Sequence > 0 {
+		b = appendVarintField(b, 33, uint64(header.Sequence))
+	}
+	if header.URL != "" {
+		b = appendBytesField(b, 34, []byte(header.URL))
+	}
+	return pw.writeBlob("OSMHeader", b)
+}
+
+// writeBlob writes a zlib compressed blob with its header.
+func (pw *pbfWriter) writeBlob(typ string, data []byte) error {
+	pw.zbuf.Reset()
+	zw := zlib.NewWriter(&pw.zbuf)
+	if _, err := zw.Write(data); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	var blob []byte
+	blob = appendVarintField(blob, 2, uint64(len(data)))
+	blob = appendBytesField(blob, 3, pw.zbuf.Bytes())
+
+	var blobHeader []byte
+	blobHeader = appendBytesField(blobHeader, 1, []byte(typ))
+	blobHeader = appendVarintField(blobHeader, 3, uint64(len(blob)))
+
+	var size [4]byte
+	binary.BigEndian.PutUint32(size[:], uint32(len(blobHeader)))
+	if _, err := pw.w.Write(size[:]); err != nil {
+		return err
+	}
+	if _, err := pw.w.Write(blobHeader); err != nil {
+		return err
+	}
+	_, err := pw.w.Write(blob)
+	return err
+}
+
+func (pw *pbfWriter) stringID(s string) uint32 {
+	if id, ok := pw.strings[s]; ok {
+		return id
+	}
+	id := uint32(len(pw.table))
+	pw.strings[s] = id
+	pw.table = append(pw.table, []byte(s))
+	return id
+}
+
+// prepare flushes the current block if it is full or of another type.
+func (pw *pbfWriter) prepare(typ blockType) error {
+	if pw.typ != noBlock && (pw.typ != typ || pw.count >= maxBlockElements || pw.size() >= maxBlockSize) {
+		if err := pw.flush(); err != nil {
+			return err
+		}
+	}
+	pw.typ = typ
+	pw.count++
+	return nil
+}
+
+func (pw *pbfWriter) size() int {
+	return len(pw.group) + len(pw.ids) + len(pw.lats) + len(pw.lons) + len(pw.keysVals)
+}
+
+func coordToInt(c float64) int64 {
+	return int64(math.Round(c * coordFactor))
+}
+
+func (pw *pbfWriter) WriteNode(n *osm.Node) error {
+	if err := pw.prepare(nodeBlock); err != nil {
+		return err
+	}
+	lat, lon := coordToInt(n.Lat), coordToInt(n.Long)
+	pw.ids.sint(n.ID - pw.lastID)
+	pw.lats.sint(lat - pw.lastLat)
+	pw.lons.sint(lon - pw.lastLon)
+	pw.lastID, pw.lastLat, pw.lastLon = n.ID, lat, lon
+	for _, k := range sortedKeys(n.Tags) {
+		pw.keysVals.uvarint(uint64(pw.stringID(k)))
+		pw.keysVals.uvarint(uint64(pw.stringID(n.Tags[k])))
+		pw.hasTags = true
+	}
+	pw.keysVals.uvarint(0)
+	return nil
+}
+
+// sortedKeys returns the keys in sorted order, for reproducible files.
+func sortedKeys(tags osm.Tags) []string {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (pw *pbfWriter) appendTags(b []byte, tags osm.Tags) []byte {
+	var keys, vals packed
+	for _, k := range sortedKeys(tags) {
+		keys.uvarint(uint64(pw.stringID(k)))
+		vals.uvarint(uint64(pw.stringID(tags[k])))
+	}
+	b = appendPacked(b, 2, keys)
+	return appendPacked(b, 3, vals)
+}
+
+func (pw *pbfWriter) WriteWay(w *osm.Way) error {
+	if err := pw.prepare(wayBlock); err != nil {
+		return err
+	}
+	var b []byte
+	b = appendVarintField(b, 1, uint64(w.ID))
+	b = pw.appendTags(b, w.Tags)
+	var refs packed
+	var last int64
+	for _, ref := range w.Refs {
+		refs.sint(ref - last)
+		last = ref
+	}
+	b = appendPacked(b, 8, refs)
+	pw.group = appendBytesField(pw.group, 3, b)
+	return nil
+}
+
+func (pw *pbfWriter) WriteRelation(r *osm.Relation) error {
+	if err := pw.prepare(relationBlock); err != nil {
+		return err
+	}
+	var b []byte
+	b = appendVarintField(b, 1, uint64(r.ID))
+	b = pw.appendTags(b, r.Tags)
+	var roles, memids, types packed
+	var last int64
+	for _, m := range r.Members {
+		roles.uvarint(uint64(pw.stringID(m.Role)))
+		memids.sint(m.ID - last)
+		last = m.ID
+		switch m.Type {
+		case osm.NodeMember:
+			types.uvarint(0)
+		case osm.WayMember:
+			types.uvarint(1)
+		case osm.RelationMember:
+			types.uvarint(2)
+		default:
+			return fmt.Errorf("unknown member type %v of relation %d", m.Type, r.ID)
+		}
+	}
+	b = appendPacked(b, 8, roles)
+	b = appendPacked(b, 9, memids)
+	b = appendPacked(b, 10, types)
+	pw.group = appendBytesField(pw.group, 4, b)
+	return nil
+}
+
+// flush writes the current block.
+func (pw *pbfWriter) flush() error {
+	if pw.typ == noBlock {
+		return nil
+	}
+	group := pw.group
+	if pw.typ == nodeBlock {
+		var dense []byte
+		dense = appendPacked(dense, 1, pw.ids)
+		dense = appendPacked(dense, 8, pw.lats)
+		dense = appendPacked(dense, 9, pw.lons)
+		if pw.hasTags {
+			dense = appendPacked(dense, 10, pw.keysVals)
+		}
+		group = appendBytesField(nil, 2, dense)
+	}
+
+	var table []byte
+	for _, s := range pw.table {
+		table = appendBytesField(table, 1, s)
+	}
+	var block []byte
+	block = appendBytesField(block, 1, table)
+	block = appendBytesField(block, 2, group)
+
+	if err := pw.writeBlob("OSMData", block); err != nil {
+		return err
+	}
+	pw.reset()
+	return nil
+}
+
+// Close writes the last block. It does not close the underlying writer.
+func (pw *pbfWriter) Close() error {
+	return pw.flush()
+}
@@ -0,0 +1,293 @@
+package cache
+
+import (
+	bin "encoding/binary"
+	"fmt"
+	"math/bits"
+	"os"
+	"sync"
+	"sync/atomic"
+	"unsafe"
+
+	osm "github.com/omniscale/go-osm"
+	"github.com/omniscale/imposm3/cache/binary"
+	"github.com/omniscale/imposm3/log"
+)
+
+const flatCoordSize = 8
+
+// flatGrowSize is the step size for growing the file.
+var flatGrowSize int64 = 1024 * 1024 * 1024
+
+// FlatCoordsCache stores all coords in a single memory-mapped file, indexed
+// directly by the node ID. Each coord requires 8 bytes, regardless if a node
+// with this ID exists. The file is sparse on most file systems, but it still
+// requires about 100GB for a planet import. It avoids the random reads of the
+// DeltaCoordsCache for large imports.
+//
+// Coords are stored as CoordToInt+1, so that unused (zero) entries can be
+// distinguished from existing nodes. The longitude is stored in the first
+// and the latitude in the second little-endian uint32.
+//
+// Coords are written under the read lock, as the mapping does not change.
+// Each coord is read and written with a single atomic 64 bit operation, so
+// that concurrent readers (e.g. the query server during diff imports) never
+// see partially written coords.
+//
+// Negative IDs (e.g. from JOSM files) are not supported. Their coords are
+// not stored and a warning is logged once.
+type FlatCoordsCache struct {
+	mu   sync.RWMutex
+	f    *os.File
+	data []byte
+
+	warnNegative sync.Once
+}
+
+func newFlatCoordsCache(path string) (*FlatCoordsCache, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	c := &FlatCoordsCache{f: f}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if err := c.resize(fi.Size()); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// nativeBigEndian is true if the CPU is big-endian, the slots are
+// little-endian on all platforms.
+var nativeBigEndian = bin.NativeEndian.Uint16([]byte{0, 1}) == 1
+
+// loadSlot returns the little-endian uint64 at off. off needs to be a
+// multiple of flatCoordSize, the mapping is page aligned.
+func (c *FlatCoordsCache) loadSlot(off int64) uint64 {
+	v := atomic.LoadUint64((*uint64)(unsafe.Pointer(&c.data[off])))
+	if nativeBigEndian {
+		v = bits.ReverseBytes64(v)
+	}
+	return v
+}
+
+// storeSlot stores v as little-endian uint64 at off. off needs to be a
+// multiple of flatCoordSize.
+func (c *FlatCoordsCache) storeSlot(off int64, v uint64) {
+	if nativeBigEndian {
+		v = bits.ReverseBytes64(v)
+	}
+	atomic.StoreUint64((*uint64)(unsafe.Pointer(&c.data[off])), v)
+}
+
+// resize maps at least size bytes of the file. Requires the write lock.
+func (c *FlatCoordsCache) resize(size int64) error {
+	if size%flatGrowSize != 0 || size == 0 {
+		size = (size/flatGrowSize + 1) * flatGrowSize
+	}
+	if c.data != nil {
+		if err := munmap(c.data); err != nil {
+			return err
+		}
+		c.data = nil
+	}
+	if err := c.f.Truncate(size); err != nil {
+		return err
+	}
+	data, err := mmap(c.f, int(size))
+	if err != nil {
+		return fmt.Errorf("mapping flat coords file: %w", err)
+	}
+	c.data = data
+	return nil
+}
+
+// rlockFor locks the cache for reading and writing coords (the mapping is
+// not changed). It grows the file if id is beyond the current size.
+func (c *FlatCoordsCache) rlockFor(id int64) error {
+	c.mu.RLock()
+	if (id+1)*flatCoordSize <= int64(len(c.data)) {
+		return nil
+	}
+	c.mu.RUnlock()
+
+	c.mu.Lock()
+	if (id+1)*flatCoordSize > int64(len(c.data)) {
+		if err := c.resize((id + 1) * flatCoordSize); err != nil {
+			c.mu.Unlock()
+			return err
+		}
+	}
+	c.mu.Unlock()
+	c.mu.RLock()
+	return nil
+}
+
+// getCoord returns the coord. Requires the read lock.
+func (c *FlatCoordsCache) getCoord(id int64) (*osm.Node, error) {
+	off := id * flatCoordSize
+	if id < 0 || off+flatCoordSize > int64(len(c.data)) {
+		return nil, NotFound
+	}
+	v := c.loadSlot(off)
+	lon, lat := uint32(v), uint32(v>>32)
+	if lon == 0 && lat == 0 {
+		return nil, NotFound
+	}
+	return &osm.Node{
+		Element: osm.Element{ID: id},
+		Long:    binary.IntToCoord(lon - 1),
+		Lat:     binary.IntToCoord(lat - 1),
+	}, nil
+}
+
+func (c *FlatCoordsCache) GetCoord(id int64) (*osm.Node, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.getCoord(id)
+}
+
+// PutCoords puts nodes into cache. Nodes do not need to be sorted, but
+// sorted nodes are faster to write.
+func (c *FlatCoordsCache) PutCoords(nodes []osm.Node) error {
+	var maxID int64 = -1
+	for _, nd := range nodes {
+		if nd.ID > maxID {
+			maxID = nd.ID
+		}
+		if nd.ID < 0 && nd.ID != SKIP {
+			c.warnNegative.Do(func() {
+				log.Printf("[warn] Flat coords cache does not support negative IDs, skipping node %d and all following negative IDs", nd.ID)
+			})
+		}
+	}
+	if maxID < 0 {
+		return nil
+	}
+	if err := c.rlockFor(maxID); err != nil {
+		return err
+	}
+	defer c.mu.RUnlock()
+	for _, nd := range nodes {
+		if nd.ID < 0 {
+			// includes SKIP
+			continue
+		}
+		off := nd.ID * flatCoordSize
+		lon := uint64(binary.CoordToInt(nd.Long) + 1)
+		lat := uint64(binary.CoordToInt(nd.Lat) + 1)
+		c.storeSlot(off, lon|lat<<32)
+	}
+	return nil
+}
+
+func (c *FlatCoordsCache) DeleteCoord(id int64) error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	off := id * flatCoordSize
+	if id < 0 || off+flatCoordSize > int64(len(c.data)) {
+		return nil
+	}
+	c.storeSlot(off, 0)
+	return nil
+}
+
+func (c *FlatCoordsCache) FillWay(way *osm.Way) error {
+	if way == nil {
+		return nil
+	}
+	if len(way.Refs) > 0 && len(way.Nodes) == len(way.Refs) {
+		// already filled, e.g. way was cached with locations from the PBF
+		return nil
+	}
+	way.Nodes = make([]osm.Node, len(way.Refs))
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for i, id := range way.Refs {
+		nd, err := c.getCoord(id)
+		if err != nil {
+			return err
+		}
+		way.Nodes[i] = *nd
+	}
+	return nil
+}
+
+func (c *FlatCoordsCache) FirstRefIsCached(refs []int64) (bool, error) {
+	if len(refs) <= 0 {
+		return false, nil
+	}
+	_, err := c.GetCoord(refs[0])
+	if err == NotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Iter returns all coords, sorted by ID. The file is scanned in chunks and
+// the lock is not held while the chunks are sent, so concurrent changes
+// can be visible in the result.
+func (c *FlatCoordsCache) Iter() chan []osm.Node {
+	coords := make(chan []osm.Node, 8)
+	go func() {
+		defer close(coords)
+		// scan the file in chunks, the lock is released before sending
+		const chunkSize = 64 * 1024
+		for start := int64(0); ; start += chunkSize {
+			var nodes []osm.Node
+			c.mu.RLock()
+			if start*flatCoordSize >= int64(len(c.data)) {
+				c.mu.RUnlock()
+				return
+			}
+			for id := start; id < start+chunkSize; id++ {
+				if nd, err := c.getCoord(id); err == nil {
+					nodes = append(nodes, *nd)
+				}
+			}
+			c.mu.RUnlock()
+			if len(nodes) > 0 {
+				coords <- nodes
+			}
+		}
+	}()
+	return coords
+}
+
+// SetLinearImport is a no-op, the FlatCoordsCache has no separate mode for
+// imports.
+func (c *FlatCoordsCache) SetLinearImport(bool) {}
+
+// SetReadOnly is a no-op, the FlatCoordsCache does not lock on reads.
+func (c *FlatCoordsCache) SetReadOnly(bool) {}
+
+// Flush is a no-op, changes are written by the OS, as with the other caches.
+func (c *FlatCoordsCache) Flush() error {
+	return nil
+}
+
+func (c *FlatCoordsCache) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.data != nil {
+		if err := munmap(c.data); err != nil {
+			return err
+		}
+		c.data = nil
+	}
+	if c.f != nil {
+		err := c.f.Close()
+		c.f = nil
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,183 @@
+package cache
+
+import (
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	osm "github.com/omniscale/go-osm"
+)
+
+func TestFlatCoordsCache(t *testing.T) {
+	origGrowSize := flatGrowSize
+	flatGrowSize = 4096
+	defer func() { flatGrowSize = origGrowSize }()
+
+	cacheDir, _ := ioutil.TempDir("", "imposm_test")
+	defer os.RemoveAll(cacheDir)
+
+	path := filepath.Join(cacheDir, "coords.flat")
+	c, err := newFlatCoordsCache(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.GetCoord(1); err != NotFound {
+		t.Fatal("expected NotFound, got", err)
+	}
+
+	nodes := []osm.Node{
+		{Element: osm.Element{ID: 1}, Long: -180, Lat: -90},
+		{Element: osm.Element{ID: 2}, Long: 0, Lat: 0},
+		{Element: osm.Element{ID: SKIP}, Long: 1, Lat: 1},
+		// negative IDs are not supported
+		{Element: osm.Element{ID: -5}, Long: 1, Lat: 1},
+		// beyond the initial file size
+		{Element: osm.Element{ID: 100000}, Long: 179.9999999, Lat: 89.9999999},
+	}
+	if err := c.PutCoords(nodes); err != nil {
+		t.Fatal(err)
+	}
+
+	check := func(c *FlatCoordsCache, id int64, long, lat float64) {
+		t.Helper()
+		nd, err := c.GetCoord(id)
+		if err != nil {
+			t.Fatal(id, err)
+		}
+		if nd.ID != id || math.Abs(nd.Long-long) > 1e-7 || math.Abs(nd.Lat-lat) > 1e-7 {
+			t.Errorf("unexpected node %v", nd)
+		}
+	}
+	check(c, 1, -180, -90)
+	check(c, 2, 0, 0)
+	check(c, 100000, 179.9999999, 89.9999999)
+	if _, err := c.GetCoord(-5); err != NotFound {
+		t.Fatal("expected NotFound, got", err)
+	}
+	if _, err := c.GetCoord(3); err != NotFound {
+		t.Fatal("expected NotFound, got", err)
+	}
+	if _, err := c.GetCoord(1 << 40); err != NotFound {
+		t.Fatal("expected NotFound, got", err)
+	}
+
+	way := &osm.Way{Refs: []int64{1, 2, 100000}}
+	if err := c.FillWay(way); err != nil {
+		t.Fatal(err)
+	}
+	if len(way.Nodes) != 3 || way.Nodes[2].ID != 100000 {
+		t.Errorf("unexpected nodes %v", way.Nodes)
+	}
+
+	if err := c.DeleteCoord(2); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.GetCoord(2); err != NotFound {
+		t.Fatal("expected NotFound, got", err)
+	}
+	if err := c.FillWay(&osm.Way{Refs: []int64{1, 2}}); err != NotFound {
+		t.Fatal("expected NotFound, got", err)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// reopen
+	c, err = newFlatCoordsCache(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	check(c, 1, -180, -90)
+	check(c, 100000, 179.9999999, 89.9999999)
+	if _, err := c.GetCoord(2); err != NotFound {
+		t.Fatal("expected NotFound, got", err)
+	}
+}
+
+func TestFlatCoordsCacheConcurrentUpdates(t *testing.T) {
+	origGrowSize := flatGrowSize
+	flatGrowSize = 4096
+	defer func() { flatGrowSize = origGrowSize }()
+
+	cacheDir, _ := ioutil.TempDir("", "imposm_test")
+	defer os.RemoveAll(cacheDir)
+
+	c, err := newFlatCoordsCache(filepath.Join(cacheDir, "coords.flat"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	// the reader should only see one of both coords, never long and lat of
+	// different updates
+	a := []osm.Node{{Element: osm.Element{ID: 1}, Long: 10, Lat: 20}}
+	b := []osm.Node{{Element: osm.Element{ID: 1}, Long: -30, Lat: -40}}
+	if err := c.PutCoords(a); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100000; i++ {
+			nodes := a
+			if i%2 == 0 {
+				nodes = b
+			}
+			if err := c.PutCoords(nodes); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+	for {
+		select {
+		case <-done:
+			return
+		default:
+		}
+		nd, err := c.GetCoord(1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		near := func(v, expected float64) bool { return math.Abs(v-expected) < 1e-6 }
+		if !(near(nd.Long, 10) && near(nd.Lat, 20)) && !(near(nd.Long, -30) && near(nd.Lat, -40)) {
+			t.Fatalf("torn coord %v %v", nd.Long, nd.Lat)
+		}
+	}
+}
+
+func TestOSMCacheFlatCoords(t *testing.T) {
+	cacheDir, _ := ioutil.TempDir("", "imposm_test")
+	defer os.RemoveAll(cacheDir)
+
+	c := NewOSMCache(cacheDir)
+	c.FlatCoords = true
+	if err := c.Open(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := c.Coords.(*FlatCoordsCache); !ok {
+		t.Fatalf("expected FlatCoordsCache, got %T", c.Coords)
+	}
+	c.Close()
+
+	// existing flat coords are detected
+	c = NewOSMCache(cacheDir)
+	if err := c.Open(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := c.Coords.(*FlatCoordsCache); !ok {
+		t.Fatalf("expected FlatCoordsCache, got %T", c.Coords)
+	}
+	c.Close()
+
+	if err := c.Remove(); err != nil {
+		t.Fatal(err)
+	}
+	if NewOSMCache(cacheDir).Exists() {
+		t.Error("cache exists after Remove")
+	}
+}
@@ -0,0 +1,38 @@
+package cache
+
+// IDSet is a set of OSM IDs. It stores IDs in bitmaps of consecutive IDs,
+// which requires far less memory than a map for the dense IDs of a region.
+type IDSet map[int64]*[idSetChunk / 64]uint64
+
+const idSetChunk = 4096
+
+// idSetPos returns the chunk and the offset of id within the chunk. The
+// chunk is floor divided, so that negative IDs (e.g. from JOSM files) have
+// a non-negative offset as well.
+func idSetPos(id int64) (int64, uint) {
+	chunk, off := id/idSetChunk, id%idSetChunk
+	if off < 0 {
+		chunk--
+		off += idSetChunk
+	}
+	return chunk, uint(off)
+}
+
+func (s IDSet) Add(id int64) {
+	chunk, off := idSetPos(id)
+	bits, ok := s[chunk]
+	if !ok {
+		bits = new([idSetChunk / 64]uint64)
+		s[chunk] = bits
+	}
+	bits[off/64] |= 1 << (off % 64)
+}
+
+func (s IDSet) Has(id int64) bool {
+	chunk, off := idSetPos(id)
+	bits, ok := s[chunk]
+	if !ok {
+		return false
+	}
+	return bits[off/64]&(1<<(off%64)) != 0
+}
@@ -0,0 +1,21 @@
+package cache
+
+import "testing"
+
+func TestIDSet(t *testing.T) {
+	s := make(IDSet)
+	ids := []int64{0, 1, 63, 64, 4095, 4096, 1 << 40, -1, -64, -100, -4096, -4097, -1 << 40}
+	for _, id := range ids {
+		s.Add(id)
+	}
+	for _, id := range ids {
+		if !s.Has(id) {
+			t.Error("missing", id)
+		}
+	}
+	for _, id := range []int64{2, 62, 65, 4097, 1<<40 + 1, -2, -63, -65, -99, -4095, -4098, -1<<40 + 1} {
+		if s.Has(id) {
+			t.Error("unexpected", id)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+/*
+Package kv defines the key/value store interface for the OSM cache.
+
+Store implementations register themselves with Register. The LevelDB store
+(package kv/leveldb) requires cgo and libleveldb, the log store (package
+kv/logstore) is implemented in pure Go.
+*/
+package kv
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// Store is a persistent key/value store. Keys are sorted bytewise.
+// All methods need to be safe for concurrent use.
+type Store interface {
+	// Get returns the value of the key or nil if the key does not exist.
+	Get(key []byte) ([]byte, error)
+	Put(key, value []byte) error
+	Delete(key []byte) error
+
+	// NewBatch returns an empty batch that can be applied with Write.
+	NewBatch() Batch
+	// Write applies all operations of the batch atomically.
+	Write(Batch) error
+
+	// NewIterator returns an iterator over all keys. The iterator does not
+	// see any changes after it was created. Iterators are intended for
+	// bulk reads and should not fill any read caches.
+	NewIterator() Iterator
+	// Snapshot returns a read-only, consistent view of the store.
+	Snapshot() Snapshot
+
+	Close() error
+}
+
+// Batch collects multiple Put and Delete operations. The key and value can
+// be reused after Put and Delete return.
+type Batch interface {
+	Put(key, value []byte)
+	Delete(key []byte)
+	Close()
+}
+
+// Iterator iterates over keys in ascending order. The iterator is not
+// valid until Seek or SeekToFirst is called.
+type Iterator interface {
+	SeekToFirst()
+	// Seek moves the iterator to the first key that is equal or larger
+	// then key.
+	Seek(key []byte)
+	Valid() bool
+	Next()
+	Key() []byte
+	Value() []byte
+	// Err returns the first error of the iterator.
+	Err() error
+	Close()
+}
+
+// Snapshot is a read-only view of a Store.
+type Snapshot interface {
+	Get(key []byte) ([]byte, error)
+	NewIterator() Iterator
+	Release()
+}
+
+// Compacter is implemented by stores that can reclaim the space of deleted
+// and overwritten values.
+type Compacter interface {
+	Compact() error
+}
+
+// Options for a store. Stores can ignore options that are not applicable.
+type Options struct {
+	CacheSizeM           int
+	MaxOpenFiles         int
+	BlockRestartInterval int
+	WriteBufferSizeM     int
+	BlockSizeK           int
+	MaxFileSizeM         int
+}
+
+// OpenFunc opens or creates the store in the directory path.
+type OpenFunc func(path string, opts Options) (Store, error)
+
+var backends = make(map[string]OpenFunc)
+
+// markers contains a file for each backend that exists in all directories
+// of this backend. LevelDB is included, as it can be excluded from the build
+// with the noleveldb tag.
+var markers = map[string]string{
+	"leveldb": "CURRENT",
+}
+
+// Register registers a store implementation. The marker file needs to be
+// present in each directory created by this store. It is used to detect
+// the store of an existing directory.
+func Register(name, marker string, open OpenFunc) {
+	backends[name] = open
+	markers[name] = marker
+}
+
+// Backends returns the names of all registered stores.
+func Backends() []string {
+	var names []string
+	for name := range backends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// DefaultBackend returns leveldb if it is available, otherwise logstore.
+func DefaultBackend() string {
+	if _, ok := backends["leveldb"]; ok {
+		return "leveldb"
+	}
+	return "logstore"
+}
+
+// detect returns the backend of an existing store directory.
+func detect(path string) string {
+	for name, marker := range markers {
+		if _, err := os.Stat(filepath.Join(path, marker)); err == nil {
+			return name
+		}
+	}
+	return ""
+}
+
+// Open opens the store in the directory path with the named backend. It
+// uses the backend of the existing directory or the DefaultBackend if name
+// is empty. Returns an error if path was created by another backend.
+func Open(name, path string, opts Options) (Store, error) {
+	existing := detect(path)
+	if name == "" {
+		name = existing
+	}
+	if name == "" {
+		name = DefaultBackend()
+	}
+	if existing != "" && existing != name {
+		return nil, errors.Errorf("cache %s was created with the %s backend, not with %s", path, existing, name)
+	}
+	open, ok := backends[name]
+	if !ok {
+		return nil, errors.Errorf("unsupported cache backend %q (available: %v)", name, Backends())
+	}
+	return open(path, opts)
+}
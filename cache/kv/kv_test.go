@@ -0,0 +1,58 @@
+package kv
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOpenDetectsBackend(t *testing.T) {
+	dir, err := ioutil.TempDir("", "imposm3_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var opened []string
+	for _, name := range []string{"testa", "testb"} {
+		name := name
+		Register(name, name+".marker", func(path string, opts Options) (Store, error) {
+			opened = append(opened, name)
+			os.MkdirAll(path, 0755)
+			return nil, ioutil.WriteFile(filepath.Join(path, name+".marker"), nil, 0644)
+		})
+	}
+	defer func() {
+		delete(backends, "testa")
+		delete(backends, "testb")
+		delete(markers, "testa")
+		delete(markers, "testb")
+	}()
+
+	path := filepath.Join(dir, "db")
+	if _, err := Open("testb", path, Options{}); err != nil {
+		t.Fatal(err)
+	}
+	// use existing backend
+	if _, err := Open("", path, Options{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open("testa", path, Options{}); err == nil {
+		t.Error("expected error for other backend")
+	}
+	if _, err := Open("unknown", path, Options{}); err == nil {
+		t.Error("expected error for unknown backend")
+	}
+	if len(opened) != 2 || opened[0] != "testb" || opened[1] != "testb" {
+		t.Errorf("unexpected backends opened: %v", opened)
+	}
+
+	// leveldb directories are detected, even if leveldb is not registered
+	ldbPath := filepath.Join(dir, "ldb")
+	os.MkdirAll(ldbPath, 0755)
+	ioutil.WriteFile(filepath.Join(ldbPath, "CURRENT"), nil, 0644)
+	if _, err := Open("testa", ldbPath, Options{}); err == nil {
+		t.Error("expected error for leveldb directory")
+	}
+}
@@ -1,6 +1,6 @@
-//go:build !ldbpre121
+//go:build cgo && !noleveldb && !ldbpre121
 
-package cache
+package leveldb
 
 // #cgo LDFLAGS: -lleveldb
 // #include "leveldb/c.h"
@@ -0,0 +1,162 @@
+//go:build cgo && !noleveldb
+
+// Package leveldb implements the kv.Store with LevelDB. The store is
+// registered as leveldb. Build with -tags="noleveldb" to build Imposm
+// without LevelDB.
+package leveldb
+
+import (
+	"github.com/jmhodges/levigo"
+	"github.com/omniscale/imposm3/cache/kv"
+)
+
+func init() {
+	kv.Register("leveldb", "CURRENT", Open)
+}
+
+type store struct {
+	db    *levigo.DB
+	cache *levigo.Cache
+	wo    *levigo.WriteOptions
+	ro    *levigo.ReadOptions
+}
+
+// Open opens or creates a LevelDB in path.
+func Open(path string, o kv.Options) (kv.Store, error) {
+	s := &store{}
+	opts := levigo.NewOptions()
+	defer opts.Close()
+	opts.SetCreateIfMissing(true)
+	if o.CacheSizeM > 0 {
+		s.cache = levigo.NewLRUCache(o.CacheSizeM * 1024 * 1024)
+		opts.SetCache(s.cache)
+	}
+	if o.MaxOpenFiles > 0 {
+		opts.SetMaxOpenFiles(o.MaxOpenFiles)
+	}
+	if o.BlockRestartInterval > 0 {
+		opts.SetBlockRestartInterval(o.BlockRestartInterval)
+	}
+	if o.WriteBufferSizeM > 0 {
+		opts.SetWriteBufferSize(o.WriteBufferSizeM * 1024 * 1024)
+	}
+	if o.BlockSizeK > 0 {
+		opts.SetBlockSize(o.BlockSizeK * 1024)
+	}
+	if o.MaxFileSizeM > 0 {
+		// max file size option is only available with LevelDB 1.21 and higher
+		// build with -tags="ldbpre121" to disable this option.
+		setMaxFileSize(opts, o.MaxFileSizeM*1024*1024)
+	}
+
+	db, err := levigo.Open(path, opts)
+	if err != nil {
+		if s.cache != nil {
+			s.cache.Close()
+		}
+		return nil, err
+	}
+	s.db = db
+	s.wo = levigo.NewWriteOptions()
+	s.ro = levigo.NewReadOptions()
+	return s, nil
+}
+
+func (s *store) Get(key []byte) ([]byte, error) {
+	return s.db.Get(s.ro, key)
+}
+
+func (s *store) Put(key, value []byte) error {
+	return s.db.Put(s.wo, key, value)
+}
+
+func (s *store) Delete(key []byte) error {
+	return s.db.Delete(s.wo, key)
+}
+
+func (s *store) NewBatch() kv.Batch {
+	return &batch{levigo.NewWriteBatch()}
+}
+
+func (s *store) Write(b kv.Batch) error {
+	return s.db.Write(s.wo, b.(*batch).WriteBatch)
+}
+
+func (s *store) NewIterator() kv.Iterator {
+	ro := levigo.NewReadOptions()
+	ro.SetFillCache(false)
+	return &iterator{Iterator: s.db.NewIterator(ro), ro: ro}
+}
+
+func (s *store) Snapshot() kv.Snapshot {
+	snap := s.db.NewSnapshot()
+	ro := levigo.NewReadOptions()
+	ro.SetSnapshot(snap)
+	return &snapshot{db: s.db, snap: snap, ro: ro}
+}
+
+// Compact compacts the whole key range.
+func (s *store) Compact() error {
+	s.db.CompactRange(levigo.Range{})
+	return nil
+}
+
+func (s *store) Close() error {
+	if s.ro != nil {
+		s.ro.Close()
+		s.ro = nil
+	}
+	if s.wo != nil {
+		s.wo.Close()
+		s.wo = nil
+	}
+	if s.db != nil {
+		s.db.Close()
+		s.db = nil
+	}
+	if s.cache != nil {
+		s.cache.Close()
+		s.cache = nil
+	}
+	return nil
+}
+
+type batch struct {
+	*levigo.WriteBatch
+}
+
+type iterator struct {
+	*levigo.Iterator
+	ro *levigo.ReadOptions
+}
+
+func (it *iterator) Err() error {
+	return it.GetError()
+}
+
+func (it *iterator) Close() {
+	it.Iterator.Close()
+	it.ro.Close()
+}
+
+type snapshot struct {
+	db   *levigo.DB
+	snap *levigo.Snapshot
+	ro   *levigo.ReadOptions
+}
+
+func (s *snapshot) Get(key []byte) ([]byte, error) {
+	return s.db.Get(s.ro, key)
+}
+
+func (s *snapshot) NewIterator() kv.Iterator {
+	ro := levigo.NewReadOptions()
+	ro.SetFillCache(false)
+	ro.SetSnapshot(s.snap)
+	return &iterator{Iterator: s.db.NewIterator(ro), ro: ro}
+}
+
+func (s *snapshot) Release() {
+	s.ro.Close()
+	s.db.ReleaseSnapshot(s.snap)
+}
@@ -0,0 +1,543 @@
+/*
+Package logstore implements the kv.Store in pure Go. The store is registered
+as logstore.
+
+All changes are appended to a single log file (data.log). The location of
+each value is kept in an in-memory index that is rebuilt from the log when
+the store is opened. The memory usage grows with the number of keys, so this
+store is suited for extracts and not for a planet import.
+
+The log is compacted (only the current values are written to a new file) as
+soon as more than half of the file contains outdated values. Compaction is
+deferred while iterators or snapshots are open.
+
+The log consists of frames. Each frame contains the operations of one Put,
+Delete or Batch:
+
+	uint32 length of payload (little endian)
+	uint32 CRC32-C of payload (little endian)
+	payload: opPut, uvarint len(key), key, uvarint len(value), value
+	         opDelete, uvarint len(key), key
+
+Incomplete or corrupt frames at the end of the file are removed when the
+store is opened.
+*/
+package logstore
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/omniscale/imposm3/cache/kv"
+	"github.com/omniscale/imposm3/log"
+)
+
+func init() {
+	kv.Register("logstore", logFilename, func(path string, opts kv.Options) (kv.Store, error) {
+		return Open(path, opts)
+	})
+}
+
+const (
+	logFilename = "data.log"
+	frameHeader = 8
+
+	opPut    = 1
+	opDelete = 2
+
+	// minimal file size before the log is compacted
+	minCompactSize = 64 * 1024 * 1024
+	// size of frames written during compaction
+	compactFrameSize = 1024 * 1024
+)
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// loc is the location of a value in the log.
+type loc struct {
+	off int64
+	n   uint32
+}
+
+// Store is a kv.Store that appends all changes to a log file.
+type Store struct {
+	path string
+
+	mu    sync.RWMutex
+	f     *os.File
+	size  int64
+	index map[string]loc
+	// size of all current keys and values in the log
+	live int64
+	// number of open iterators and snapshots
+	readers int
+}
+
+// Open opens or creates the store in the directory path.
+func Open(path string, opts kv.Options) (*Store, error) {
+	if err := os.MkdirAll(path, 0755); err != nil {
+		return nil, err
+	}
+	s := &Store{
+		path:  path,
+		index: make(map[string]loc),
+	}
+	f, err := os.OpenFile(filepath.Join(path, logFilename), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	s.f = f
+	if err := s.load(); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("loading %s: %w", f.Name(), err)
+	}
+	return s, nil
+}
+
+// load rebuilds the index from the log and truncates incomplete frames.
+func (s *Store) load() error {
+	fi, err := s.f.Stat()
+	if err != nil {
+		return err
+	}
+	r := bufio.NewReaderSize(io.NewSectionReader(s.f, 0, fi.Size()), 1024*1024)
+	var off int64
+	header := make([]byte, frameHeader)
+	var payload []byte
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			if err == io.EOF {
+				break
+			}
+			if err == io.ErrUnexpectedEOF {
+				return s.truncate(off)
+			}
+			return err
+		}
+		n := binary.LittleEndian.Uint32(header[0:4])
+		if off+frameHeader+int64(n) > fi.Size() {
+			return s.truncate(off)
+		}
+		if cap(payload) < int(n) {
+			payload = make([]byte, n)
+		}
+		payload = payload[:n]
+		if _, err := io.ReadFull(r, payload); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				return s.truncate(off)
+			}
+			return err
+		}
+		if crc32.Checksum(payload, crcTable) != binary.LittleEndian.Uint32(header[4:8]) {
+			return s.truncate(off)
+		}
+		if err := s.apply(payload, off+frameHeader); err != nil {
+			return s.truncate(off)
+		}
+		off += frameHeader + int64(n)
+	}
+	s.size = off
+	return nil
+}
+
+func (s *Store) truncate(off int64) error {
+	log.Printf("[warn] Removing incomplete data at the end of %s", s.f.Name())
+	if err := s.f.Truncate(off); err != nil {
+		return err
+	}
+	s.size = off
+	return nil
+}
+
+// apply updates the index with all operations of the payload. base is the
+// file offset of the payload.
+func (s *Store) apply(payload []byte, base int64) error {
+	return decodeOps(payload, func(op byte, key []byte, valueOff int, valueLen int) {
+		if old, ok := s.index[string(key)]; ok {
+			s.live -= opSize(len(key), int(old.n))
+		}
+		if op == opDelete {
+			delete(s.index, string(key))
+			return
+		}
+		s.index[string(key)] = loc{off: base + int64(valueOff), n: uint32(valueLen)}
+		s.live += opSize(len(key), valueLen)
+	})
+}
+
+func decodeOps(payload []byte, fn func(op byte, key []byte, valueOff, valueLen int)) error {
+	pos := 0
+	for pos < len(payload) {
+		op := payload[pos]
+		pos++
+		keyLen, n := binary.Uvarint(payload[pos:])
+		if n <= 0 || pos+n+int(keyLen) > len(payload) {
+			return fmt.Errorf("invalid key length at %d", pos)
+		}
+		pos += n
+		key := payload[pos : pos+int(keyLen)]
+		pos += int(keyLen)
+		switch op {
+		case opDelete:
+			fn(op, key, 0, 0)
+		case opPut:
+			valueLen, n := binary.Uvarint(payload[pos:])
+			if n <= 0 || pos+n+int(valueLen) > len(payload) {
+				return fmt.Errorf("invalid value length at %d", pos)
+			}
+			pos += n
+			fn(op, key, pos, int(valueLen))
+			pos += int(valueLen)
+		default:
+			return fmt.Errorf("unknown operation %d at %d", op, pos-1)
+		}
+	}
+	return nil
+}
+
+func opSize(keyLen, valueLen int) int64 {
+	return int64(1 + uvarintLen(keyLen) + keyLen + uvarintLen(valueLen) + valueLen)
+}
+
+func uvarintLen(x int) int {
+	n := 1
+	for x >= 0x80 {
+		x >>= 7
+		n++
+	}
+	return n
+}
+
+func appendPut(buf, key, value []byte) []byte {
+	buf = append(buf, opPut)
+	buf = binary.AppendUvarint(buf, uint64(len(key)))
+	buf = append(buf, key...)
+	buf = binary.AppendUvarint(buf, uint64(len(value)))
+	return append(buf, value...)
+}
+
+func appendDelete(buf, key []byte) []byte {
+	buf = append(buf, opDelete)
+	buf = binary.AppendUvarint(buf, uint64(len(key)))
+	return append(buf, key...)
+}
+
+// frame fills the frame header of buf. buf needs to start with frameHeader
+// reserved bytes, followed by the payload.
+func frame(buf []byte) []byte {
+	payload := buf[frameHeader:]
+	binary.LittleEndian.PutUint32(buf[0:4], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(buf[4:8], crc32.Checksum(payload, crcTable))
+	return buf
+}
+
+// write appends the framed buf to the log and updates the index.
+func (s *Store) write(buf []byte) error {
+	if len(buf) == frameHeader {
+		return nil
+	}
+	buf = frame(buf)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.f == nil {
+		return fmt.Errorf("store %s is closed", s.path)
+	}
+	if _, err := s.f.WriteAt(buf, s.size); err != nil {
+		// remove partial frame, it would be removed on next load anyway
+		s.f.Truncate(s.size)
+		return err
+	}
+	if err := s.apply(buf[frameHeader:], s.size+frameHeader); err != nil {
+		return err
+	}
+	s.size += int64(len(buf))
+
+	if s.readers == 0 && s.size > minCompactSize && s.live < s.size/2 {
+		if err := s.compact(); err != nil {
+			return fmt.Errorf("compacting %s: %w", s.path, err)
+		}
+	}
+	return nil
+}
+
+func (s *Store) Get(key []byte) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.f == nil {
+		return nil, fmt.Errorf("store %s is closed", s.path)
+	}
+	l, ok := s.index[string(key)]
+	if !ok {
+		return nil, nil
+	}
+	return s.read(l)
+}
+
+// read returns the value at l. Requires a lock.
+func (s *Store) read(l loc) ([]byte, error) {
+	value := make([]byte, l.n)
+	if _, err := s.f.ReadAt(value, l.off); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+func (s *Store) Put(key, value []byte) error {
+	buf := make([]byte, frameHeader, frameHeader+int(opSize(len(key), len(value))))
+	return s.write(appendPut(buf, key, value))
+}
+
+func (s *Store) Delete(key []byte) error {
+	buf := make([]byte, frameHeader, frameHeader+int(opSize(len(key), 0)))
+	return s.write(appendDelete(buf, key))
+}
+
+type batch struct {
+	buf []byte
+}
+
+func (b *batch) Put(key, value []byte) {
+	b.buf = appendPut(b.buf, key, value)
+}
+
+func (b *batch) Delete(key []byte) {
+	b.buf = appendDelete(b.buf, key)
+}
+
+func (b *batch) Close() {
+	b.buf = nil
+}
+
+func (s *Store) NewBatch() kv.Batch {
+	return &batch{buf: make([]byte, frameHeader, 64*1024)}
+}
+
+func (s *Store) Write(b kv.Batch) error {
+	return s.write(b.(*batch).buf)
+}
+
+type entry struct {
+	key string
+	loc loc
+}
+
+// Snapshot returns a read-only view of the store. Values are read from the
+// log as it is never modified, only extended. The log is not compacted
+// till the snapshot is released.
+func (s *Store) Snapshot() kv.Snapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entries := make([]entry, 0, len(s.index))
+	for k, l := range s.index {
+		entries = append(entries, entry{k, l})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].key < entries[j].key })
+	s.readers++
+	return &snapshot{s: s, entries: entries}
+}
+
+func (s *Store) NewIterator() kv.Iterator {
+	snap := s.Snapshot().(*snapshot)
+	it := snap.NewIterator().(*iterator)
+	it.release = snap.Release
+	return it
+}
+
+// Close closes the log file.
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.f == nil {
+		return nil
+	}
+	err := s.f.Close()
+	s.f = nil
+	return err
+}
+
+// Compact rewrites the log with the current values. Returns an error if a
+// snapshot or iterator is still open.
+func (s *Store) Compact() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.f == nil {
+		return fmt.Errorf("store %s is closed", s.path)
+	}
+	if s.readers > 0 {
+		return fmt.Errorf("unable to compact %s with open snapshots", s.path)
+	}
+	if err := s.compact(); err != nil {
+		return fmt.Errorf("compacting %s: %w", s.path, err)
+	}
+	return nil
+}
+
+// compact writes all current values into a new log file and replaces the
+// old log. Requires the write lock.
+func (s *Store) compact() error {
+	keys := make([]string, 0, len(s.index))
+	for k := range s.index {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	tmpName := filepath.Join(s.path, logFilename+".tmp")
+	tmp, err := os.Create(tmpName)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if tmp != nil {
+			tmp.Close()
+			os.Remove(tmpName)
+		}
+	}()
+
+	index := make(map[string]loc, len(s.index))
+	var size int64
+	buf := make([]byte, frameHeader, compactFrameSize+64*1024)
+	flush := func() error {
+		if len(buf) == frameHeader {
+			return nil
+		}
+		frame(buf)
+		if _, err := tmp.Write(buf); err != nil {
+			return err
+		}
+		size += int64(len(buf))
+		buf = buf[:frameHeader]
+		return nil
+	}
+	for _, k := range keys {
+		value, err := s.read(s.index[k])
+		if err != nil {
+			return err
+		}
+		if len(buf) > compactFrameSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+		buf = appendPut(buf, []byte(k), value)
+		index[k] = loc{off: size + int64(len(buf)-len(value)), n: uint32(len(value))}
+	}
+	if err := flush(); err != nil {
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpName, filepath.Join(s.path, logFilename)); err != nil {
+		return err
+	}
+	s.f.Close()
+	s.f, tmp = tmp, nil
+	s.index = index
+	s.size = size
+	return nil
+}
+
+type snapshot struct {
+	s       *Store
+	entries []entry
+	once    sync.Once
+}
+
+func (snap *snapshot) search(key []byte) int {
+	return sort.Search(len(snap.entries), func(i int) bool {
+		return snap.entries[i].key >= string(key)
+	})
+}
+
+func (snap *snapshot) Get(key []byte) ([]byte, error) {
+	i := snap.search(key)
+	if i == len(snap.entries) || snap.entries[i].key != string(key) {
+		return nil, nil
+	}
+	return snap.read(snap.entries[i].loc)
+}
+
+func (snap *snapshot) read(l loc) ([]byte, error) {
+	snap.s.mu.RLock()
+	defer snap.s.mu.RUnlock()
+	if snap.s.f == nil {
+		return nil, fmt.Errorf("store %s is closed", snap.s.path)
+	}
+	return snap.s.read(l)
+}
+
+func (snap *snapshot) NewIterator() kv.Iterator {
+	return &iterator{snap: snap, pos: -1}
+}
+
+func (snap *snapshot) Release() {
+	snap.once.Do(func() {
+		snap.s.mu.Lock()
+		snap.s.readers--
+		snap.s.mu.Unlock()
+	})
+}
+
+type iterator struct {
+	snap    *snapshot
+	pos     int
+	value   []byte
+	err     error
+	release func()
+}
+
+func (it *iterator) SeekToFirst() {
+	it.pos = 0
+	it.load()
+}
+
+func (it *iterator) Seek(key []byte) {
+	it.pos = it.snap.search(key)
+	it.load()
+}
+
+func (it *iterator) Valid() bool {
+	return it.err == nil && it.pos >= 0 && it.pos < len(it.snap.entries)
+}
+
+func (it *iterator) Next() {
+	it.pos++
+	it.load()
+}
+
+func (it *iterator) load() {
+	it.value = nil
+	if !it.Valid() {
+		return
+	}
+	it.value, it.err = it.snap.read(it.snap.entries[it.pos].loc)
+}
+
+func (it *iterator) Key() []byte {
+	return []byte(it.snap.entries[it.pos].key)
+}
+
+func (it *iterator) Value() []byte {
+	return it.value
+}
+
+func (it *iterator) Err() error {
+	return it.err
+}
+
+func (it *iterator) Close() {
+	if it.release != nil {
+		it.release()
+		it.release = nil
+	}
+}
+
+var _ kv.Store = &Store{}
@@ -0,0 +1,248 @@
+package logstore
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/omniscale/imposm3/cache/kv"
+)
+
+func key(i int) []byte {
+	return []byte(fmt.Sprintf("k%04d", i))
+}
+
+func openTemp(t *testing.T) (*Store, string) {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "imposm3_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := Open(filepath.Join(dir, "db"), kv.Options{})
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return s, dir
+}
+
+func checkGet(t *testing.T, s interface {
+	Get([]byte) ([]byte, error)
+}, k []byte, want []byte) {
+	t.Helper()
+	v, err := s.Get(k)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want == nil && v != nil {
+		t.Errorf("%s: expected nil, got %q", k, v)
+	} else if !bytes.Equal(v, want) {
+		t.Errorf("%s: expected %q, got %q", k, want, v)
+	}
+}
+
+func TestPutGetDelete(t *testing.T) {
+	s, dir := openTemp(t)
+	defer os.RemoveAll(dir)
+	defer s.Close()
+
+	checkGet(t, s, key(1), nil)
+	if err := s.Put(key(1), []byte("foo")); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Put(key(2), []byte("bar")); err != nil {
+		t.Fatal(err)
+	}
+	checkGet(t, s, key(1), []byte("foo"))
+	if err := s.Put(key(1), []byte("baz")); err != nil {
+		t.Fatal(err)
+	}
+	checkGet(t, s, key(1), []byte("baz"))
+	if err := s.Delete(key(1)); err != nil {
+		t.Fatal(err)
+	}
+	checkGet(t, s, key(1), nil)
+	checkGet(t, s, key(2), []byte("bar"))
+}
+
+func TestBatch(t *testing.T) {
+	s, dir := openTemp(t)
+	defer os.RemoveAll(dir)
+	defer s.Close()
+
+	s.Put(key(1), []byte("foo"))
+
+	b := s.NewBatch()
+	buf := []byte("val")
+	for i := 2; i < 100; i++ {
+		b.Put(key(i), buf)
+		// buffers can be reused after Put
+		buf[0]++
+	}
+	b.Delete(key(1))
+	if v, _ := s.Get(key(2)); v != nil {
+		t.Error("batch applied before Write")
+	}
+	if err := s.Write(b); err != nil {
+		t.Fatal(err)
+	}
+	b.Close()
+
+	checkGet(t, s, key(1), nil)
+	checkGet(t, s, key(2), []byte("val"))
+	checkGet(t, s, key(3), []byte("wal"))
+}
+
+func TestIterator(t *testing.T) {
+	s, dir := openTemp(t)
+	defer os.RemoveAll(dir)
+	defer s.Close()
+
+	for _, i := range []int{5, 3, 9, 1, 7} {
+		s.Put(key(i), key(i*10))
+	}
+
+	it := s.NewIterator()
+	// changes after NewIterator are not visible
+	s.Put(key(4), []byte("new"))
+	s.Delete(key(5))
+
+	var got []string
+	for it.SeekToFirst(); it.Valid(); it.Next() {
+		if !bytes.Equal(it.Value(), key(idx(it.Key())*10)) {
+			t.Errorf("unexpected value %q for %q", it.Value(), it.Key())
+		}
+		got = append(got, string(it.Key()))
+	}
+	if err := it.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(got) != "[k0001 k0003 k0005 k0007 k0009]" {
+		t.Errorf("unexpected keys %v", got)
+	}
+
+	it.Seek(key(6))
+	if !it.Valid() || string(it.Key()) != "k0007" {
+		t.Errorf("unexpected seek result")
+	}
+	it.Seek(key(10))
+	if it.Valid() {
+		t.Errorf("iterator valid after last key")
+	}
+	it.Close()
+
+	if s.readers != 0 {
+		t.Errorf("readers not released: %d", s.readers)
+	}
+}
+
+func idx(k []byte) int {
+	var i int
+	fmt.Sscanf(string(k), "k%d", &i)
+	return i
+}
+
+func TestSnapshot(t *testing.T) {
+	s, dir := openTemp(t)
+	defer os.RemoveAll(dir)
+	defer s.Close()
+
+	s.Put(key(1), []byte("foo"))
+	snap := s.Snapshot()
+	s.Put(key(1), []byte("bar"))
+	s.Put(key(2), []byte("bar"))
+
+	checkGet(t, snap, key(1), []byte("foo"))
+	checkGet(t, snap, key(2), nil)
+	checkGet(t, s, key(1), []byte("bar"))
+	snap.Release()
+	snap.Release()
+	if s.readers != 0 {
+		t.Errorf("readers not released: %d", s.readers)
+	}
+}
+
+func TestReopen(t *testing.T) {
+	s, dir := openTemp(t)
+	defer os.RemoveAll(dir)
+
+	for i := 0; i < 100; i++ {
+		s.Put(key(i), key(i))
+	}
+	s.Delete(key(50))
+	s.Close()
+
+	logFile := filepath.Join(dir, "db", logFilename)
+	fi, err := os.Stat(logFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// simulate partial write of the last frame
+	f, err := os.OpenFile(logFile, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte{100, 0, 0, 0, 1, 2, 3, 4, opPut})
+	f.Close()
+
+	s, err = Open(filepath.Join(dir, "db"), kv.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	checkGet(t, s, key(1), key(1))
+	checkGet(t, s, key(50), nil)
+	checkGet(t, s, key(99), key(99))
+	if s.size != fi.Size() {
+		t.Errorf("partial frame not removed: %d != %d", s.size, fi.Size())
+	}
+	if err := s.Put(key(100), key(100)); err != nil {
+		t.Fatal(err)
+	}
+	checkGet(t, s, key(100), key(100))
+}
+
+func TestCompact(t *testing.T) {
+	s, dir := openTemp(t)
+	defer os.RemoveAll(dir)
+	defer s.Close()
+
+	for n := 0; n < 10; n++ {
+		for i := 0; i < 100; i++ {
+			s.Put(key(i), []byte(fmt.Sprintf("%d-%d", i, n)))
+		}
+	}
+	for i := 0; i < 50; i++ {
+		s.Delete(key(i))
+	}
+	sizeBefore := s.size
+
+	snap := s.Snapshot()
+	if err := s.Compact(); err == nil {
+		t.Error("expected error for compact with open snapshot")
+	}
+	snap.Release()
+
+	if err := s.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	if s.size >= sizeBefore/10 {
+		t.Errorf("log not compacted %d -> %d", sizeBefore, s.size)
+	}
+	checkGet(t, s, key(1), nil)
+	checkGet(t, s, key(51), []byte("51-9"))
+
+	s.Put(key(1), []byte("new"))
+	s.Close()
+
+	s, err := Open(filepath.Join(dir, "db"), kv.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkGet(t, s, key(1), []byte("new"))
+	checkGet(t, s, key(2), nil)
+	checkGet(t, s, key(99), []byte("99-9"))
+}
@@ -0,0 +1,74 @@
+package cache
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+const lockFilename = "imposm.lock"
+
+// ErrLocked is returned by LockDir if the cache is locked by another
+// process.
+var ErrLocked = errors.New("cache is in use by another imposm process")
+
+// DirLock is an exclusive lock of a cache directory. It prevents that
+// multiple imposm processes use the same cache, e.g. `imposm cache compact`
+// while `imposm run` updates the cache.
+type DirLock struct {
+	f *os.File
+}
+
+// LockDir locks the cache directory. Returns an error that wraps ErrLocked
+// if the directory is already locked. The lock is released when the
+// process exits.
+func LockDir(dir string) (*DirLock, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	path := filepath.Join(dir, lockFilename)
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if err := lockFile(f); err != nil {
+		f.Close()
+		if err == errWouldBlock {
+			if pid := lockPID(path); pid != 0 {
+				return nil, fmt.Errorf("%s: %w (pid %d)", dir, ErrLocked, pid)
+			}
+			return nil, fmt.Errorf("%s: %w", dir, ErrLocked)
+		}
+		return nil, fmt.Errorf("locking %s: %w", dir, err)
+	}
+	// pid is only informative, ignore errors
+	if err := f.Truncate(0); err == nil {
+		f.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+	}
+	return &DirLock{f: f}, nil
+}
+
+// Unlock releases the lock.
+func (l *DirLock) Unlock() error {
+	if l.f == nil {
+		return nil
+	}
+	l.f.Truncate(0)
+	err := unlockFile(l.f)
+	l.f.Close()
+	l.f = nil
+	return err
+}
+
+func lockPID(path string) int {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	pid, _ := strconv.Atoi(string(bytes.TrimSpace(b)))
+	return pid
+}
@@ -0,0 +1,37 @@
+//go:build unix
+
+package cache
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestLockDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "imposm_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	lock, err := LockDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := LockDir(dir); !errors.Is(err, ErrLocked) {
+		t.Fatal("expected ErrLocked, got", err)
+	}
+
+	if err := lock.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+
+	lock, err = LockDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lock.Unlock()
+}
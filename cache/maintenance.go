@@ -0,0 +1,118 @@
+package cache
+
+import (
+	bin "encoding/binary"
+
+	osm "github.com/omniscale/go-osm"
+	"github.com/omniscale/imposm3/cache/binary"
+	"github.com/omniscale/imposm3/cache/kv"
+	"github.com/omniscale/imposm3/element"
+)
+
+// Count returns the number of entries in the cache.
+func (c *cache) Count() (int64, error) {
+	it := c.db.NewIterator()
+	defer it.Close()
+	var n int64
+	for it.SeekToFirst(); it.Valid(); it.Next() {
+		n++
+	}
+	return n, it.Err()
+}
+
+// Compact reclaims the space of deleted and updated entries. It is a no-op
+// for stores that do not support compaction.
+func (c *cache) Compact() error {
+	if compacter, ok := c.db.(kv.Compacter); ok {
+		return compacter.Compact()
+	}
+	return nil
+}
+
+// Count returns the number of cached coords.
+func (c *DeltaCoordsCache) Count() (int64, error) {
+	it := c.db.NewIterator()
+	defer it.Close()
+	var n int64
+	var nodes []osm.Node
+	for it.SeekToFirst(); it.Valid(); it.Next() {
+		var err error
+		nodes, err = binary.UnmarshalDeltaNodes(it.Value(), nodes)
+		if err != nil {
+			return 0, err
+		}
+		n += int64(len(nodes))
+	}
+	return n, it.Err()
+}
+
+// Count returns the number of cached coords.
+func (c *FlatCoordsCache) Count() (int64, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	var n int64
+	for off := 0; off+flatCoordSize <= len(c.data); off += flatCoordSize {
+		if bin.LittleEndian.Uint64(c.data[off:]) != 0 {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// Count returns the number of IDs with refs.
+func (index *bunchRefCache) Count() (int64, error) {
+	it := index.db.NewIterator()
+	defer it.Close()
+	var n int64
+	idRefs := idRefsPool.get()
+	defer idRefsPool.release(idRefs)
+	for it.SeekToFirst(); it.Valid(); it.Next() {
+		idRefs = binary.UnmarshalIDRefsBunch2(it.Value(), idRefs)
+		n += int64(len(idRefs))
+	}
+	return n, it.Err()
+}
+
+// Iter returns all IDs with their refs in ascending order.
+func (index *bunchRefCache) Iter() chan element.IDRefs {
+	idRefs := make(chan element.IDRefs, 1024)
+	go func() {
+		it := index.db.NewIterator()
+		// close iterator before closing the chan, see WaysCache.Iter
+		defer close(idRefs)
+		defer it.Close()
+		for it.SeekToFirst(); it.Valid(); it.Next() {
+			for _, idRef := range binary.UnmarshalIDRefsBunch(it.Value()) {
+				idRefs <- idRef
+			}
+		}
+	}()
+	return idRefs
+}
+
+// Compact compacts all sub-caches.
+func (c *OSMCache) Compact() error {
+	if coords, ok := c.Coords.(*DeltaCoordsCache); ok {
+		if err := coords.Compact(); err != nil {
+			return err
+		}
+	}
+	if err := c.Nodes.Compact(); err != nil {
+		return err
+	}
+	if err := c.Ways.Compact(); err != nil {
+		return err
+	}
+	return c.Relations.Compact()
+}
+
+// Compact compacts all ref indexes.
+func (c *DiffCache) Compact() error {
+	if err := c.Coords.Compact(); err != nil {
+		return err
+	}
+	if err := c.CoordsRel.Compact(); err != nil {
+		return err
+	}
+	return c.Ways.Compact()
+}
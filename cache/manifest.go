@@ -0,0 +1,134 @@
+package cache
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/omniscale/imposm3"
+)
+
+// Format is the version of the binary format of all caches. It needs to be
+// incremented with each incompatible change of cache/binary or of the
+// layout of the caches, together with a new entry in migrations.
+//
+// Caches without manifest were created before the format was versioned.
+// They are handled as format 1.
+const Format = 1
+
+const manifestFilename = "imposm_cache.json"
+
+// Manifest describes the format of the caches in a cache directory. It is
+// written on creation of a new cache and checked each time a cache is
+// opened.
+type Manifest struct {
+	Format int `json:"format"`
+	// CoordsBunchSize is the number of coords stored in each entry of the
+	// DeltaCoordsCache.
+	CoordsBunchSize int       `json:"coords_bunch_size"`
+	Created         time.Time `json:"created"`
+	// Version of imposm that created or migrated the cache.
+	Version string `json:"imposm_version"`
+}
+
+// ErrIncompatible is returned by Open for caches that were created with
+// another format. Caches with an older format can be migrated with
+// Migrate.
+var ErrIncompatible = errors.New("incompatible cache")
+
+func newManifest() *Manifest {
+	return &Manifest{
+		Format:          Format,
+		CoordsBunchSize: globalCacheOptions.Coords.BunchSize,
+		Created:         time.Now().UTC().Truncate(time.Second),
+		Version:         imposm3.Version,
+	}
+}
+
+// ReadManifest reads the manifest of the cache directory. Returns nil if
+// the directory contains no manifest.
+func ReadManifest(dir string) (*Manifest, error) {
+	f, err := os.Open(filepath.Join(dir, manifestFilename))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	m := &Manifest{}
+	if err := json.NewDecoder(f).Decode(m); err != nil {
+		return nil, fmt.Errorf("reading cache manifest %s: %w", f.Name(), err)
+	}
+	return m, nil
+}
+
+func writeManifest(dir string, m *Manifest) error {
+	path := filepath.Join(dir, manifestFilename)
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(m); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// openManifest returns the manifest of the cache directory and checks that
+// the format is supported. It writes a new manifest if the cache does not
+// exist.
+func openManifest(dir string, exists bool) (*Manifest, error) {
+	if !exists {
+		// new cache, replaces manifests of removed caches
+		m := newManifest()
+		if err := writeManifest(dir, m); err != nil {
+			return nil, fmt.Errorf("writing cache manifest: %w", err)
+		}
+		return m, nil
+	}
+	m, err := ReadManifest(dir)
+	if err != nil {
+		return nil, err
+	}
+	if m == nil {
+		return &Manifest{Format: 1}, nil
+	}
+	if m.Format > Format {
+		return nil, fmt.Errorf("%w: cache %s has format %d and was created by imposm %s, this version only supports format %d",
+			ErrIncompatible, dir, m.Format, m.Version, Format)
+	}
+	if m.Format < Format {
+		return nil, fmt.Errorf("%w: cache %s has the old format %d, run `imposm cache migrate` to upgrade it to format %d",
+			ErrIncompatible, dir, m.Format, Format)
+	}
+	return m, nil
+}
+
+// checkCoordsBunchSize checks that the DeltaCoordsCache was created with the
+// configured bunch size.
+func checkCoordsBunchSize(dir string, m *Manifest) error {
+	if m.CoordsBunchSize == 0 {
+		// unknown for caches without manifest
+		return nil
+	}
+	if _, err := os.Stat(filepath.Join(dir, "coords")); err != nil {
+		return nil
+	}
+	if m.CoordsBunchSize != globalCacheOptions.Coords.BunchSize {
+		return fmt.Errorf("%w: coords of cache %s were created with a BunchSize of %d, but the cache config uses %d, "+
+			"use the original config or run `imposm cache migrate` to rewrite the coords",
+			ErrIncompatible, dir, m.CoordsBunchSize, globalCacheOptions.Coords.BunchSize)
+	}
+	return nil
+}
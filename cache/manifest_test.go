@@ -0,0 +1,199 @@
+package cache
+
+import (
+	"errors"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	osm "github.com/omniscale/go-osm"
+)
+
+func TestManifest(t *testing.T) {
+	cacheDir, _ := ioutil.TempDir("", "imposm_test")
+	defer os.RemoveAll(cacheDir)
+
+	c := NewOSMCache(cacheDir)
+	if err := c.Open(); err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+
+	m, err := ReadManifest(cacheDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m == nil || m.Format != Format || m.CoordsBunchSize != globalCacheOptions.Coords.BunchSize || m.Created.IsZero() {
+		t.Fatalf("unexpected manifest %#v", m)
+	}
+
+	// diff cache uses the manifest of the existing OSM cache
+	dc := NewDiffCache(cacheDir)
+	if err := dc.Open(); err != nil {
+		t.Fatal(err)
+	}
+	dc.Close()
+
+	for _, check := range []struct {
+		format int
+		err    string
+	}{
+		{Format + 1, "this version only supports format"},
+		{Format - 1, "run `imposm cache migrate`"},
+	} {
+		if err := writeManifest(cacheDir, &Manifest{Format: check.format}); err != nil {
+			t.Fatal(err)
+		}
+		c := NewOSMCache(cacheDir)
+		err := c.Open()
+		if !errors.Is(err, ErrIncompatible) || !strings.Contains(err.Error(), check.err) {
+			t.Errorf("expected error %q for format %d, got %v", check.err, check.format, err)
+		}
+		dc := NewDiffCache(cacheDir)
+		if err := dc.Open(); !errors.Is(err, ErrIncompatible) {
+			t.Errorf("expected ErrIncompatible for diff cache with format %d, got %v", check.format, err)
+		}
+	}
+
+	// caches without manifest are opened as format 1
+	if err := os.Remove(filepath.Join(cacheDir, manifestFilename)); err != nil {
+		t.Fatal(err)
+	}
+	c = NewOSMCache(cacheDir)
+	if err := c.Open(); err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+	if m, _ := ReadManifest(cacheDir); m != nil {
+		t.Error("manifest written for existing cache", m)
+	}
+}
+
+func TestMigrateCoordsBunchSize(t *testing.T) {
+	cacheDir, _ := ioutil.TempDir("", "imposm_test")
+	defer os.RemoveAll(cacheDir)
+
+	bunchSize := globalCacheOptions.Coords.BunchSize
+	defer func() { globalCacheOptions.Coords.BunchSize = bunchSize }()
+
+	c := NewOSMCache(cacheDir)
+	if err := c.Open(); err != nil {
+		t.Fatal(err)
+	}
+	var nodes []osm.Node
+	for i := 0; i < 10000; i++ {
+		nodes = append(nodes, osm.Node{Element: osm.Element{ID: int64(i * 3)}, Long: float64(i) / 1000, Lat: -float64(i) / 1000})
+	}
+	if err := c.Coords.PutCoords(nodes); err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+
+	globalCacheOptions.Coords.BunchSize = bunchSize * 2
+	c = NewOSMCache(cacheDir)
+	if err := c.Open(); !errors.Is(err, ErrIncompatible) {
+		t.Fatal("expected ErrIncompatible for changed BunchSize, got", err)
+	}
+
+	if err := Migrate(cacheDir); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(cacheDir, "coords.migrate")); !os.IsNotExist(err) {
+		t.Error("coords.migrate not removed", err)
+	}
+	m, err := ReadManifest(cacheDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.CoordsBunchSize != bunchSize*2 {
+		t.Error("unexpected bunch size", m.CoordsBunchSize)
+	}
+
+	c = NewOSMCache(cacheDir)
+	if err := c.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	for _, nd := range nodes {
+		actual, err := c.Coords.GetCoord(nd.ID)
+		if err != nil {
+			t.Fatal(nd.ID, err)
+		}
+		if math.Abs(actual.Long-nd.Long) > 1e-6 || math.Abs(actual.Lat-nd.Lat) > 1e-6 {
+			t.Fatalf("unexpected coord %v != %v", actual, nd)
+		}
+	}
+	if _, err := c.Coords.GetCoord(1); err != NotFound {
+		t.Error("expected NotFound, got", err)
+	}
+}
+
+func TestMigrateWithoutManifest(t *testing.T) {
+	bunchSize := globalCacheOptions.Coords.BunchSize
+	defer func() { globalCacheOptions.Coords.BunchSize = bunchSize }()
+
+	for _, tc := range []struct {
+		name       string
+		configured int
+		rewrite    bool
+	}{
+		{"same bunch size", bunchSize, false},
+		{"changed bunch size", bunchSize * 2, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cacheDir, _ := ioutil.TempDir("", "imposm_test")
+			defer os.RemoveAll(cacheDir)
+
+			globalCacheOptions.Coords.BunchSize = bunchSize
+			c := NewOSMCache(cacheDir)
+			if err := c.Open(); err != nil {
+				t.Fatal(err)
+			}
+			var nodes []osm.Node
+			for i := 0; i < 10000; i++ {
+				nodes = append(nodes, osm.Node{Element: osm.Element{ID: int64(i * 3)}, Long: float64(i) / 1000, Lat: 1})
+			}
+			if err := c.Coords.PutCoords(nodes); err != nil {
+				t.Fatal(err)
+			}
+			c.Close()
+			// cache created before versioning
+			if err := os.Remove(filepath.Join(cacheDir, manifestFilename)); err != nil {
+				t.Fatal(err)
+			}
+
+			globalCacheOptions.Coords.BunchSize = tc.configured
+			matches, err := coordsMatchBunchSize(filepath.Join(cacheDir, "coords"), tc.configured)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if matches == tc.rewrite {
+				t.Error("unexpected match result", matches)
+			}
+			if err := Migrate(cacheDir); err != nil {
+				t.Fatal(err)
+			}
+			m, err := ReadManifest(cacheDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if m == nil || m.Format != 1 || m.CoordsBunchSize != tc.configured {
+				t.Fatal("unexpected manifest", m)
+			}
+
+			c = NewOSMCache(cacheDir)
+			if err := c.Open(); err != nil {
+				t.Fatal(err)
+			}
+			defer c.Close()
+			for _, nd := range nodes {
+				if _, err := c.Coords.GetCoord(nd.ID); err != nil {
+					t.Fatal(nd.ID, err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,180 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	osm "github.com/omniscale/go-osm"
+	"github.com/omniscale/imposm3"
+	"github.com/omniscale/imposm3/cache/binary"
+	"github.com/omniscale/imposm3/log"
+)
+
+// migrations contains the functions to migrate a cache directory from
+// format n to n+1. It is empty, as 1 is the first versioned format.
+var migrations = map[int]func(dir string) error{}
+
+// Migrate updates the manifest of the caches in dir and rewrites the coords
+// if they were created with another coords BunchSize than configured. It
+// writes the manifest for caches that were created before the format was
+// versioned. Caches with an older Format are migrated with the migrations,
+// each step updates the manifest, so that an interrupted migration can be
+// continued by calling Migrate again. The caches need to be closed.
+func Migrate(dir string) error {
+	if !NewOSMCache(dir).Exists() && !NewDiffCache(dir).Exists() {
+		return fmt.Errorf("no cache found in %s", dir)
+	}
+	m, err := ReadManifest(dir)
+	if err != nil {
+		return err
+	}
+	if m == nil {
+		log.Printf("[info] cache %s was created before cache versioning, assuming format 1", dir)
+		m = &Manifest{Format: 1}
+	}
+	if m.Format > Format {
+		return fmt.Errorf("%w: cache %s has format %d and was created by imposm %s, this version only supports format %d",
+			ErrIncompatible, dir, m.Format, m.Version, Format)
+	}
+
+	for m.Format < Format {
+		migrate, ok := migrations[m.Format]
+		if !ok {
+			return fmt.Errorf("no migration for cache format %d", m.Format)
+		}
+		step := log.Step(fmt.Sprintf("Migrating cache from format %d to %d", m.Format, m.Format+1))
+		if err := migrate(dir); err != nil {
+			return fmt.Errorf("migrating cache from format %d: %w", m.Format, err)
+		}
+		m.Format++
+		m.Version = imposm3.Version
+		if err := writeManifest(dir, m); err != nil {
+			return err
+		}
+		step()
+	}
+
+	bunchSize := globalCacheOptions.Coords.BunchSize
+	coordsPath := filepath.Join(dir, "coords")
+	if _, err := os.Stat(coordsPath); err == nil && m.CoordsBunchSize != bunchSize {
+		rewrite := m.CoordsBunchSize != 0
+		was := fmt.Sprint(m.CoordsBunchSize)
+		if m.CoordsBunchSize == 0 {
+			// unknown for caches without manifest, check the stored coords
+			// instead of trusting the current config
+			matches, err := coordsMatchBunchSize(coordsPath, bunchSize)
+			if err != nil {
+				return fmt.Errorf("checking coords: %w", err)
+			}
+			rewrite = !matches
+			was = "unknown"
+		}
+		if rewrite {
+			step := log.Step(fmt.Sprintf("Rewriting coords with BunchSize %d (was %s)", bunchSize, was))
+			if err := rewriteCoords(dir); err != nil {
+				return fmt.Errorf("rewriting coords: %w", err)
+			}
+			step()
+		}
+	}
+	if m.CoordsBunchSize == bunchSize && m.Version == imposm3.Version {
+		log.Printf("[info] cache %s is up to date (format %d)", dir, m.Format)
+		return nil
+	}
+	m.CoordsBunchSize = bunchSize
+	m.Version = imposm3.Version
+	if m.Created.IsZero() {
+		m.Created = newManifest().Created
+	}
+	return writeManifest(dir, m)
+}
+
+// coordsMatchBunchSize returns true if all coords of the DeltaCoordsCache
+// at path are stored in the bunch of bunchSize. Each bunch contains the
+// node IDs, so the check does not require the original bunch size.
+func coordsMatchBunchSize(path string, bunchSize int) (bool, error) {
+	c, err := newDeltaCoordsCache(path)
+	if err != nil {
+		return false, err
+	}
+	defer c.Close()
+	it := c.db.NewIterator()
+	defer it.Close()
+	var nodes []osm.Node
+	for it.SeekToFirst(); it.Valid(); it.Next() {
+		bunchID := idFromKeyBuf(it.Key())
+		nodes, err = binary.UnmarshalDeltaNodes(it.Value(), nodes)
+		if err != nil {
+			return false, err
+		}
+		for _, nd := range nodes {
+			if nd.ID/int64(bunchSize) != bunchID {
+				return false, nil
+			}
+		}
+	}
+	return true, it.Err()
+}
+
+// rewriteCoords rewrites the DeltaCoordsCache with the configured
+// BunchSize. The coords are written into a new cache that replaces the
+// existing one.
+func rewriteCoords(dir string) error {
+	path := filepath.Join(dir, "coords")
+	tmpPath := filepath.Join(dir, "coords.migrate")
+	oldPath := filepath.Join(dir, "coords.old")
+	if err := os.RemoveAll(tmpPath); err != nil {
+		return err
+	}
+
+	// Iter does not depend on the bunch size
+	src, err := newDeltaCoordsCache(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := newDeltaCoordsCache(tmpPath)
+	if err != nil {
+		return err
+	}
+	dst.SetLinearImport(true)
+
+	coords := src.Iter()
+	batch := make([]osm.Node, 0, 64*1024)
+	var n int64
+	for nodes := range coords {
+		batch = append(batch, nodes...)
+		if len(batch) >= cap(batch)-len(nodes) {
+			if err := dst.PutCoords(batch); err != nil {
+				drain(coords)
+				dst.Close()
+				return err
+			}
+			n += int64(len(batch))
+			batch = batch[:0]
+		}
+	}
+	if err := dst.PutCoords(batch); err != nil {
+		dst.Close()
+		return err
+	}
+	n += int64(len(batch))
+	if err := dst.Close(); err != nil {
+		return err
+	}
+	src.Close()
+	log.Printf("[info] rewrote %d coords", n)
+
+	if err := os.RemoveAll(oldPath); err != nil {
+		return err
+	}
+	if err := os.Rename(path, oldPath); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return errors.New("moving new coords into place failed, the old coords are in " + oldPath + ": " + err.Error())
+	}
+	return os.RemoveAll(oldPath)
+}
@@ -1,13 +1,13 @@
 package cache
 
 import (
-	"github.com/jmhodges/levigo"
 	osm "github.com/omniscale/go-osm"
 	"github.com/omniscale/imposm3/cache/binary"
 )
 
 type NodesCache struct {
 	cache
+	tags *binary.TagDict
 }
 
 func newNodesCache(path string) (*NodesCache, error) {
@@ -28,15 +28,15 @@ func (p *NodesCache) PutNode(node *osm.Node) error {
 		return nil
 	}
 	keyBuf := idToKeyBuf(node.ID)
-	data, err := binary.MarshalNode(node)
+	data, err := binary.MarshalNode(node, p.tags)
 	if err != nil {
 		return err
 	}
-	return p.db.Put(p.wo, keyBuf, data)
+	return p.db.Put(keyBuf, data)
 }
 
 func (p *NodesCache) PutNodes(nodes []osm.Node) (int, error) {
-	batch := levigo.NewWriteBatch()
+	batch := p.db.NewBatch()
 	defer batch.Close()
 
 	var n int
@@ -48,26 +48,26 @@ func (p *NodesCache) PutNodes(nodes []osm.Node) (int, error) {
 			continue
 		}
 		keyBuf := idToKeyBuf(node.ID)
-		data, err := binary.MarshalNode(&node)
+		data, err := binary.MarshalNode(&node, p.tags)
 		if err != nil {
 			return 0, err
 		}
 		batch.Put(keyBuf, data)
 		n++
 	}
-	return n, p.db.Write(p.wo, batch)
+	return n, p.db.Write(batch)
 }
 
 func (p *NodesCache) GetNode(id int64) (*osm.Node, error) {
 	keyBuf := idToKeyBuf(id)
-	data, err := p.db.Get(p.ro, keyBuf)
+	data, err := p.db.Get(keyBuf)
 	if err != nil {
 		return nil, err
 	}
 	if data == nil {
 		return nil, NotFound
 	}
-	node, err := binary.UnmarshalNode(data)
+	node, err := binary.UnmarshalNode(data, p.tags)
 	if err != nil {
 		return nil, err
 	}
@@ -77,15 +77,13 @@ func (p *NodesCache) GetNode(id int64) (*osm.Node, error) {
 
 func (p *NodesCache) DeleteNode(id int64) error {
 	keyBuf := idToKeyBuf(id)
-	return p.db.Delete(p.wo, keyBuf)
+	return p.db.Delete(keyBuf)
 }
 
 func (p *NodesCache) Iter() chan *osm.Node {
 	nodes := make(chan *osm.Node)
 	go func() {
-		ro := levigo.NewReadOptions()
-		ro.SetFillCache(false)
-		it := p.db.NewIterator(ro)
+		it := p.db.NewIterator()
 		// we need to Close the iter before closing the
 		// chan (and thus signaling that we are done)
 		// to avoid race where db is closed before the iterator
@@ -93,7 +91,7 @@ func (p *NodesCache) Iter() chan *osm.Node {
 		defer it.Close()
 		it.SeekToFirst()
 		for ; it.Valid(); it.Next() {
-			node, err := binary.UnmarshalNode(it.Value())
+			node, err := binary.UnmarshalNode(it.Value(), p.tags)
 			if err != nil {
 				panic(err)
 			}
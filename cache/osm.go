@@ -3,11 +3,13 @@ package cache
 import (
 	bin "encoding/binary"
 	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
 
-	"github.com/jmhodges/levigo"
 	osm "github.com/omniscale/go-osm"
+	"github.com/omniscale/imposm3/cache/binary"
+	"github.com/omniscale/imposm3/cache/kv"
 )
 
 var (
@@ -16,13 +18,40 @@ var (
 
 const SKIP int64 = -1
 
+const tagDictFilename = "tagdict.json"
+
+// CoordsCache stores the coordinates of all nodes. It is implemented by
+// DeltaCoordsCache and FlatCoordsCache.
+type CoordsCache interface {
+	GetCoord(id int64) (*osm.Node, error)
+	PutCoords(nodes []osm.Node) error
+	DeleteCoord(id int64) error
+	FillWay(way *osm.Way) error
+	FirstRefIsCached(refs []int64) (bool, error)
+	SetLinearImport(bool)
+	SetReadOnly(bool)
+	// Count returns the number of cached coords.
+	Count() (int64, error)
+	// Iter returns all coords in batches, sorted by ID.
+	Iter() chan []osm.Node
+	Flush() error
+	Close() error
+}
+
 type OSMCache struct {
 	dir       string
-	Coords    *DeltaCoordsCache
+	Coords    CoordsCache
 	Ways      *WaysCache
 	Nodes     *NodesCache
 	Relations *RelationsCache
 	opened    bool
+	// FlatCoords enables the FlatCoordsCache for new caches. Existing
+	// caches are opened with the coords cache they were created with.
+	FlatCoords bool
+	// TagDict is stored with new caches and used to encode the tags of all
+	// elements. Existing caches are opened with the dictionary they were
+	// created with, if any.
+	TagDict *binary.TagDict
 }
 
 func (c *OSMCache) Close() {
@@ -50,11 +79,22 @@ func NewOSMCache(dir string) *OSMCache {
 }
 
 func (c *OSMCache) Open() error {
+	exists := c.Exists()
 	err := os.MkdirAll(c.dir, 0755)
 	if err != nil {
 		return err
 	}
-	c.Coords, err = newDeltaCoordsCache(filepath.Join(c.dir, "coords"))
+	manifest, err := openManifest(c.dir, exists)
+	if err != nil {
+		return err
+	}
+	if err := checkCoordsBunchSize(c.dir, manifest); err != nil {
+		return err
+	}
+	if err := c.openTagDict(exists); err != nil {
+		return err
+	}
+	c.Coords, err = c.openCoords()
 	if err != nil {
 		return err
 	}
@@ -73,10 +113,79 @@ func (c *OSMCache) Open() error {
 		c.Close()
 		return err
 	}
+	c.Nodes.tags = c.TagDict
+	c.Ways.tags = c.TagDict
+	c.Relations.tags = c.TagDict
 	c.opened = true
 	return nil
 }
 
+// openTagDict reads the tag dictionary of existing caches or writes
+// c.TagDict for new caches. Caches without a dictionary only use the static
+// table of common tags.
+func (c *OSMCache) openTagDict(exists bool) error {
+	path := filepath.Join(c.dir, tagDictFilename)
+	f, err := os.Open(path)
+	if err == nil {
+		defer f.Close()
+		c.TagDict, err = binary.ReadTagDict(f)
+		if err != nil {
+			return fmt.Errorf("reading %s: %w", path, err)
+		}
+		return nil
+	}
+	if !os.IsNotExist(err) {
+		return err
+	}
+	if c.TagDict == nil {
+		return nil
+	}
+	if exists {
+		return errors.New("existing cache was created without tag dictionary")
+	}
+
+	tmp := path + ".tmp"
+	f, err = os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if err := c.TagDict.Write(f); err != nil {
+		f.Close()
+		return fmt.Errorf("writing %s: %w", tmp, err)
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func (c *OSMCache) openCoords() (CoordsCache, error) {
+	flatPath := filepath.Join(c.dir, "coords.flat")
+	_, err := os.Stat(flatPath)
+	flat := err == nil
+	if _, err := os.Stat(filepath.Join(c.dir, "coords")); err == nil {
+		if flat {
+			return nil, errors.New("cache contains coords and coords.flat")
+		}
+		if c.FlatCoords {
+			return nil, errors.New("existing cache was created without flat coords")
+		}
+	}
+	// return untyped nil on errors, Close checks for c.Coords != nil
+	if flat || c.FlatCoords {
+		coords, err := newFlatCoordsCache(flatPath)
+		if err != nil {
+			return nil, err
+		}
+		return coords, nil
+	}
+	coords, err := newDeltaCoordsCache(filepath.Join(c.dir, "coords"))
+	if err != nil {
+		return nil, err
+	}
+	return coords, nil
+}
+
 func (c *OSMCache) Exists() bool {
 	if c.opened {
 		return true
@@ -84,6 +193,9 @@ func (c *OSMCache) Exists() bool {
 	if _, err := os.Stat(filepath.Join(c.dir, "coords")); !os.IsNotExist(err) {
 		return true
 	}
+	if _, err := os.Stat(filepath.Join(c.dir, "coords.flat")); !os.IsNotExist(err) {
+		return true
+	}
 	if _, err := os.Stat(filepath.Join(c.dir, "nodes")); !os.IsNotExist(err) {
 		return true
 	}
@@ -96,6 +208,9 @@ func (c *OSMCache) Exists() bool {
 	if _, err := os.Stat(filepath.Join(c.dir, "inserted_ways")); !os.IsNotExist(err) {
 		return true
 	}
+	if _, err := os.Stat(filepath.Join(c.dir, tagDictFilename)); !os.IsNotExist(err) {
+		return true
+	}
 	return false
 }
 
@@ -106,6 +221,9 @@ func (c *OSMCache) Remove() error {
 	if err := os.RemoveAll(filepath.Join(c.dir, "coords")); err != nil {
 		return err
 	}
+	if err := os.RemoveAll(filepath.Join(c.dir, "coords.flat")); err != nil {
+		return err
+	}
 	if err := os.RemoveAll(filepath.Join(c.dir, "nodes")); err != nil {
 		return err
 	}
@@ -118,6 +236,9 @@ func (c *OSMCache) Remove() error {
 	if err := os.RemoveAll(filepath.Join(c.dir, "inserted_ways")); err != nil {
 		return err
 	}
+	if err := os.RemoveAll(filepath.Join(c.dir, tagDictFilename)); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -149,46 +270,16 @@ func (c *OSMCache) FirstMemberIsCached(members []osm.Member) (bool, error) {
 }
 
 type cache struct {
-	db      *levigo.DB
+	db      kv.Store
 	options *cacheOptions
-	cache   *levigo.Cache
-	wo      *levigo.WriteOptions
-	ro      *levigo.ReadOptions
 }
 
 func (c *cache) open(path string) error {
-	opts := levigo.NewOptions()
-	opts.SetCreateIfMissing(true)
-	if c.options.CacheSizeM > 0 {
-		c.cache = levigo.NewLRUCache(c.options.CacheSizeM * 1024 * 1024)
-		opts.SetCache(c.cache)
-	}
-	if c.options.MaxOpenFiles > 0 {
-		opts.SetMaxOpenFiles(c.options.MaxOpenFiles)
-	}
-	if c.options.BlockRestartInterval > 0 {
-		opts.SetBlockRestartInterval(c.options.BlockRestartInterval)
-	}
-	if c.options.WriteBufferSizeM > 0 {
-		opts.SetWriteBufferSize(c.options.WriteBufferSizeM * 1024 * 1024)
-	}
-	if c.options.BlockSizeK > 0 {
-		opts.SetBlockSize(c.options.BlockSizeK * 1024)
-	}
-	if c.options.MaxFileSizeM > 0 {
-		// max file size option is only available with LevelDB 1.21 and higher
-		// build with -tags="ldppost121" to enable this option.
-		setMaxFileSize(opts, c.options.MaxFileSizeM*1024*1024)
-	}
-
-	db, err := levigo.Open(path, opts)
+	db, err := kv.Open(globalCacheOptions.Backend, path, kv.Options(*c.options))
 	if err != nil {
 		return err
 	}
 	c.db = db
-	c.wo = levigo.NewWriteOptions()
-	c.ro = levigo.NewReadOptions()
-
 	return nil
 }
 
@@ -203,20 +294,8 @@ func idFromKeyBuf(buf []byte) int64 {
 }
 
 func (c *cache) Close() {
-	if c.ro != nil {
-		c.ro.Close()
-		c.ro = nil
-	}
-	if c.wo != nil {
-		c.wo.Close()
-		c.wo = nil
-	}
 	if c.db != nil {
 		c.db.Close()
 		c.db = nil
 	}
-	if c.cache != nil {
-		c.cache.Close()
-		c.cache = nil
-	}
 }
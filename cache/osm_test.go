@@ -4,9 +4,11 @@ import (
 	"io/ioutil"
 	"math/rand"
 	"os"
+	"reflect"
 	"testing"
 
 	osm "github.com/omniscale/go-osm"
+	"github.com/omniscale/imposm3/cache/binary"
 )
 
 func TestCreateCache(t *testing.T) {
@@ -179,3 +181,60 @@ func TestIDs(t *testing.T) {
 	}
 
 }
+
+func TestOSMCacheTagDict(t *testing.T) {
+	cacheDir, _ := ioutil.TempDir("", "imposm_test")
+	defer os.RemoveAll(cacheDir)
+
+	dict, err := binary.NewTagDict([][2]string{{"foo", "bar"}}, []string{"operator"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := NewOSMCache(cacheDir)
+	c.TagDict = dict
+	if err := c.Open(); err != nil {
+		t.Fatal(err)
+	}
+	tags := osm.Tags{"foo": "bar", "operator": "baz"}
+	if err := c.Nodes.PutNode(&osm.Node{Element: osm.Element{ID: 1, Tags: tags}}); err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+
+	// existing dictionary is used
+	c = NewOSMCache(cacheDir)
+	if err := c.Open(); err != nil {
+		t.Fatal(err)
+	}
+	if c.TagDict == nil || c.TagDict.NumTags() != 1 || c.TagDict.NumKeys() != 1 {
+		t.Fatal("dictionary not loaded", c.TagDict)
+	}
+	nd, err := c.Nodes.GetNode(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(nd.Tags, tags) {
+		t.Error("unexpected tags", nd.Tags)
+	}
+	c.Close()
+
+	if err := c.Remove(); err != nil {
+		t.Fatal(err)
+	}
+	if NewOSMCache(cacheDir).Exists() {
+		t.Error("cache exists after Remove")
+	}
+
+	// no dictionary for existing caches without dictionary
+	c = NewOSMCache(cacheDir)
+	if err := c.Open(); err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+	c = NewOSMCache(cacheDir)
+	c.TagDict = dict
+	if err := c.Open(); err == nil {
+		c.Close()
+		t.Error("expected error for existing cache without dictionary")
+	}
+}
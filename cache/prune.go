@@ -0,0 +1,362 @@
+package cache
+
+import (
+	"math"
+
+	osm "github.com/omniscale/go-osm"
+	"github.com/omniscale/imposm3/cache/binary"
+	"github.com/omniscale/imposm3/element"
+	"github.com/omniscale/imposm3/log"
+)
+
+// PruneStats are the number of elements that were removed by Prune.
+type PruneStats struct {
+	Coords    int64
+	Nodes     int64
+	Ways      int64
+	Relations int64
+	IndexRefs int64
+}
+
+// PruneArea is the area of the elements that are kept by Prune.
+type PruneArea interface {
+	// Intersects returns true if the location is within the area.
+	Intersects(long, lat float64) bool
+	// IntersectsBBox returns true if the bounding box intersects the area.
+	IntersectsBBox(minLong, minLat, maxLong, maxLat float64) bool
+}
+
+// Prune removes all elements from the caches that cannot affect any
+// element within the area. It keeps:
+//
+//   - all nodes within the area,
+//   - all ways with a bounding box that intersects the area, this includes
+//     ways that cross or enclose the area without a node inside,
+//   - all relations with a node or way of above as member, or with a
+//     bounding box of their node and way members that intersects the area,
+//   - all ways and relations that reference these nodes or ways in the
+//     ref indexes of the diffCache,
+//   - all members of the kept relations (including the members of
+//     sub-relations) and all nodes of the kept ways.
+//
+// The locations of ways that were cached with their nodes (e.g. from PBF
+// files with locations on ways) are used if there are no coords.
+//
+// Entries of removed elements are also removed from the diffCache. The
+// diffCache is optional. The removed entries only free disk space after
+// Compact.
+func Prune(osmCache *OSMCache, diffCache *DiffCache, area PruneArea) (PruneStats, error) {
+	var stats PruneStats
+
+	inside := make(IDSet)
+	ways := make(IDSet)
+	rels := make(IDSet)
+
+	step := log.Step("Selecting nodes")
+	selectNode := func(nd *osm.Node) {
+		if !area.Intersects(nd.Long, nd.Lat) {
+			return
+		}
+		inside.Add(nd.ID)
+		if diffCache != nil {
+			for _, id := range diffCache.Coords.Get(nd.ID) {
+				ways.Add(id)
+			}
+			for _, id := range diffCache.CoordsRel.Get(nd.ID) {
+				rels.Add(id)
+			}
+		}
+	}
+	for batch := range osmCache.Coords.Iter() {
+		for i := range batch {
+			selectNode(&batch[i])
+		}
+	}
+	for nd := range osmCache.Nodes.Iter() {
+		selectNode(nd)
+	}
+	step()
+
+	step = log.Step("Selecting ways")
+	waysIter := osmCache.Ways.Iter()
+	defer drain(waysIter)
+	for w := range waysIter {
+		if !ways.Has(w.ID) && selectWay(osmCache, w, inside, area) {
+			ways.Add(w.ID)
+		}
+		if ways.Has(w.ID) && diffCache != nil {
+			for _, id := range diffCache.Ways.Get(w.ID) {
+				rels.Add(id)
+			}
+		}
+	}
+	step()
+
+	step = log.Step("Selecting relations")
+	// relation members of all relations, to keep the sub-relations
+	subRels := make(map[int64][]int64)
+	relsIter := osmCache.Relations.Iter()
+	defer drain(relsIter)
+	for rel := range relsIter {
+		for _, m := range rel.Members {
+			if m.Type == osm.RelationMember {
+				subRels[rel.ID] = append(subRels[rel.ID], m.ID)
+			}
+		}
+		if !rels.Has(rel.ID) && selectRelation(osmCache, rel, inside, ways, area) {
+			rels.Add(rel.ID)
+		}
+	}
+	var queue []int64
+	for id := range subRels {
+		if rels.Has(id) {
+			queue = append(queue, id)
+		}
+	}
+	for len(queue) > 0 {
+		id := queue[len(queue)-1]
+		queue = queue[:len(queue)-1]
+		for _, sub := range subRels[id] {
+			if !rels.Has(sub) {
+				rels.Add(sub)
+				queue = append(queue, sub)
+			}
+		}
+	}
+	step()
+
+	keepWays := make(IDSet)
+	keepNodes := make(IDSet)
+	step = log.Step("Pruning relations")
+	relsIter = osmCache.Relations.Iter()
+	defer drain(relsIter)
+	for rel := range relsIter {
+		if !rels.Has(rel.ID) {
+			if err := osmCache.Relations.DeleteRelation(rel.ID); err != nil {
+				return stats, err
+			}
+			stats.Relations++
+			continue
+		}
+		for _, m := range rel.Members {
+			switch m.Type {
+			case osm.NodeMember:
+				keepNodes.Add(m.ID)
+			case osm.WayMember:
+				keepWays.Add(m.ID)
+			}
+		}
+	}
+	step()
+
+	step = log.Step("Pruning ways")
+	waysIter = osmCache.Ways.Iter()
+	defer drain(waysIter)
+	for w := range waysIter {
+		if !ways.Has(w.ID) && !keepWays.Has(w.ID) {
+			if err := osmCache.Ways.DeleteWay(w.ID); err != nil {
+				return stats, err
+			}
+			stats.Ways++
+			continue
+		}
+		keepWays.Add(w.ID)
+		for _, ref := range w.Refs {
+			keepNodes.Add(ref)
+		}
+	}
+	step()
+
+	keepNode := func(id int64) bool { return inside.Has(id) || keepNodes.Has(id) }
+
+	step = log.Step("Pruning nodes")
+	coordsIter := osmCache.Coords.Iter()
+	defer drain(coordsIter)
+	for batch := range coordsIter {
+		for _, nd := range batch {
+			if !keepNode(nd.ID) {
+				if err := osmCache.Coords.DeleteCoord(nd.ID); err != nil {
+					return stats, err
+				}
+				stats.Coords++
+			}
+		}
+	}
+	if err := osmCache.Coords.Flush(); err != nil {
+		return stats, err
+	}
+	nodesIter := osmCache.Nodes.Iter()
+	defer drain(nodesIter)
+	for nd := range nodesIter {
+		if !keepNode(nd.ID) {
+			if err := osmCache.Nodes.DeleteNode(nd.ID); err != nil {
+				return stats, err
+			}
+			stats.Nodes++
+		}
+	}
+	step()
+
+	if diffCache == nil {
+		return stats, nil
+	}
+	step = log.Step("Pruning ref indexes")
+	for _, p := range []struct {
+		index *bunchRefCache
+		keep  func(int64) bool
+		ref   func(int64) bool
+	}{
+		{diffCache.Coords.bunchRefCache, keepNode, keepWays.Has},
+		{diffCache.CoordsRel.bunchRefCache, keepNode, rels.Has},
+		{diffCache.Ways.bunchRefCache, keepWays.Has, rels.Has},
+	} {
+		n, err := p.index.prune(p.keep, p.ref)
+		if err != nil {
+			return stats, err
+		}
+		stats.IndexRefs += n
+	}
+	step()
+	return stats, nil
+}
+
+// selectWay returns true if the way has a node inside or if the bbox of
+// the way intersects the area.
+func selectWay(osmCache *OSMCache, w *osm.Way, inside IDSet, area PruneArea) bool {
+	for _, ref := range w.Refs {
+		if inside.Has(ref) {
+			return true
+		}
+	}
+	b := newPruneBBox()
+	b.extendWay(osmCache, w)
+	return b.intersects(area)
+}
+
+// selectRelation returns true if the relation has a selected node or way
+// as member or if the bbox of the node and way members intersects the area.
+func selectRelation(osmCache *OSMCache, rel *osm.Relation, inside, ways IDSet, area PruneArea) bool {
+	for _, m := range rel.Members {
+		switch m.Type {
+		case osm.NodeMember:
+			if inside.Has(m.ID) {
+				return true
+			}
+		case osm.WayMember:
+			if ways.Has(m.ID) {
+				return true
+			}
+		}
+	}
+	b := newPruneBBox()
+	for _, m := range rel.Members {
+		switch m.Type {
+		case osm.NodeMember:
+			nd, err := osmCache.Coords.GetCoord(m.ID)
+			if err != nil {
+				nd, err = osmCache.Nodes.GetNode(m.ID)
+			}
+			if err == nil {
+				b.extend(nd.Long, nd.Lat)
+			}
+		case osm.WayMember:
+			if w, err := osmCache.Ways.GetWay(m.ID); err == nil {
+				b.extendWay(osmCache, w)
+			}
+		}
+	}
+	return b.intersects(area)
+}
+
+// pruneBBox is the bbox of the locations of an element. Missing nodes are
+// ignored.
+type pruneBBox struct {
+	minLong, minLat, maxLong, maxLat float64
+}
+
+func newPruneBBox() pruneBBox {
+	return pruneBBox{math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)}
+}
+
+func (b *pruneBBox) extend(long, lat float64) {
+	b.minLong = math.Min(b.minLong, long)
+	b.minLat = math.Min(b.minLat, lat)
+	b.maxLong = math.Max(b.maxLong, long)
+	b.maxLat = math.Max(b.maxLat, lat)
+}
+
+// extendWay extends the bbox by the nodes of the way. It uses the nodes of
+// ways that were cached with their locations and the coords cache
+// otherwise.
+func (b *pruneBBox) extendWay(osmCache *OSMCache, w *osm.Way) {
+	if len(w.Refs) > 0 && len(w.Nodes) == len(w.Refs) {
+		for _, nd := range w.Nodes {
+			b.extend(nd.Long, nd.Lat)
+		}
+		return
+	}
+	for _, ref := range w.Refs {
+		if nd, err := osmCache.Coords.GetCoord(ref); err == nil {
+			b.extend(nd.Long, nd.Lat)
+		}
+	}
+}
+
+// intersects returns false for empty bboxes.
+func (b *pruneBBox) intersects(area PruneArea) bool {
+	if b.minLong > b.maxLong {
+		return false
+	}
+	return area.IntersectsBBox(b.minLong, b.minLat, b.maxLong, b.maxLat)
+}
+
+// prune removes all IDs and refs that are not kept. Returns the number of
+// removed refs.
+func (index *bunchRefCache) prune(keepID, keepRef func(int64) bool) (int64, error) {
+	it := index.db.NewIterator()
+	defer it.Close()
+	var removed int64
+	for it.SeekToFirst(); it.Valid(); it.Next() {
+		idRefs := binary.UnmarshalIDRefsBunch(it.Value())
+		pruned := make([]element.IDRefs, 0, len(idRefs))
+		changed := false
+		for _, idRef := range idRefs {
+			refs := idRef.Refs[:0]
+			for _, ref := range idRef.Refs {
+				if keepID(idRef.ID) && keepRef(ref) {
+					refs = append(refs, ref)
+				}
+			}
+			if len(refs) != len(idRef.Refs) {
+				removed += int64(len(idRef.Refs) - len(refs))
+				changed = true
+			}
+			if len(refs) > 0 {
+				pruned = append(pruned, element.IDRefs{ID: idRef.ID, Refs: refs})
+			} else {
+				changed = true
+			}
+		}
+		if !changed {
+			continue
+		}
+		// iterator does not see changes, the key can be updated
+		key := append([]byte(nil), it.Key()...)
+		var err error
+		if len(pruned) == 0 {
+			err = index.db.Delete(key)
+		} else {
+			err = index.db.Put(key, binary.MarshalIDRefsBunch(pruned))
+		}
+		if err != nil {
+			return removed, err
+		}
+	}
+	return removed, it.Err()
+}
+
+// drain reads all remaining elements to stop the iterator.
+func drain[T any](c chan T) {
+	for range c {
+	}
+}
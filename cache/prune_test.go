@@ -0,0 +1,181 @@
+package cache
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"sort"
+	"testing"
+
+	osm "github.com/omniscale/go-osm"
+)
+
+func TestPrune(t *testing.T) {
+	cacheDir, _ := ioutil.TempDir("", "imposm_test")
+	defer os.RemoveAll(cacheDir)
+
+	c := NewOSMCache(cacheDir)
+	if err := c.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	dc := NewDiffCache(cacheDir)
+	if err := dc.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer dc.Close()
+
+	var coords []osm.Node
+	for id := int64(1); id <= 6; id++ {
+		coords = append(coords, osm.Node{Element: osm.Element{ID: id}, Long: float64(id)})
+	}
+	for id, ll := range map[int64][2]float64{
+		// ring around the area
+		7: {0, -5}, 8: {5, -5}, 9: {5, 5}, 10: {0, 5},
+		// corners of a multipolygon around the area
+		11: {-5, -5}, 12: {10, -5}, 13: {10, 10}, 14: {-5, 10},
+		// way of a sub-relation, outside
+		40: {20, 20}, 41: {21, 21},
+	} {
+		coords = append(coords, osm.Node{Element: osm.Element{ID: id}, Long: ll[0], Lat: ll[1]})
+	}
+	sort.Slice(coords, func(i, j int) bool { return coords[i].ID < coords[j].ID })
+	if err := c.Coords.PutCoords(coords); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Coords.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	for _, nd := range []osm.Node{
+		{Element: osm.Element{ID: 2, Tags: osm.Tags{"amenity": "cafe"}}, Long: 2},
+		{Element: osm.Element{ID: 4, Tags: osm.Tags{"amenity": "bar"}}, Long: 4},
+	} {
+		if err := c.Nodes.PutNode(&nd); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// way 10 crosses the area without a node inside, 12 is only kept as
+	// member of relation 20, 13 encloses the area, 14 and 15 are cached
+	// with their locations and without coords
+	for _, w := range []osm.Way{
+		{Element: osm.Element{ID: 10}, Refs: []int64{1, 3}},
+		{Element: osm.Element{ID: 11}, Refs: []int64{4, 5}},
+		{Element: osm.Element{ID: 12}, Refs: []int64{5, 6}},
+		{Element: osm.Element{ID: 13}, Refs: []int64{7, 8, 9, 10, 7}},
+		{Element: osm.Element{ID: 14}, Refs: []int64{30, 31}, Nodes: []osm.Node{
+			{Element: osm.Element{ID: 30}, Long: 2, Lat: 0.5}, {Element: osm.Element{ID: 31}, Long: 2, Lat: 0.6}}},
+		{Element: osm.Element{ID: 15}, Refs: []int64{32, 33}, Nodes: []osm.Node{
+			{Element: osm.Element{ID: 32}, Long: 10, Lat: 10}, {Element: osm.Element{ID: 33}, Long: 11, Lat: 11}}},
+		{Element: osm.Element{ID: 16}, Refs: []int64{11, 12}},
+		{Element: osm.Element{ID: 17}, Refs: []int64{12, 13}},
+		{Element: osm.Element{ID: 18}, Refs: []int64{13, 14}},
+		{Element: osm.Element{ID: 19}, Refs: []int64{14, 11}},
+		{Element: osm.Element{ID: 20}, Refs: []int64{40, 41}},
+	} {
+		for _, ref := range w.Refs {
+			dc.Coords.Add(ref, w.ID)
+		}
+		// PutWay delta encodes the refs in place
+		if err := c.Ways.PutWay(&w); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// relation 22 is a multipolygon around the area, relation 24 is kept as
+	// member of 23
+	for _, rel := range []osm.Relation{
+		{Element: osm.Element{ID: 20}, Members: []osm.Member{
+			{Type: osm.WayMember, ID: 10}, {Type: osm.WayMember, ID: 12}}},
+		{Element: osm.Element{ID: 21}, Members: []osm.Member{
+			{Type: osm.WayMember, ID: 11}}},
+		{Element: osm.Element{ID: 22}, Members: []osm.Member{
+			{Type: osm.WayMember, ID: 16}, {Type: osm.WayMember, ID: 17},
+			{Type: osm.WayMember, ID: 18}, {Type: osm.WayMember, ID: 19}}},
+		{Element: osm.Element{ID: 23}, Members: []osm.Member{
+			{Type: osm.NodeMember, ID: 2}, {Type: osm.RelationMember, ID: 24}}},
+		{Element: osm.Element{ID: 24}, Members: []osm.Member{
+			{Type: osm.WayMember, ID: 20}}},
+	} {
+		if err := c.Relations.PutRelation(&rel); err != nil {
+			t.Fatal(err)
+		}
+		for _, m := range rel.Members {
+			if m.Type == osm.WayMember {
+				dc.Ways.Add(m.ID, rel.ID)
+			}
+		}
+	}
+	dc.Flush()
+
+	stats, err := Prune(c, dc, testArea{1.5, -1, 2.5, 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats != (PruneStats{Coords: 1, Nodes: 1, Ways: 2, Relations: 1, IndexRefs: 5}) {
+		t.Errorf("unexpected stats %#v", stats)
+	}
+
+	for _, id := range []int64{1, 2, 3, 5, 6, 7, 11, 40, 41} {
+		if _, err := c.Coords.GetCoord(id); err != nil {
+			t.Error("missing coord", id, err)
+		}
+	}
+	if _, err := c.Coords.GetCoord(4); err != NotFound {
+		t.Error("coord 4 not pruned", err)
+	}
+	if _, err := c.Nodes.GetNode(2); err != nil {
+		t.Error("missing node 2", err)
+	}
+	if _, err := c.Nodes.GetNode(4); err != NotFound {
+		t.Error("node 4 not pruned", err)
+	}
+	for _, id := range []int64{10, 12, 13, 14, 16, 17, 18, 19, 20} {
+		if _, err := c.Ways.GetWay(id); err != nil {
+			t.Error("missing way", id, err)
+		}
+	}
+	for _, id := range []int64{11, 15} {
+		if _, err := c.Ways.GetWay(id); err != NotFound {
+			t.Error("way not pruned", id, err)
+		}
+	}
+	for _, id := range []int64{20, 22, 23, 24} {
+		if _, err := c.Relations.GetRelation(id); err != nil {
+			t.Error("missing relation", id, err)
+		}
+	}
+	if _, err := c.Relations.GetRelation(21); err != NotFound {
+		t.Error("relation 21 not pruned", err)
+	}
+
+	for _, check := range []struct {
+		index *bunchRefCache
+		id    int64
+		refs  []int64
+	}{
+		{dc.Coords.bunchRefCache, 1, []int64{10}},
+		{dc.Coords.bunchRefCache, 4, nil},
+		{dc.Coords.bunchRefCache, 5, []int64{12}},
+		{dc.Ways.bunchRefCache, 10, []int64{20}},
+		{dc.Ways.bunchRefCache, 11, nil},
+		{dc.Coords.bunchRefCache, 32, nil},
+		{dc.Coords.bunchRefCache, 40, []int64{20}},
+	} {
+		if refs := check.index.Get(check.id); !reflect.DeepEqual(refs, check.refs) {
+			t.Errorf("unexpected refs for %d: %v != %v", check.id, refs, check.refs)
+		}
+	}
+}
+
+// testArea is a bbox for Prune.
+type testArea struct {
+	minLong, minLat, maxLong, maxLat float64
+}
+
+func (a testArea) Intersects(long, lat float64) bool {
+	return long >= a.minLong && long <= a.maxLong && lat >= a.minLat && lat <= a.maxLat
+}
+
+func (a testArea) IntersectsBBox(minLong, minLat, maxLong, maxLat float64) bool {
+	return maxLong >= a.minLong && minLong <= a.maxLong && maxLat >= a.minLat && minLat <= a.maxLat
+}
@@ -0,0 +1,205 @@
+package query
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	osm "github.com/omniscale/go-osm"
+	"github.com/omniscale/imposm3/cache"
+	"github.com/omniscale/imposm3/cache/export"
+	"github.com/omniscale/imposm3/log"
+)
+
+// tagFilter matches elements with the key. The value is only compared if
+// it is not empty.
+type tagFilter struct {
+	key   string
+	value string
+}
+
+// parseTagFilters parses a comma separated list of key or key=value.
+// key=* is the same as key.
+func parseTagFilters(s string) ([]tagFilter, error) {
+	var filters []tagFilter
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		kv := strings.SplitN(part, "=", 2)
+		if kv[0] == "" {
+			return nil, fmt.Errorf("missing key in tag filter %q", part)
+		}
+		f := tagFilter{key: kv[0]}
+		if len(kv) == 2 && kv[1] != "*" {
+			f.value = kv[1]
+		}
+		filters = append(filters, f)
+	}
+	return filters, nil
+}
+
+// filter selects elements from the cache. Elements match if they have any
+// of the tags and if any of their nodes are within the spatial filter.
+type filter struct {
+	tags   []tagFilter
+	within export.Filter
+	// limit is the max number of results for each element type, 0 for no
+	// limit
+	limit int
+}
+
+func (f *filter) matchTags(tags osm.Tags) bool {
+	if len(f.tags) == 0 {
+		return true
+	}
+	for _, t := range f.tags {
+		if v, ok := tags[t.key]; ok && (t.value == "" || t.value == v) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *filter) matchNode(nd *osm.Node) bool {
+	if !f.matchTags(nd.Tags) {
+		return false
+	}
+	return f.within == nil || f.within(nd.Long, nd.Lat)
+}
+
+func (f *filter) matchWay(osmCache *cache.OSMCache, w *osm.Way) (bool, error) {
+	if !f.matchTags(w.Tags) {
+		return false, nil
+	}
+	if f.within == nil {
+		return true, nil
+	}
+	return f.wayWithin(osmCache, w)
+}
+
+func (f *filter) wayWithin(osmCache *cache.OSMCache, w *osm.Way) (bool, error) {
+	if w.Nodes == nil {
+		if err := osmCache.Coords.FillWay(w); err == cache.NotFound {
+			return false, nil
+		} else if err != nil {
+			return false, err
+		}
+	}
+	for _, nd := range w.Nodes {
+		if f.within(nd.Long, nd.Lat) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// matchRelation checks the node and way members of the relation. Members
+// that are not in the cache are ignored.
+func (f *filter) matchRelation(osmCache *cache.OSMCache, rel *osm.Relation) (bool, error) {
+	if !f.matchTags(rel.Tags) {
+		return false, nil
+	}
+	if f.within == nil {
+		return true, nil
+	}
+	for _, m := range rel.Members {
+		switch m.Type {
+		case osm.NodeMember:
+			nd, err := osmCache.Coords.GetCoord(m.ID)
+			if err == cache.NotFound {
+				continue
+			} else if err != nil {
+				return false, err
+			}
+			if f.within(nd.Long, nd.Lat) {
+				return true, nil
+			}
+		case osm.WayMember:
+			w, err := osmCache.Ways.GetWay(m.ID)
+			if err == cache.NotFound {
+				continue
+			} else if err != nil {
+				return false, err
+			}
+			if ok, err := f.wayWithin(osmCache, w); ok || err != nil {
+				return ok, err
+			}
+		}
+	}
+	return false, nil
+}
+
+func (f *filter) limitReached(n int, typ string) bool {
+	if f.limit > 0 && n >= f.limit {
+		log.Printf("[warn] stopped after %d %s, use -limit to query more", n, typ)
+		return true
+	}
+	return false
+}
+
+// filterNodes returns all tagged nodes that match the filter. Untagged nodes
+// are only stored as coords and they are not returned.
+func filterNodes(osmCache *cache.OSMCache, f *filter) nodes {
+	ns := make(nodes)
+	c := osmCache.Nodes.Iter()
+	defer drain(c)
+	for nd := range c {
+		if !f.matchNode(nd) {
+			continue
+		}
+		if f.limitReached(len(ns), "nodes") {
+			break
+		}
+		ns[strconv.FormatInt(nd.ID, 10)] = &node{*nd, nil}
+	}
+	return ns
+}
+
+func filterWays(osmCache *cache.OSMCache, f *filter) (ways, error) {
+	ws := make(ways)
+	c := osmCache.Ways.Iter()
+	defer drain(c)
+	for w := range c {
+		ok, err := f.matchWay(osmCache, w)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		if f.limitReached(len(ws), "ways") {
+			break
+		}
+		w.Nodes = nil
+		ws[strconv.FormatInt(w.ID, 10)] = &way{*w, nil, nil}
+	}
+	return ws, nil
+}
+
+func filterRelations(osmCache *cache.OSMCache, f *filter) (relations, error) {
+	rels := make(relations)
+	c := osmCache.Relations.Iter()
+	defer drain(c)
+	for rel := range c {
+		ok, err := f.matchRelation(osmCache, rel)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		if f.limitReached(len(rels), "relations") {
+			break
+		}
+		rels[strconv.FormatInt(rel.ID, 10)] = &relation{*rel, nil}
+	}
+	return rels, nil
+}
+
+// drain reads all remaining elements to stop the cache iterator.
+func drain[T any](c chan T) {
+	for range c {
+	}
+}
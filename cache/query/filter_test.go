@@ -0,0 +1,131 @@
+package query
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"sort"
+	"testing"
+
+	osm "github.com/omniscale/go-osm"
+	"github.com/omniscale/imposm3/cache"
+	"github.com/omniscale/imposm3/cache/export"
+)
+
+func TestParseTagFilters(t *testing.T) {
+	f, err := parseTagFilters("building, highway=primary,amenity=*,")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []tagFilter{{"building", ""}, {"highway", "primary"}, {"amenity", ""}}
+	if !reflect.DeepEqual(f, expected) {
+		t.Errorf("unexpected filters %v", f)
+	}
+	if _, err := parseTagFilters("=foo"); err == nil {
+		t.Error("expected error for missing key")
+	}
+}
+
+// createCache creates a cache with nodes 1-10 along the equator (long 1-10),
+// way 100 with nodes 1-3, way 101 with nodes 8-10, relation 200 with way 100
+// and relation 201 with node 9.
+func createCache(t *testing.T, dir string) *cache.OSMCache {
+	t.Helper()
+	osmCache := cache.NewOSMCache(dir)
+	if err := osmCache.Open(); err != nil {
+		t.Fatal(err)
+	}
+	var nodes []osm.Node
+	for i := int64(1); i <= 10; i++ {
+		nodes = append(nodes, osm.Node{Element: osm.Element{ID: i}, Long: float64(i)})
+	}
+	if err := osmCache.Coords.PutCoords(nodes); err != nil {
+		t.Fatal(err)
+	}
+	tagged := []osm.Node{
+		{Element: osm.Element{ID: 5, Tags: osm.Tags{"amenity": "cafe"}}, Long: 5},
+		{Element: osm.Element{ID: 9, Tags: osm.Tags{"highway": "bus_stop"}}, Long: 9},
+	}
+	if _, err := osmCache.Nodes.PutNodes(tagged); err != nil {
+		t.Fatal(err)
+	}
+	ways := []osm.Way{
+		{Element: osm.Element{ID: 100, Tags: osm.Tags{"highway": "residential"}}, Refs: []int64{1, 2, 3}},
+		{Element: osm.Element{ID: 101, Tags: osm.Tags{"building": "yes"}}, Refs: []int64{10, 9, 8}},
+	}
+	if err := osmCache.Ways.PutWays(ways); err != nil {
+		t.Fatal(err)
+	}
+	rels := []osm.Relation{
+		{Element: osm.Element{ID: 200, Tags: osm.Tags{"type": "route"}}, Members: []osm.Member{
+			{ID: 100, Type: osm.WayMember},
+		}},
+		{Element: osm.Element{ID: 201, Tags: osm.Tags{"type": "route"}}, Members: []osm.Member{
+			{ID: 9, Type: osm.NodeMember},
+		}},
+	}
+	if err := osmCache.Relations.PutRelations(rels); err != nil {
+		t.Fatal(err)
+	}
+	return osmCache
+}
+
+func keys[T any](m map[string]T) []string {
+	var ks []string
+	for k := range m {
+		ks = append(ks, k)
+	}
+	sort.Strings(ks)
+	return ks
+}
+
+func TestFilter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "imposm_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	osmCache := createCache(t, dir)
+	defer osmCache.Close()
+
+	for _, check := range []struct {
+		tags  string
+		bbox  []float64
+		limit int
+		nodes []string
+		ways  []string
+		rels  []string
+	}{
+		{"", []float64{0, -1, 2.5, 1}, 0, nil, []string{"100"}, []string{"200"}},
+		{"", []float64{4, -1, 9, 1}, 0, []string{"5", "9"}, []string{"101"}, []string{"201"}},
+		{"highway", nil, 0, []string{"9"}, []string{"100"}, nil},
+		{"highway=residential,building", nil, 0, nil, []string{"100", "101"}, nil},
+		{"highway", []float64{4, -1, 9, 1}, 0, []string{"9"}, nil, nil},
+		{"type=route", nil, 1, nil, nil, []string{"200"}},
+	} {
+		f := &filter{limit: check.limit}
+		if f.tags, err = parseTagFilters(check.tags); err != nil {
+			t.Fatal(err)
+		}
+		if check.bbox != nil {
+			f.within = export.BBoxFilter(check.bbox[0], check.bbox[1], check.bbox[2], check.bbox[3])
+		}
+		if actual := keys(filterNodes(osmCache, f)); !reflect.DeepEqual(actual, check.nodes) {
+			t.Errorf("%s %v: unexpected nodes %v", check.tags, check.bbox, actual)
+		}
+		ws, err := filterWays(osmCache, f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual := keys(ws); !reflect.DeepEqual(actual, check.ways) {
+			t.Errorf("%s %v: unexpected ways %v", check.tags, check.bbox, actual)
+		}
+		rels, err := filterRelations(osmCache, f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual := keys(rels); !reflect.DeepEqual(actual, check.rels) {
+			t.Errorf("%s %v: unexpected relations %v", check.tags, check.bbox, actual)
+		}
+	}
+}
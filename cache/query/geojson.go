@@ -0,0 +1,171 @@
+package query
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+
+	osm "github.com/omniscale/go-osm"
+	"github.com/omniscale/imposm3/cache"
+	"github.com/omniscale/imposm3/geom"
+	"github.com/omniscale/imposm3/geom/geojson"
+	"github.com/omniscale/imposm3/geom/geos"
+)
+
+// maxRingGap is the max distance between two nodes to close a ring of a
+// relation, same as the writers use for EPSG:4326.
+const maxRingGap = 1e-6 // ~0.1m
+
+type feature struct {
+	Type       string            `json:"type"`
+	ID         string            `json:"id"`
+	Geometry   *geojson.Geometry `json:"geometry"`
+	Properties properties        `json:"properties"`
+}
+
+type properties struct {
+	Type  string   `json:"osm_type"`
+	ID    int64    `json:"osm_id"`
+	Tags  osm.Tags `json:"tags"`
+	Error string   `json:"error,omitempty"`
+}
+
+type featureCollection struct {
+	Type     string    `json:"type"`
+	Features []feature `json:"features"`
+}
+
+// sortedIDs returns the keys of the result maps, sorted by ID.
+func sortedIDs[T any](m map[string]T) []string {
+	ids := make([]string, 0, len(m))
+	for id := range m {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		a, _ := strconv.ParseInt(ids[i], 10, 64)
+		b, _ := strconv.ParseInt(ids[j], 10, 64)
+		return a < b
+	})
+	return ids
+}
+
+// asGeoJSON builds the geometries of all elements of the result. Geometries
+// are created with the same functions as the writers use for the import.
+// Errors (e.g. from building the rings of a multipolygon) are reported in
+// the error property. Elements that are not found in the cache are not
+// included.
+func asGeoJSON(osmCache *cache.OSMCache, res result) featureCollection {
+	g := geos.NewGeos()
+	defer g.Finish()
+
+	fc := featureCollection{Type: "FeatureCollection", Features: []feature{}}
+	for _, id := range sortedIDs(res.Nodes) {
+		if n := res.Nodes[id]; n != nil {
+			gj, err := nodeGeometry(g, &n.Node)
+			fc.Features = append(fc.Features, newFeature("node", n.ID, n.Tags, gj, err))
+		}
+	}
+	for _, id := range sortedIDs(res.Ways) {
+		if w := res.Ways[id]; w != nil {
+			gj, err := wayGeometry(g, osmCache, w.Way)
+			fc.Features = append(fc.Features, newFeature("way", w.ID, w.Tags, gj, err))
+		}
+	}
+	for _, id := range sortedIDs(res.Relations) {
+		if r := res.Relations[id]; r != nil {
+			gj, err := relationGeometry(g, osmCache, r.Relation)
+			fc.Features = append(fc.Features, newFeature("relation", r.ID, r.Tags, gj, err))
+		}
+	}
+	return fc
+}
+
+func newFeature(typ string, id int64, tags osm.Tags, g *geojson.Geometry, err error) feature {
+	f := feature{
+		Type:       "Feature",
+		ID:         typ + "/" + strconv.FormatInt(id, 10),
+		Geometry:   g,
+		Properties: properties{Type: typ, ID: id, Tags: tags},
+	}
+	if err != nil {
+		f.Properties.Error = err.Error()
+	}
+	return f
+}
+
+func toGeoJSON(g *geos.Geos, geometry *geos.Geom) (*geojson.Geometry, error) {
+	wkb := g.AsWkb(geometry)
+	if wkb == nil {
+		return nil, errors.New("unable to create WKB")
+	}
+	return geojson.FromWKB(wkb)
+}
+
+func nodeGeometry(g *geos.Geos, nd *osm.Node) (*geojson.Geometry, error) {
+	p, err := geom.Point(g, *nd)
+	if err != nil {
+		return nil, err
+	}
+	return toGeoJSON(g, p)
+}
+
+// wayGeometry returns a polygon for closed ways and a linestring for all
+// other ways. Closed ways that are not valid polygons are returned as
+// linestring, with the error of the polygon.
+func wayGeometry(g *geos.Geos, osmCache *cache.OSMCache, w osm.Way) (*geojson.Geometry, error) {
+	if err := osmCache.Coords.FillWay(&w); err != nil {
+		return nil, err
+	}
+	if w.IsClosed() {
+		poly, polyErr := geom.Polygon(g, w.Nodes)
+		if polyErr == nil {
+			return toGeoJSON(g, poly)
+		}
+		if line, err := geom.LineString(g, w.Nodes); err == nil {
+			gj, err := toGeoJSON(g, line)
+			if err != nil {
+				return nil, err
+			}
+			return gj, polyErr
+		}
+		return nil, polyErr
+	}
+	line, err := geom.LineString(g, w.Nodes)
+	if err != nil {
+		return nil, err
+	}
+	return toGeoJSON(g, line)
+}
+
+// relationGeometry builds the multipolygon of the relation, the same way as
+// the RelationWriter does.
+func relationGeometry(g *geos.Geos, osmCache *cache.OSMCache, rel osm.Relation) (*geojson.Geometry, error) {
+	// copy members, FillMembers and FillWay modify the members
+	rel.Members = append([]osm.Member(nil), rel.Members...)
+	if err := osmCache.Ways.FillMembers(rel.Members); err != nil {
+		return nil, fmt.Errorf("loading way members: %w", err)
+	}
+	for i, m := range rel.Members {
+		if m.Way == nil {
+			continue
+		}
+		if err := osmCache.Coords.FillWay(m.Way); err != nil {
+			return nil, fmt.Errorf("loading nodes of way %d: %w", m.ID, err)
+		}
+		rel.Members[i].Element = &m.Way.Element
+	}
+
+	prep, err := geom.PrepareRelation(&rel, 4326, maxRingGap)
+	if err != nil {
+		return nil, err
+	}
+	mp, err := prep.Build()
+	if mp.Geom != nil {
+		defer g.Destroy(mp.Geom)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return toGeoJSON(g, mp.Geom)
+}
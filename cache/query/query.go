@@ -2,6 +2,7 @@ package query
 
 import (
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"os"
@@ -10,6 +11,9 @@ import (
 
 	osm "github.com/omniscale/go-osm"
 	"github.com/omniscale/imposm3/cache"
+	"github.com/omniscale/imposm3/cache/export"
+	"github.com/omniscale/imposm3/geom/geos"
+	"github.com/omniscale/imposm3/geom/limit"
 	"github.com/omniscale/imposm3/log"
 )
 
@@ -22,6 +26,13 @@ var (
 	full     = flags.Bool("full", false, "recurse into relations/ways")
 	deps     = flags.Bool("deps", false, "show dependent ways/relations")
 	cachedir = flags.String("cachedir", "/tmp/imposm", "cache directory")
+
+	tags       = flags.String("tags", "", "only query elements with any of these tags (key or key=value, comma separated)")
+	bbox       = flags.String("bbox", "", "only query elements within minx,miny,maxx,maxy (EPSG:4326)")
+	limitTo    = flags.String("limitto", "", "only query elements within this GeoJSON polygon")
+	types      = flags.String("types", "node,way,rel", "element types to query with -tags/-bbox/-limitto")
+	maxResults = flags.Int("limit", 1000, "max number of elements of each type for -tags/-bbox/-limitto, 0 for no limit")
+	geoJSON    = flags.Bool("geojson", false, "output geometries as GeoJSON FeatureCollection")
 )
 
 type nodes map[string]*node
@@ -50,7 +61,7 @@ type result struct {
 	Relations relations `json:"relations,omitempty"`
 }
 
-func collectRelations(osmCache *cache.OSMCache, ids []int64, recurse bool) relations {
+func collectRelations(osmCache *cache.OSMCache, ids []int64, recurse bool) (relations, error) {
 	rels := make(relations)
 	for _, id := range ids {
 		sid := strconv.FormatInt(id, 10)
@@ -58,25 +69,31 @@ func collectRelations(osmCache *cache.OSMCache, ids []int64, recurse bool) relat
 		if err == cache.NotFound {
 			rels[sid] = nil
 		} else if err != nil {
-			log.Fatal(err)
+			return nil, err
 		} else {
 			rels[sid] = &relation{*rel, nil}
 			if recurse {
-				memberWayIDs := []int64{}
-				for _, m := range rel.Members {
-					if m.Type == osm.WayMember {
-						memberWayIDs = append(memberWayIDs, m.ID)
-					}
+				rels[sid].Ways, err = collectWays(osmCache, nil, memberWayIDs(rel), true, false)
+				if err != nil {
+					return nil, err
 				}
-				rels[sid].Ways = collectWays(osmCache, nil, memberWayIDs, true, false)
-
 			}
 		}
 	}
-	return rels
+	return rels, nil
+}
+
+func memberWayIDs(rel *osm.Relation) []int64 {
+	ids := []int64{}
+	for _, m := range rel.Members {
+		if m.Type == osm.WayMember {
+			ids = append(ids, m.ID)
+		}
+	}
+	return ids
 }
 
-func collectWays(osmCache *cache.OSMCache, diffCache *cache.DiffCache, ids []int64, recurse, deps bool) ways {
+func collectWays(osmCache *cache.OSMCache, diffCache *cache.DiffCache, ids []int64, recurse, deps bool) (ways, error) {
 	ws := make(ways)
 	for _, id := range ids {
 		sid := strconv.FormatInt(id, 10)
@@ -84,37 +101,43 @@ func collectWays(osmCache *cache.OSMCache, diffCache *cache.DiffCache, ids []int
 		if err == cache.NotFound {
 			ws[sid] = nil
 		} else if err != nil {
-			log.Fatal(err)
+			return nil, err
 		} else {
 			ws[sid] = &way{*w, nil, nil}
 			if recurse {
-				ws[sid].Nodes = collectNodes(osmCache, nil, w.Refs, false)
+				ws[sid].Nodes, err = collectNodes(osmCache, nil, w.Refs, false)
+				if err != nil {
+					return nil, err
+				}
 			}
 			if deps {
 				rels := diffCache.Ways.Get(id)
 				if len(rels) != 0 {
-					ws[sid].Relations = collectRelations(osmCache, rels, false)
+					ws[sid].Relations, err = collectRelations(osmCache, rels, false)
+					if err != nil {
+						return nil, err
+					}
 				}
 			}
 		}
 	}
-	return ws
+	return ws, nil
 }
 
-func collectNodes(osmCache *cache.OSMCache, diffCache *cache.DiffCache, ids []int64, deps bool) nodes {
+func collectNodes(osmCache *cache.OSMCache, diffCache *cache.DiffCache, ids []int64, deps bool) (nodes, error) {
 	ns := make(nodes)
 	for _, id := range ids {
 		sid := strconv.FormatInt(id, 10)
 		n, err := osmCache.Nodes.GetNode(id)
 		if err != cache.NotFound && err != nil {
-			log.Fatal(err)
+			return nil, err
 		}
 		if n == nil {
 			n, err = osmCache.Coords.GetCoord(id)
 			if err == cache.NotFound {
 				ns[sid] = nil
 			} else if err != nil {
-				log.Fatal(err)
+				return nil, err
 			}
 		}
 		if n != nil {
@@ -122,18 +145,46 @@ func collectNodes(osmCache *cache.OSMCache, diffCache *cache.DiffCache, ids []in
 			if deps {
 				ways := diffCache.Coords.Get(id)
 				if len(ways) != 0 {
-					ns[sid].Ways = collectWays(osmCache, diffCache, ways, false, true)
+					ns[sid].Ways, err = collectWays(osmCache, diffCache, ways, false, true)
+					if err != nil {
+						return nil, err
+					}
 				}
 			}
 		}
 	}
-	return ns
+	return ns, nil
+}
+
+// lookup returns the elements with the IDs. -full and -deps are exclusive.
+func lookup(osmCache *cache.OSMCache, diffCache *cache.DiffCache, nodeIDs, wayIDs, relIDs []int64, full, deps bool) (result, error) {
+	res := result{}
+	if full && deps {
+		return res, errors.New("cannot use -full and -deps option together")
+	}
+	var err error
+	if len(relIDs) > 0 {
+		if res.Relations, err = collectRelations(osmCache, relIDs, full); err != nil {
+			return res, err
+		}
+	}
+	if len(wayIDs) > 0 {
+		if res.Ways, err = collectWays(osmCache, diffCache, wayIDs, full, deps); err != nil {
+			return res, err
+		}
+	}
+	if len(nodeIDs) > 0 {
+		if res.Nodes, err = collectNodes(osmCache, diffCache, nodeIDs, deps); err != nil {
+			return res, err
+		}
+	}
+	return res, nil
 }
 
 func Usage() {
 	fmt.Fprintf(os.Stderr, "Usage of %s %s:\n\n", os.Args[0], os.Args[1])
 	flags.PrintDefaults()
-	fmt.Fprintln(os.Stderr, "\nQuery cache for nodes/ways/relations.")
+	fmt.Fprintln(os.Stderr, "\nQuery cache for nodes/ways/relations by ID or with -tags/-bbox/-limitto.")
 	os.Exit(1)
 }
 
@@ -145,16 +196,19 @@ func printJSON(obj interface{}) {
 	fmt.Println(string(bytes))
 }
 
-func splitIDs(ids string) []int64 {
+func splitIDs(ids string) ([]int64, error) {
 	result := []int64{}
+	if ids == "" {
+		return result, nil
+	}
 	for _, s := range strings.Split(ids, ",") {
 		id, err := strconv.ParseInt(s, 10, 64)
 		if err != nil {
-			log.Fatal(err)
+			return nil, fmt.Errorf("invalid ID %q", s)
 		}
 		result = append(result, id)
 	}
-	return result
+	return result, nil
 }
 
 func Query(args []string) {
@@ -180,26 +234,111 @@ func Query(args []string) {
 		log.Fatal(err)
 	}
 
-	if *full && *deps {
-		log.Fatal("cannot use -full and -deps option together")
+	var res result
+	if *tags != "" || *bbox != "" || *limitTo != "" {
+		if *nodeIDs != "" || *wayIDs != "" || *relIDs != "" {
+			log.Fatal("cannot use -node/-way/-rel with -tags/-bbox/-limitto")
+		}
+		f, err := newFilter()
+		if err != nil {
+			log.Fatal(err)
+		}
+		res, err = filterQuery(osmCache, diffCache, f, strings.Split(*types, ","), *full, *deps)
+		if err != nil {
+			log.Fatal(err)
+		}
+	} else {
+		var ids [3][]int64
+		for i, s := range []string{*nodeIDs, *wayIDs, *relIDs} {
+			if ids[i], err = splitIDs(s); err != nil {
+				log.Fatal(err)
+			}
+		}
+		res, err = lookup(osmCache, diffCache, ids[0], ids[1], ids[2], *full, *deps)
+		if err != nil {
+			log.Fatal(err)
+		}
 	}
 
-	result := result{}
-
-	if *relIDs != "" {
-		ids := splitIDs(*relIDs)
-		result.Relations = collectRelations(osmCache, ids, *full)
+	if *geoJSON {
+		printJSON(asGeoJSON(osmCache, res))
+	} else {
+		printJSON(res)
 	}
+}
 
-	if *wayIDs != "" {
-		ids := splitIDs(*wayIDs)
-		result.Ways = collectWays(osmCache, diffCache, ids, *full, *deps)
+// filterQuery returns all elements of these types that match the filter.
+func filterQuery(osmCache *cache.OSMCache, diffCache *cache.DiffCache, f *filter, types []string, full, deps bool) (result, error) {
+	res := result{}
+	if full && deps {
+		return res, errors.New("cannot use -full and -deps option together")
 	}
-
-	if *nodeIDs != "" {
-		ids := splitIDs(*nodeIDs)
-		result.Nodes = collectNodes(osmCache, diffCache, ids, *deps)
+	var err error
+	for _, typ := range types {
+		switch strings.TrimSpace(typ) {
+		case "node":
+			res.Nodes = filterNodes(osmCache, f)
+		case "way":
+			res.Ways, err = filterWays(osmCache, f)
+		case "rel":
+			res.Relations, err = filterRelations(osmCache, f)
+		default:
+			err = fmt.Errorf("unknown type %q in -types", typ)
+		}
+		if err != nil {
+			return res, err
+		}
 	}
+	for _, w := range res.Ways {
+		if full {
+			if w.Nodes, err = collectNodes(osmCache, nil, w.Refs, false); err != nil {
+				return res, err
+			}
+		}
+		if deps {
+			if rels := diffCache.Ways.Get(w.ID); len(rels) != 0 {
+				if w.Relations, err = collectRelations(osmCache, rels, false); err != nil {
+					return res, err
+				}
+			}
+		}
+	}
+	if full {
+		for _, r := range res.Relations {
+			if r.Ways, err = collectWays(osmCache, nil, memberWayIDs(&r.Relation), true, false); err != nil {
+				return res, err
+			}
+		}
+	}
+	return res, nil
+}
 
-	printJSON(result)
+func newFilter() (*filter, error) {
+	f := &filter{limit: *maxResults}
+	var err error
+	if f.tags, err = parseTagFilters(*tags); err != nil {
+		return nil, err
+	}
+	if *bbox != "" && *limitTo != "" {
+		return nil, errors.New("-bbox and -limitto are exclusive")
+	}
+	if *bbox != "" {
+		b, err := export.ParseBBox(*bbox)
+		if err != nil {
+			return nil, err
+		}
+		f.within = export.BBoxFilter(b[0], b[1], b[2], b[3])
+	}
+	if *limitTo != "" {
+		limiter, err := limit.NewFromGeoJSON(*limitTo, 0, 4326)
+		if err != nil {
+			return nil, fmt.Errorf("reading limitto: %w", err)
+		}
+		// handle is used until the process exits
+		g := geos.NewGeos()
+		f.within = func(long, lat float64) bool {
+			return limiter.Intersects(g, long, lat)
+		}
+	}
+	return f, nil
 }
@@ -0,0 +1,73 @@
+package query
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/omniscale/imposm3/cache"
+	"github.com/omniscale/imposm3/log"
+)
+
+// NewHandler returns a read-only HTTP handler for the same lookups as the
+// query-cache command. It expects the IDs as comma separated node, way and
+// rel parameters, and full=true or deps=true to include the dependent
+// elements, e.g. /query?way=123,456&full=true
+//
+// Each request reads from a snapshot of the opened caches. The caches need
+// to stay open until the server is closed.
+func NewHandler(osmCache *cache.OSMCache, diffCache *cache.DiffCache) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/query", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "only GET is supported", http.StatusMethodNotAllowed)
+			return
+		}
+		serveQuery(w, r, osmCache, diffCache)
+	})
+	return mux
+}
+
+func serveQuery(w http.ResponseWriter, r *http.Request, osmCache *cache.OSMCache, diffCache *cache.DiffCache) {
+	q := r.URL.Query()
+	var ids [3][]int64
+	for i, param := range []string{"node", "way", "rel"} {
+		var err error
+		if ids[i], err = splitIDs(q.Get(param)); err != nil {
+			http.Error(w, param+": "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	var opts [2]bool
+	for i, param := range []string{"full", "deps"} {
+		if v := q.Get(param); v != "" {
+			var err error
+			if opts[i], err = strconv.ParseBool(v); err != nil {
+				http.Error(w, "invalid "+param+" parameter", http.StatusBadRequest)
+				return
+			}
+		}
+	}
+	if opts[0] && opts[1] {
+		http.Error(w, "cannot use full and deps together", http.StatusBadRequest)
+		return
+	}
+
+	osmSnap := osmCache.Snapshot()
+	defer osmSnap.Close()
+	diffSnap := diffCache.Snapshot()
+	defer diffSnap.Close()
+
+	res, err := lookup(osmSnap, diffSnap, ids[0], ids[1], ids[2], opts[0], opts[1])
+	if err != nil {
+		log.Println("[error] Querying cache:", err)
+		http.Error(w, "querying cache failed", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(res); err != nil {
+		log.Println("[warn] Writing query response:", err)
+	}
+}
@@ -0,0 +1,81 @@
+package query
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/omniscale/imposm3/cache"
+)
+
+func TestHandler(t *testing.T) {
+	dir, err := ioutil.TempDir("", "imposm_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	osmCache := createCache(t, dir)
+	defer osmCache.Close()
+	diffCache := cache.NewDiffCache(dir)
+	if err := diffCache.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer diffCache.Close()
+	diffCache.Ways.Add(100, 200)
+
+	srv := httptest.NewServer(NewHandler(osmCache, diffCache))
+	defer srv.Close()
+
+	for _, check := range []struct {
+		query  string
+		status int
+		check  func(result) bool
+	}{
+		{"way=100,999", 200, func(r result) bool {
+			return r.Ways["100"] != nil && r.Ways["100"].Tags["highway"] == "residential" && r.Ways["999"] == nil
+		}},
+		{"way=100&full=true", 200, func(r result) bool {
+			return len(r.Ways["100"].Nodes) == 3
+		}},
+		{"way=100&deps=1", 200, func(r result) bool {
+			return r.Ways["100"].Relations["200"] != nil
+		}},
+		{"node=5,9&rel=201", 200, func(r result) bool {
+			return r.Nodes["5"].Tags["amenity"] == "cafe" && len(r.Relations["201"].Members) == 1
+		}},
+		{"way=foo", 400, nil},
+		{"way=100&full=true&deps=true", 400, nil},
+		{"way=100&full=yes", 400, nil},
+	} {
+		resp, err := http.Get(srv.URL + "/query?" + check.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var r result
+		if check.status == 200 {
+			if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+				t.Error(check.query, err)
+			}
+		}
+		resp.Body.Close()
+		if resp.StatusCode != check.status {
+			t.Errorf("%s: unexpected status %d", check.query, resp.StatusCode)
+			continue
+		}
+		if check.check != nil && !check.check(r) {
+			t.Errorf("%s: unexpected result %#v", check.query, r)
+		}
+	}
+
+	resp, err := http.Post(srv.URL+"/query?way=100", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Error("unexpected status for POST", resp.StatusCode)
+	}
+}
@@ -1,13 +1,13 @@
 package cache
 
 import (
-	"github.com/jmhodges/levigo"
 	osm "github.com/omniscale/go-osm"
 	"github.com/omniscale/imposm3/cache/binary"
 )
 
 type RelationsCache struct {
 	cache
+	tags *binary.TagDict
 }
 
 func newRelationsCache(path string) (*RelationsCache, error) {
@@ -25,15 +25,15 @@ func (p *RelationsCache) PutRelation(relation *osm.Relation) error {
 		return nil
 	}
 	keyBuf := idToKeyBuf(relation.ID)
-	data, err := binary.MarshalRelation(relation)
+	data, err := binary.MarshalRelation(relation, p.tags)
 	if err != nil {
 		return err
 	}
-	return p.db.Put(p.wo, keyBuf, data)
+	return p.db.Put(keyBuf, data)
 }
 
 func (p *RelationsCache) PutRelations(rels []osm.Relation) error {
-	batch := levigo.NewWriteBatch()
+	batch := p.db.NewBatch()
 	defer batch.Close()
 
 	for _, rel := range rels {
@@ -44,21 +44,19 @@ func (p *RelationsCache) PutRelations(rels []osm.Relation) error {
 			continue
 		}
 		keyBuf := idToKeyBuf(rel.ID)
-		data, err := binary.MarshalRelation(&rel)
+		data, err := binary.MarshalRelation(&rel, p.tags)
 		if err != nil {
 			return err
 		}
 		batch.Put(keyBuf, data)
 	}
-	return p.db.Write(p.wo, batch)
+	return p.db.Write(batch)
 }
 
 func (p *RelationsCache) Iter() chan *osm.Relation {
 	rels := make(chan *osm.Relation)
 	go func() {
-		ro := levigo.NewReadOptions()
-		ro.SetFillCache(false)
-		it := p.db.NewIterator(ro)
+		it := p.db.NewIterator()
 		// we need to Close the iter before closing the
 		// chan (and thus signaling that we are done)
 		// to avoid race where db is closed before the iterator
@@ -66,7 +64,7 @@ func (p *RelationsCache) Iter() chan *osm.Relation {
 		defer it.Close()
 		it.SeekToFirst()
 		for ; it.Valid(); it.Next() {
-			rel, err := binary.UnmarshalRelation(it.Value())
+			rel, err := binary.UnmarshalRelation(it.Value(), p.tags)
 			if err != nil {
 				panic(err)
 			}
@@ -80,14 +78,14 @@ func (p *RelationsCache) Iter() chan *osm.Relation {
 
 func (p *RelationsCache) GetRelation(id int64) (*osm.Relation, error) {
 	keyBuf := idToKeyBuf(id)
-	data, err := p.db.Get(p.ro, keyBuf)
+	data, err := p.db.Get(keyBuf)
 	if err != nil {
 		return nil, err
 	}
 	if data == nil {
 		return nil, NotFound
 	}
-	relation, err := binary.UnmarshalRelation(data)
+	relation, err := binary.UnmarshalRelation(data, p.tags)
 	if err != nil {
 		return nil, err
 	}
@@ -97,5 +95,5 @@ func (p *RelationsCache) GetRelation(id int64) (*osm.Relation, error) {
 
 func (p *RelationsCache) DeleteRelation(id int64) error {
 	keyBuf := idToKeyBuf(id)
-	return p.db.Delete(p.wo, keyBuf)
+	return p.db.Delete(keyBuf)
 }
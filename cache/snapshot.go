@@ -0,0 +1,107 @@
+package cache
+
+import (
+	"container/list"
+	"errors"
+
+	"github.com/omniscale/imposm3/cache/kv"
+)
+
+var errReadOnly = errors.New("cache snapshot is read-only")
+
+// snapshotStore is a read-only kv.Store of a kv.Snapshot. Close releases
+// the snapshot.
+type snapshotStore struct {
+	snap kv.Snapshot
+}
+
+func (s *snapshotStore) Get(key []byte) ([]byte, error) { return s.snap.Get(key) }
+func (s *snapshotStore) Put(key, value []byte) error    { return errReadOnly }
+func (s *snapshotStore) Delete(key []byte) error        { return errReadOnly }
+func (s *snapshotStore) NewBatch() kv.Batch             { return readOnlyBatch{} }
+func (s *snapshotStore) Write(kv.Batch) error           { return errReadOnly }
+func (s *snapshotStore) NewIterator() kv.Iterator       { return s.snap.NewIterator() }
+func (s *snapshotStore) Snapshot() kv.Snapshot          { return nopRelease{s.snap} }
+
+func (s *snapshotStore) Close() error {
+	s.snap.Release()
+	return nil
+}
+
+type readOnlyBatch struct{}
+
+func (readOnlyBatch) Put(key, value []byte) {}
+func (readOnlyBatch) Delete(key []byte)     {}
+func (readOnlyBatch) Close()                {}
+
+// nopRelease is a snapshot of a snapshotStore, which is released when the
+// store is closed.
+type nopRelease struct {
+	kv.Snapshot
+}
+
+func (nopRelease) Release() {}
+
+func (c *cache) snapshot() cache {
+	return cache{db: &snapshotStore{c.db.Snapshot()}, options: c.options}
+}
+
+// liveCoords is a CoordsCache that does not support snapshots. Close does
+// not close the underlying cache.
+type liveCoords struct {
+	CoordsCache
+}
+
+func (liveCoords) Close() error { return nil }
+
+// Snapshot returns a read-only view of the opened cache, for reads
+// while the cache is updated by another goroutine. The snapshots of the
+// single caches are not created atomically, they can differ if the cache
+// is updated during Snapshot. The FlatCoordsCache is read directly.
+// Changes that are buffered in the coords cache are only visible after
+// Flush. Close releases the snapshot.
+func (c *OSMCache) Snapshot() *OSMCache {
+	s := &OSMCache{
+		dir:        c.dir,
+		FlatCoords: c.FlatCoords,
+		TagDict:    c.TagDict,
+		opened:     true,
+	}
+	switch coords := c.Coords.(type) {
+	case *DeltaCoordsCache:
+		s.Coords = coords.snapshot()
+	default:
+		s.Coords = liveCoords{coords}
+	}
+	s.Nodes = &NodesCache{cache: c.Nodes.snapshot(), tags: c.Nodes.tags}
+	s.Ways = &WaysCache{cache: c.Ways.snapshot(), tags: c.Ways.tags}
+	s.Relations = &RelationsCache{cache: c.Relations.snapshot(), tags: c.Relations.tags}
+	return s
+}
+
+func (c *DeltaCoordsCache) snapshot() *DeltaCoordsCache {
+	return &DeltaCoordsCache{
+		cache:     c.cache.snapshot(),
+		lruList:   list.New(),
+		table:     make(map[int64]*coordsBunch),
+		capacity:  c.capacity,
+		bunchSize: c.bunchSize,
+		readOnly:  true,
+	}
+}
+
+// Snapshot returns a read-only view of the opened diff cache. See
+// OSMCache.Snapshot. Close releases the snapshot.
+func (c *DiffCache) Snapshot() *DiffCache {
+	return &DiffCache{
+		Dir:       c.Dir,
+		Coords:    &CoordsRefIndex{c.Coords.snapshot()},
+		CoordsRel: &CoordsRelRefIndex{c.CoordsRel.snapshot()},
+		Ways:      &WaysRefIndex{c.Ways.snapshot()},
+		opened:    true,
+	}
+}
+
+func (index *bunchRefCache) snapshot() *bunchRefCache {
+	return &bunchRefCache{cache: index.cache.snapshot()}
+}
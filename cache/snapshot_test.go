@@ -0,0 +1,80 @@
+package cache
+
+import (
+	"io/ioutil"
+	"math"
+	"os"
+	"reflect"
+	"testing"
+
+	osm "github.com/omniscale/go-osm"
+)
+
+func TestSnapshot(t *testing.T) {
+	cacheDir, _ := ioutil.TempDir("", "imposm_test")
+	defer os.RemoveAll(cacheDir)
+
+	c := NewOSMCache(cacheDir)
+	if err := c.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	dc := NewDiffCache(cacheDir)
+	if err := dc.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer dc.Close()
+
+	if err := c.Coords.PutCoords([]osm.Node{{Element: osm.Element{ID: 1}, Long: 8, Lat: 50}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Coords.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Ways.PutWay(&osm.Way{Element: osm.Element{ID: 10, Tags: osm.Tags{"highway": "track"}}, Refs: []int64{1}}); err != nil {
+		t.Fatal(err)
+	}
+	dc.Coords.Add(1, 10)
+
+	snap := c.Snapshot()
+	diffSnap := dc.Snapshot()
+
+	// changes after the snapshot
+	if err := c.Coords.PutCoords([]osm.Node{{Element: osm.Element{ID: 2}, Long: 9, Lat: 51}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Coords.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Ways.DeleteWay(10); err != nil {
+		t.Fatal(err)
+	}
+	dc.Coords.Add(1, 11)
+
+	if nd, err := snap.Coords.GetCoord(1); err != nil || math.Abs(nd.Long-8) > 1e-6 {
+		t.Error("unexpected coord", nd, err)
+	}
+	if _, err := snap.Coords.GetCoord(2); err != NotFound {
+		t.Error("coord from after snapshot found", err)
+	}
+	if w, err := snap.Ways.GetWay(10); err != nil || w.Tags["highway"] != "track" {
+		t.Error("unexpected way", w, err)
+	}
+	if refs := diffSnap.Coords.Get(1); !reflect.DeepEqual(refs, []int64{10}) {
+		t.Error("unexpected refs", refs)
+	}
+	if err := snap.Ways.PutWay(&osm.Way{Element: osm.Element{ID: 11}}); err != errReadOnly {
+		t.Error("expected errReadOnly, got", err)
+	}
+
+	snap.Close()
+	diffSnap.Close()
+
+	// live cache is still open
+	if _, err := c.Coords.GetCoord(2); err != nil {
+		t.Error(err)
+	}
+	if refs := dc.Coords.Get(1); !reflect.DeepEqual(refs, []int64{10, 11}) {
+		t.Error("unexpected refs", refs)
+	}
+}
@@ -1,13 +1,13 @@
 package cache
 
 import (
-	"github.com/jmhodges/levigo"
 	osm "github.com/omniscale/go-osm"
 	"github.com/omniscale/imposm3/cache/binary"
 )
 
 type WaysCache struct {
 	cache
+	tags *binary.TagDict
 }
 
 func newWaysCache(path string) (*WaysCache, error) {
@@ -25,15 +25,15 @@ func (c *WaysCache) PutWay(way *osm.Way) error {
 		return nil
 	}
 	keyBuf := idToKeyBuf(way.ID)
-	data, err := binary.MarshalWay(way)
+	data, err := binary.MarshalWay(way, c.tags)
 	if err != nil {
 		return err
 	}
-	return c.db.Put(c.wo, keyBuf, data)
+	return c.db.Put(keyBuf, data)
 }
 
 func (c *WaysCache) PutWays(ways []osm.Way) error {
-	batch := levigo.NewWriteBatch()
+	batch := c.db.NewBatch()
 	defer batch.Close()
 
 	for _, way := range ways {
@@ -41,25 +41,25 @@ func (c *WaysCache) PutWays(ways []osm.Way) error {
 			continue
 		}
 		keyBuf := idToKeyBuf(way.ID)
-		data, err := binary.MarshalWay(&way)
+		data, err := binary.MarshalWay(&way, c.tags)
 		if err != nil {
 			return err
 		}
 		batch.Put(keyBuf, data)
 	}
-	return c.db.Write(c.wo, batch)
+	return c.db.Write(batch)
 }
 
 func (c *WaysCache) GetWay(id int64) (*osm.Way, error) {
 	keyBuf := idToKeyBuf(id)
-	data, err := c.db.Get(c.ro, keyBuf)
+	data, err := c.db.Get(keyBuf)
 	if err != nil {
 		return nil, err
 	}
 	if data == nil {
 		return nil, NotFound
 	}
-	way, err := binary.UnmarshalWay(data)
+	way, err := binary.UnmarshalWay(data, c.tags)
 	if err != nil {
 		return nil, err
 	}
@@ -69,15 +69,13 @@ func (c *WaysCache) GetWay(id int64) (*osm.Way, error) {
 
 func (c *WaysCache) DeleteWay(id int64) error {
 	keyBuf := idToKeyBuf(id)
-	return c.db.Delete(c.wo, keyBuf)
+	return c.db.Delete(keyBuf)
 }
 
 func (c *WaysCache) Iter() chan *osm.Way {
 	ways := make(chan *osm.Way, 1024)
 	go func() {
-		ro := levigo.NewReadOptions()
-		ro.SetFillCache(false)
-		it := c.db.NewIterator(ro)
+		it := c.db.NewIterator()
 		// we need to Close the iter before closing the
 		// chan (and thus signaling that we are done)
 		// to avoid race where db is closed before the iterator
@@ -85,7 +83,7 @@ func (c *WaysCache) Iter() chan *osm.Way {
 		defer it.Close()
 		it.SeekToFirst()
 		for ; it.Valid(); it.Next() {
-			way, err := binary.UnmarshalWay(it.Value())
+			way, err := binary.UnmarshalWay(it.Value(), c.tags)
 			if err != nil {
 				panic(err)
 			}
@@ -7,10 +7,12 @@ import (
 	"strings"
 
 	"github.com/omniscale/imposm3"
+	"github.com/omniscale/imposm3/cache/admin"
 	"github.com/omniscale/imposm3/cache/query"
 	"github.com/omniscale/imposm3/config"
 	"github.com/omniscale/imposm3/import_"
 	"github.com/omniscale/imposm3/log"
+	mappingadmin "github.com/omniscale/imposm3/mapping/admin"
 	"github.com/omniscale/imposm3/stats"
 	"github.com/omniscale/imposm3/update"
 )
@@ -22,6 +24,8 @@ func PrintCmds() {
 	fmt.Println("\tdiff")
 	fmt.Println("\trun")
 	fmt.Println("\tquery-cache")
+	fmt.Println("\tcache")
+	fmt.Println("\tmapping")
 	fmt.Println("\tversion")
 }
 
@@ -62,6 +66,10 @@ func Main(usage func()) {
 		update.Run(opts)
 	case "query-cache":
 		query.Query(os.Args[2:])
+	case "cache":
+		admin.Cache(os.Args[2:])
+	case "mapping":
+		mappingadmin.Mapping(os.Args[2:])
 	case "version":
 		fmt.Println(imposm3.Version)
 		os.Exit(0)
@@ -6,6 +6,7 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/omniscale/imposm3/log"
@@ -25,6 +26,7 @@ type Config struct {
 	CommitLatest        bool            `json:"commit_latest"`
 	ReplicationURL      string          `json:"replication_url"`
 	ReplicationInterval MinutesInterval `json:"replication_interval"`
+	ReplicationCatchup  bool            `json:"replication_catchup"`
 	DiffStateBefore     MinutesInterval `json:"diff_state_before"`
 }
 
@@ -50,6 +52,7 @@ type Base struct {
 	LimitToCacheBuffer  float64
 	ConfigFile          string
 	HTTPProfile         string
+	HTTPQuery           string
 	Quiet               bool
 	Schemas             Schemas
 	ExpireTilesDir      string
@@ -57,8 +60,10 @@ type Base struct {
 	CommitLatest        bool
 	ReplicationURL      string
 	ReplicationInterval time.Duration
+	ReplicationCatchup  bool
 	DiffStateBefore     time.Duration
 	ForceDiffImport     bool
+	WatchDir            string
 }
 
 func (o *Base) updateFromConfig() error {
@@ -137,6 +142,9 @@ func (o *Base) updateFromConfig() error {
 		o.ReplicationInterval = time.Minute
 	}
 	o.ReplicationURL = conf.ReplicationURL
+	if !o.ReplicationCatchup {
+		o.ReplicationCatchup = conf.ReplicationCatchup
+	}
 
 	if o.DiffDir == "" {
 		if conf.DiffDir == "" {
@@ -168,7 +176,10 @@ type Import struct {
 	Base             Base
 	Overwritecache   bool
 	Appendcache      bool
-	Read             string
+	FlatCoords       bool
+	TagDict          bool
+	Read             []string
+	At               time.Time
 	Write            bool
 	Optimize         bool
 	Diff             bool
@@ -210,7 +221,10 @@ func ParseImport(args []string) Import {
 	addBaseFlags(&opts.Base, flags)
 	flags.BoolVar(&opts.Overwritecache, "overwritecache", false, "overwritecache")
 	flags.BoolVar(&opts.Appendcache, "appendcache", false, "append cache")
-	flags.StringVar(&opts.Read, "read", "", "read")
+	flags.BoolVar(&opts.FlatCoords, "flatcoords", false, "store coords in a flat file, for large imports")
+	flags.BoolVar(&opts.TagDict, "tagdict", false, "build a tag dictionary from the input files for a smaller cache")
+	flags.Var((*fileList)(&opts.Read), "read", "read OSM file(s), comma separated or repeated")
+	flags.Var((*timestamp)(&opts.At), "at", "import snapshot of full-history file at this time (2006-01-02 or RFC3339)")
 	flags.BoolVar(&opts.Write, "write", false, "write")
 	flags.BoolVar(&opts.Optimize, "optimize", false, "optimize")
 	flags.BoolVar(&opts.Diff, "diff", false, "enable diff support")
@@ -255,6 +269,7 @@ func ParseDiffImport(args []string) (Base, []string) {
 	flags.IntVar(&opts.ExpireTilesZoom, "expiretiles-zoom", 14, "write expire tiles in this zoom level")
 	flags.BoolVar(&opts.ForceDiffImport, "force", false, "force import of diff if sequence was already imported")
 	flags.BoolVar(&opts.CommitLatest, "commit-latest", false, "commit after last diff, instead after each diff")
+	flags.StringVar(&opts.WatchDir, "watch", "", "watch dir for new change files and import them")
 
 	flags.Usage = func() {
 		fmt.Fprintf(os.Stderr, "Usage: %s %s [args] [.osc.gz, ...]\n\n", os.Args[0], os.Args[1])
@@ -299,6 +314,8 @@ func ParseRunImport(args []string) Base {
 	flags.IntVar(&opts.ExpireTilesZoom, "expiretiles-zoom", 14, "write expire tiles in this zoom level")
 	flags.BoolVar(&opts.CommitLatest, "commit-latest", false, "commit after last diff, instead after each diff")
 	flags.DurationVar(&opts.ReplicationInterval, "replication-interval", time.Minute, "replication interval as duration (1m, 1h, 24h)")
+	flags.BoolVar(&opts.ReplicationCatchup, "catchup", false, "use hourly or daily replication to catch up if far behind")
+	flags.StringVar(&opts.HTTPQuery, "httpquery", "", "bind address for read-only cache query server")
 
 	flags.Usage = func() {
 		fmt.Fprintf(os.Stderr, "Usage: %s %s [args]\n\n", os.Args[0], os.Args[1])
@@ -359,3 +376,41 @@ func (d *MinutesInterval) UnmarshalJSON(b []byte) (err error) {
 
 	return
 }
+
+// fileList is a flag.Value for a list of files. The flag can be repeated and
+// each value can contain multiple comma separated files.
+type fileList []string
+
+func (l *fileList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *fileList) Set(value string) error {
+	for _, f := range strings.Split(value, ",") {
+		if f != "" {
+			*l = append(*l, f)
+		}
+	}
+	return nil
+}
+
+// timestamp is a flag.Value for a time. Accepts RFC3339 timestamps and dates
+// (UTC).
+type timestamp time.Time
+
+func (t *timestamp) String() string {
+	if time.Time(*t).IsZero() {
+		return ""
+	}
+	return time.Time(*t).Format(time.RFC3339)
+}
+
+func (t *timestamp) Set(value string) error {
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02"} {
+		if ts, err := time.Parse(layout, value); err == nil {
+			*t = timestamp(ts)
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid timestamp %q, expected 2006-01-02 or 2006-01-02T15:04:05Z", value)
+}
@@ -74,6 +74,8 @@ func init() {
 		"int64":              &simpleColumnType{"BIGINT"},
 		"float32":            &simpleColumnType{"REAL"},
 		"hstore_string":      &simpleColumnType{"HSTORE"},
+		"jsonb_string":       &simpleColumnType{"JSONB"},
+		"timestamp":          &simpleColumnType{"TIMESTAMP WITH TIME ZONE"},
 		"geometry":           &geometryType{"GEOMETRY"},
 		"validated_geometry": &validatedGeometryType{geometryType{"GEOMETRY"}},
 	}
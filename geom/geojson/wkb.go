@@ -0,0 +1,168 @@
+package geojson
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+const (
+	wkbZFlag    = 0x80000000
+	wkbMFlag    = 0x40000000
+	wkbSridFlag = 0x20000000
+)
+
+var wkbTypes = map[uint32]string{
+	1: "Point",
+	2: "LineString",
+	3: "Polygon",
+	4: "MultiPoint",
+	5: "MultiLineString",
+	6: "MultiPolygon",
+	7: "GeometryCollection",
+}
+
+// Geometry is a GeoJSON geometry object.
+type Geometry struct {
+	Type        string      `json:"type"`
+	Coordinates interface{} `json:"coordinates,omitempty"`
+	Geometries  []*Geometry `json:"geometries,omitempty"`
+}
+
+// FromWKB decodes a geometry from WKB or EWKB (with SRID or Z/M flags).
+// Z and M values are dropped. The coordinates are not transformed.
+func FromWKB(wkb []byte) (*Geometry, error) {
+	r := &wkbReader{r: bytes.NewReader(wkb)}
+	g := r.geometry()
+	if r.err != nil {
+		return nil, fmt.Errorf("decoding WKB: %w", r.err)
+	}
+	return g, nil
+}
+
+type wkbReader struct {
+	r     io.Reader
+	order binary.ByteOrder
+	dims  int
+	err   error
+}
+
+func (r *wkbReader) uint32() uint32 {
+	var buf [4]byte
+	if r.err != nil {
+		return 0
+	}
+	if _, r.err = io.ReadFull(r.r, buf[:]); r.err != nil {
+		return 0
+	}
+	return r.order.Uint32(buf[:])
+}
+
+func (r *wkbReader) point() [2]float64 {
+	var buf [8]byte
+	var p [2]float64
+	for i := 0; i < r.dims; i++ {
+		if r.err != nil {
+			return p
+		}
+		if _, r.err = io.ReadFull(r.r, buf[:]); r.err != nil {
+			return p
+		}
+		if i < 2 {
+			p[i] = math.Float64frombits(r.order.Uint64(buf[:]))
+		}
+	}
+	return p
+}
+
+func (r *wkbReader) points() [][2]float64 {
+	n := r.uint32()
+	pts := [][2]float64{}
+	for i := uint32(0); i < n && r.err == nil; i++ {
+		pts = append(pts, r.point())
+	}
+	return pts
+}
+
+func (r *wkbReader) rings() [][][2]float64 {
+	n := r.uint32()
+	rings := [][][2]float64{}
+	for i := uint32(0); i < n && r.err == nil; i++ {
+		rings = append(rings, r.points())
+	}
+	return rings
+}
+
+// geometry reads a geometry including the byte order and type header.
+func (r *wkbReader) geometry() *Geometry {
+	var order [1]byte
+	if _, r.err = io.ReadFull(r.r, order[:]); r.err != nil {
+		return nil
+	}
+	switch order[0] {
+	case 0:
+		r.order = binary.BigEndian
+	case 1:
+		r.order = binary.LittleEndian
+	default:
+		r.err = fmt.Errorf("invalid byte order %d", order[0])
+		return nil
+	}
+	typ := r.uint32()
+	if typ&wkbSridFlag != 0 {
+		r.uint32() // SRID
+	}
+	r.dims = 2
+	if typ&wkbZFlag != 0 {
+		r.dims++
+	}
+	if typ&wkbMFlag != 0 {
+		r.dims++
+	}
+	typ &^= wkbZFlag | wkbMFlag | wkbSridFlag
+	if r.err != nil {
+		return nil
+	}
+	name, ok := wkbTypes[typ]
+	if !ok {
+		r.err = fmt.Errorf("unsupported geometry type %d", typ)
+		return nil
+	}
+
+	g := &Geometry{Type: name}
+	switch name {
+	case "Point":
+		g.Coordinates = r.point()
+	case "LineString":
+		g.Coordinates = r.points()
+	case "Polygon":
+		g.Coordinates = r.rings()
+	case "MultiPoint", "MultiLineString", "MultiPolygon":
+		n := r.uint32()
+		coords := []interface{}{}
+		for i := uint32(0); i < n && r.err == nil; i++ {
+			part := r.geometry()
+			if part == nil {
+				break
+			}
+			if "Multi"+part.Type != name {
+				r.err = errors.New("unexpected " + part.Type + " in " + name)
+				break
+			}
+			coords = append(coords, part.Coordinates)
+		}
+		g.Coordinates = coords
+	case "GeometryCollection":
+		n := r.uint32()
+		g.Geometries = []*Geometry{}
+		for i := uint32(0); i < n && r.err == nil; i++ {
+			if part := r.geometry(); part != nil {
+				g.Geometries = append(g.Geometries, part)
+			}
+		}
+	}
+	return g
+}
@@ -0,0 +1,68 @@
+package geojson
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+)
+
+func TestFromWKB(t *testing.T) {
+	for _, check := range []struct {
+		wkb      string
+		expected string
+	}{
+		// POINT(8 50)
+		{"01010000000000000000002040" + "0000000000004940",
+			`{"type":"Point","coordinates":[8,50]}`},
+		// SRID=4326;LINESTRING(8 50, 9 51) as EWKB
+		{"0102000020e610000002000000" + "00000000000020400000000000004940" + "00000000000022400000000000804940",
+			`{"type":"LineString","coordinates":[[8,50],[9,51]]}`},
+		// big endian POINT Z(8 50 1)
+		{"0080000001" + "4020000000000000" + "4049000000000000" + "3ff0000000000000",
+			`{"type":"Point","coordinates":[8,50]}`},
+		// POLYGON((0 0, 1 0, 1 1, 0 0))
+		{"01030000000100000004000000" +
+			"00000000000000000000000000000000" + "000000000000f03f0000000000000000" +
+			"000000000000f03f000000000000f03f" + "00000000000000000000000000000000",
+			`{"type":"Polygon","coordinates":[[[0,0],[1,0],[1,1],[0,0]]]}`},
+		// MULTIPOINT(8 50, 9 51)
+		{"010400000002000000" + "010100000000000000000020400000000000004940" + "010100000000000000000022400000000000804940",
+			`{"type":"MultiPoint","coordinates":[[8,50],[9,51]]}`},
+		// GEOMETRYCOLLECTION(POINT(8 50), LINESTRING EMPTY)
+		{"010700000002000000" + "010100000000000000000020400000000000004940" + "010200000000000000",
+			`{"type":"GeometryCollection","geometries":[{"type":"Point","coordinates":[8,50]},{"type":"LineString","coordinates":[]}]}`},
+	} {
+		wkb, err := hex.DecodeString(check.wkb)
+		if err != nil {
+			t.Fatal(err)
+		}
+		g, err := FromWKB(wkb)
+		if err != nil {
+			t.Errorf("%s: %v", check.wkb, err)
+			continue
+		}
+		actual, err := json.Marshal(g)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(actual) != check.expected {
+			t.Errorf("unexpected GeoJSON %s != %s", actual, check.expected)
+		}
+	}
+}
+
+func TestFromWKBErrors(t *testing.T) {
+	for _, wkb := range []string{
+		"",
+		"02",
+		"0108000000",
+		"0101000000000000000000204000",
+		// MULTIPOINT with LineString
+		"010400000001000000010200000000000000",
+	} {
+		data, _ := hex.DecodeString(wkb)
+		if _, err := FromWKB(data); err == nil {
+			t.Errorf("expected error for %q", wkb)
+		}
+	}
+}
@@ -339,6 +339,73 @@ func (l *Limiter) IntersectsBuffer(g *geos.Geos, x, y float64) bool {
 	return g.PreparedIntersects(l.bufferedPrep, p)
 }
 
+// Intersects returns true if the point intersects the LimitTo geometry. The
+// point needs to be in the SRID of the Limiter.
+func (l *Limiter) Intersects(g *geos.Geos, x, y float64) bool {
+	p := g.Point(x, y)
+	if p == nil {
+		return false
+	}
+	defer g.Destroy(p)
+
+	l.geomPrepMu.Lock()
+	defer l.geomPrepMu.Unlock()
+	return g.PreparedIntersects(l.geomPrep, p)
+}
+
+// IntersectsBounds returns true if the bounds intersect the LimitTo
+// geometry. The bounds need to be in the SRID of the Limiter.
+func (l *Limiter) IntersectsBounds(g *geos.Geos, bounds geos.Bounds) bool {
+	b := boundsPolygon(g, bounds)
+	if b == nil {
+		return false
+	}
+	defer g.Destroy(b)
+
+	l.geomPrepMu.Lock()
+	defer l.geomPrepMu.Unlock()
+	return g.PreparedIntersects(l.geomPrep, b)
+}
+
+// IntersectsBoundsBuffer returns true if the bounds (EPSG:4326) intersect
+// the buffered LimitTo geometry.
+func (l *Limiter) IntersectsBoundsBuffer(g *geos.Geos, bounds geos.Bounds) bool {
+	if l.bufferedPrep == nil {
+		return true
+	}
+	if bounds.MaxX < l.bufferedBbox.MinX ||
+		bounds.MaxY < l.bufferedBbox.MinY ||
+		bounds.MinX > l.bufferedBbox.MaxX ||
+		bounds.MinY > l.bufferedBbox.MaxY {
+		return false
+	}
+	b := boundsPolygon(g, bounds)
+	if b == nil {
+		return false
+	}
+	defer g.Destroy(b)
+
+	l.bufferedPrepMu.Lock()
+	defer l.bufferedPrepMu.Unlock()
+	return g.PreparedIntersects(l.bufferedPrep, b)
+}
+
+// boundsPolygon returns the bounds as polygon. Bounds without width or
+// height (e.g. of a horizontal way) are extended slightly, to avoid
+// collapsed polygons.
+func boundsPolygon(g *geos.Geos, bounds geos.Bounds) *geos.Geom {
+	const eps = 1e-9
+	if bounds.MinX == bounds.MaxX {
+		bounds.MinX -= eps
+		bounds.MaxX += eps
+	}
+	if bounds.MinY == bounds.MaxY {
+		bounds.MinY -= eps
+		bounds.MaxY += eps
+	}
+	return g.BoundsPolygon(bounds)
+}
+
 func flattenPolygons(g *geos.Geos, geoms []*geos.Geom) []*geos.Geom {
 	var result []*geos.Geom
 	for _, geom := range geoms {
@@ -319,6 +319,34 @@ func TestClipperWithBuffer(t *testing.T) {
 
 }
 
+func TestIntersectsBounds(t *testing.T) {
+	g := geos.NewGeos()
+	defer g.Finish()
+	limiter, err := NewFromGeoJSON("./clipping.geojson", 0.1, 4326)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		bounds   geos.Bounds
+		want     bool
+		buffered bool
+	}{
+		// enclosing the whole geometry
+		{geos.MakeBounds(0, 40, 20, 60), true, true},
+		{geos.MakeBounds(0, 0, 1, 1), false, false},
+		// collapsed bounds
+		{geos.MakeBounds(9.04, 53.53, 9.04, 53.53), false, false},
+		{geos.MakeBounds(9.04, 53.53, 9.9, 53.53), false, true},
+	} {
+		if got := limiter.IntersectsBounds(g, tc.bounds); got != tc.want {
+			t.Errorf("IntersectsBounds(%v) = %v, want %v", tc.bounds, got, tc.want)
+		}
+		if got := limiter.IntersectsBoundsBuffer(g, tc.bounds); got != tc.buffered {
+			t.Errorf("IntersectsBoundsBuffer(%v) = %v, want %v", tc.bounds, got, tc.buffered)
+		}
+	}
+}
+
 func TestSplitParams(t *testing.T) {
 	var gridWidth, startWidth float64
 
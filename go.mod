@@ -8,6 +8,7 @@ require (
 	github.com/lib/pq v1.8.0
 	github.com/omniscale/go-osm v0.3.1
 	github.com/pkg/errors v0.9.1
+	gopkg.in/fsnotify.v1 v1.4.7
 	gopkg.in/yaml.v2 v2.4.0
 )
 
@@ -15,5 +16,4 @@ require (
 	github.com/kr/pretty v0.3.1 // indirect
 	golang.org/x/sys v0.4.0 // indirect
 	gopkg.in/check.v1 v1.0.0-20190902080502-41f04d3bba15 // indirect
-	gopkg.in/fsnotify.v1 v1.4.7 // indirect
 )
@@ -17,14 +17,14 @@ import (
 	"github.com/omniscale/imposm3/mapping"
 	"github.com/omniscale/imposm3/reader"
 	"github.com/omniscale/imposm3/stats"
-	"github.com/omniscale/imposm3/update"
+	updatestate "github.com/omniscale/imposm3/update/state"
 	"github.com/omniscale/imposm3/writer"
 )
 
 func Import(importOpts config.Import) {
 	baseOpts := importOpts.Base
 
-	if (importOpts.Write || importOpts.Read != "") && (importOpts.RevertDeploy || importOpts.RemoveBackup) {
+	if (importOpts.Write || len(importOpts.Read) > 0) && (importOpts.RevertDeploy || importOpts.RemoveBackup) {
 		log.Fatal("-revertdeploy and -removebackup not compatible with -read/-write")
 	}
 
@@ -33,7 +33,7 @@ func Import(importOpts config.Import) {
 	}
 
 	var geometryLimiter *limit.Limiter
-	if (importOpts.Write || importOpts.Read != "") && baseOpts.LimitTo != "" {
+	if (importOpts.Write || len(importOpts.Read) > 0) && baseOpts.LimitTo != "" {
 		var err error
 		step := log.Step("Reading limitto geometries")
 		geometryLimiter, err = limit.NewFromGeoJSON(
@@ -72,9 +72,18 @@ func Import(importOpts config.Import) {
 		defer db.Close()
 	}
 
+	if len(importOpts.Read) > 0 || importOpts.Write {
+		lock, err := cache.LockDir(baseOpts.CacheDir)
+		if err != nil {
+			log.Fatal("[error] locking cache: ", err)
+		}
+		defer lock.Unlock()
+	}
+
 	osmCache := cache.NewOSMCache(baseOpts.CacheDir)
+	osmCache.FlatCoords = importOpts.FlatCoords
 
-	if importOpts.Read != "" && osmCache.Exists() {
+	if len(importOpts.Read) > 0 && osmCache.Exists() {
 		if importOpts.Overwritecache {
 			log.Printf("[info] removing existing cache %s", baseOpts.CacheDir)
 			err := osmCache.Remove()
@@ -90,7 +99,22 @@ func Import(importOpts config.Import) {
 
 	var elementCounts *stats.ElementCounts
 
-	if importOpts.Read != "" {
+	if len(importOpts.Read) > 0 && importOpts.TagDict {
+		if osmCache.Exists() {
+			log.Printf("[warn] -tagdict is ignored for existing caches")
+		} else {
+			step := log.Step("Building tag dictionary")
+			tagDict, err := reader.BuildTagDict(importOpts.Read, tagmapping, importOpts.At)
+			if err != nil {
+				log.Fatal(err)
+			}
+			log.Printf("[info] tag dictionary with %d tags and %d keys", tagDict.NumTags(), tagDict.NumKeys())
+			osmCache.TagDict = tagDict
+			step()
+		}
+	}
+
+	if len(importOpts.Read) > 0 {
 		step := log.Step("Reading OSM data")
 		err = osmCache.Open()
 		if err != nil {
@@ -108,11 +132,13 @@ func Import(importOpts config.Import) {
 			readLimiter = nil
 		}
 
-		err := reader.ReadPbf(importOpts.Read,
+		err := reader.Read(importOpts.Read,
 			osmCache,
 			progress,
 			tagmapping,
 			readLimiter,
+			importOpts.Diff,
+			importOpts.At,
 		)
 		if err != nil {
 			log.Fatal(err)
@@ -123,12 +149,19 @@ func Import(importOpts config.Import) {
 		osmCache.Close()
 		step()
 		if importOpts.Diff {
-			diffstate, err := estimateFromPBF(importOpts.Read, baseOpts.DiffStateBefore, baseOpts.ReplicationURL, baseOpts.ReplicationInterval)
+			var diffstate *state.DiffState
+			var err error
+			if !importOpts.At.IsZero() {
+				// continue replication from the snapshot time
+				diffstate, err = estimateFromTimestamp(importOpts.At, baseOpts.DiffStateBefore, baseOpts.ReplicationURL, baseOpts.ReplicationInterval)
+			} else {
+				diffstate, err = estimateFromFiles(importOpts.Read, baseOpts.DiffStateBefore, baseOpts.ReplicationURL, baseOpts.ReplicationInterval)
+			}
 			if err != nil {
-				log.Println("[error] parsing diff state form PBF", err)
+				log.Println("[error] parsing diff state form OSM file", err)
 			} else if diffstate != nil {
 				os.MkdirAll(baseOpts.DiffDir, 0755)
-				err := state.WriteFile(filepath.Join(baseOpts.DiffDir, update.LastStateFilename), diffstate)
+				err := state.WriteFile(filepath.Join(baseOpts.DiffDir, updatestate.LastStateFilename), diffstate)
 				if err != nil {
 					log.Println("[error] writing last.state.txt: ", err)
 				}
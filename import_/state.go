@@ -1,38 +1,49 @@
 package import_
 
 import (
-	"fmt"
 	"math"
-	"net/http"
 	"os"
 	"time"
 
-	"github.com/omniscale/go-osm/parser/pbf"
 	"github.com/omniscale/go-osm/state"
+	"github.com/omniscale/imposm3/reader"
+	updatestate "github.com/omniscale/imposm3/update/state"
 	"github.com/pkg/errors"
 )
 
-func estimateFromPBF(filename string, before time.Duration, replicationURL string, replicationInterval time.Duration) (*state.DiffState, error) {
-	f, err := os.Open(filename)
+// estimateFromFiles estimates the diff state for the OSM files. Uses the
+// timestamp of the oldest file, as all changes since then need to be
+// applied.
+func estimateFromFiles(filenames []string, before time.Duration, replicationURL string, replicationInterval time.Duration) (*state.DiffState, error) {
+	timestamp, err := oldestTimestamp(filenames)
 	if err != nil {
-		return nil, errors.Wrap(err, "opening PBF file")
+		return nil, err
 	}
-	defer f.Close()
+	return estimateFromTimestamp(timestamp, before, replicationURL, replicationInterval)
+}
 
-	pbfparser := pbf.New(f, pbf.Config{})
-	header, err := pbfparser.Header()
+// oldestTimestamp returns the oldest data timestamp of all files. Uses the
+// modification time for files without a timestamp in the header.
+func oldestTimestamp(filenames []string) (time.Time, error) {
+	var oldest time.Time
+	for _, filename := range filenames {
+		header, err := reader.ReadHeader(filename)
 
-	var timestamp time.Time
-	if err == nil && header.Time.Unix() > 0 {
-		timestamp = header.Time
-	} else {
-		fstat, err := os.Stat(filename)
-		if err != nil {
-			return nil, errors.Wrapf(err, "reading mod time from %q", filename)
+		var timestamp time.Time
+		if err == nil && header.Time.Unix() > 0 {
+			timestamp = header.Time
+		} else {
+			fstat, err := os.Stat(filename)
+			if err != nil {
+				return time.Time{}, errors.Wrapf(err, "reading mod time from %q", filename)
+			}
+			timestamp = fstat.ModTime()
+		}
+		if oldest.IsZero() || timestamp.Before(oldest) {
+			oldest = timestamp
 		}
-		timestamp = fstat.ModTime()
 	}
-	return estimateFromTimestamp(timestamp, before, replicationURL, replicationInterval)
+	return oldest, nil
 }
 
 func estimateFromTimestamp(timestamp time.Time, before time.Duration, replicationURL string, replicationInterval time.Duration) (*state.DiffState, error) {
@@ -46,7 +57,7 @@ func estimateFromTimestamp(timestamp time.Time, before time.Duration, replicatio
 			replicationURL = "https://planet.openstreetmap.org/replication/minute/"
 		}
 	}
-	seq, err := estimateSequence(replicationURL, replicationInterval, timestamp)
+	seq, err := updatestate.EstimateSequence(replicationURL, replicationInterval, timestamp)
 	if err != nil {
 		return nil, errors.Wrap(err, "fetching current sequence for estimated import sequence")
 	}
@@ -55,31 +66,3 @@ func estimateFromTimestamp(timestamp time.Time, before time.Duration, replicatio
 	seq -= int(math.Ceil(before.Minutes() / replicationInterval.Minutes()))
 	return &state.DiffState{Time: timestamp, URL: replicationURL, Sequence: seq}, nil
 }
-
-func currentState(url string) (*state.DiffState, error) {
-	resp, err := http.Get(url + "state.txt")
-	if err != nil {
-		return nil, err
-	}
-	if resp.StatusCode != 200 {
-		return nil, errors.New(fmt.Sprintf("invalid response: %v", resp))
-	}
-	defer resp.Body.Close()
-	return state.Parse(resp.Body)
-}
-
-func estimateSequence(url string, interval time.Duration, timestamp time.Time) (int, error) {
-	state, err := currentState(url)
-	if err != nil {
-		// discard first error and try a second time before failing
-		time.Sleep(time.Second * 2)
-		state, err = currentState(url)
-		if err != nil {
-			return 0, errors.Wrap(err, "fetching current state")
-		}
-	}
-
-	behind := state.Time.Sub(timestamp)
-	// Sequence unit depends on replication interval (minute, hour, day).
-	return state.Sequence - int(math.Ceil(behind.Minutes()/interval.Minutes())), nil
-}
@@ -1,6 +1,8 @@
 package import_
 
 import (
+	"os"
+	"path/filepath"
 	"regexp"
 	"strings"
 	"testing"
@@ -115,7 +117,7 @@ func TestFromPBF(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	state, err := estimateFromPBF("../vendor/github.com/omniscale/go-osm/parser/pbf/monaco-20150428.osm.pbf", time.Hour*24*3, "https://planet.openstreetmap.org/replication/day/", time.Hour*24)
+	state, err := estimateFromFiles([]string{"../vendor/github.com/omniscale/go-osm/parser/pbf/monaco-20150428.osm.pbf"}, time.Hour*24*3, "https://planet.openstreetmap.org/replication/day/", time.Hour*24)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -133,3 +135,26 @@ func TestFromPBF(t *testing.T) {
 		t.Error("unexpected timestamp", state)
 	}
 }
+
+func TestOldestTimestamp(t *testing.T) {
+	newer := filepath.Join(t.TempDir(), "newer.osm")
+	if err := os.WriteFile(newer, []byte(`<osm timestamp="2020-01-02T03:04:05Z"></osm>`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	timestamp, err := oldestTimestamp([]string{newer, "../vendor/github.com/omniscale/go-osm/parser/pbf/monaco-20150428.osm.pbf"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if timestamp.Unix() != 1430166062 {
+		t.Error("unexpected timestamp", timestamp)
+	}
+
+	timestamp, err = oldestTimestamp([]string{newer})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if timestamp.Unix() != 1577934245 {
+		t.Error("unexpected timestamp", timestamp)
+	}
+}
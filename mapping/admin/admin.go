@@ -0,0 +1,102 @@
+// Package admin implements the `imposm mapping` command to check and test
+// mapping files without a database.
+package admin
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/omniscale/imposm3/log"
+	"github.com/omniscale/imposm3/mapping"
+)
+
+var flags = flag.NewFlagSet("mapping", flag.ExitOnError)
+
+var (
+	mappingFile = flags.String("mapping", "", "mapping file")
+
+	read    = flags.String("read", "", "OSM file(s) for test, comma separated")
+	nodeIDs = flags.String("node", "", "only test these nodes (comma separated IDs)")
+	wayIDs  = flags.String("way", "", "only test these ways (comma separated IDs)")
+	relIDs  = flags.String("rel", "", "only test these relations (comma separated IDs)")
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s mapping COMMAND [args]\n\n", os.Args[0])
+	fmt.Fprintln(os.Stderr, "Available commands:")
+	fmt.Fprintln(os.Stderr, "\tcheck    report errors and warnings in the mapping file")
+	fmt.Fprintln(os.Stderr, "\ttest     show the matched tables of each element from -read as JSON lines")
+	fmt.Fprintln(os.Stderr, "\nArgs:")
+	flags.PrintDefaults()
+}
+
+// Mapping runs the `imposm mapping` command.
+func Mapping(args []string) {
+	flags.Usage = usage
+	if len(args) == 0 {
+		usage()
+		os.Exit(1)
+	}
+	cmd := args[0]
+	if err := flags.Parse(args[1:]); err != nil {
+		log.Fatal(err)
+	}
+	if *mappingFile == "" {
+		usage()
+		log.Fatal("[error] missing -mapping")
+	}
+
+	switch cmd {
+	case "check":
+		ok, err := runCheck(os.Stdout, *mappingFile)
+		if err != nil {
+			log.Fatal("[error] ", err)
+		}
+		if !ok {
+			os.Exit(1)
+		}
+	case "test":
+		if *read == "" {
+			log.Fatal("[error] missing -read option for test")
+		}
+		ids, err := parseTestIDs(*nodeIDs, *wayIDs, *relIDs)
+		if err != nil {
+			log.Fatal("[error] ", err)
+		}
+		m, err := mapping.FromFile(*mappingFile)
+		if err != nil {
+			log.Fatal("[error] loading mapping: ", err)
+		}
+		if err := runTest(os.Stdout, m, strings.Split(*read, ","), ids); err != nil {
+			log.Fatal("[error] ", err)
+		}
+	default:
+		usage()
+		log.Fatalf("invalid mapping command: '%s'", cmd)
+	}
+}
+
+// runCheck prints all problems of the mapping file. Returns false if there
+// are errors.
+func runCheck(w io.Writer, filename string) (bool, error) {
+	problems, err := mapping.CheckFile(filename)
+	if err != nil {
+		return false, err
+	}
+	errs := 0
+	for _, p := range problems {
+		if p.Line > 0 {
+			fmt.Fprintf(w, "%s:%d: %s\n", filename, p.Line, p)
+		} else {
+			fmt.Fprintf(w, "%s: %s\n", filename, p)
+		}
+		if p.Error {
+			errs++
+		}
+	}
+	fmt.Fprintf(w, "%s: %d errors, %d warnings\n", filename, errs, len(problems)-errs)
+	return errs == 0, nil
+}
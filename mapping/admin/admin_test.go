@@ -0,0 +1,68 @@
+package admin
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/omniscale/imposm3/mapping"
+)
+
+const monacoPBF = "../../vendor/github.com/omniscale/go-osm/parser/pbf/monaco-20150428.osm.pbf"
+
+func TestRunCheck(t *testing.T) {
+	var buf bytes.Buffer
+	ok, err := runCheck(&buf, "../test_mapping.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok {
+		t.Error("expected error for missing waterareas table")
+	}
+	if !strings.Contains(buf.String(), `../test_mapping.yml:27: error: missing source "waterareas"`) {
+		t.Error("unexpected output", buf.String())
+	}
+}
+
+func TestRunTest(t *testing.T) {
+	m, err := mapping.FromFile("../../example-mapping.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids, err := parseTestIDs("25195781", "4227155", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := runTest(&buf, m, []string{monacoPBF}, ids); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected two lines, got %q", buf.String())
+	}
+	var results []testResult
+	for _, l := range lines {
+		var r testResult
+		if err := json.Unmarshal([]byte(l), &r); err != nil {
+			t.Fatal(err)
+		}
+		results = append(results, r)
+	}
+
+	if r := results[0]; r.Type != "node" || r.ID != 25195781 || len(r.Matches) != 1 ||
+		r.Matches[0].Table != "transport_points" || r.Matches[0].Columns["name"] != "Castelleretto" {
+		t.Errorf("unexpected node result %#v", r)
+	}
+	if r := results[1]; r.Type != "way" || r.ID != 4227155 || len(r.Matches) != 1 ||
+		r.Matches[0].Table != "landusages" || len(r.Rejected) != 1 ||
+		r.Rejected[0].Table != "roads" || r.Rejected[0].Filter != "area=yes" {
+		t.Errorf("unexpected way result %#v", r)
+	}
+
+	if _, err := parseTestIDs("1,x", "", ""); err == nil {
+		t.Error("expected error for invalid ID")
+	}
+}
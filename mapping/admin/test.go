@@ -0,0 +1,154 @@
+package admin
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	osm "github.com/omniscale/go-osm"
+	"github.com/omniscale/imposm3/mapping"
+	"github.com/omniscale/imposm3/reader"
+)
+
+// testResult is the output of the test command for a single element.
+type testResult struct {
+	Type     string          `json:"type"`
+	ID       int64           `json:"id"`
+	Tags     osm.Tags        `json:"tags"`
+	Matches  []testMatch     `json:"matches"`
+	Rejected []testRejection `json:"rejected,omitempty"`
+}
+
+type testMatch struct {
+	Table      string                 `json:"table"`
+	SubMapping string                 `json:"submapping,omitempty"`
+	Key        string                 `json:"key"`
+	Value      string                 `json:"value"`
+	Columns    map[string]interface{} `json:"columns,omitempty"`
+	// Members contains the columns of each member for relation_member
+	// tables.
+	Members []map[string]interface{} `json:"members,omitempty"`
+}
+
+type testRejection struct {
+	Table      string `json:"table"`
+	SubMapping string `json:"submapping,omitempty"`
+	Key        string `json:"key"`
+	Value      string `json:"value"`
+	Filter     string `json:"filter"`
+}
+
+// testIDs are the elements to print, all elements with a match or a
+// rejection are printed if all are empty.
+type testIDs struct {
+	nodes, ways, relations map[int64]bool
+}
+
+func (ids testIDs) empty() bool {
+	return len(ids.nodes) == 0 && len(ids.ways) == 0 && len(ids.relations) == 0
+}
+
+func parseTestIDs(nodes, ways, relations string) (testIDs, error) {
+	var ids testIDs
+	for _, p := range []struct {
+		dst   *map[int64]bool
+		param string
+		flag  string
+	}{
+		{&ids.nodes, nodes, "node"},
+		{&ids.ways, ways, "way"},
+		{&ids.relations, relations, "rel"},
+	} {
+		*p.dst = make(map[int64]bool)
+		if p.param == "" {
+			continue
+		}
+		for _, s := range strings.Split(p.param, ",") {
+			id, err := strconv.ParseInt(s, 10, 64)
+			if err != nil {
+				return ids, fmt.Errorf("invalid ID %q for -%s", s, p.flag)
+			}
+			(*p.dst)[id] = true
+		}
+	}
+	return ids, nil
+}
+
+// runTest writes a JSON line for each element of the files with the
+// matched tables and their columns, and with the tables that rejected the
+// element.
+func runTest(w io.Writer, m *mapping.Mapping, filenames []string, ids testIDs) error {
+	out := bufio.NewWriter(w)
+	enc := json.NewEncoder(out)
+	enc.SetEscapeHTML(false)
+	var encErr error
+	// rel is only set for relations, to build the rows of relation_member
+	// tables
+	write := func(typ string, elem *osm.Element, rel *osm.Relation, matches []mapping.Match, rejected []mapping.Rejection) {
+		if encErr != nil {
+			return
+		}
+		if ids.empty() && len(matches) == 0 && len(rejected) == 0 {
+			return
+		}
+		r := testResult{Type: typ, ID: elem.ID, Tags: elem.Tags, Matches: []testMatch{}}
+		for _, match := range matches {
+			tm := testMatch{
+				Table:      match.Table.Name,
+				SubMapping: match.Table.SubMapping,
+				Key:        match.Key,
+				Value:      match.Value,
+			}
+			if rel != nil && mapping.TableType(m.Conf.Tables[match.Table.Name].Type) == mapping.RelationMemberTable {
+				tm.Members = []map[string]interface{}{}
+				for i := range rel.Members {
+					tm.Members = append(tm.Members, match.MemberColumns(rel, &rel.Members[i], i))
+				}
+			} else {
+				tm.Columns = match.Columns(elem)
+			}
+			r.Matches = append(r.Matches, tm)
+		}
+		for _, rej := range rejected {
+			r.Rejected = append(r.Rejected, testRejection{
+				Table:      rej.Table.Name,
+				SubMapping: rej.Table.SubMapping,
+				Key:        rej.Key,
+				Value:      rej.Value,
+				Filter:     rej.Filter,
+			})
+		}
+		encErr = enc.Encode(r)
+	}
+
+	err := reader.Elements(filenames, m,
+		func(nd *osm.Node) {
+			if ids.empty() || ids.nodes[nd.ID] {
+				matches, rejected := m.ExplainNode(nd)
+				write("node", &nd.Element, nil, matches, rejected)
+			}
+		},
+		func(w *osm.Way) {
+			if ids.empty() || ids.ways[w.ID] {
+				matches, rejected := m.ExplainWay(w)
+				write("way", &w.Element, nil, matches, rejected)
+			}
+		},
+		func(rel *osm.Relation) {
+			if ids.empty() || ids.relations[rel.ID] {
+				matches, rejected := m.ExplainRelation(rel)
+				write("relation", &rel.Element, rel, matches, rejected)
+			}
+		},
+	)
+	if err != nil {
+		return err
+	}
+	if encErr != nil {
+		return encErr
+	}
+	return out.Flush()
+}
@@ -0,0 +1,460 @@
+package mapping
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	osm "github.com/omniscale/go-osm"
+	"github.com/omniscale/imposm3/mapping/config"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Problem is an error or warning in a mapping file found by Check.
+type Problem struct {
+	// Line is the line in the mapping file, 0 if unknown.
+	Line int
+	// Error is false for warnings. The mapping can be used despite of
+	// warnings, but some data will not be imported as expected.
+	Error bool
+	Msg   string
+}
+
+func (p Problem) String() string {
+	level := "warning"
+	if p.Error {
+		level = "error"
+	}
+	return level + ": " + p.Msg
+}
+
+// CheckFile checks the mapping file. See Check.
+func CheckFile(filename string) ([]Problem, error) {
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	abs, err := filepath.Abs(filename)
+	if err != nil {
+		return nil, err
+	}
+	return check(b, filepath.Dir(abs)), nil
+}
+
+// Check loads the mapping and returns all problems ordered by line. It
+// reports errors that would abort New, and warnings for tables that do not
+// receive any element and for keys that are removed by the tag filters.
+// Included files are relative to the working directory. The lines are
+// only reported for the mapping itself, problems in included files have no
+// line.
+func Check(b []byte) []Problem {
+	return check(b, "")
+}
+
+func check(b []byte, dir string) []Problem {
+	c := checker{lines: newLineIndex(b)}
+
+	// parse without includes first, to get the line of syntax errors
+	if err := yaml.Unmarshal(b, &config.Mapping{}); err != nil {
+		c.errorf(yamlErrorLine(err), "%v", err)
+		return c.problems
+	}
+	conf, err := config.Parse(b, dir)
+	if err != nil {
+		c.errorf(c.lines.find("include"), "%v", err)
+		return c.problems
+	}
+	m := Mapping{Conf: *conf}
+	for name, t := range m.Conf.Tables {
+		t.Name = name
+		if t.OldFields != nil {
+			t.Columns = t.OldFields
+		}
+	}
+	for name, t := range m.Conf.GeneralizedTables {
+		t.Name = name
+	}
+
+	for _, name := range sortedKeys(m.Conf.Tables) {
+		c.checkTable(name, m.Conf.Tables[name])
+	}
+	c.checkGeneralizedTables(m.Conf)
+	c.checkTags(m.Conf)
+
+	if !c.hasErrors() {
+		// catch everything else that fails while loading the mapping
+		if err := m.prepare(); err != nil {
+			c.errorf(0, "%v", err)
+		} else if err := m.createMatcher(); err != nil {
+			c.errorf(0, "%v", err)
+		}
+	}
+	if !c.hasErrors() {
+		c.checkTagFilters(&m)
+	}
+
+	sort.SliceStable(c.problems, func(i, j int) bool {
+		return c.problems[i].Line < c.problems[j].Line
+	})
+	return c.problems
+}
+
+type checker struct {
+	lines    lineIndex
+	problems []Problem
+}
+
+func (c *checker) errorf(line int, format string, args ...interface{}) {
+	c.problems = append(c.problems, Problem{Line: line, Error: true, Msg: fmt.Sprintf(format, args...)})
+}
+
+func (c *checker) warnf(line int, format string, args ...interface{}) {
+	c.problems = append(c.problems, Problem{Line: line, Msg: fmt.Sprintf(format, args...)})
+}
+
+func (c *checker) hasErrors() bool {
+	for _, p := range c.problems {
+		if p.Error {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *checker) checkTable(name string, t *config.Table) {
+	line := c.lines.find("tables", name)
+	switch TableType(t.Type) {
+	case "":
+		c.errorf(line, "missing type for table %s", name)
+	case PointTable, LineStringTable, PolygonTable, RelationTable, RelationMemberTable:
+	case GeometryTable:
+		if t.Mapping != nil || t.Mappings != nil {
+			c.errorf(line, "table with type:geometry requires type_mappings for table %s", name)
+		}
+	default:
+		c.errorf(c.lines.find("tables", name, "type"), "unknown type %q for table %s", t.Type, name)
+	}
+
+	if len(t.Mapping) == 0 && len(t.Mappings) == 0 && len(t.TypeMappings.Points) == 0 &&
+		len(t.TypeMappings.LineStrings) == 0 && len(t.TypeMappings.Polygons) == 0 {
+		c.warnf(line, "table %s has no mapping and will stay empty", name)
+	}
+
+	for _, col := range t.Columns {
+		colLine := c.lines.findItem("name", col.Name, "tables", name, "columns")
+		if _, ok := AvailableColumnTypes[col.Type]; !ok {
+			c.errorf(colLine, "unknown type %q for column %s in table %s", col.Type, col.Name, name)
+			continue
+		}
+		if _, err := MakeColumnType(col); err != nil {
+			c.errorf(colLine, "column %s in table %s: %v", col.Name, name, err)
+		}
+	}
+
+	if t.Filters != nil {
+		for _, f := range []struct {
+			name    string
+			regexps config.KeyRegexpValue
+		}{
+			{"require_regexp", t.Filters.RequireRegexp},
+			{"reject_regexp", t.Filters.RejectRegexp},
+		} {
+			for _, key := range sortedKeys(f.regexps) {
+				if _, err := regexp.Compile(f.regexps[key]); err != nil {
+					c.errorf(c.lines.find("tables", name, "filters", f.name, string(key)),
+						"invalid %s for key %s in table %s: %v", f.name, key, name, err)
+				}
+			}
+		}
+	}
+}
+
+func (c *checker) checkGeneralizedTables(conf config.Mapping) {
+	for _, name := range sortedKeys(conf.GeneralizedTables) {
+		t := conf.GeneralizedTables[name]
+		line := c.lines.find("generalized_tables", name, "source")
+		if _, ok := conf.Tables[t.SourceTableName]; ok {
+			continue
+		}
+		if _, ok := conf.GeneralizedTables[t.SourceTableName]; !ok {
+			c.errorf(line, "missing source %q for generalized table %s", t.SourceTableName, name)
+			continue
+		}
+
+		// follow the sources and report each cycle once, for the table
+		// with the lowest name
+		cycle := []string{name}
+		seen := map[string]bool{name: true}
+		for src := t.SourceTableName; ; {
+			if src == name {
+				if isLowest(name, cycle) {
+					c.errorf(line, "generalized tables form a cycle: %s -> %s",
+						strings.Join(cycle, " -> "), name)
+				}
+				break
+			}
+			next, ok := conf.GeneralizedTables[src]
+			if !ok || seen[src] {
+				break
+			}
+			seen[src] = true
+			cycle = append(cycle, src)
+			src = next.SourceTableName
+		}
+	}
+}
+
+func isLowest(name string, names []string) bool {
+	for _, n := range names {
+		if n < name {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *checker) checkTags(conf config.Mapping) {
+	for _, key := range conf.Tags.Exclude {
+		if _, err := path.Match(string(key), ""); err != nil {
+			c.errorf(c.lines.find("tags", "exclude"), "invalid pattern %q in tags.exclude: %v", key, err)
+		}
+	}
+	if _, err := newTagRewriter(conf.TagRewrites); err != nil {
+		c.errorf(c.lines.find("tag_rewrites"), "%v", err)
+	}
+}
+
+type namedTagFilter struct {
+	name   string
+	filter TagFilterer
+}
+
+// checkTagFilters warns about mapped keys, columns and filters that never
+// see their tags, as the tags are removed while reading the input.
+func (c *checker) checkTagFilters(m *Mapping) {
+	nodes := namedTagFilter{"node", m.NodeTagFilter()}
+	ways := namedTagFilter{"way", m.WayTagFilter()}
+	rels := namedTagFilter{"relation", m.RelationTagFilter()}
+	filtersFor := map[TableType][]namedTagFilter{
+		PointTable:          {nodes},
+		LineStringTable:     {ways},
+		PolygonTable:        {ways, rels},
+		GeometryTable:       {nodes, ways, rels},
+		RelationTable:       {rels},
+		RelationMemberTable: {rels},
+	}
+
+	for _, name := range sortedKeys(m.Conf.Tables) {
+		t := m.Conf.Tables[name]
+		filters := filtersFor[TableType(t.Type)]
+
+		// the table is unreachable if all mapped tags are removed
+		mapped, kept := 0, 0
+		checkMapping := func(mapping config.KeyValues, filters []namedTagFilter) {
+			for key, values := range mapping {
+				for _, v := range values {
+					mapped++
+					if len(removedBy(filters, string(key), string(v.Value))) < len(filters) {
+						kept++
+					}
+				}
+			}
+		}
+		checkMapping(t.Mapping, filters)
+		for _, sub := range t.Mappings {
+			checkMapping(sub.Mapping, filters)
+		}
+		checkMapping(t.TypeMappings.Points, []namedTagFilter{nodes})
+		checkMapping(t.TypeMappings.LineStrings, []namedTagFilter{ways})
+		checkMapping(t.TypeMappings.Polygons, []namedTagFilter{ways, rels})
+		if mapped > 0 && kept == 0 {
+			c.warnf(c.lines.find("tables", name), "table %s is unreachable, all mapped tags are removed by the tag filters", name)
+		}
+
+		for _, col := range t.Columns {
+			keys := col.Keys
+			if col.Key != "" {
+				keys = append([]config.Key{col.Key}, keys...)
+			}
+			if col.Type == "expression" {
+				for _, k := range expressionTags(*col) {
+					keys = append(keys, config.Key(k))
+				}
+			}
+			for _, key := range keys {
+				if removed := removedBy(filters, string(key), ""); len(removed) > 0 {
+					c.warnf(c.lines.findItem("name", col.Name, "tables", name, "columns"),
+						"key %s of column %s in table %s is removed by the %s tag filter",
+						key, col.Name, name, strings.Join(removed, " and "))
+				}
+			}
+		}
+
+		if t.Filters == nil {
+			continue
+		}
+		filterKeys := make(map[string][]string)
+		for _, f := range []struct {
+			name   string
+			values config.KeyValues
+		}{
+			{"require", t.Filters.Require},
+			{"reject", t.Filters.Reject},
+		} {
+			for key, values := range f.values {
+				for _, v := range values {
+					if removed := removedBy(filters, string(key), string(v.Value)); len(removed) > 0 {
+						filterKeys[f.name+"."+string(key)] = removed
+						break
+					}
+				}
+			}
+		}
+		for _, f := range []struct {
+			name    string
+			regexps config.KeyRegexpValue
+		}{
+			{"require_regexp", t.Filters.RequireRegexp},
+			{"reject_regexp", t.Filters.RejectRegexp},
+		} {
+			for key := range f.regexps {
+				if removed := removedBy(filters, string(key), ""); len(removed) > 0 {
+					filterKeys[f.name+"."+string(key)] = removed
+				}
+			}
+		}
+		for _, fk := range sortedKeys(filterKeys) {
+			parts := strings.SplitN(fk, ".", 2)
+			c.warnf(c.lines.find("tables", name, "filters", parts[0], parts[1]),
+				"key %s of %s filter in table %s is removed by the %s tag filter",
+				parts[1], parts[0], name, strings.Join(filterKeys[fk], " and "))
+		}
+	}
+}
+
+// removedBy returns the names of all filters that remove the tag. An empty
+// or __any__ value stands for any value.
+func removedBy(filters []namedTagFilter, key, value string) []string {
+	if value == "" || value == "__any__" {
+		value = "__imposm_check__"
+	}
+	var removed []string
+	for _, f := range filters {
+		tags := osm.Tags{key: value}
+		f.filter.Filter(&tags)
+		if _, ok := tags[key]; !ok {
+			removed = append(removed, f.name)
+		}
+	}
+	return removed
+}
+
+func sortedKeys[K ~string, V any](m map[K]V) []K {
+	keys := make([]K, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	return keys
+}
+
+var yamlErrorLineRe = regexp.MustCompile(`line (\d+)`)
+
+func yamlErrorLine(err error) int {
+	if m := yamlErrorLineRe.FindStringSubmatch(err.Error()); m != nil {
+		line, _ := strconv.Atoi(m[1])
+		return line
+	}
+	return 0
+}
+
+// lineIndex finds the lines of keys in a YAML or (indented) JSON document.
+// yaml.v2 does not keep the positions of the parsed values, so the keys are
+// located by their indentation.
+type lineIndex []string
+
+func newLineIndex(b []byte) lineIndex {
+	return lineIndex(strings.Split(string(b), "\n"))
+}
+
+// find returns the line (1-based) of the key at path, or 0 if it was not
+// found.
+func (l lineIndex) find(path ...string) int {
+	line, _, found := l.block(path)
+	if !found {
+		return 0
+	}
+	return line
+}
+
+// findItem returns the line of the list item with field: value within
+// the block at path, e.g. a column by its name. It returns the line of the
+// last found parent if the item was not found.
+func (l lineIndex) findItem(field, value string, path ...string) int {
+	line, indent, found := l.block(path)
+	if !found {
+		return line
+	}
+	re := regexp.MustCompile(`^\s*(?:-\s+|\{\s*)?["']?` + regexp.QuoteMeta(field) + `["']?\s*:\s*["']?` +
+		regexp.QuoteMeta(value) + `["']?\s*([,}#].*)?$`)
+	for i := line; i < len(l); i++ {
+		if ind, ok := l.indent(i); ok && ind <= indent {
+			break
+		}
+		if re.MatchString(l[i]) {
+			return i + 1
+		}
+	}
+	return line
+}
+
+// block returns the line and the indentation of the key at path.
+func (l lineIndex) block(path []string) (line, indent int, found bool) {
+	indent = -1
+	start := 0
+	for _, key := range path {
+		re := regexp.MustCompile(`^(\s*(?:-\s+)?)["']?` + regexp.QuoteMeta(key) + `["']?\s*:`)
+		next := -1
+		for i := start; i < len(l); i++ {
+			ind, ok := l.indent(i)
+			if !ok {
+				continue
+			}
+			if ind <= indent {
+				break
+			}
+			if m := re.FindStringSubmatch(l[i]); m != nil {
+				next = i
+				indent = len(m[1])
+				break
+			}
+		}
+		if next < 0 {
+			return line, indent, false
+		}
+		line = next + 1
+		start = next + 1
+	}
+	return line, indent, len(path) > 0
+}
+
+// indent returns the indentation of line i, ok is false for empty and
+// comment lines. List items count as indented by one more, as YAML allows
+// lists on the same level as their parent key.
+func (l lineIndex) indent(i int) (int, bool) {
+	trimmed := strings.TrimLeft(l[i], " \t")
+	if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+		return 0, false
+	}
+	indent := len(l[i]) - len(trimmed)
+	if strings.HasPrefix(trimmed, "- ") {
+		indent++
+	}
+	return indent, true
+}
@@ -0,0 +1,197 @@
+package mapping
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		mapping  string
+		problems []Problem
+	}{
+		{
+			name: "valid",
+			mapping: `
+tables:
+  roads:
+    type: linestring
+    columns:
+    - name: osm_id
+      type: id
+    - {name: name, key: name, type: string}
+    mapping:
+      highway: [__any__]
+`,
+		},
+		{
+			name: "yaml error",
+			mapping: `
+tables:
+  roads: [
+`,
+			problems: []Problem{{Line: 3, Error: true, Msg: "did not find expected node content"}},
+		},
+		{
+			name: "types and regexps",
+			mapping: `
+tables:
+  roads:
+    type: linestring
+    columns:
+    - name: osm_id
+      type: id
+    - key: ref
+      name: ref
+      type: strin
+    filters:
+      reject_regexp:
+        ref: '[0-9'
+    mapping:
+      highway: [__any__]
+  odd:
+    type: pointz
+    mapping:
+      amenity: [cafe]
+`,
+			problems: []Problem{
+				{Line: 9, Error: true, Msg: `unknown type "strin" for column ref in table roads`},
+				{Line: 13, Error: true, Msg: "invalid reject_regexp for key ref in table roads"},
+				{Line: 17, Error: true, Msg: `unknown type "pointz" for table odd`},
+			},
+		},
+		{
+			name: "generalized tables",
+			mapping: `
+generalized_tables:
+  a_gen:
+    source: b_gen
+  b_gen:
+    source: c_gen
+  c_gen:
+    source: a_gen
+  roads_gen:
+    source: roadz
+  roads_gen2:
+    source: roads_gen
+tables:
+  roads:
+    type: linestring
+    mapping:
+      highway: [__any__]
+`,
+			problems: []Problem{
+				{Line: 4, Error: true, Msg: "generalized tables form a cycle: a_gen -> b_gen -> c_gen -> a_gen"},
+				{Line: 10, Error: true, Msg: `missing source "roadz" for generalized table roads_gen`},
+			},
+		},
+		{
+			name: "tag filters",
+			mapping: `
+tags:
+  load_all: true
+  exclude: [shop, "name:*"]
+tables:
+  roads:
+    type: linestring
+    columns:
+    - key: name:de
+      name: name_de
+      type: string
+    mapping:
+      highway: [__any__]
+  shops:
+    type: point
+    mapping:
+      shop: [__any__]
+  empty:
+    type: polygon
+`,
+			problems: []Problem{
+				{Line: 10, Msg: "key name:de of column name_de in table roads is removed by the way tag filter"},
+				{Line: 14, Msg: "table shops is unreachable"},
+				{Line: 18, Msg: "table empty has no mapping"},
+			},
+		},
+		{
+			name: "filter keys",
+			mapping: `
+tables:
+  roads:
+    type: linestring
+    filters:
+      require:
+        surface: [paved]
+    mapping:
+      highway: [__any__]
+`,
+			problems: []Problem{
+				{Line: 7, Msg: "key surface of require filter in table roads is removed by the way tag filter"},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			problems := Check([]byte(tc.mapping))
+			if len(problems) != len(tc.problems) {
+				t.Fatalf("unexpected problems %v", problems)
+			}
+			for i, p := range problems {
+				expected := tc.problems[i]
+				if p.Line != expected.Line || p.Error != expected.Error || !strings.Contains(p.Msg, expected.Msg) {
+					t.Errorf("unexpected problem %#v, expected %#v", p, expected)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckExampleMapping(t *testing.T) {
+	for _, filename := range []string{"../example-mapping.yml", "../example-mapping.json"} {
+		problems, err := CheckFile(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, p := range problems {
+			t.Error(filename, p.Line, p)
+		}
+	}
+}
+
+func TestLineIndex(t *testing.T) {
+	l := newLineIndex([]byte(`{
+  "tables": {
+    "roads": {
+      "columns": [
+        {"name": "osm_id", "type": "id"},
+        {
+          "name": "name",
+          "key": "name"
+        }
+      ],
+      "type": "linestring"
+    }
+  }
+}`))
+	for _, tc := range []struct {
+		line int
+		path []string
+		item string
+	}{
+		{2, []string{"tables"}, ""},
+		{11, []string{"tables", "roads", "type"}, ""},
+		{0, []string{"tables", "roads", "mapping"}, ""},
+		{4, []string{"tables", "roads", "columns"}, "unknown"},
+		{0, []string{"generalized_tables"}, ""},
+		{5, []string{"tables", "roads", "columns"}, "osm_id"},
+		{7, []string{"tables", "roads", "columns"}, "name"},
+	} {
+		line := l.find(tc.path...)
+		if tc.item != "" {
+			line = l.findItem("name", tc.item, tc.path...)
+		}
+		if line != tc.line {
+			t.Errorf("%v %s: got line %d, expected %d", tc.path, tc.item, line, tc.line)
+		}
+	}
+}
@@ -1,7 +1,9 @@
 package mapping
 
 import (
+	"encoding/json"
 	"math"
+	"path"
 	"regexp"
 	"strconv"
 	"strings"
@@ -33,6 +35,7 @@ func init() {
 		"geometry":             {"geometry", "geometry", Geometry, nil, nil, false},
 		"validated_geometry":   {"validated_geometry", "validated_geometry", Geometry, nil, nil, false},
 		"hstore_tags":          {"hstore_tags", "hstore_string", nil, MakeHStoreString, nil, false},
+		"jsonb_tags":           {"jsonb_tags", "jsonb_string", nil, MakeJSONBTags, nil, false},
 		"wayzorder":            {"wayzorder", "int32", nil, MakeWayZOrder, nil, false},
 		"pseudoarea":           {"pseudoarea", "float32", nil, MakePseudoArea, nil, false},
 		"area":                 {"area", "float32", Area, nil, nil, false},
@@ -44,6 +47,12 @@ func init() {
 		"categorize_int":             {Name: "categorize_int", GoType: "int32", MakeFunc: MakeCategorizeInt},
 		"geojson_intersects":         {Name: "geojson_intersects", GoType: "bool", MakeFunc: MakeIntersectsField},
 		"geojson_intersects_feature": {Name: "geojson_intersects_feature", GoType: "string", MakeFunc: MakeIntersectsFeatureField},
+		"expression":                 {Name: "expression", GoType: "string", MakeFunc: MakeExpression},
+
+		"osm_version":   {Name: "osm_version", GoType: "int32", Func: OSMVersion},
+		"osm_timestamp": {Name: "osm_timestamp", GoType: "timestamp", Func: OSMTimestamp},
+		"osm_changeset": {Name: "osm_changeset", GoType: "int64", Func: OSMChangeset},
+		"osm_user":      {Name: "osm_user", GoType: "string", Func: OSMUser},
 	}
 }
 
@@ -195,6 +204,89 @@ func MakeHStoreString(columnName string, columnType ColumnType, column config.Co
 	return hstoreString, nil
 }
 
+// jsonNumber matches values that are stored as JSON numbers if cast_numbers
+// is enabled. Values with exponents or leading zeros (e.g. housenumbers
+// like 1e or 007) remain strings.
+var jsonNumber = regexp.MustCompile(`^-?(0|[1-9][0-9]*)(\.[0-9]+)?$`)
+
+func MakeJSONBTags(columnName string, columnType ColumnType, column config.Column) (MakeValue, error) {
+	include, err := decodePatternsArg(column, "include")
+	if err != nil {
+		return nil, err
+	}
+	exclude, err := decodePatternsArg(column, "exclude")
+	if err != nil {
+		return nil, err
+	}
+	var castNumbers bool
+	if v, ok := column.Args["cast_numbers"]; ok {
+		if castNumbers, ok = v.(bool); !ok {
+			return nil, errors.Errorf("'cast_numbers' in args for %s not a bool", column.Type)
+		}
+	}
+
+	matchAny := func(patterns []string, key string) bool {
+		key = strings.ReplaceAll(key, "/", keySeparator)
+		for _, p := range patterns {
+			if ok, _ := path.Match(p, key); ok {
+				return true
+			}
+		}
+		return false
+	}
+
+	jsonbTags := func(val string, elem *osm.Element, geom *geom.Geometry, match Match) interface{} {
+		tags := make(map[string]interface{}, len(elem.Tags))
+		for k, v := range elem.Tags {
+			if include != nil && !matchAny(include, k) || matchAny(exclude, k) {
+				continue
+			}
+			if castNumbers && jsonNumber.MatchString(v) {
+				tags[k] = json.Number(v)
+			} else {
+				tags[k] = v
+			}
+		}
+		// can't fail for strings and valid numbers, keys are sorted
+		var buf strings.Builder
+		enc := json.NewEncoder(&buf)
+		enc.SetEscapeHTML(false)
+		enc.Encode(tags)
+		return strings.TrimSuffix(buf.String(), "\n")
+	}
+	return jsonbTags, nil
+}
+
+// keySeparator replaces / in patterns and keys, as path.Match does not
+// match / with * or ?. Tag keys like source/ref have no path separators and
+// can't contain NUL.
+const keySeparator = "\x00"
+
+// decodePatternsArg returns the list of glob patterns for key, with / replaced
+// by keySeparator. Returns nil if key is not set.
+func decodePatternsArg(column config.Column, key string) ([]string, error) {
+	_patterns, ok := column.Args[key]
+	if !ok {
+		return nil, nil
+	}
+	patternsList, ok := _patterns.([]interface{})
+	if !ok {
+		return nil, errors.Errorf("'%v' in args for %s not a list", key, column.Type)
+	}
+	patterns := make([]string, 0, len(patternsList))
+	for _, p := range patternsList {
+		pattern, ok := p.(string)
+		if !ok {
+			return nil, errors.Errorf("value in '%v' not a string", key)
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, errors.Errorf("invalid pattern %q in '%v'", pattern, key)
+		}
+		patterns = append(patterns, strings.ReplaceAll(pattern, "/", keySeparator))
+	}
+	return patterns, nil
+}
+
 func MakeWayZOrder(columnName string, columnType ColumnType, column config.Column) (MakeValue, error) {
 	if _, ok := column.Args["ranks"]; !ok {
 		return DefaultWayZOrder, nil
@@ -0,0 +1,112 @@
+package mapping
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	osm "github.com/omniscale/go-osm"
+	"github.com/omniscale/imposm3/geom"
+	"github.com/omniscale/imposm3/mapping/config"
+)
+
+// expressionResultTypes maps the type arg of expression columns to the
+// GoType of the column and to the conversion of the expression result.
+var expressionResultTypes = map[string]struct {
+	goType  string
+	convert func(interface{}) interface{}
+}{
+	"string":  {"string", expressionString},
+	"integer": {"int32", expressionInteger},
+	"float":   {"float32", expressionFloat},
+	"bool":    {"bool", expressionBool},
+}
+
+func MakeExpression(columnName string, columnType ColumnType, column config.Column) (MakeValue, error) {
+	expr, err := columnExpression(column)
+	if err != nil {
+		return nil, err
+	}
+	resultType, err := expressionResultType(column)
+	if err != nil {
+		return nil, err
+	}
+	convert := expressionResultTypes[resultType].convert
+
+	makeValue := func(val string, elem *osm.Element, geom *geom.Geometry, match Match) interface{} {
+		return convert(expr.Eval(elem, geom, &match))
+	}
+	return makeValue, nil
+}
+
+func columnExpression(column config.Column) (*expression, error) {
+	_src, ok := column.Args["expression"]
+	if !ok {
+		return nil, errors.New("missing 'expression' in 'args' for expression")
+	}
+	src, ok := _src.(string)
+	if !ok {
+		return nil, fmt.Errorf("'expression' in 'args' for expression not a string but %T", _src)
+	}
+	expr, err := compileExpression(src)
+	if err != nil {
+		return nil, fmt.Errorf("invalid expression: %w", err)
+	}
+	return expr, nil
+}
+
+func expressionResultType(column config.Column) (string, error) {
+	_resultType, ok := column.Args["type"]
+	if !ok {
+		return "string", nil
+	}
+	resultType, ok := _resultType.(string)
+	if _, known := expressionResultTypes[resultType]; !ok || !known {
+		return "", fmt.Errorf("'type' in 'args' for expression not one of string, integer, float or bool: %v", _resultType)
+	}
+	return resultType, nil
+}
+
+// expressionTags returns the keys of all tags the expression of the column
+// reads. Returns nil for invalid expressions.
+func expressionTags(column config.Column) []string {
+	expr, err := columnExpression(column)
+	if err != nil {
+		return nil
+	}
+	return expr.tags
+}
+
+func expressionString(v interface{}) interface{} {
+	if v == nil {
+		return nil
+	}
+	return toString(v)
+}
+
+func expressionInteger(v interface{}) interface{} {
+	n, ok := toNumber(v)
+	if !ok {
+		return nil
+	}
+	n = math.Trunc(n)
+	if n < math.MinInt32 || n > math.MaxInt32 {
+		return nil
+	}
+	return int32(n)
+}
+
+func expressionFloat(v interface{}) interface{} {
+	n, ok := toNumber(v)
+	if !ok {
+		return nil
+	}
+	return float32(n)
+}
+
+func expressionBool(v interface{}) interface{} {
+	if v == nil {
+		return nil
+	}
+	return truthy(v)
+}
@@ -0,0 +1,57 @@
+package mapping
+
+import (
+	osm "github.com/omniscale/go-osm"
+	"github.com/omniscale/imposm3/geom"
+)
+
+// metadataColumnTypes are column types that require the metadata of each
+// element. Metadata is only read and cached if the mapping contains one
+// of these columns.
+var metadataColumnTypes = map[string]bool{
+	"osm_version":   true,
+	"osm_timestamp": true,
+	"osm_changeset": true,
+	"osm_user":      true,
+}
+
+// UsesMetadata returns whether the mapping contains columns with element
+// metadata (version, timestamp, etc.).
+func (m *Mapping) UsesMetadata() bool {
+	for _, t := range m.Conf.Tables {
+		for _, col := range t.Columns {
+			if metadataColumnTypes[col.Type] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func OSMVersion(val string, elem *osm.Element, geom *geom.Geometry, match Match) interface{} {
+	if elem.Metadata == nil {
+		return nil
+	}
+	return elem.Metadata.Version
+}
+
+func OSMTimestamp(val string, elem *osm.Element, geom *geom.Geometry, match Match) interface{} {
+	if elem.Metadata == nil || elem.Metadata.Timestamp.IsZero() {
+		return nil
+	}
+	return elem.Metadata.Timestamp
+}
+
+func OSMChangeset(val string, elem *osm.Element, geom *geom.Geometry, match Match) interface{} {
+	if elem.Metadata == nil {
+		return nil
+	}
+	return elem.Metadata.Changeset
+}
+
+func OSMUser(val string, elem *osm.Element, geom *geom.Geometry, match Match) interface{} {
+	if elem.Metadata == nil || elem.Metadata.UserName == "" {
+		return nil
+	}
+	return elem.Metadata.UserName
+}
@@ -1,7 +1,9 @@
 package mapping
 
 import (
+	"strings"
 	"testing"
+	"time"
 
 	osm "github.com/omniscale/go-osm"
 	"github.com/omniscale/imposm3/geom"
@@ -352,3 +354,256 @@ func TestHstoreString(t *testing.T) {
 	}
 
 }
+
+func TestMetadataColumns(t *testing.T) {
+	ts := time.Date(2024, 6, 1, 12, 30, 0, 0, time.UTC)
+	withMetadata := &osm.Element{Metadata: &osm.Metadata{
+		Version:   3,
+		Timestamp: ts,
+		Changeset: 123456789,
+		UserID:    42,
+		UserName:  "mapper",
+	}}
+	withoutMetadata := &osm.Element{}
+
+	for _, test := range []struct {
+		column   MakeValue
+		elem     *osm.Element
+		expected interface{}
+	}{
+		{OSMVersion, withMetadata, int32(3)},
+		{OSMTimestamp, withMetadata, ts},
+		{OSMChangeset, withMetadata, int64(123456789)},
+		{OSMUser, withMetadata, "mapper"},
+		{OSMVersion, withoutMetadata, nil},
+		{OSMTimestamp, withoutMetadata, nil},
+		{OSMChangeset, withoutMetadata, nil},
+		{OSMUser, withoutMetadata, nil},
+	} {
+		actual := test.column("", test.elem, nil, Match{})
+		if actual != test.expected {
+			t.Errorf("%#v != %#v", actual, test.expected)
+		}
+	}
+}
+
+func TestUsesMetadata(t *testing.T) {
+	for _, test := range []struct {
+		columnType string
+		expected   bool
+	}{
+		{"string", false},
+		{"osm_version", true},
+		{"osm_timestamp", true},
+		{"osm_changeset", true},
+		{"osm_user", true},
+	} {
+		m, err := New([]byte(`
+tables:
+  roads:
+    type: linestring
+    columns:
+      - name: osm_id
+        type: id
+      - name: col
+        type: ` + test.columnType + `
+        key: col
+    mapping:
+      highway: [__any__]
+`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m.UsesMetadata() != test.expected {
+			t.Errorf("unexpected UsesMetadata for %s", test.columnType)
+		}
+	}
+}
+
+func TestExpression(t *testing.T) {
+	elem := &osm.Element{ID: 42, Tags: osm.Tags{
+		"name":            " Main Street ",
+		"ref":             "",
+		"building:levels": "4",
+		"height":          "12.5 m",
+	}}
+	match := Match{Key: "highway", Value: "primary"}
+
+	for _, tc := range []struct {
+		expr       string
+		resultType string
+		expected   interface{}
+	}{
+		{`tag("name")`, "", " Main Street "},
+		{`upper(trim(tag("name")))`, "", "MAIN STREET"},
+		{`coalesce(tag("ref"), tag("missing"), substr(trim(tag("name")), 0, 4))`, "", "Main"},
+		{`concat(key, "=", value, "/", id)`, "", "highway=primary/42"},
+		{`replace(lower(tag("name")), "street", "st")`, "", " main st "},
+		{`tag("building:levels") * 3 + 0.5`, "float", float32(12.5)},
+		{`tag("building:levels") * 3 + 0.5`, "integer", int32(12)},
+		{`round(coalesce(number(tag("height")), tag("building:levels") * 3))`, "integer", int32(12)},
+		{`tag("height") * 2`, "integer", nil},
+		{`1 / 0`, "float", nil},
+		{`if(value == "primary" and not tag("missing"), "major", "minor")`, "", "major"},
+		{`if(tag("building:levels") >= 10, "high")`, "", nil},
+		{`len(trim(tag("name"))) > 5 or false`, "bool", true},
+		{`tag("missing") == null`, "bool", true},
+		{`tag("missing")`, "bool", nil},
+		{`contains(tag("name"), "Main") and startswith(value, "pri")`, "bool", true},
+		{`max(1, tag("building:levels"), -2)`, "integer", int32(4)},
+		{`-tag("building:levels") % 3`, "integer", int32(-1)},
+		{`5000000000`, "integer", nil},
+		{`area`, "float", nil},
+		{`length`, "", nil},
+		{`2147483647`, "integer", int32(2147483647)},
+		{`2147483648`, "integer", nil},
+	} {
+		col := config.Column{Name: "test", Type: "expression", Args: map[string]interface{}{"expression": tc.expr}}
+		if tc.resultType != "" {
+			col.Args["type"] = tc.resultType
+		}
+		columnType, err := MakeColumnType(&col)
+		if err != nil {
+			t.Errorf("%s: %s", tc.expr, err)
+			continue
+		}
+		if v := columnType.Func("", elem, &geom.Geometry{}, match); v != tc.expected {
+			t.Errorf("%s: unexpected value %#v, expected %#v", tc.expr, v, tc.expected)
+		}
+	}
+
+	for resultType, goType := range map[string]string{"": "string", "integer": "int32", "float": "float32", "bool": "bool"} {
+		col := config.Column{Name: "test", Type: "expression", Args: map[string]interface{}{"expression": "1"}}
+		if resultType != "" {
+			col.Args["type"] = resultType
+		}
+		columnType, err := MakeColumnType(&col)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if columnType.GoType != goType {
+			t.Errorf("unexpected GoType %s for %q", columnType.GoType, resultType)
+		}
+	}
+
+	col := config.Column{Name: "test", Type: "expression", Args: map[string]interface{}{
+		"expression": `coalesce(tag("name"), tag("name:en"))`}}
+	if tags := expressionTags(col); len(tags) != 2 || tags[0] != "name" || tags[1] != "name:en" {
+		t.Error("unexpected tags", tags)
+	}
+
+	// tags of expressions are kept by the tag filter
+	m, err := New([]byte(`
+    tables:
+      pois:
+        type: point
+        columns:
+        - {name: label, type: expression, args: {expression: 'coalesce(tag("name:en"), tag("name"))'}}
+        mapping:
+          amenity: [__any__]
+    `))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tags := osm.Tags{"amenity": "cafe", "name:en": "Cafe", "fixme": "yes"}
+	m.NodeTagFilter().Filter(&tags)
+	if len(tags) != 2 || tags["name:en"] != "Cafe" {
+		t.Error("unexpected filtered tags", tags)
+	}
+}
+
+func TestExpressionErrors(t *testing.T) {
+	for _, tc := range []struct {
+		args map[string]interface{}
+		err  string
+	}{
+		{map[string]interface{}{}, "missing 'expression'"},
+		{map[string]interface{}{"expression": 1}, "not a string"},
+		{map[string]interface{}{"expression": "1", "type": "date"}, "'type' in 'args'"},
+		{map[string]interface{}{"expression": `tag(name)`}, "requires a single string literal"},
+		{map[string]interface{}{"expression": `tag("a" + "b")`}, "requires a single string literal"},
+		{map[string]interface{}{"expression": `exec("rm")`}, "unknown function 'exec' at position 0"},
+		{map[string]interface{}{"expression": `foo + 1`}, "unknown name 'foo'"},
+		{map[string]interface{}{"expression": `upper("a", "b")`}, "wrong number of arguments for upper()"},
+		{map[string]interface{}{"expression": `(1 + 2`}, "expected ')' at position 6, got end of expression"},
+		{map[string]interface{}{"expression": `1 2`}, "unexpected '2' at position 2"},
+		{map[string]interface{}{"expression": `"abc`}, "unterminated string"},
+		{map[string]interface{}{"expression": `1 = 2`}, "unexpected character '=' at position 2"},
+		{map[string]interface{}{"expression": `1 € 2`}, "unexpected character '€' at position 2"},
+		{map[string]interface{}{"expression": `straße + 1`}, "unknown name 'straße'"},
+		{map[string]interface{}{"expression": strings.Repeat("not ", 100) + "true"}, "nested too deeply"},
+		{map[string]interface{}{"expression": strings.Repeat("(", 100) + "1" + strings.Repeat(")", 100)}, "nested too deeply"},
+	} {
+		_, err := MakeColumnType(&config.Column{Name: "test", Type: "expression", Args: tc.args})
+		if err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("%v: expected error %q, got %v", tc.args, tc.err, err)
+		}
+	}
+}
+
+func TestJSONBTags(t *testing.T) {
+	makeColumn := func(args map[string]interface{}) MakeValue {
+		column := config.Column{Name: "tags", Type: "jsonb_tags", Args: args}
+		f, err := MakeJSONBTags("tags", ColumnType{}, column)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return f
+	}
+	all := makeColumn(nil)
+	filtered := makeColumn(map[string]interface{}{
+		"include": []interface{}{"name", "name:*", "addr:*", "height"},
+		"exclude": []interface{}{"name:xx*"},
+	})
+	slashes := makeColumn(map[string]interface{}{
+		"include": []interface{}{"source*", "a/?/c"},
+		"exclude": []interface{}{"source:*"},
+	})
+	cast := makeColumn(map[string]interface{}{
+		"exclude":      []interface{}{"source*"},
+		"cast_numbers": true,
+	})
+
+	tags := osm.Tags{
+		"name":             "Foo <&>",
+		"name:de":          `"Föö"`,
+		"name:xx-latn":     "x",
+		"addr:street":      "Main",
+		"addr:housenumber": "007",
+		"height":           "12.5",
+		"building:levels":  "-3",
+		"ref":              "1e5",
+		"source":           "survey",
+		"source:name":      "survey",
+		"source/ref":       "survey",
+	}
+	for _, test := range []struct {
+		column   MakeValue
+		tags     osm.Tags
+		expected string
+	}{
+		{all, osm.Tags{}, `{}`},
+		{all, osm.Tags{"a": `\"`, "b": "2"}, `{"a":"\\\"","b":"2"}`},
+		{filtered, tags, `{"addr:housenumber":"007","addr:street":"Main","height":"12.5","name":"Foo <&>","name:de":"\"Föö\""}`},
+		{slashes, tags, `{"source":"survey","source/ref":"survey"}`},
+		{slashes, osm.Tags{"a/b/c": "1", "a/bb/c": "2"}, `{"a/b/c":"1"}`},
+		{cast, tags, `{"addr:housenumber":"007","addr:street":"Main","building:levels":-3,"height":12.5,"name":"Foo <&>","name:de":"\"Föö\"","name:xx-latn":"x","ref":"1e5"}`},
+	} {
+		actual := test.column("", &osm.Element{Tags: test.tags}, nil, Match{})
+		if actual.(string) != test.expected {
+			t.Errorf("%s != %s", actual, test.expected)
+		}
+	}
+
+	for _, args := range []map[string]interface{}{
+		{"include": "name"},
+		{"exclude": []interface{}{1}},
+		{"exclude": []interface{}{"name["}},
+		{"cast_numbers": "yes"},
+	} {
+		column := config.Column{Name: "tags", Type: "jsonb_tags", Args: args}
+		if _, err := MakeJSONBTags("tags", ColumnType{}, column); err == nil {
+			t.Errorf("expected error for %v", args)
+		}
+	}
+}
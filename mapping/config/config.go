@@ -7,10 +7,14 @@ import (
 )
 
 type Mapping struct {
+	// Include lists mapping files that are merged into this mapping.
+	// Relative paths are relative to the including file.
+	Include           []string          `yaml:"include"`
 	Tables            Tables            `yaml:"tables"`
 	GeneralizedTables GeneralizedTables `yaml:"generalized_tables"`
 	Tags              Tags              `yaml:"tags"`
 	Areas             Areas             `yaml:"areas"`
+	TagRewrites       []TagRewrite      `yaml:"tag_rewrites"`
 	// SingleIDSpace mangles the overlapping node/way/relation IDs
 	// to be unique (nodes positive, ways negative, relations negative -1e17)
 	SingleIDSpace bool `yaml:"use_single_id_space"`
@@ -36,6 +40,8 @@ type Table struct {
 	OldFields     []*Column             `yaml:"fields"`
 	Filters       *Filters              `yaml:"filters"`
 	RelationTypes []string              `yaml:"relation_types"`
+	// Override replaces the included table with the same name.
+	Override bool `yaml:"override"`
 }
 
 type GeneralizedTables map[string]*GeneralizedTable
@@ -44,6 +50,8 @@ type GeneralizedTable struct {
 	SourceTableName string  `yaml:"source"`
 	Tolerance       float64 `yaml:"tolerance"`
 	SQLFilter       string  `yaml:"sql_filter"`
+	// Override replaces the included table with the same name.
+	Override bool `yaml:"override"`
 }
 
 type Filters struct {
@@ -65,6 +73,22 @@ type Tags struct {
 	Include []Key `yaml:"include"`
 }
 
+// TagRewrite is a single rule of the tag_rewrites section. Type is one of
+// rename, remap, replace or drop.
+type TagRewrite struct {
+	Type string `yaml:"type"`
+	Key  Key    `yaml:"key"`
+	// To is the new key for rename.
+	To Key `yaml:"to"`
+	// Values maps old to new values for remap.
+	Values map[Value]Value `yaml:"values"`
+	// Regexp and Replacement are used for replace.
+	Regexp      string `yaml:"regexp"`
+	Replacement string `yaml:"replacement"`
+	// Value limits drop to tags with this value.
+	Value Value `yaml:"value"`
+}
+
 type Key string
 type Value string
 
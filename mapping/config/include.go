@@ -0,0 +1,195 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+
+	"gopkg.in/yaml.v2"
+)
+
+// ReadFile reads the mapping file and merges all included files.
+func ReadFile(filename string) (*Mapping, error) {
+	abs, err := filepath.Abs(filename)
+	if err != nil {
+		return nil, err
+	}
+	b, err := ioutil.ReadFile(abs)
+	if err != nil {
+		return nil, err
+	}
+	return parse(b, filepath.Dir(abs), abs)
+}
+
+// Parse parses the mapping and merges all included files. Relative
+// includes are resolved against dir.
+func Parse(b []byte, dir string) (*Mapping, error) {
+	return parse(b, dir, "")
+}
+
+// includedFile is a mapping file without the merged includes.
+type includedFile struct {
+	name string
+	m    *Mapping
+	// all files that are included by this file, including the files of
+	// nested includes
+	includes map[string]bool
+}
+
+// includer reads all included files. Each file is only read once, even if
+// it is included by multiple files (e.g. a shared base file).
+type includer struct {
+	files []*includedFile
+	read  map[string]*includedFile
+}
+
+// readFile reads filename and all files it includes. The files are
+// appended in include order, included files before the including file.
+// stack contains the including files to detect cycles.
+func (inc *includer) readFile(filename string, stack []string) (*includedFile, error) {
+	for _, f := range stack {
+		if f == filename {
+			return nil, fmt.Errorf("include cycle with %s", filename)
+		}
+	}
+	if f, ok := inc.read[filename]; ok {
+		return f, nil
+	}
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	m := &Mapping{}
+	if err := yaml.Unmarshal(b, m); err != nil {
+		return nil, err
+	}
+	f, err := inc.includeAll(m, filepath.Dir(filename), append(stack, filename))
+	if err != nil {
+		return nil, err
+	}
+	f.name = filename
+	inc.read[filename] = f
+	inc.files = append(inc.files, f)
+	return f, nil
+}
+
+// includeAll reads all includes of m.
+func (inc *includer) includeAll(m *Mapping, dir string, stack []string) (*includedFile, error) {
+	f := &includedFile{m: m, includes: make(map[string]bool)}
+	for _, name := range m.Include {
+		if !filepath.IsAbs(name) {
+			name = filepath.Join(dir, name)
+		}
+		name = filepath.Clean(name)
+		included, err := inc.readFile(name, stack)
+		if err != nil {
+			return nil, fmt.Errorf("including %s: %w", name, err)
+		}
+		f.includes[name] = true
+		for n := range included.includes {
+			f.includes[n] = true
+		}
+	}
+	return f, nil
+}
+
+func parse(b []byte, dir string, filename string) (*Mapping, error) {
+	m := &Mapping{}
+	if err := yaml.Unmarshal(b, m); err != nil {
+		return nil, err
+	}
+
+	inc := &includer{read: make(map[string]*includedFile)}
+	var stack []string
+	if filename != "" {
+		stack = []string{filename}
+	}
+	root, err := inc.includeAll(m, dir, stack)
+	if err != nil {
+		return nil, err
+	}
+
+	base := &Mapping{}
+	tables := make(map[string]string)
+	generalizedTables := make(map[string]string)
+	for _, f := range inc.files {
+		if err := base.merge(f, tables, generalizedTables); err != nil {
+			return nil, fmt.Errorf("including %s: %w", f.name, err)
+		}
+	}
+	if err := base.merge(root, tables, generalizedTables); err != nil {
+		return nil, err
+	}
+	// overrides are resolved
+	for _, t := range base.Tables {
+		t.Override = false
+	}
+	for _, t := range base.GeneralizedTables {
+		t.Override = false
+	}
+	base.Include = m.Include
+	return base, nil
+}
+
+// merge adds all tables, generalized tables, tags, areas and tag rewrites
+// of the file. Tables and generalized tables with the same name are only
+// allowed if the table of the file sets override and if the replaced table
+// is from a file that is included by this file. tables and
+// generalizedTables contain the file name of all merged tables.
+func (m *Mapping) merge(f *includedFile, tables, generalizedTables map[string]string) error {
+	other := f.m
+	if m.Tables == nil {
+		m.Tables = make(Tables)
+	}
+	for name, t := range other.Tables {
+		if _, ok := m.Tables[name]; ok && !t.Override {
+			return fmt.Errorf("duplicate table %s, set override: true to replace the included table", name)
+		} else if t.Override && (!ok || !f.includes[tables[name]]) {
+			return fmt.Errorf("table %s overrides a table that was not included", name)
+		}
+		m.Tables[name] = t
+		tables[name] = f.name
+	}
+	if m.GeneralizedTables == nil {
+		m.GeneralizedTables = make(GeneralizedTables)
+	}
+	for name, t := range other.GeneralizedTables {
+		if _, ok := m.GeneralizedTables[name]; ok && !t.Override {
+			return fmt.Errorf("duplicate generalized table %s, set override: true to replace the included table", name)
+		} else if t.Override && (!ok || !f.includes[generalizedTables[name]]) {
+			return fmt.Errorf("generalized table %s overrides a table that was not included", name)
+		}
+		m.GeneralizedTables[name] = t
+		generalizedTables[name] = f.name
+	}
+
+	m.Tags.LoadAll = m.Tags.LoadAll || other.Tags.LoadAll
+	m.Tags.Exclude = appendKeys(m.Tags.Exclude, other.Tags.Exclude)
+	m.Tags.Include = appendKeys(m.Tags.Include, other.Tags.Include)
+	m.Areas.AreaTags = appendKeys(m.Areas.AreaTags, other.Areas.AreaTags)
+	m.Areas.LinearTags = appendKeys(m.Areas.LinearTags, other.Areas.LinearTags)
+	m.TagRewrites = append(m.TagRewrites, other.TagRewrites...)
+	m.SingleIDSpace = m.SingleIDSpace || other.SingleIDSpace
+	return nil
+}
+
+// appendKeys appends all keys that are not already in keys. keys stays
+// nil if other is nil, as an empty list differs from an unset list.
+func appendKeys(keys, other []Key) []Key {
+	if keys == nil && other != nil {
+		keys = []Key{}
+	}
+	for _, k := range other {
+		found := false
+		for _, existing := range keys {
+			if existing == k {
+				found = true
+				break
+			}
+		}
+		if !found {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
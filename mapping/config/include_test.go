@@ -0,0 +1,206 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestInclude(t *testing.T) {
+	dir, err := ioutil.TempDir("", "imposm_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	writeFiles(t, dir, map[string]string{
+		"base/base.yml": `
+include: [areas.yml]
+tags:
+  exclude: [created_by]
+tables:
+  roads:
+    type: linestring
+    mapping:
+      highway: [__any__]
+  buildings:
+    type: polygon
+    mapping:
+      building: [__any__]
+generalized_tables:
+  roads_gen:
+    source: roads
+    tolerance: 50
+`,
+		"base/areas.yml": `
+areas:
+  area_tags: [building]
+  linear_tags: [highway]
+`,
+		"product.yml": `
+include: [base/base.yml]
+tags:
+  exclude: [created_by, source]
+areas:
+  area_tags: [landuse]
+tables:
+  buildings:
+    override: true
+    type: point
+    mapping:
+      building: [__any__]
+  pois:
+    type: point
+    mapping:
+      amenity: [__any__]
+`,
+	})
+
+	m, err := ReadFile(filepath.Join(dir, "product.yml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tables []string
+	for name := range m.Tables {
+		tables = append(tables, name)
+	}
+	if len(tables) != 3 || m.Tables["roads"] == nil || m.Tables["pois"] == nil {
+		t.Error("unexpected tables", tables)
+	}
+	if m.Tables["buildings"].Type != "point" {
+		t.Error("buildings not overridden", m.Tables["buildings"])
+	}
+	if m.GeneralizedTables["roads_gen"] == nil {
+		t.Error("missing generalized table")
+	}
+	if !reflect.DeepEqual(m.Tags.Exclude, []Key{"created_by", "source"}) {
+		t.Error("unexpected tags", m.Tags.Exclude)
+	}
+	if !reflect.DeepEqual(m.Areas.AreaTags, []Key{"building", "landuse"}) ||
+		!reflect.DeepEqual(m.Areas.LinearTags, []Key{"highway"}) {
+		t.Error("unexpected areas", m.Areas)
+	}
+
+	for _, tc := range []struct {
+		mapping string
+		err     string
+	}{
+		{`
+include: [base/base.yml]
+tables:
+  roads:
+    type: linestring
+`, "duplicate table roads"},
+		{`
+include: [base/base.yml]
+generalized_tables:
+  roads_gen:
+    source: roads
+`, "duplicate generalized table roads_gen"},
+		{`
+include: [base/base.yml]
+tables:
+  pois:
+    override: true
+    type: point
+`, "table pois overrides a table that was not included"},
+		{`
+include: [base/base.yml, dup.yml]
+`, "duplicate table roads"},
+		{`
+include: [cycle.yml]
+`, "include cycle"},
+		{`
+include: [missing.yml]
+`, "missing.yml"},
+	} {
+		writeFiles(t, dir, map[string]string{
+			"dup.yml":   "tables:\n  roads:\n    type: linestring\n",
+			"cycle.yml": "include: [test.yml]\n",
+			"test.yml":  tc.mapping,
+		})
+		_, err := ReadFile(filepath.Join(dir, "test.yml"))
+		if err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("expected error %q, got %v", tc.err, err)
+		}
+	}
+}
+
+func TestIncludeNested(t *testing.T) {
+	dir, err := ioutil.TempDir("", "imposm_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	writeFiles(t, dir, map[string]string{
+		"c.yml": `
+tags:
+  exclude: [created_by]
+tag_rewrites:
+  - {type: drop, key: fixme}
+tables:
+  roads:
+    type: linestring
+    mapping:
+      highway: [__any__]
+`,
+		// overrides the table of an included file
+		"b.yml": `
+include: [c.yml]
+tables:
+  roads:
+    override: true
+    type: polygon
+    mapping:
+      highway: [__any__]
+`,
+		"a.yml": "include: [b.yml]\n",
+		// includes the shared c.yml directly and through b.yml
+		"e.yml": "include: [c.yml, b.yml]\n",
+		// b.yml and sibling.yml both override roads of c.yml
+		"sibling.yml": `
+tables:
+  roads:
+    override: true
+    type: point
+`,
+		"f.yml": "include: [c.yml, sibling.yml]\n",
+	})
+
+	for _, name := range []string{"b.yml", "a.yml", "e.yml"} {
+		m, err := ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if len(m.Tables) != 1 || m.Tables["roads"].Type != "polygon" || m.Tables["roads"].Override {
+			t.Errorf("%s: unexpected tables %v", name, m.Tables)
+		}
+		if len(m.TagRewrites) != 1 || !reflect.DeepEqual(m.Tags.Exclude, []Key{"created_by"}) {
+			t.Errorf("%s: c.yml merged more than once %v %v", name, m.TagRewrites, m.Tags)
+		}
+	}
+
+	// sibling.yml does not include c.yml
+	_, err = ReadFile(filepath.Join(dir, "f.yml"))
+	if err == nil || !strings.Contains(err.Error(), "table roads overrides a table that was not included") {
+		t.Errorf("unexpected error %v", err)
+	}
+}
@@ -0,0 +1,145 @@
+package mapping
+
+import (
+	"sort"
+
+	osm "github.com/omniscale/go-osm"
+	"github.com/omniscale/imposm3/geom"
+)
+
+// Rejection is a table with a mapping for the tags of an element, that
+// rejected the element.
+type Rejection struct {
+	Match
+	// Filter describes the reason, e.g. "require name", "linear_tags" or
+	// "area=no".
+	Filter string
+}
+
+// ExplainNode returns the matches of the PointMatcher for the node and the
+// tables that rejected the node.
+func (m *Mapping) ExplainNode(node *osm.Node) ([]Match, []Rejection) {
+	var e explanation
+	e.add(m.PointMatcher.(*tagMatcher).explain(node.Tags, false, false))
+	return e.sorted()
+}
+
+// ExplainWay returns the matches of the LineStringMatcher and the
+// PolygonMatcher for the way and the tables that rejected the way.
+func (m *Mapping) ExplainWay(way *osm.Way) ([]Match, []Rejection) {
+	var e explanation
+	e.add(m.LineStringMatcher.(*tagMatcher).explainWay(way))
+	e.add(m.PolygonMatcher.(*tagMatcher).explainWay(way))
+	return e.sorted()
+}
+
+// ExplainRelation returns the matches of the PolygonMatcher, the
+// RelationMatcher and the RelationMemberMatcher for the relation and the
+// tables that rejected the relation.
+func (m *Mapping) ExplainRelation(rel *osm.Relation) ([]Match, []Rejection) {
+	var e explanation
+	e.add(m.PolygonMatcher.(*tagMatcher).explain(rel.Tags, true, true))
+	e.add(m.RelationMatcher.(*tagMatcher).explain(rel.Tags, true, true))
+	e.add(m.RelationMemberMatcher.(*tagMatcher).explain(rel.Tags, true, true))
+	return e.sorted()
+}
+
+type explanation struct {
+	matches  []Match
+	rejected []Rejection
+}
+
+func (e *explanation) add(matches []Match, rejected []Rejection) {
+	e.matches = append(e.matches, matches...)
+	e.rejected = append(e.rejected, rejected...)
+}
+
+// sorted returns the matches and rejections ordered by table, as they are
+// collected from maps.
+func (e *explanation) sorted() ([]Match, []Rejection) {
+	less := func(a, b DestTable) bool {
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return a.SubMapping < b.SubMapping
+	}
+	sort.SliceStable(e.matches, func(i, j int) bool { return less(e.matches[i].Table, e.matches[j].Table) })
+	sort.SliceStable(e.rejected, func(i, j int) bool { return less(e.rejected[i].Table, e.rejected[j].Table) })
+	return e.matches, e.rejected
+}
+
+// explain is match that also returns the rejected tables.
+func (tm *tagMatcher) explain(tags osm.Tags, closed bool, relation bool) ([]Match, []Rejection) {
+	var matches []Match
+	var rejected []Rejection
+	for t, match := range tm.candidates(tags) {
+		if filter := tm.rejectedBy(t.Name, tags, Key(match.Key), closed, relation); filter != "" {
+			rejected = append(rejected, Rejection{Match: match.Match, Filter: filter})
+		} else {
+			matches = append(matches, match.Match)
+		}
+	}
+	return matches, rejected
+}
+
+// explainWay is MatchWay that also returns the rejected tables.
+func (tm *tagMatcher) explainWay(way *osm.Way) ([]Match, []Rejection) {
+	closed := way.IsClosed()
+	var reason string
+	switch {
+	case tm.matchAreas && !closed:
+		reason = "not closed"
+	case tm.matchAreas && way.Tags["area"] == "no":
+		reason = "area=no"
+	case !tm.matchAreas && closed && way.Tags["area"] == "yes":
+		reason = "area=yes"
+	}
+	if reason == "" {
+		return tm.explain(way.Tags, closed, false)
+	}
+	var rejected []Rejection
+	for _, match := range tm.candidates(way.Tags) {
+		rejected = append(rejected, Rejection{Match: match.Match, Filter: reason})
+	}
+	return nil, rejected
+}
+
+// geometryColumnTypes are the column types that require the geometry of
+// the element.
+var geometryColumnTypes = map[string]bool{
+	"geometry":                   true,
+	"validated_geometry":         true,
+	"area":                       true,
+	"webmerc_area":               true,
+	"pseudoarea":                 true,
+	"geojson_intersects":         true,
+	"geojson_intersects_feature": true,
+}
+
+// Columns returns the values of Row by column name, without the columns
+// that require a geometry. For inspecting the mapping without building
+// geometries.
+func (m *Match) Columns(elem *osm.Element) map[string]interface{} {
+	return m.builder.makeColumns(func(column valueBuilder) interface{} {
+		return column.Value(elem, &geom.Geometry{}, *m)
+	})
+}
+
+// MemberColumns returns the values of MemberRow by column name, without
+// the columns that require a geometry.
+func (m *Match) MemberColumns(rel *osm.Relation, member *osm.Member, memberIndex int) map[string]interface{} {
+	return m.builder.makeColumns(func(column valueBuilder) interface{} {
+		return column.MemberValue(rel, member, memberIndex, &geom.Geometry{}, *m)
+	})
+}
+
+func (r *rowBuilder) makeColumns(value func(valueBuilder) interface{}) map[string]interface{} {
+	columns := make(map[string]interface{})
+	for _, column := range r.columns {
+		if geometryColumnTypes[column.colType.Name] {
+			continue
+		}
+		columns[column.name] = value(column)
+	}
+	return columns
+}
@@ -0,0 +1,100 @@
+package mapping
+
+import (
+	"reflect"
+	"testing"
+
+	osm "github.com/omniscale/go-osm"
+)
+
+func TestExplain(t *testing.T) {
+	m, err := New([]byte(`
+    areas:
+      linear_tags: [highway]
+    tables:
+      amenities:
+        type: point
+        columns:
+        - {name: osm_id, type: id}
+        - {name: geometry, type: geometry}
+        - {name: name, key: name, type: string}
+        filters:
+          require:
+            name: [__any__]
+        mapping:
+          amenity: [__any__]
+      roads:
+        type: linestring
+        mapping:
+          highway: [__any__]
+      areas:
+        type: polygon
+        mapping:
+          highway: [pedestrian]
+          landuse: [__any__]
+      routes:
+        type: relation_member
+        columns:
+        - {name: member, type: member_id}
+        - {name: role, type: member_role}
+        relation_types: [route]
+        mapping:
+          route: [bus]
+    `))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type result struct {
+		matches  []string
+		rejected map[string]string
+	}
+	explained := func(matches []Match, rejected []Rejection) result {
+		r := result{rejected: map[string]string{}}
+		for _, m := range matches {
+			r.matches = append(r.matches, m.Table.Name)
+		}
+		for _, rej := range rejected {
+			r.rejected[rej.Table.Name] = rej.Filter
+		}
+		return r
+	}
+
+	for _, tc := range []struct {
+		name     string
+		got      result
+		expected result
+	}{
+		{"node", explained(m.ExplainNode(&osm.Node{Element: osm.Element{Tags: osm.Tags{"amenity": "cafe", "name": "Foo"}}})),
+			result{[]string{"amenities"}, map[string]string{}}},
+		{"node without name", explained(m.ExplainNode(&osm.Node{Element: osm.Element{Tags: osm.Tags{"amenity": "cafe"}}})),
+			result{nil, map[string]string{"amenities": "require name"}}},
+		{"open way", explained(m.ExplainWay(&osm.Way{Element: osm.Element{Tags: osm.Tags{"highway": "pedestrian"}}, Refs: []int64{1, 2}})),
+			result{[]string{"roads"}, map[string]string{"areas": "not closed"}}},
+		{"closed linear way", explained(m.ExplainWay(&osm.Way{Element: osm.Element{Tags: osm.Tags{"highway": "pedestrian"}}, Refs: []int64{1, 2, 3, 1}})),
+			result{[]string{"roads"}, map[string]string{"areas": "linear_tags"}}},
+		{"closed area way", explained(m.ExplainWay(&osm.Way{Element: osm.Element{Tags: osm.Tags{"highway": "pedestrian", "area": "yes"}}, Refs: []int64{1, 2, 3, 1}})),
+			result{[]string{"areas"}, map[string]string{"roads": "area=yes"}}},
+		{"relation", explained(m.ExplainRelation(&osm.Relation{Element: osm.Element{Tags: osm.Tags{"type": "route", "route": "bus"}}})),
+			result{[]string{"routes"}, map[string]string{}}},
+		{"relation without type", explained(m.ExplainRelation(&osm.Relation{Element: osm.Element{Tags: osm.Tags{"landuse": "forest", "route": "bus"}}})),
+			result{nil, map[string]string{"areas": "type=multipolygon", "routes": "relation_types"}}},
+	} {
+		if !reflect.DeepEqual(tc.got, tc.expected) {
+			t.Errorf("%s: unexpected result %v, expected %v", tc.name, tc.got, tc.expected)
+		}
+	}
+
+	nd := &osm.Node{Element: osm.Element{ID: 42, Tags: osm.Tags{"amenity": "cafe", "name": "Foo"}}}
+	matches, _ := m.ExplainNode(nd)
+	if cols := matches[0].Columns(&nd.Element); !reflect.DeepEqual(cols, map[string]interface{}{"osm_id": int64(42), "name": "Foo"}) {
+		t.Error("unexpected columns", cols)
+	}
+
+	rel := &osm.Relation{Element: osm.Element{ID: 7, Tags: osm.Tags{"type": "route", "route": "bus"}},
+		Members: []osm.Member{{ID: 3, Type: osm.WayMember, Role: "forward"}}}
+	matches, _ = m.ExplainRelation(rel)
+	if cols := matches[0].MemberColumns(rel, &rel.Members[0], 0); !reflect.DeepEqual(cols, map[string]interface{}{"member": int64(3), "role": "forward"}) {
+		t.Error("unexpected member columns", cols)
+	}
+}
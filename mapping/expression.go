@@ -0,0 +1,719 @@
+package mapping
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	osm "github.com/omniscale/go-osm"
+	"github.com/omniscale/imposm3/geom"
+)
+
+// This file implements the expressions of the expression column type. An
+// expression is compiled once into a tree of closures. Expressions can only
+// read the element, the match and the geometry. There are no loops or
+// user-defined functions, so the evaluation time is bounded by the size of
+// the expression.
+//
+// Values are nil (null), float64, string or bool.
+
+// maxExpressionDepth limits the nesting of expressions.
+const maxExpressionDepth = 64
+
+type exprEnv struct {
+	elem  *osm.Element
+	geom  *geom.Geometry
+	match *Match
+}
+
+type exprFunc func(env *exprEnv) interface{}
+
+// expression is a compiled expression.
+type expression struct {
+	eval exprFunc
+	// tags are the keys of all tag() calls
+	tags []string
+}
+
+func compileExpression(src string) (*expression, error) {
+	tokens, err := tokenize(src)
+	if err != nil {
+		return nil, err
+	}
+	p := exprParser{tokens: tokens}
+	eval, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, fmt.Errorf("unexpected %s at position %d", t, t.pos)
+	}
+	return &expression{eval: eval, tags: p.tags}, nil
+}
+
+func (e *expression) Eval(elem *osm.Element, geom *geom.Geometry, match *Match) interface{} {
+	return e.eval(&exprEnv{elem: elem, geom: geom, match: match})
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokNumber
+	tokString
+	tokIdent
+	tokOp
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	num  float64
+	pos  int
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokEOF:
+		return "end of expression"
+	case tokString:
+		return strconv.Quote(t.text)
+	}
+	return "'" + t.text + "'"
+}
+
+var twoCharOps = []string{"==", "!=", "<=", ">="}
+
+func tokenize(src string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(src); {
+		c := src[i]
+		r, size := utf8.DecodeRuneInString(src[i:])
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c >= '0' && c <= '9' || c == '.' && i+1 < len(src) && src[i+1] >= '0' && src[i+1] <= '9':
+			start := i
+			for i < len(src) && (src[i] >= '0' && src[i] <= '9' || src[i] == '.') {
+				i++
+			}
+			num, err := strconv.ParseFloat(src[start:i], 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid number %q at position %d", src[start:i], start)
+			}
+			tokens = append(tokens, token{kind: tokNumber, text: src[start:i], num: num, pos: start})
+		case c == '"' || c == '\'':
+			start := i
+			var sb strings.Builder
+			i++
+			for ; i < len(src) && src[i] != c; i++ {
+				if src[i] == '\\' && i+1 < len(src) {
+					i++
+				}
+				sb.WriteByte(src[i])
+			}
+			if i >= len(src) {
+				return nil, fmt.Errorf("unterminated string at position %d", start)
+			}
+			i++
+			tokens = append(tokens, token{kind: tokString, text: sb.String(), pos: start})
+		case r == '_' || unicode.IsLetter(r):
+			start := i
+			for i < len(src) {
+				r, size := utf8.DecodeRuneInString(src[i:])
+				if r != '_' && !unicode.IsLetter(r) && !(r >= '0' && r <= '9') {
+					break
+				}
+				i += size
+			}
+			tokens = append(tokens, token{kind: tokIdent, text: src[start:i], pos: start})
+		default:
+			op := src[i : i+size]
+			for _, o := range twoCharOps {
+				if strings.HasPrefix(src[i:], o) {
+					op = o
+				}
+			}
+			if len(op) == size && !strings.Contains("+-*/%<>(),", op) {
+				return nil, fmt.Errorf("unexpected character %q at position %d", r, i)
+			}
+			tokens = append(tokens, token{kind: tokOp, text: op, pos: i})
+			i += len(op)
+		}
+	}
+	return append(tokens, token{kind: tokEOF, pos: len(src)}), nil
+}
+
+type exprParser struct {
+	tokens []token
+	pos    int
+	depth  int
+	tags   []string
+}
+
+func (p *exprParser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *exprParser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *exprParser) isOp(ops ...string) bool {
+	t := p.peek()
+	if t.kind != tokOp && t.kind != tokIdent {
+		return false
+	}
+	for _, op := range ops {
+		if t.text == op {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *exprParser) expect(op string) error {
+	if t := p.next(); t.kind != tokOp || t.text != op {
+		return fmt.Errorf("expected '%s' at position %d, got %s", op, t.pos, t)
+	}
+	return nil
+}
+
+// enter increments the nesting depth of the parser. The caller needs to
+// call leave.
+func (p *exprParser) enter() error {
+	p.depth++
+	if p.depth > maxExpressionDepth {
+		return fmt.Errorf("expression nested too deeply at position %d", p.peek().pos)
+	}
+	return nil
+}
+
+func (p *exprParser) leave() {
+	p.depth--
+}
+
+func (p *exprParser) parseOr() (exprFunc, error) {
+	defer p.leave()
+	if err := p.enter(); err != nil {
+		return nil, err
+	}
+
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("or") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(env *exprEnv) interface{} { return truthy(l(env)) || truthy(right(env)) }
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseAnd() (exprFunc, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("and") {
+		p.next()
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(env *exprEnv) interface{} { return truthy(l(env)) && truthy(right(env)) }
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseNot() (exprFunc, error) {
+	if p.isOp("not") {
+		p.next()
+		defer p.leave()
+		if err := p.enter(); err != nil {
+			return nil, err
+		}
+		operand, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return func(env *exprEnv) interface{} { return !truthy(operand(env)) }, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *exprParser) parseComparison() (exprFunc, error) {
+	left, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+	if !p.isOp("==", "!=", "<", "<=", ">", ">=") || p.peek().kind != tokOp {
+		return left, nil
+	}
+	op := p.next().text
+	right, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+	return func(env *exprEnv) interface{} {
+		return compareValues(op, left(env), right(env))
+	}, nil
+}
+
+func (p *exprParser) parseAdditive() (exprFunc, error) {
+	left, err := p.parseMultiplicative()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("+", "-") && p.peek().kind == tokOp {
+		op := p.next().text
+		right, err := p.parseMultiplicative()
+		if err != nil {
+			return nil, err
+		}
+		left = arithmetic(op, left, right)
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseMultiplicative() (exprFunc, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("*", "/", "%") && p.peek().kind == tokOp {
+		op := p.next().text
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = arithmetic(op, left, right)
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseUnary() (exprFunc, error) {
+	if p.isOp("-") && p.peek().kind == tokOp {
+		p.next()
+		defer p.leave()
+		if err := p.enter(); err != nil {
+			return nil, err
+		}
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return func(env *exprEnv) interface{} {
+			if n, ok := toNumber(operand(env)); ok {
+				return -n
+			}
+			return nil
+		}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *exprParser) parsePrimary() (exprFunc, error) {
+	t := p.next()
+	switch t.kind {
+	case tokNumber:
+		return constant(t.num), nil
+	case tokString:
+		return constant(t.text), nil
+	case tokOp:
+		if t.text == "(" {
+			e, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			return e, p.expect(")")
+		}
+	case tokIdent:
+		if p.isOp("(") && p.peek().kind == tokOp {
+			return p.parseCall(t)
+		}
+		switch t.text {
+		case "true":
+			return constant(true), nil
+		case "false":
+			return constant(false), nil
+		case "null":
+			return constant(nil), nil
+		}
+		if v, ok := exprVariables[t.text]; ok {
+			return v, nil
+		}
+		return nil, fmt.Errorf("unknown name '%s' at position %d", t.text, t.pos)
+	}
+	return nil, fmt.Errorf("unexpected %s at position %d", t, t.pos)
+}
+
+func (p *exprParser) parseCall(name token) (exprFunc, error) {
+	p.next() // (
+
+	if name.text == "tag" {
+		// only literal keys, to know all keys that need to be kept by the
+		// tag filters
+		t := p.next()
+		if t.kind != tokString || p.expect(")") != nil {
+			return nil, fmt.Errorf("tag() at position %d requires a single string literal", name.pos)
+		}
+		key := t.text
+		p.tags = append(p.tags, key)
+		return func(env *exprEnv) interface{} {
+			if v, ok := env.elem.Tags[key]; ok {
+				return v
+			}
+			return nil
+		}, nil
+	}
+
+	var args []exprFunc
+	for !p.isOp(")") || p.peek().kind != tokOp {
+		if len(args) > 0 {
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+		arg, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+	}
+	p.next() // )
+
+	f, ok := exprFunctions[name.text]
+	if !ok {
+		return nil, fmt.Errorf("unknown function '%s' at position %d", name.text, name.pos)
+	}
+	if len(args) < f.minArgs || f.maxArgs >= 0 && len(args) > f.maxArgs {
+		return nil, fmt.Errorf("wrong number of arguments for %s() at position %d", name.text, name.pos)
+	}
+	return f.make(args), nil
+}
+
+func constant(v interface{}) exprFunc {
+	return func(*exprEnv) interface{} { return v }
+}
+
+var exprVariables = map[string]exprFunc{
+	"id": func(env *exprEnv) interface{} { return float64(env.elem.ID) },
+	"key": func(env *exprEnv) interface{} {
+		if env.match == nil {
+			return nil
+		}
+		return env.match.Key
+	},
+	"value": func(env *exprEnv) interface{} {
+		if env.match == nil {
+			return nil
+		}
+		return env.match.Value
+	},
+	"area": func(env *exprEnv) interface{} {
+		if env.geom == nil || env.geom.Geom == nil {
+			return nil
+		}
+		return env.geom.Geom.Area()
+	},
+	"length": func(env *exprEnv) interface{} {
+		if env.geom == nil || env.geom.Geom == nil {
+			return nil
+		}
+		return env.geom.Geom.Length()
+	},
+}
+
+type exprFunction struct {
+	minArgs, maxArgs int // maxArgs -1 for any number
+	make             func(args []exprFunc) exprFunc
+}
+
+// stringFunc returns a function for a single string argument. null stays
+// null.
+func stringFunc(f func(string) interface{}) exprFunction {
+	return exprFunction{1, 1, func(args []exprFunc) exprFunc {
+		return func(env *exprEnv) interface{} {
+			v := args[0](env)
+			if v == nil {
+				return nil
+			}
+			return f(toString(v))
+		}
+	}}
+}
+
+// numberFunc returns a function for a single number argument. Returns null
+// for non-numeric values.
+func numberFunc(f func(float64) interface{}) exprFunction {
+	return exprFunction{1, 1, func(args []exprFunc) exprFunc {
+		return func(env *exprEnv) interface{} {
+			if n, ok := toNumber(args[0](env)); ok {
+				return f(n)
+			}
+			return nil
+		}
+	}}
+}
+
+var exprFunctions map[string]exprFunction
+
+func init() {
+	exprFunctions = map[string]exprFunction{
+		"coalesce": {1, -1, func(args []exprFunc) exprFunc {
+			return func(env *exprEnv) interface{} {
+				for _, arg := range args {
+					if v := arg(env); v != nil && v != "" {
+						return v
+					}
+				}
+				return nil
+			}
+		}},
+		"if": {2, 3, func(args []exprFunc) exprFunc {
+			return func(env *exprEnv) interface{} {
+				if truthy(args[0](env)) {
+					return args[1](env)
+				}
+				if len(args) == 3 {
+					return args[2](env)
+				}
+				return nil
+			}
+		}},
+		"concat": {1, -1, func(args []exprFunc) exprFunc {
+			return func(env *exprEnv) interface{} {
+				var sb strings.Builder
+				for _, arg := range args {
+					if v := arg(env); v != nil {
+						sb.WriteString(toString(v))
+					}
+				}
+				return sb.String()
+			}
+		}},
+		"substr": {2, 3, func(args []exprFunc) exprFunc {
+			return func(env *exprEnv) interface{} {
+				v := args[0](env)
+				start, ok := toNumber(args[1](env))
+				if v == nil || !ok {
+					return nil
+				}
+				s := []rune(toString(v))
+				from := clampIndex(int(start), len(s))
+				to := len(s)
+				if len(args) == 3 {
+					n, ok := toNumber(args[2](env))
+					if !ok {
+						return nil
+					}
+					to = clampIndex(from+int(n), len(s))
+				}
+				if to < from {
+					return ""
+				}
+				return string(s[from:to])
+			}
+		}},
+		"replace": {3, 3, func(args []exprFunc) exprFunc {
+			return func(env *exprEnv) interface{} {
+				v, old, new := args[0](env), args[1](env), args[2](env)
+				if v == nil {
+					return nil
+				}
+				return strings.Replace(toString(v), toString(old), toString(new), -1)
+			}
+		}},
+		"contains":   stringPredicate(strings.Contains),
+		"startswith": stringPredicate(strings.HasPrefix),
+		"endswith":   stringPredicate(strings.HasSuffix),
+		"lower":      stringFunc(func(s string) interface{} { return strings.ToLower(s) }),
+		"upper":      stringFunc(func(s string) interface{} { return strings.ToUpper(s) }),
+		"trim":       stringFunc(func(s string) interface{} { return strings.TrimSpace(s) }),
+		"len":        stringFunc(func(s string) interface{} { return float64(len([]rune(s))) }),
+		"string": stringFunc(func(s string) interface{} {
+			return s
+		}),
+		"number": numberFunc(func(n float64) interface{} { return n }),
+		"int":    numberFunc(func(n float64) interface{} { return math.Trunc(n) }),
+		"round":  numberFunc(func(n float64) interface{} { return math.Round(n) }),
+		"abs":    numberFunc(func(n float64) interface{} { return math.Abs(n) }),
+		"min":    numbersFunc(math.Min),
+		"max":    numbersFunc(math.Max),
+	}
+}
+
+func stringPredicate(f func(s, substr string) bool) exprFunction {
+	return exprFunction{2, 2, func(args []exprFunc) exprFunc {
+		return func(env *exprEnv) interface{} {
+			v, sub := args[0](env), args[1](env)
+			if v == nil || sub == nil {
+				return false
+			}
+			return f(toString(v), toString(sub))
+		}
+	}}
+}
+
+// numbersFunc returns a function that reduces all numeric arguments with f.
+// Other arguments are ignored.
+func numbersFunc(f func(a, b float64) float64) exprFunction {
+	return exprFunction{1, -1, func(args []exprFunc) exprFunc {
+		return func(env *exprEnv) interface{} {
+			var result interface{}
+			for _, arg := range args {
+				n, ok := toNumber(arg(env))
+				if !ok {
+					continue
+				}
+				if result == nil {
+					result = n
+				} else {
+					result = f(result.(float64), n)
+				}
+			}
+			return result
+		}
+	}}
+}
+
+func clampIndex(i, length int) int {
+	if i < 0 {
+		return 0
+	}
+	if i > length {
+		return length
+	}
+	return i
+}
+
+func arithmetic(op string, left, right exprFunc) exprFunc {
+	return func(env *exprEnv) interface{} {
+		a, ok := toNumber(left(env))
+		if !ok {
+			return nil
+		}
+		b, ok := toNumber(right(env))
+		if !ok {
+			return nil
+		}
+		switch op {
+		case "+":
+			return a + b
+		case "-":
+			return a - b
+		case "*":
+			return a * b
+		case "/":
+			if b == 0 {
+				return nil
+			}
+			return a / b
+		case "%":
+			if b == 0 {
+				return nil
+			}
+			return math.Mod(a, b)
+		}
+		return nil
+	}
+}
+
+// compareValues compares numerically if both values are numbers or numeric
+// strings, and as strings otherwise. Only == and != are true for null.
+func compareValues(op string, a, b interface{}) bool {
+	if a == nil || b == nil {
+		switch op {
+		case "==":
+			return a == nil && b == nil
+		case "!=":
+			return !(a == nil && b == nil)
+		}
+		return false
+	}
+	var cmp int
+	na, okA := toNumber(a)
+	nb, okB := toNumber(b)
+	if okA && okB {
+		switch {
+		case na < nb:
+			cmp = -1
+		case na > nb:
+			cmp = 1
+		}
+	} else {
+		cmp = strings.Compare(toString(a), toString(b))
+	}
+	switch op {
+	case "==":
+		return cmp == 0
+	case "!=":
+		return cmp != 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	}
+	return false
+}
+
+func truthy(v interface{}) bool {
+	switch v := v.(type) {
+	case bool:
+		return v
+	case float64:
+		return v != 0
+	case string:
+		return v != "" && v != "no" && v != "false" && v != "0"
+	}
+	return false
+}
+
+func toNumber(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case float64:
+		return v, true
+	case bool:
+		if v {
+			return 1, true
+		}
+		return 0, true
+	case string:
+		n, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil || math.IsNaN(n) || math.IsInf(n, 0) {
+			return 0, false
+		}
+		return n, true
+	}
+	return 0, false
+}
+
+func toString(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	return ""
+}
@@ -1,7 +1,6 @@
 package mapping
 
 import (
-	"io/ioutil"
 	"regexp"
 
 	osm "github.com/omniscale/go-osm"
@@ -9,7 +8,6 @@ import (
 	"github.com/omniscale/imposm3/mapping/config"
 
 	"github.com/pkg/errors"
-	"gopkg.in/yaml.v2"
 )
 
 type orderedDestTable struct {
@@ -88,24 +86,33 @@ type Mapping struct {
 	PolygonMatcher        RelWayMatcher
 	RelationMatcher       RelationMatcher
 	RelationMemberMatcher RelationMatcher
+
+	tagRewriter *TagRewriter
 }
 
+// FromFile loads the mapping file. Included files are relative to the
+// mapping file.
 func FromFile(filename string) (*Mapping, error) {
-	b, err := ioutil.ReadFile(filename)
+	conf, err := config.ReadFile(filename)
 	if err != nil {
 		return nil, err
 	}
-	return New(b)
+	return fromConfig(conf)
 }
 
+// New loads the mapping. Included files are relative to the working
+// directory.
 func New(b []byte) (*Mapping, error) {
-	mapping := Mapping{}
-	err := yaml.Unmarshal(b, &mapping.Conf)
+	conf, err := config.Parse(b, "")
 	if err != nil {
 		return nil, err
 	}
+	return fromConfig(conf)
+}
 
-	err = mapping.prepare()
+func fromConfig(conf *config.Mapping) (*Mapping, error) {
+	mapping := Mapping{Conf: *conf}
+	err := mapping.prepare()
 	if err != nil {
 		return nil, err
 	}
@@ -138,6 +145,12 @@ func (m *Mapping) prepare() error {
 	for name, t := range m.Conf.GeneralizedTables {
 		t.Name = name
 	}
+
+	var err error
+	m.tagRewriter, err = newTagRewriter(m.Conf.TagRewrites)
+	if err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -208,6 +221,7 @@ func makeRowBuilder(tbl *config.Table) (*rowBuilder, error) {
 
 	for _, mappingColumn := range tbl.Columns {
 		column := valueBuilder{}
+		column.name = mappingColumn.Name
 		column.key = Key(mappingColumn.Key)
 
 		columnType, err := MakeColumnType(mappingColumn)
@@ -231,7 +245,14 @@ func MakeColumnType(c *config.Column) (*ColumnType, error) {
 		if err != nil {
 			return nil, err
 		}
-		columnType = ColumnType{columnType.Name, columnType.GoType, makeValue, nil, nil, columnType.FromMember}
+		goType := columnType.GoType
+		if c.Type == "expression" {
+			// the GoType of expression columns depends on the type arg,
+			// which MakeFunc already validated
+			resultType, _ := expressionResultType(*c)
+			goType = expressionResultTypes[resultType].goType
+		}
+		columnType = ColumnType{columnType.Name, goType, makeValue, nil, nil, columnType.FromMember}
 	}
 	columnType.FromMember = c.FromMember
 	return &columnType, nil
@@ -250,6 +271,11 @@ func (m *Mapping) extraTags(tableType TableType, tags map[Key]bool) {
 			for _, k := range col.Keys {
 				tags[Key(k)] = true
 			}
+			if col.Type == "expression" {
+				for _, k := range expressionTags(*col) {
+					tags[Key(k)] = true
+				}
+			}
 		}
 
 		if t.Filters != nil && t.Filters.ExcludeTags != nil {
@@ -272,10 +298,18 @@ func (m *Mapping) extraTags(tableType TableType, tags map[Key]bool) {
 	tags["area"] = true
 }
 
-type elementFilter func(tags osm.Tags, key Key, closed bool) bool
+type elementFilter struct {
+	// name describes the filter for Explain, e.g. "require name"
+	name   string
+	accept func(tags osm.Tags, key Key, closed bool) bool
+}
 
 type tableElementFilters map[string][]elementFilter
 
+func (f tableElementFilters) add(table, name string, accept func(tags osm.Tags, key Key, closed bool) bool) {
+	f[table] = append(f[table], elementFilter{name: name, accept: accept})
+}
+
 func (m *Mapping) addTypedFilters(tableType TableType, filters tableElementFilters) {
 	var areaTags map[Key]struct{}
 	var linearTags map[Key]struct{}
@@ -310,7 +344,7 @@ func (m *Mapping) addTypedFilters(tableType TableType, filters tableElementFilte
 				}
 				return true
 			}
-			filters[name] = append(filters[name], f)
+			filters.add(name, "area_tags", f)
 		}
 		if TableType(t.Type) == PolygonTable && linearTags != nil {
 			f := func(tags osm.Tags, key Key, closed bool) bool {
@@ -324,7 +358,7 @@ func (m *Mapping) addTypedFilters(tableType TableType, filters tableElementFilte
 				}
 				return true
 			}
-			filters[name] = append(filters[name], f)
+			filters.add(name, "linear_tags", f)
 		}
 	}
 }
@@ -343,7 +377,7 @@ func (m *Mapping) addRelationFilters(tableType TableType, filters tableElementFi
 				}
 				return false
 			}
-			filters[name] = append(filters[name], f)
+			filters.add(name, "relation_types", f)
 		} else {
 			if TableType(t.Type) == PolygonTable {
 				// standard multipolygon handling (boundary and land_area are for backwards compatibility)
@@ -355,7 +389,7 @@ func (m *Mapping) addRelationFilters(tableType TableType, filters tableElementFi
 					}
 					return false
 				}
-				filters[name] = append(filters[name], f)
+				filters.add(name, "type=multipolygon", f)
 			}
 		}
 	}
@@ -377,32 +411,32 @@ func (m *Mapping) addFilters(filters tableElementFilters) {
 						Order: 1,
 					},
 				}
-				filters[name] = append(filters[name], makeFiltersFunction(name, false, true, keyname, vararr))
+				filters.add(name, "exclude_tags "+keyname, makeFiltersFunction(name, false, true, keyname, vararr))
 
 			}
 		}
 
 		if t.Filters.Require != nil {
 			for keyname, vararr := range t.Filters.Require {
-				filters[name] = append(filters[name], makeFiltersFunction(name, true, false, string(keyname), vararr))
+				filters.add(name, "require "+string(keyname), makeFiltersFunction(name, true, false, string(keyname), vararr))
 			}
 		}
 
 		if t.Filters.Reject != nil {
 			for keyname, vararr := range t.Filters.Reject {
-				filters[name] = append(filters[name], makeFiltersFunction(name, false, true, string(keyname), vararr))
+				filters.add(name, "reject "+string(keyname), makeFiltersFunction(name, false, true, string(keyname), vararr))
 			}
 		}
 
 		if t.Filters.RequireRegexp != nil {
 			for keyname, regexp := range t.Filters.RequireRegexp {
-				filters[name] = append(filters[name], makeRegexpFiltersFunction(name, true, false, string(keyname), regexp))
+				filters.add(name, "require_regexp "+string(keyname), makeRegexpFiltersFunction(name, true, false, string(keyname), regexp))
 			}
 		}
 
 		if t.Filters.RejectRegexp != nil {
 			for keyname, regexp := range t.Filters.RejectRegexp {
-				filters[name] = append(filters[name], makeRegexpFiltersFunction(name, false, true, string(keyname), regexp))
+				filters.add(name, "reject_regexp "+string(keyname), makeRegexpFiltersFunction(name, false, true, string(keyname), regexp))
 			}
 		}
 
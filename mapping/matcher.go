@@ -164,6 +164,18 @@ type orderedMatch struct {
 }
 
 func (tm *tagMatcher) match(tags osm.Tags, closed bool, relation bool) []Match {
+	var matches []Match
+	for t, match := range tm.candidates(tags) {
+		if tm.rejectedBy(t.Name, tags, Key(match.Key), closed, relation) == "" {
+			matches = append(matches, match.Match)
+		}
+	}
+	return matches
+}
+
+// candidates returns the match for each table with a mapping for the tags,
+// before the filters of the tables are applied.
+func (tm *tagMatcher) candidates(tags osm.Tags) map[DestTable]orderedMatch {
 	tables := make(map[DestTable]orderedMatch)
 
 	addTables := func(k, v string, tbls []orderedDestTable) {
@@ -201,38 +213,29 @@ func (tm *tagMatcher) match(tags osm.Tags, closed bool, relation bool) []Match {
 			}
 		}
 	}
-	var matches []Match
-	for t, match := range tables {
-		filters, ok := tm.filters[t.Name]
-		filteredOut := false
-		if ok {
-			for _, filter := range filters {
-				if !filter(tags, Key(match.Key), closed) {
-					filteredOut = true
-					break
-				}
-			}
+	return tables
+}
+
+// rejectedBy returns the name of the first filter of the table that rejects
+// the tags, or an empty string.
+func (tm *tagMatcher) rejectedBy(table string, tags osm.Tags, key Key, closed bool, relation bool) string {
+	for _, filter := range tm.filters[table] {
+		if !filter.accept(tags, key, closed) {
+			return filter.name
 		}
-		if relation && !filteredOut {
-			filters, ok := tm.relFilters[t.Name]
-			if ok {
-				for _, filter := range filters {
-					if !filter(tags, Key(match.Key), closed) {
-						filteredOut = true
-						break
-					}
-				}
+	}
+	if relation {
+		for _, filter := range tm.relFilters[table] {
+			if !filter.accept(tags, key, closed) {
+				return filter.name
 			}
 		}
-
-		if !filteredOut {
-			matches = append(matches, match.Match)
-		}
 	}
-	return matches
+	return ""
 }
 
 type valueBuilder struct {
+	name    string
 	key     Key
 	colType ColumnType
 }
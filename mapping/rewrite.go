@@ -0,0 +1,132 @@
+package mapping
+
+import (
+	"path"
+	"regexp"
+	"strings"
+
+	osm "github.com/omniscale/go-osm"
+	"github.com/omniscale/imposm3/mapping/config"
+	"github.com/pkg/errors"
+)
+
+// TagRewriter normalizes tags with the rules from the tag_rewrites section
+// of the mapping. Tags need to be rewritten before they are filtered, as the
+// filters only keep the normalized tags.
+type TagRewriter struct {
+	rules []rewriteRule
+}
+
+type rewriteRule func(tags osm.Tags)
+
+// Rewrite applies all rules in the configured order. Rewrite is a no-op for
+// a nil TagRewriter.
+func (r *TagRewriter) Rewrite(tags *osm.Tags) {
+	if r == nil || tags == nil || *tags == nil {
+		return
+	}
+	for _, rule := range r.rules {
+		rule(*tags)
+	}
+}
+
+// TagRewriter returns the rewriter for the tag_rewrites of the mapping.
+// Returns nil if there are no rules.
+func (m *Mapping) TagRewriter() *TagRewriter {
+	return m.tagRewriter
+}
+
+func newTagRewriter(rewrites []config.TagRewrite) (*TagRewriter, error) {
+	if len(rewrites) == 0 {
+		return nil, nil
+	}
+	r := &TagRewriter{}
+	for i, rw := range rewrites {
+		if rw.Key == "" {
+			return nil, errors.Errorf("missing key for tag_rewrites #%d", i+1)
+		}
+		rule, err := makeRewriteRule(rw)
+		if err != nil {
+			return nil, errors.Wrapf(err, "tag_rewrites #%d", i+1)
+		}
+		r.rules = append(r.rules, rule)
+	}
+	return r, nil
+}
+
+func makeRewriteRule(rw config.TagRewrite) (rewriteRule, error) {
+	key := string(rw.Key)
+	switch rw.Type {
+	case "rename":
+		if rw.To == "" {
+			return nil, errors.New("rename requires to")
+		}
+		to := string(rw.To)
+		return func(tags osm.Tags) {
+			v, ok := tags[key]
+			if !ok {
+				return
+			}
+			delete(tags, key)
+			// keep existing tags, e.g. highway=primary for Highway=primary
+			if _, ok := tags[to]; !ok {
+				tags[to] = v
+			}
+		}, nil
+	case "remap":
+		if len(rw.Values) == 0 {
+			return nil, errors.New("remap requires values")
+		}
+		values := make(map[string]string, len(rw.Values))
+		for from, to := range rw.Values {
+			values[string(from)] = string(to)
+		}
+		return func(tags osm.Tags) {
+			if v, ok := tags[key]; ok {
+				if newV, ok := values[v]; ok {
+					tags[key] = newV
+				}
+			}
+		}, nil
+	case "replace":
+		if rw.Regexp == "" {
+			return nil, errors.New("replace requires regexp")
+		}
+		re, err := regexp.Compile(rw.Regexp)
+		if err != nil {
+			return nil, errors.Wrap(err, "compiling regexp")
+		}
+		return func(tags osm.Tags) {
+			v, ok := tags[key]
+			if !ok {
+				return
+			}
+			v = re.ReplaceAllString(v, rw.Replacement)
+			if v == "" {
+				delete(tags, key)
+			} else {
+				tags[key] = v
+			}
+		}, nil
+	case "drop":
+		value := string(rw.Value)
+		if strings.ContainsAny(key, "?*[") {
+			if _, err := path.Match(key, ""); err != nil {
+				return nil, errors.Wrapf(err, "invalid key pattern %q", key)
+			}
+			return func(tags osm.Tags) {
+				for k, v := range tags {
+					if ok, _ := path.Match(key, k); ok && (value == "" || v == value) {
+						delete(tags, k)
+					}
+				}
+			}, nil
+		}
+		return func(tags osm.Tags) {
+			if v, ok := tags[key]; ok && (value == "" || v == value) {
+				delete(tags, key)
+			}
+		}, nil
+	}
+	return nil, errors.Errorf("unknown type %q (rename, remap, replace or drop)", rw.Type)
+}
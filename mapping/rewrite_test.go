@@ -0,0 +1,102 @@
+package mapping
+
+import (
+	"reflect"
+	"testing"
+
+	osm "github.com/omniscale/go-osm"
+)
+
+func TestTagRewriter(t *testing.T) {
+	m, err := New([]byte(`
+tag_rewrites:
+  - type: rename
+    key: Highway
+    to: highway
+  - type: remap
+    key: landuse
+    values:
+      farm: farmland
+  - type: replace
+    key: addr:street
+    regexp: '^\s+|\s+$'
+    replacement: ''
+  - type: drop
+    key: fixme
+  - type: drop
+    key: "tiger:*"
+  - type: drop
+    key: oneway
+    value: "no"
+tables:
+  roads:
+    type: linestring
+    columns:
+    - name: type
+      type: mapping_value
+    mapping:
+      highway: [__any__]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := m.TagRewriter()
+
+	for _, tc := range []struct {
+		tags     osm.Tags
+		expected osm.Tags
+	}{
+		{osm.Tags{"Highway": "primary"}, osm.Tags{"highway": "primary"}},
+		{osm.Tags{"Highway": "primary", "highway": "secondary"}, osm.Tags{"highway": "secondary"}},
+		{osm.Tags{"landuse": "farm"}, osm.Tags{"landuse": "farmland"}},
+		{osm.Tags{"landuse": "forest"}, osm.Tags{"landuse": "forest"}},
+		{osm.Tags{"addr:street": " Main Street  "}, osm.Tags{"addr:street": "Main Street"}},
+		{osm.Tags{"addr:street": "   "}, osm.Tags{}},
+		{osm.Tags{"fixme": "yes", "tiger:cfcc": "A41", "tiger:county": "X", "name": "foo"}, osm.Tags{"name": "foo"}},
+		{osm.Tags{"oneway": "no"}, osm.Tags{}},
+		{osm.Tags{"oneway": "yes"}, osm.Tags{"oneway": "yes"}},
+	} {
+		tags := tc.tags
+		r.Rewrite(&tags)
+		if !reflect.DeepEqual(tags, tc.expected) {
+			t.Errorf("unexpected tags %v, expected %v", tags, tc.expected)
+		}
+	}
+
+	// rewritten tags pass the filter
+	tags := osm.Tags{"Highway": "primary", "fixme": "yes"}
+	r.Rewrite(&tags)
+	m.WayTagFilter().Filter(&tags)
+	if !reflect.DeepEqual(tags, osm.Tags{"highway": "primary"}) {
+		t.Errorf("unexpected tags %v", tags)
+	}
+
+	// no rules
+	m, err = New([]byte(`tables: {}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.TagRewriter() != nil {
+		t.Error("expected nil TagRewriter")
+	}
+	tags = osm.Tags{"Highway": "primary"}
+	m.TagRewriter().Rewrite(&tags)
+	if tags["Highway"] != "primary" {
+		t.Errorf("unexpected tags %v", tags)
+	}
+}
+
+func TestTagRewriterInvalid(t *testing.T) {
+	for _, conf := range []string{
+		`[{type: rename, key: Highway}]`,
+		`[{type: rename, to: highway}]`,
+		`[{type: remap, key: landuse}]`,
+		`[{type: replace, key: name, regexp: "("}]`,
+		`[{type: drop, key: "tiger:["}]`,
+		`[{type: unknown, key: name}]`,
+	} {
+		if _, err := New([]byte("tag_rewrites: " + conf + "\ntables: {}")); err == nil {
+			t.Errorf("expected error for %s", conf)
+		}
+	}
+}
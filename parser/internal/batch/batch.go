@@ -0,0 +1,211 @@
+/*
+Package batch collects parsed elements and sends them in batches to the
+destination channels of a parser.
+
+It is used by the sequential parsers (OSM XML, o5m) to provide the same
+channels and callbacks as the PBF parser from github.com/omniscale/go-osm.
+*/
+package batch
+
+import (
+	"context"
+
+	osm "github.com/omniscale/go-osm"
+)
+
+// Size defines how many elements are collected before they are sent.
+const Size = 8000
+
+// Sender collects nodes, ways and relations and sends them in batches.
+// Sender is not safe for concurrent use.
+type Sender struct {
+	Coords          chan []osm.Node
+	Nodes           chan []osm.Node
+	Ways            chan []osm.Way
+	Relations       chan []osm.Relation
+	OnFirstWay      func()
+	OnFirstRelation func()
+
+	coords []osm.Node
+	nodes  []osm.Node
+	ways   []osm.Way
+	rels   []osm.Relation
+
+	firstWayDone      bool
+	firstRelationDone bool
+}
+
+// AddNode adds a node. The node is added to Coords and to Nodes if it has
+// tags. All nodes are added to Nodes if Coords is nil.
+func (s *Sender) AddNode(ctx context.Context, nd osm.Node) error {
+	if s.Coords != nil {
+		coord := nd
+		coord.Tags = nil
+		coord.Metadata = nil
+		s.coords = append(s.coords, coord)
+		if hasTags(nd.Tags) && s.Nodes != nil {
+			s.nodes = append(s.nodes, nd)
+		}
+	} else if s.Nodes != nil {
+		s.nodes = append(s.nodes, nd)
+	}
+	if len(s.coords) >= Size || len(s.nodes) >= Size {
+		return s.sendNodes(ctx)
+	}
+	return nil
+}
+
+// AddWay adds a way. Calls OnFirstWay before the first way is added.
+func (s *Sender) AddWay(ctx context.Context, way osm.Way) error {
+	if err := s.firstWay(ctx); err != nil {
+		return err
+	}
+	if s.Ways == nil {
+		return nil
+	}
+	s.ways = append(s.ways, way)
+	if len(s.ways) >= Size {
+		return s.sendWays(ctx)
+	}
+	return nil
+}
+
+// AddRelation adds a relation. Calls OnFirstWay (if not already called) and
+// OnFirstRelation before the first relation is added.
+func (s *Sender) AddRelation(ctx context.Context, rel osm.Relation) error {
+	if err := s.firstRelation(ctx); err != nil {
+		return err
+	}
+	if s.Relations == nil {
+		return nil
+	}
+	s.rels = append(s.rels, rel)
+	if len(s.rels) >= Size {
+		return s.sendRelations(ctx)
+	}
+	return nil
+}
+
+// Finish sends all pending elements. Also calls the OnFirstWay and
+// OnFirstRelation callbacks if there were no ways/relations, so that
+// receivers waiting for them are released.
+func (s *Sender) Finish(ctx context.Context) error {
+	if err := s.firstRelation(ctx); err != nil {
+		return err
+	}
+	if err := s.sendNodes(ctx); err != nil {
+		return err
+	}
+	if err := s.sendWays(ctx); err != nil {
+		return err
+	}
+	return s.sendRelations(ctx)
+}
+
+// Close closes all destination channels.
+func (s *Sender) Close() {
+	if s.Coords != nil {
+		close(s.Coords)
+	}
+	if s.Nodes != nil {
+		close(s.Nodes)
+	}
+	if s.Ways != nil {
+		close(s.Ways)
+	}
+	if s.Relations != nil {
+		close(s.Relations)
+	}
+}
+
+// firstWay sends all pending nodes and calls the OnFirstWay callback, if
+// this was not already done.
+func (s *Sender) firstWay(ctx context.Context) error {
+	if s.firstWayDone {
+		return nil
+	}
+	s.firstWayDone = true
+	if err := s.sendNodes(ctx); err != nil {
+		return err
+	}
+	if s.OnFirstWay != nil {
+		s.OnFirstWay()
+	}
+	return nil
+}
+
+// firstRelation sends all pending nodes and ways and calls the
+// OnFirstRelation callback, if this was not already done.
+func (s *Sender) firstRelation(ctx context.Context) error {
+	if s.firstRelationDone {
+		return nil
+	}
+	s.firstRelationDone = true
+	if err := s.firstWay(ctx); err != nil {
+		return err
+	}
+	if err := s.sendWays(ctx); err != nil {
+		return err
+	}
+	if s.OnFirstRelation != nil {
+		s.OnFirstRelation()
+	}
+	return nil
+}
+
+func (s *Sender) sendNodes(ctx context.Context) error {
+	if len(s.coords) > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case s.Coords <- s.coords:
+		}
+		s.coords = nil
+	}
+	if len(s.nodes) > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case s.Nodes <- s.nodes:
+		}
+		s.nodes = nil
+	}
+	return nil
+}
+
+func (s *Sender) sendWays(ctx context.Context) error {
+	if len(s.ways) > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case s.Ways <- s.ways:
+		}
+		s.ways = nil
+	}
+	return nil
+}
+
+func (s *Sender) sendRelations(ctx context.Context) error {
+	if len(s.rels) > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case s.Relations <- s.rels:
+		}
+		s.rels = nil
+	}
+	return nil
+}
+
+// hasTags returns whether the tags contain more then a created_by tag.
+// Same as the PBF parser, nodes with only a created_by tag are not sent
+// to the Nodes channel.
+func hasTags(tags osm.Tags) bool {
+	if len(tags) == 0 {
+		return false
+	}
+	if _, ok := tags["created_by"]; ok && len(tags) == 1 {
+		return false
+	}
+	return true
+}
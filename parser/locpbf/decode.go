@@ -0,0 +1,847 @@
+package locpbf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+
+	osm "github.com/omniscale/go-osm"
+)
+
+const coordScale = 0.000000001
+
+var supportedFeatures = map[string]bool{
+	"OsmSchema-V0.6":        true,
+	"DenseNodes":            true,
+	"HistoricalInformation": true,
+}
+
+// nextBlock reads the next BlobHeader and Blob. Returns the type of the blob
+// and the encoded Blob message.
+func nextBlock(r io.Reader) (string, []byte, error) {
+	var size int32
+	if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+		if err == io.EOF {
+			return "", nil, err
+		}
+		return "", nil, fmt.Errorf("reading blob header size: %w", err)
+	}
+	if size < 0 || size > 64*1024 {
+		return "", nil, fmt.Errorf("invalid blob header size %d", size)
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return "", nil, fmt.Errorf("reading blob header: %w", err)
+	}
+
+	var typ string
+	var datasize uint64
+	m := message{data: data}
+	for {
+		ok, err := m.next()
+		if err != nil {
+			return "", nil, fmt.Errorf("decoding blob header: %w", err)
+		}
+		if !ok {
+			break
+		}
+		switch m.field {
+		case 1:
+			b, err := m.bytes()
+			if err != nil {
+				return "", nil, fmt.Errorf("decoding blob header: %w", err)
+			}
+			typ = string(b)
+		case 3:
+			if datasize, err = m.uvarint(); err != nil {
+				return "", nil, fmt.Errorf("decoding blob header: %w", err)
+			}
+		default:
+			if err := m.skip(); err != nil {
+				return "", nil, fmt.Errorf("decoding blob header: %w", err)
+			}
+		}
+	}
+
+	if datasize > 32*1024*1024 {
+		return "", nil, fmt.Errorf("invalid blob size %d", datasize)
+	}
+	blob := make([]byte, datasize)
+	if _, err := io.ReadFull(r, blob); err != nil {
+		return "", nil, fmt.Errorf("reading blob: %w", err)
+	}
+	return typ, blob, nil
+}
+
+// decodeBlob returns the uncompressed content of a Blob message.
+func decodeBlob(data []byte) ([]byte, error) {
+	var raw, zlibData []byte
+	var rawSize uint64
+	m := message{data: data}
+	for {
+		ok, err := m.next()
+		if err != nil {
+			return nil, fmt.Errorf("decoding blob: %w", err)
+		}
+		if !ok {
+			break
+		}
+		switch m.field {
+		case 1:
+			raw, err = m.bytes()
+		case 2:
+			rawSize, err = m.uvarint()
+		case 3:
+			zlibData, err = m.bytes()
+		case 4, 5, 6, 7:
+			return nil, fmt.Errorf("unsupported blob compression (field %d)", m.field)
+		default:
+			err = m.skip()
+		}
+		if err != nil {
+			return nil, fmt.Errorf("decoding blob: %w", err)
+		}
+	}
+	if raw != nil {
+		return raw, nil
+	}
+	r, err := zlib.NewReader(bytes.NewReader(zlibData))
+	if err != nil {
+		return nil, fmt.Errorf("start uncompressing zlib data: %w", err)
+	}
+	b := make([]byte, rawSize)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return nil, fmt.Errorf("uncompressing zlib data: %w", err)
+	}
+	return b, nil
+}
+
+func decodeHeaderBlock(data []byte) (*Header, error) {
+	b, err := decodeBlob(data)
+	if err != nil {
+		return nil, err
+	}
+
+	header := &Header{}
+	m := message{data: b}
+	for {
+		ok, err := m.next()
+		if err != nil {
+			return nil, fmt.Errorf("decoding header block: %w", err)
+		}
+		if !ok {
+			break
+		}
+		var v []byte
+		var n uint64
+		switch m.field {
+		case 4:
+			v, err = m.bytes()
+			header.RequiredFeatures = append(header.RequiredFeatures, string(v))
+		case 5:
+			v, err = m.bytes()
+			header.OptionalFeatures = append(header.OptionalFeatures, string(v))
+		case 32:
+			n, err = m.uvarint()
+			if n != 0 {
+				// keep Time zero if timestamp is 0
+				header.Time = time.Unix(int64(n), 0)
+			}
+		case 33:
+			n, err = m.uvarint()
+			header.Sequence = int64(n)
+		default:
+			err = m.skip()
+		}
+		if err != nil {
+			return nil, fmt.Errorf("decoding header block: %w", err)
+		}
+	}
+
+	for _, feature := range header.RequiredFeatures {
+		if !supportedFeatures[feature] {
+			return nil, fmt.Errorf("cannot parse file, feature %v not supported", feature)
+		}
+		if feature == "HistoricalInformation" {
+			header.HistoricalInformation = true
+		}
+	}
+	for _, feature := range header.OptionalFeatures {
+		if feature == "LocationsOnWays" {
+			header.LocationsOnWays = true
+		}
+	}
+	return header, nil
+}
+
+// block contains the decoded fields of a PrimitiveBlock, except for the
+// groups which are decoded individually.
+type block struct {
+	strings         []string
+	groups          [][]byte
+	granularity     int64
+	dateGranularity int64
+	latOffset       int64
+	lonOffset       int64
+}
+
+func decodePrimitiveBlock(data []byte) (*block, error) {
+	b, err := decodeBlob(data)
+	if err != nil {
+		return nil, err
+	}
+
+	blk := &block{granularity: 100, dateGranularity: 1000}
+	m := message{data: b}
+	for {
+		ok, err := m.next()
+		if err != nil {
+			return nil, fmt.Errorf("decoding primitive block: %w", err)
+		}
+		if !ok {
+			break
+		}
+		var v []byte
+		var n uint64
+		switch m.field {
+		case 1:
+			v, err = m.bytes()
+			if err == nil {
+				blk.strings, err = decodeStringTable(v)
+			}
+		case 2:
+			v, err = m.bytes()
+			blk.groups = append(blk.groups, v)
+		case 17:
+			n, err = m.uvarint()
+			blk.granularity = int64(n)
+		case 18:
+			n, err = m.uvarint()
+			blk.dateGranularity = int64(n)
+		case 19:
+			n, err = m.uvarint()
+			blk.latOffset = int64(n)
+		case 20:
+			n, err = m.uvarint()
+			blk.lonOffset = int64(n)
+		default:
+			err = m.skip()
+		}
+		if err != nil {
+			return nil, fmt.Errorf("decoding primitive block: %w", err)
+		}
+	}
+	return blk, nil
+}
+
+func decodeStringTable(data []byte) ([]string, error) {
+	var result []string
+	m := message{data: data}
+	for {
+		ok, err := m.next()
+		if err != nil || !ok {
+			return result, err
+		}
+		if m.field != 1 {
+			if err := m.skip(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		v, err := m.bytes()
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, string(v))
+	}
+}
+
+func (b *block) str(idx uint64) (string, error) {
+	if idx >= uint64(len(b.strings)) {
+		return "", fmt.Errorf("invalid string table index %d", idx)
+	}
+	return b.strings[idx], nil
+}
+
+func (b *block) coord(offset, v int64) float64 {
+	return coordScale * float64(offset+(b.granularity*v))
+}
+
+func (b *block) timestamp(v int64) time.Time {
+	return time.UnixMilli(v * b.dateGranularity)
+}
+
+func (b *block) tags(keys, vals []uint64) (osm.Tags, error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	if len(keys) != len(vals) {
+		return nil, errors.New("number of keys and values differ")
+	}
+	tags := make(osm.Tags, len(keys))
+	for i := range keys {
+		k, err := b.str(keys[i])
+		if err != nil {
+			return nil, err
+		}
+		v, err := b.str(vals[i])
+		if err != nil {
+			return nil, err
+		}
+		tags[k] = v
+	}
+	return tags, nil
+}
+
+// group contains the decoded elements of a PrimitiveGroup.
+type group struct {
+	coords    []osm.Node
+	nodes     []osm.Node
+	ways      []osm.Way
+	relations []osm.Relation
+
+	// allNodes adds all nodes to nodes, not only nodes with tags
+	allNodes  bool
+	includeMD bool
+	// locations fills the Nodes of ways with locations
+	locations bool
+	// snap filters the versions of a full-history file, can be nil
+	snap *snapshot
+}
+
+// needInfo returns whether the Info messages need to be decoded.
+func (g *group) needInfo() bool {
+	return g.includeMD || g.snap != nil
+}
+
+// decodeGroup decodes all elements of the group into g. All nodes are added
+// to coords. Nodes with tags are also added to nodes, or all nodes if
+// allNodes is set.
+func (b *block) decodeGroup(g *group, data []byte) error {
+	m := message{data: data}
+	for {
+		ok, err := m.next()
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return nil
+		}
+		if m.wire != wireBytes {
+			if err := m.skip(); err != nil {
+				return err
+			}
+			continue
+		}
+		v, err := m.bytes()
+		if err != nil {
+			return err
+		}
+		switch m.field {
+		case 1:
+			err = b.decodeNode(g, v)
+		case 2:
+			err = b.decodeDenseNodes(g, v)
+		case 3:
+			err = b.decodeWay(g, v)
+		case 4:
+			err = b.decodeRelation(g, v)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// addNode adds the node, or the previous version that was current at the
+// snapshot time of full-history files.
+func (g *group) addNode(nd osm.Node, visible bool) error {
+	if g.snap != nil {
+		var ok bool
+		var err error
+		if nd, ok, err = g.snap.nodes.add(nd.ID, nd, nd.Metadata, visible); err != nil || !ok {
+			return err
+		}
+	}
+	g.emitNode(nd)
+	return nil
+}
+
+// emitNode adds the node to the coords and if it has tags (other then
+// created_by) to the nodes.
+func (g *group) emitNode(nd osm.Node) {
+	if !g.includeMD {
+		nd.Metadata = nil
+	}
+	coord := nd
+	coord.Tags = nil
+	coord.Metadata = nil
+	g.coords = append(g.coords, coord)
+
+	if _, ok := nd.Tags["created_by"]; g.allNodes || len(nd.Tags) > 1 || (len(nd.Tags) == 1 && !ok) {
+		g.nodes = append(g.nodes, nd)
+	}
+}
+
+func (g *group) addWay(way osm.Way, visible bool) error {
+	if g.snap != nil {
+		if nd, ok := g.snap.nodes.flush(); ok {
+			g.emitNode(nd)
+		}
+		var ok bool
+		var err error
+		if way, ok, err = g.snap.ways.add(way.ID, way, way.Metadata, visible); err != nil || !ok {
+			return err
+		}
+	}
+	g.emitWay(way)
+	return nil
+}
+
+func (g *group) emitWay(way osm.Way) {
+	if !g.includeMD {
+		way.Metadata = nil
+	}
+	g.ways = append(g.ways, way)
+}
+
+func (g *group) addRelation(rel osm.Relation, visible bool) error {
+	if g.snap != nil {
+		if nd, ok := g.snap.nodes.flush(); ok {
+			g.emitNode(nd)
+		}
+		if way, ok := g.snap.ways.flush(); ok {
+			g.emitWay(way)
+		}
+		var ok bool
+		var err error
+		if rel, ok, err = g.snap.relations.add(rel.ID, rel, rel.Metadata, visible); err != nil || !ok {
+			return err
+		}
+	}
+	g.emitRelation(rel)
+	return nil
+}
+
+func (g *group) emitRelation(rel osm.Relation) {
+	if !g.includeMD {
+		rel.Metadata = nil
+	}
+	g.relations = append(g.relations, rel)
+}
+
+// flush adds the pending elements of full-history files.
+func (g *group) flush() {
+	if g.snap == nil {
+		return
+	}
+	if nd, ok := g.snap.nodes.flush(); ok {
+		g.emitNode(nd)
+	}
+	if way, ok := g.snap.ways.flush(); ok {
+		g.emitWay(way)
+	}
+	if rel, ok := g.snap.relations.flush(); ok {
+		g.emitRelation(rel)
+	}
+}
+
+func (b *block) decodeNode(g *group, data []byte) error {
+	nd := osm.Node{}
+	visible := true
+	var keys, vals []uint64
+	var lat, lon int64
+	m := message{data: data}
+	for {
+		ok, err := m.next()
+		if err != nil {
+			return fmt.Errorf("decoding node: %w", err)
+		}
+		if !ok {
+			break
+		}
+		switch m.field {
+		case 1:
+			nd.ID, err = m.svarint()
+		case 2:
+			keys, err = m.uvarints(keys)
+		case 3:
+			vals, err = m.uvarints(vals)
+		case 4:
+			nd.Metadata, visible, err = b.decodeInfo(&m, g.needInfo())
+		case 8:
+			lat, err = m.svarint()
+		case 9:
+			lon, err = m.svarint()
+		default:
+			err = m.skip()
+		}
+		if err != nil {
+			return fmt.Errorf("decoding node: %w", err)
+		}
+	}
+	nd.Lat = b.coord(b.latOffset, lat)
+	nd.Long = b.coord(b.lonOffset, lon)
+	tags, err := b.tags(keys, vals)
+	if err != nil {
+		return fmt.Errorf("decoding tags of node %d: %w", nd.ID, err)
+	}
+	nd.Tags = tags
+	return g.addNode(nd, visible)
+}
+
+func (b *block) decodeDenseNodes(g *group, data []byte) error {
+	var ids, lats, lons []int64
+	var keysVals []uint64
+	var info *denseInfo
+	m := message{data: data}
+	for {
+		ok, err := m.next()
+		if err != nil {
+			return fmt.Errorf("decoding dense nodes: %w", err)
+		}
+		if !ok {
+			break
+		}
+		switch m.field {
+		case 1:
+			ids, err = m.svarints(ids)
+		case 5:
+			if g.needInfo() {
+				var v []byte
+				v, err = m.bytes()
+				if err == nil {
+					info, err = decodeDenseInfo(v)
+				}
+			} else {
+				err = m.skip()
+			}
+		case 8:
+			lats, err = m.svarints(lats)
+		case 9:
+			lons, err = m.svarints(lons)
+		case 10:
+			keysVals, err = m.uvarints(keysVals)
+		default:
+			err = m.skip()
+		}
+		if err != nil {
+			return fmt.Errorf("decoding dense nodes: %w", err)
+		}
+	}
+	if len(lats) != len(ids) || len(lons) != len(ids) {
+		return errors.New("decoding dense nodes: number of ids and coordinates differ")
+	}
+	if info != nil && !info.complete(len(ids)) {
+		return errors.New("decoding dense nodes: incomplete dense info")
+	}
+
+	var id, lat, lon int64
+	var timestamp, changeset, uid, userSid int64
+	kvPos := 0
+	for i := range ids {
+		id += ids[i]
+		lat += lats[i]
+		lon += lons[i]
+		nd := osm.Node{
+			Element: osm.Element{ID: id},
+			Lat:     b.coord(b.latOffset, lat),
+			Long:    b.coord(b.lonOffset, lon),
+		}
+
+		for kvPos < len(keysVals) {
+			k := keysVals[kvPos]
+			kvPos++
+			if k == 0 {
+				break
+			}
+			if kvPos >= len(keysVals) {
+				return errors.New("decoding dense nodes: missing tag value")
+			}
+			v := keysVals[kvPos]
+			kvPos++
+			key, err := b.str(k)
+			if err != nil {
+				return err
+			}
+			val, err := b.str(v)
+			if err != nil {
+				return err
+			}
+			if nd.Tags == nil {
+				nd.Tags = make(osm.Tags)
+			}
+			nd.Tags[key] = val
+		}
+
+		visible := true
+		if info != nil {
+			timestamp += info.timestamps[i]
+			changeset += info.changesets[i]
+			uid += info.uids[i]
+			userSid += info.userSids[i]
+			userName, err := b.str(uint64(userSid))
+			if err != nil {
+				return err
+			}
+			nd.Metadata = &osm.Metadata{
+				Version:   int32(info.versions[i]),
+				Timestamp: b.timestamp(timestamp),
+				Changeset: changeset,
+				UserID:    int32(uid),
+				UserName:  userName,
+			}
+			if len(info.visible) > 0 {
+				visible = info.visible[i] != 0
+			}
+		}
+		if err := g.addNode(nd, visible); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// denseInfo contains the (still delta coded) metadata of dense nodes.
+type denseInfo struct {
+	versions   []uint64
+	timestamps []int64
+	changesets []int64
+	uids       []int64
+	userSids   []int64
+	// visible is only set for full-history files
+	visible []uint64
+}
+
+func (i *denseInfo) complete(n int) bool {
+	return len(i.versions) == n && len(i.timestamps) == n &&
+		len(i.changesets) == n && len(i.uids) == n && len(i.userSids) == n &&
+		(len(i.visible) == 0 || len(i.visible) == n)
+}
+
+func decodeDenseInfo(data []byte) (*denseInfo, error) {
+	info := &denseInfo{}
+	m := message{data: data}
+	for {
+		ok, err := m.next()
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return info, nil
+		}
+		switch m.field {
+		case 1:
+			info.versions, err = m.uvarints(info.versions)
+		case 2:
+			info.timestamps, err = m.svarints(info.timestamps)
+		case 3:
+			info.changesets, err = m.svarints(info.changesets)
+		case 4:
+			info.uids, err = m.svarints(info.uids)
+		case 5:
+			info.userSids, err = m.svarints(info.userSids)
+		case 6:
+			info.visible, err = m.uvarints(info.visible)
+		default:
+			err = m.skip()
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// decodeInfo decodes the Info message of the current field if decode is
+// true. Returns the metadata and the visible flag of full-history files.
+func (b *block) decodeInfo(m *message, decode bool) (*osm.Metadata, bool, error) {
+	if !decode {
+		return nil, true, m.skip()
+	}
+	data, err := m.bytes()
+	if err != nil {
+		return nil, false, err
+	}
+	md := &osm.Metadata{}
+	visible := true
+	im := message{data: data}
+	for {
+		ok, err := im.next()
+		if err != nil {
+			return nil, false, err
+		}
+		if !ok {
+			break
+		}
+		var v uint64
+		switch im.field {
+		case 1:
+			v, err = im.uvarint()
+			md.Version = int32(v)
+		case 2:
+			v, err = im.uvarint()
+			md.Timestamp = b.timestamp(int64(v))
+		case 3:
+			v, err = im.uvarint()
+			md.Changeset = int64(v)
+		case 4:
+			v, err = im.uvarint()
+			md.UserID = int32(v)
+		case 5:
+			v, err = im.uvarint()
+			if err == nil {
+				md.UserName, err = b.str(v)
+			}
+		case 6:
+			v, err = im.uvarint()
+			visible = v != 0
+		default:
+			err = im.skip()
+		}
+		if err != nil {
+			return nil, false, err
+		}
+	}
+	return md, visible, nil
+}
+
+func (b *block) decodeWay(g *group, data []byte) error {
+	way := osm.Way{}
+	visible := true
+	var keys, vals []uint64
+	var refs, lats, lons []int64
+	m := message{data: data}
+	for {
+		ok, err := m.next()
+		if err != nil {
+			return fmt.Errorf("decoding way: %w", err)
+		}
+		if !ok {
+			break
+		}
+		var v uint64
+		switch m.field {
+		case 1:
+			v, err = m.uvarint()
+			way.ID = int64(v)
+		case 2:
+			keys, err = m.uvarints(keys)
+		case 3:
+			vals, err = m.uvarints(vals)
+		case 4:
+			way.Metadata, visible, err = b.decodeInfo(&m, g.needInfo())
+		case 8:
+			refs, err = m.svarints(refs)
+		case 9:
+			lats, err = m.svarints(lats)
+		case 10:
+			lons, err = m.svarints(lons)
+		default:
+			err = m.skip()
+		}
+		if err != nil {
+			return fmt.Errorf("decoding way: %w", err)
+		}
+	}
+
+	tags, err := b.tags(keys, vals)
+	if err != nil {
+		return fmt.Errorf("decoding tags of way %d: %w", way.ID, err)
+	}
+	way.Tags = tags
+
+	way.Refs = make([]int64, len(refs))
+	var ref int64
+	for i := range refs {
+		ref += refs[i]
+		way.Refs[i] = ref
+	}
+
+	if g.locations && len(refs) > 0 && len(lats) == len(refs) && len(lons) == len(refs) {
+		way.Nodes = make([]osm.Node, len(refs))
+		var lat, lon int64
+		for i := range refs {
+			lat += lats[i]
+			lon += lons[i]
+			if lat == undefinedLocation || lon == undefinedLocation {
+				// missing node, FillWay uses the coords cache for this way
+				way.Nodes = nil
+				break
+			}
+			way.Nodes[i].ID = way.Refs[i]
+			way.Nodes[i].Lat = b.coord(b.latOffset, lat)
+			way.Nodes[i].Long = b.coord(b.lonOffset, lon)
+		}
+	}
+	return g.addWay(way, visible)
+}
+
+// undefinedLocation is the raw lat/lon of nodes that were missing when the
+// locations were added to the ways (e.g. by osmium add-locations-to-ways).
+const undefinedLocation = math.MaxInt32
+
+func (b *block) decodeRelation(g *group, data []byte) error {
+	rel := osm.Relation{}
+	visible := true
+	var keys, vals, roles, types []uint64
+	var memids []int64
+	m := message{data: data}
+	for {
+		ok, err := m.next()
+		if err != nil {
+			return fmt.Errorf("decoding relation: %w", err)
+		}
+		if !ok {
+			break
+		}
+		var v uint64
+		switch m.field {
+		case 1:
+			v, err = m.uvarint()
+			rel.ID = int64(v)
+		case 2:
+			keys, err = m.uvarints(keys)
+		case 3:
+			vals, err = m.uvarints(vals)
+		case 4:
+			rel.Metadata, visible, err = b.decodeInfo(&m, g.needInfo())
+		case 8:
+			roles, err = m.uvarints(roles)
+		case 9:
+			memids, err = m.svarints(memids)
+		case 10:
+			types, err = m.uvarints(types)
+		default:
+			err = m.skip()
+		}
+		if err != nil {
+			return fmt.Errorf("decoding relation: %w", err)
+		}
+	}
+
+	tags, err := b.tags(keys, vals)
+	if err != nil {
+		return fmt.Errorf("decoding tags of relation %d: %w", rel.ID, err)
+	}
+	rel.Tags = tags
+
+	if len(roles) != len(memids) || len(types) != len(memids) {
+		return fmt.Errorf("decoding members of relation %d: number of ids, roles and types differ", rel.ID)
+	}
+	rel.Members = make([]osm.Member, len(memids))
+	var id int64
+	for i := range memids {
+		id += memids[i]
+		role, err := b.str(roles[i])
+		if err != nil {
+			return err
+		}
+		rel.Members[i] = osm.Member{ID: id, Type: osm.MemberType(types[i]), Role: role}
+	}
+	return g.addRelation(rel, visible)
+}
@@ -0,0 +1,20 @@
+/*
+Package locpbf provides a parser for OpenStreetMap PBF files with node
+locations on ways and for full-history PBF files.
+
+osmium can write PBF files with the coordinates of all nodes embedded in
+the ways (optional feature LocationsOnWays). The PBF parser from
+github.com/omniscale/go-osm ignores these coordinates. This parser returns
+ways with filled Nodes, so that the geometries can be build without a lookup
+of the coordinates. It can parse other PBF files as well, but the parser from
+go-osm should be used for these.
+
+Full-history files (required feature HistoricalInformation) contain all
+versions of each element, including deleted ones. The parser returns a
+snapshot of these files for the time configured with Config.At.
+
+The parser uses the same configuration and callbacks as the PBF parser from
+go-osm. Files are parsed in parallel and nodes, ways, relations passed back in
+blocks via channels.
+*/
+package locpbf
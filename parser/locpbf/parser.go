@@ -0,0 +1,324 @@
+package locpbf
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+	"sync"
+	"time"
+
+	osm "github.com/omniscale/go-osm"
+)
+
+type Config struct {
+	// IncludeMetadata indicates whether metadata like timestamps, versions and
+	// user names should be parsed.
+	IncludeMetadata bool
+
+	// Nodes specifies the destination for parsed nodes. See also Coords below.
+	// For efficiency, multiple nodes are passed in batches.
+	Nodes chan []osm.Node
+	// Ways specifies the destination for parsed ways. The Nodes of the ways
+	// are filled if the file contains locations on ways.
+	// For efficiency, multiple ways are passed in batches.
+	Ways chan []osm.Way
+	// Relations specifies the destination for parsed relations.
+	// For efficiency, multiple relations are passed in batches.
+	Relations chan []osm.Relation
+
+	// Coords specifies the destination for parsed nodes without any tags. This
+	// can be used for more efficient storage/proceessing of nodes that are
+	// only used as coordinates for ways and relations.
+	// For efficiency, multiple nodes are passed in batches.
+	//
+	// If a Coords channel is specified, then nodes without tags are
+	// not sent to the Nodes channel. However, the Coords channel will receive
+	// all nodes.
+	Coords chan []osm.Node
+
+	// KeepOpen specifies whether the destination channels should be keept open
+	// after Parse(). By default, Nodes, Ways, Relations and Coords channels
+	// are closed after Parse().
+	KeepOpen bool
+
+	// OnFirstWay defines an optional func that gets called when the the first
+	// way is parsed. The callback should block until it is safe to fill the
+	// Ways channel.
+	//
+	// This only works when the PBF file is ordered by type (nodes before ways
+	// before relations).
+	OnFirstWay func()
+
+	// OnFirstRelation defines an optional func that gets called when the
+	// the first relation is parsed. The callback should block until it is
+	// safe to fill the Relations channel.
+	//
+	// This only works when the PBF file is ordered by type (nodes before ways
+	// before relations).
+	OnFirstRelation func()
+
+	// Concurrency specifies how many concurrent parsers are started. Defaults
+	// to runtime.NumCPU if <= 0.
+	Concurrency int
+
+	// SkipLocations disables the parsing of node locations on ways.
+	SkipLocations bool
+
+	// At selects the versions of a full-history file (required feature
+	// HistoricalInformation). Only the version of each element that was
+	// current at this time is passed, elements that were deleted by then or
+	// created later are dropped. The file needs to be sorted by type, ID and
+	// version. Full-history files are always parsed with a Concurrency of 1.
+	At time.Time
+}
+
+type Header struct {
+	Time     time.Time
+	Sequence int64
+
+	RequiredFeatures []string
+	OptionalFeatures []string
+
+	// LocationsOnWays is true if the file contains the coordinates of all
+	// nodes in the ways.
+	LocationsOnWays bool
+	// HistoricalInformation is true for full-history files, which contain
+	// all versions of each element.
+	HistoricalInformation bool
+}
+
+// Parser is a parallel parser for PBF files.
+type Parser struct {
+	conf    Config
+	r       io.Reader
+	header  *Header
+	waySync *barrier
+	relSync *barrier
+	snap    *snapshot
+	err     error
+}
+
+// New creates a new PBF parser for the provided input. Config specifies the
+// destinations for the parsed elements.
+func New(r io.Reader, conf Config) *Parser {
+	p := &Parser{
+		r:    r,
+		conf: conf,
+	}
+
+	if conf.Concurrency <= 0 {
+		p.conf.Concurrency = runtime.NumCPU()
+	}
+	if !conf.At.IsZero() {
+		// versions of an element can span multiple blocks
+		p.conf.Concurrency = 1
+		p.snap = newSnapshot(conf.At)
+	}
+
+	if conf.OnFirstWay != nil {
+		p.waySync = newBarrier(conf.OnFirstWay)
+		p.waySync.add(p.conf.Concurrency)
+	}
+	if conf.OnFirstRelation != nil {
+		p.relSync = newBarrier(conf.OnFirstRelation)
+		p.relSync.add(p.conf.Concurrency)
+	}
+	return p
+}
+
+// Header returns the header information from the PBF. Can be called before or
+// after Parse().
+func (p *Parser) Header() (*Header, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	if p.header == nil {
+		if p.err = p.parseHeader(); p.err != nil {
+			return nil, p.err
+		}
+	}
+	return p.header, nil
+}
+
+// Error returns the first error that occurred during Header/Parse calls.
+func (p *Parser) Error() error {
+	return p.err
+}
+
+func (p *Parser) parseHeader() error {
+	typ, data, err := nextBlock(p.r)
+	if err != nil {
+		return fmt.Errorf("reading header: %w", err)
+	}
+	if typ != "OSMHeader" {
+		return errors.New("invalid block type, expected OSMHeader, got " + typ)
+	}
+	p.header, err = decodeHeaderBlock(data)
+	return err
+}
+
+// Parse parses the PBF file and sends the parsed nodes, ways and relations
+// into the channels provided to the Parsers Config.
+// Context can be used to cancel the parsing.
+func (p *Parser) Parse(ctx context.Context) (err error) {
+	if p.err != nil {
+		return p.err
+	}
+
+	defer func() {
+		if err != nil {
+			p.err = err
+		}
+	}()
+
+	if !p.conf.KeepOpen {
+		defer p.closeChannels()
+	}
+
+	if p.header == nil {
+		if err := p.parseHeader(); err != nil {
+			return err
+		}
+	}
+	if p.header.HistoricalInformation && p.snap == nil {
+		return errors.New("full-history file requires a snapshot time (At)")
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var blockErr error
+	var errOnce sync.Once
+
+	wg := sync.WaitGroup{}
+	blocks := make(chan []byte)
+
+	for i := 0; i < p.conf.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			for block := range blocks {
+				if err := p.parseBlock(ctx, block); err != nil {
+					errOnce.Do(func() {
+						blockErr = err
+						cancel()
+					})
+				}
+			}
+			if p.snap != nil && ctx.Err() == nil {
+				// single worker for full-history files
+				g := p.newGroup()
+				g.flush()
+				p.sendGroup(g)
+			}
+			if p.waySync != nil {
+				p.waySync.doneWait()
+			}
+			if p.relSync != nil {
+				p.relSync.doneWait()
+			}
+			wg.Done()
+		}()
+	}
+
+	var readErr error
+read:
+	for {
+		typ, data, err := nextBlock(p.r)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			readErr = fmt.Errorf("parsing next block: %w", err)
+			break
+		}
+		if typ != "OSMData" {
+			readErr = errors.New("next block not of type OSMData but " + typ)
+			break
+		}
+		select {
+		case <-ctx.Done():
+			break read
+		case blocks <- data:
+		}
+	}
+
+	close(blocks)
+	wg.Wait()
+
+	if readErr != nil {
+		return readErr
+	}
+	if blockErr != nil {
+		return blockErr
+	}
+	return ctx.Err()
+}
+
+func (p *Parser) closeChannels() {
+	if p.conf.Coords != nil {
+		close(p.conf.Coords)
+	}
+	if p.conf.Nodes != nil {
+		close(p.conf.Nodes)
+	}
+	if p.conf.Ways != nil {
+		close(p.conf.Ways)
+	}
+	if p.conf.Relations != nil {
+		close(p.conf.Relations)
+	}
+}
+
+func (p *Parser) newGroup() *group {
+	return &group{
+		allNodes:  p.conf.Coords == nil,
+		includeMD: p.conf.IncludeMetadata,
+		locations: !p.conf.SkipLocations,
+		snap:      p.snap,
+	}
+}
+
+func (p *Parser) parseBlock(ctx context.Context, blob []byte) error {
+	block, err := decodePrimitiveBlock(blob)
+	if err != nil {
+		return err
+	}
+
+	for _, data := range block.groups {
+		if ctx.Err() != nil {
+			return nil
+		}
+		g := p.newGroup()
+		if err := block.decodeGroup(g, data); err != nil {
+			return fmt.Errorf("decoding primitive group: %w", err)
+		}
+		p.sendGroup(g)
+	}
+	return nil
+}
+
+func (p *Parser) sendGroup(g *group) {
+	if len(g.coords) > 0 && p.conf.Coords != nil {
+		p.conf.Coords <- g.coords
+	}
+	if len(g.nodes) > 0 && p.conf.Nodes != nil {
+		p.conf.Nodes <- g.nodes
+	}
+	if len(g.ways) > 0 && p.conf.Ways != nil {
+		if p.waySync != nil {
+			p.waySync.doneWait()
+		}
+		p.conf.Ways <- g.ways
+	}
+	if len(g.relations) > 0 && p.conf.Relations != nil {
+		if p.waySync != nil {
+			p.waySync.doneWait()
+		}
+		if p.relSync != nil {
+			p.relSync.doneWait()
+		}
+		p.conf.Relations <- g.relations
+	}
+}
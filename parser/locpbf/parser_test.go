@@ -0,0 +1,468 @@
+package locpbf
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"math"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	osm "github.com/omniscale/go-osm"
+	"github.com/omniscale/go-osm/parser/pbf"
+)
+
+const monaco = "../../vendor/github.com/omniscale/go-osm/parser/pbf/monaco-20150428.osm.pbf"
+
+type elements struct {
+	coords    map[int64]osm.Node
+	nodes     map[int64]osm.Node
+	ways      map[int64]osm.Way
+	relations map[int64]osm.Relation
+}
+
+func collect(coords, nodes chan []osm.Node, ways chan []osm.Way, relations chan []osm.Relation) (*elements, chan struct{}) {
+	e := &elements{
+		coords:    map[int64]osm.Node{},
+		nodes:     map[int64]osm.Node{},
+		ways:      map[int64]osm.Way{},
+		relations: map[int64]osm.Relation{},
+	}
+	done := make(chan struct{})
+	go func() {
+		for coords != nil || nodes != nil || ways != nil || relations != nil {
+			select {
+			case c, ok := <-coords:
+				if !ok {
+					coords = nil
+				}
+				for _, nd := range c {
+					e.coords[nd.ID] = nd
+				}
+			case n, ok := <-nodes:
+				if !ok {
+					nodes = nil
+				}
+				for _, nd := range n {
+					e.nodes[nd.ID] = nd
+				}
+			case w, ok := <-ways:
+				if !ok {
+					ways = nil
+				}
+				for _, way := range w {
+					e.ways[way.ID] = way
+				}
+			case r, ok := <-relations:
+				if !ok {
+					relations = nil
+				}
+				for _, rel := range r {
+					e.relations[rel.ID] = rel
+				}
+			}
+		}
+		close(done)
+	}()
+	return e, done
+}
+
+func TestParseSameAsGoOSM(t *testing.T) {
+	parse := func(newParser func(f *os.File, coords, nodes chan []osm.Node, ways chan []osm.Way, relations chan []osm.Relation) func(context.Context) error) *elements {
+		f, err := os.Open(monaco)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		coords := make(chan []osm.Node)
+		nodes := make(chan []osm.Node)
+		ways := make(chan []osm.Way)
+		relations := make(chan []osm.Relation)
+		e, done := collect(coords, nodes, ways, relations)
+		if err := newParser(f, coords, nodes, ways, relations)(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		<-done
+		return e
+	}
+
+	expected := parse(func(f *os.File, coords, nodes chan []osm.Node, ways chan []osm.Way, relations chan []osm.Relation) func(context.Context) error {
+		return pbf.New(f, pbf.Config{Coords: coords, Nodes: nodes, Ways: ways, Relations: relations, IncludeMetadata: true}).Parse
+	})
+	var header *Header
+	actual := parse(func(f *os.File, coords, nodes chan []osm.Node, ways chan []osm.Way, relations chan []osm.Relation) func(context.Context) error {
+		p := New(f, Config{Coords: coords, Nodes: nodes, Ways: ways, Relations: relations, IncludeMetadata: true})
+		var err error
+		header, err = p.Header()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return p.Parse
+	})
+
+	if header.Time.Unix() != 1430166062 || header.LocationsOnWays {
+		t.Errorf("unexpected header %#v", header)
+	}
+
+	if len(actual.coords) != len(expected.coords) || len(actual.coords) == 0 {
+		t.Errorf("unexpected number of coords %d != %d", len(actual.coords), len(expected.coords))
+	}
+	for id, nd := range expected.coords {
+		if !reflect.DeepEqual(actual.coords[id], nd) {
+			t.Fatalf("coord %d differs: %#v != %#v", id, actual.coords[id], nd)
+		}
+	}
+	if len(actual.nodes) != len(expected.nodes) {
+		t.Errorf("unexpected number of nodes %d != %d", len(actual.nodes), len(expected.nodes))
+	}
+	for id, nd := range expected.nodes {
+		if !reflect.DeepEqual(actual.nodes[id], nd) {
+			t.Fatalf("node %d differs: %#v != %#v", id, actual.nodes[id], nd)
+		}
+	}
+	if len(actual.ways) != len(expected.ways) || len(actual.ways) == 0 {
+		t.Errorf("unexpected number of ways %d != %d", len(actual.ways), len(expected.ways))
+	}
+	for id, w := range expected.ways {
+		if !reflect.DeepEqual(actual.ways[id], w) {
+			t.Fatalf("way %d differs: %#v != %#v", id, actual.ways[id], w)
+		}
+	}
+	if len(actual.relations) != len(expected.relations) || len(actual.relations) == 0 {
+		t.Errorf("unexpected number of relations %d != %d", len(actual.relations), len(expected.relations))
+	}
+	for id, r := range expected.relations {
+		if !reflect.DeepEqual(actual.relations[id], r) {
+			t.Fatalf("relation %d differs: %#v != %#v", id, actual.relations[id], r)
+		}
+	}
+}
+
+// encoder writes protobuf messages for testing.
+type encoder struct {
+	buf []byte
+}
+
+func (e *encoder) key(field, wire int) {
+	e.buf = binary.AppendUvarint(e.buf, uint64(field<<3|wire))
+}
+
+func (e *encoder) uvarint(field int, v uint64) {
+	e.key(field, wireVarint)
+	e.buf = binary.AppendUvarint(e.buf, v)
+}
+
+func (e *encoder) bytes(field int, b []byte) {
+	e.key(field, wireBytes)
+	e.buf = binary.AppendUvarint(e.buf, uint64(len(b)))
+	e.buf = append(e.buf, b...)
+}
+
+func (e *encoder) packedUvarints(field int, values ...uint64) {
+	var b []byte
+	for _, v := range values {
+		b = binary.AppendUvarint(b, v)
+	}
+	e.bytes(field, b)
+}
+
+func (e *encoder) packedSvarints(field int, values ...int64) {
+	var b []byte
+	for _, v := range values {
+		b = binary.AppendUvarint(b, uint64(v<<1)^uint64(v>>63))
+	}
+	e.bytes(field, b)
+}
+
+func writeBlob(buf *bytes.Buffer, typ string, content []byte) {
+	blob := encoder{}
+	blob.bytes(1, content)
+	blob.uvarint(2, uint64(len(content)))
+
+	header := encoder{}
+	header.bytes(1, []byte(typ))
+	header.uvarint(3, uint64(len(blob.buf)))
+
+	binary.Write(buf, binary.BigEndian, int32(len(header.buf)))
+	buf.Write(header.buf)
+	buf.Write(blob.buf)
+}
+
+// locationsOnWaysPBF returns a PBF with two nodes, a way with locations, a
+// way with an undefined location and a relation.
+func locationsOnWaysPBF() []byte {
+	buf := &bytes.Buffer{}
+
+	header := encoder{}
+	header.bytes(4, []byte("OsmSchema-V0.6"))
+	header.bytes(4, []byte("DenseNodes"))
+	header.bytes(5, []byte("LocationsOnWays"))
+	header.uvarint(32, 1577934245)
+	writeBlob(buf, "OSMHeader", header.buf)
+
+	strings := encoder{}
+	for _, s := range []string{"", "highway", "residential", "type", "route", "stop"} {
+		strings.bytes(1, []byte(s))
+	}
+
+	// only the tagged node is included in the PBF
+	dense := encoder{}
+	dense.packedSvarints(1, 2)
+	dense.packedSvarints(8, 515000000)
+	dense.packedSvarints(9, 75000000)
+	dense.packedUvarints(10, 1, 2, 0)
+	nodeGroup := encoder{}
+	nodeGroup.bytes(2, dense.buf)
+
+	way := encoder{}
+	way.uvarint(1, 10)
+	way.packedUvarints(2, 1)
+	way.packedUvarints(3, 2)
+	way.packedSvarints(8, 1, 1)
+	way.packedSvarints(9, 510000000, 5000000)
+	way.packedSvarints(10, 70000000, 5000000)
+	// node 3 was missing when the locations were added
+	missing := encoder{}
+	missing.uvarint(1, 11)
+	missing.packedSvarints(8, 1, 2)
+	missing.packedSvarints(9, 510000000, math.MaxInt32-510000000)
+	missing.packedSvarints(10, 70000000, math.MaxInt32-70000000)
+	wayGroup := encoder{}
+	wayGroup.bytes(3, way.buf)
+	wayGroup.bytes(3, missing.buf)
+
+	rel := encoder{}
+	rel.uvarint(1, 20)
+	rel.packedUvarints(2, 3)
+	rel.packedUvarints(3, 4)
+	rel.packedUvarints(8, 5, 0)
+	rel.packedSvarints(9, 2, 8)
+	rel.packedUvarints(10, 0, 1)
+	relGroup := encoder{}
+	relGroup.bytes(4, rel.buf)
+
+	block := encoder{}
+	block.bytes(1, strings.buf)
+	block.bytes(2, nodeGroup.buf)
+	block.bytes(2, wayGroup.buf)
+	block.bytes(2, relGroup.buf)
+	writeBlob(buf, "OSMData", block.buf)
+
+	return buf.Bytes()
+}
+
+func TestParseLocationsOnWays(t *testing.T) {
+	coords := make(chan []osm.Node)
+	nodes := make(chan []osm.Node)
+	ways := make(chan []osm.Way)
+	relations := make(chan []osm.Relation)
+	e, done := collect(coords, nodes, ways, relations)
+
+	p := New(bytes.NewReader(locationsOnWaysPBF()), Config{Coords: coords, Nodes: nodes, Ways: ways, Relations: relations})
+	header, err := p.Header()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !header.LocationsOnWays || header.Time.Unix() != 1577934245 {
+		t.Errorf("unexpected header %#v", header)
+	}
+	if err := p.Parse(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	<-done
+
+	if len(e.coords) != 1 || len(e.nodes) != 1 || e.nodes[2].Tags["highway"] != "residential" {
+		t.Errorf("unexpected nodes %v %v", e.coords, e.nodes)
+	}
+	if nd := e.coords[2]; !coordEqual(nd, 51.5, 7.5) {
+		t.Errorf("unexpected coord %#v", nd)
+	}
+
+	way, ok := e.ways[10]
+	if !ok {
+		t.Fatal("way missing")
+	}
+	if !reflect.DeepEqual(way.Refs, []int64{1, 2}) {
+		t.Errorf("unexpected refs %v", way.Refs)
+	}
+	if len(way.Nodes) != 2 ||
+		way.Nodes[0].ID != 1 || !coordEqual(way.Nodes[0], 51, 7) ||
+		way.Nodes[1].ID != 2 || !coordEqual(way.Nodes[1], 51.5, 7.5) {
+		t.Errorf("unexpected way nodes %v", way.Nodes)
+	}
+	if way := e.ways[11]; !reflect.DeepEqual(way.Refs, []int64{1, 3}) || way.Nodes != nil {
+		t.Errorf("unexpected way with undefined location %#v", way)
+	}
+
+	expectedMembers := []osm.Member{
+		{ID: 2, Type: osm.NodeMember, Role: "stop"},
+		{ID: 10, Type: osm.WayMember, Role: ""},
+	}
+	if rel := e.relations[20]; !reflect.DeepEqual(rel.Members, expectedMembers) || rel.Tags["type"] != "route" {
+		t.Errorf("unexpected relation %#v", rel)
+	}
+}
+
+func coordEqual(nd osm.Node, lat, long float64) bool {
+	return math.Abs(nd.Lat-lat) < 1e-9 && math.Abs(nd.Long-long) < 1e-9
+}
+
+// historyPBF returns a full-history PBF. The versions of way 10 are split
+// across two blocks.
+func historyPBF() []byte {
+	buf := &bytes.Buffer{}
+
+	header := encoder{}
+	header.bytes(4, []byte("OsmSchema-V0.6"))
+	header.bytes(4, []byte("DenseNodes"))
+	header.bytes(4, []byte("HistoricalInformation"))
+	writeBlob(buf, "OSMHeader", header.buf)
+
+	strings := encoder{}
+	for _, s := range []string{"", "highway", "residential", "primary", "type", "route", "user"} {
+		strings.bytes(1, []byte(s))
+	}
+
+	info := encoder{}
+	info.packedUvarints(1, 1, 2, 1, 2, 1)
+	info.packedSvarints(2, 1500000000, 100000000, -100000000, 50000000, 50000000)
+	info.packedSvarints(3, 1, 1, 1, 1, 1)
+	info.packedSvarints(4, 1, 0, 0, 0, 0)
+	info.packedSvarints(5, 6, 0, 0, 0, 0)
+	info.packedUvarints(6, 1, 1, 1, 0, 1)
+	dense := encoder{}
+	// node 1 v1+v2, node 2 v1+v2 (deleted), node 3 v1
+	dense.packedSvarints(1, 1, 0, 1, 0, 1)
+	dense.bytes(5, info.buf)
+	dense.packedSvarints(8, 510000000, 10000000, 0, 0, 0)
+	dense.packedSvarints(9, 70000000, 10000000, 0, 0, 0)
+	nodeGroup := encoder{}
+	nodeGroup.bytes(2, dense.buf)
+
+	way := func(version, timestamp uint64, visible bool, highway uint64) []byte {
+		wayInfo := encoder{}
+		wayInfo.uvarint(1, version)
+		wayInfo.uvarint(2, timestamp)
+		if !visible {
+			wayInfo.uvarint(6, 0)
+		}
+		w := encoder{}
+		w.uvarint(1, 10)
+		w.packedUvarints(2, 1)
+		w.packedUvarints(3, highway)
+		w.bytes(4, wayInfo.buf)
+		w.packedSvarints(8, 1, 1)
+		return w.buf
+	}
+	wayGroup := encoder{}
+	wayGroup.bytes(3, way(1, 1500000000, true, 2))
+
+	block := encoder{}
+	block.bytes(1, strings.buf)
+	block.bytes(2, nodeGroup.buf)
+	block.bytes(2, wayGroup.buf)
+	writeBlob(buf, "OSMData", block.buf)
+
+	wayGroup = encoder{}
+	wayGroup.bytes(3, way(2, 1550000000, true, 3))
+	wayGroup.bytes(3, way(3, 1650000000, false, 3))
+
+	relInfo := encoder{}
+	relInfo.uvarint(1, 1)
+	relInfo.uvarint(2, 1500000000)
+	rel := encoder{}
+	rel.uvarint(1, 20)
+	rel.packedUvarints(2, 4)
+	rel.packedUvarints(3, 5)
+	rel.bytes(4, relInfo.buf)
+	rel.packedUvarints(8, 0)
+	rel.packedSvarints(9, 10)
+	rel.packedUvarints(10, 1)
+	relGroup := encoder{}
+	relGroup.bytes(4, rel.buf)
+
+	block = encoder{}
+	block.bytes(1, strings.buf)
+	block.bytes(2, wayGroup.buf)
+	block.bytes(2, relGroup.buf)
+	writeBlob(buf, "OSMData", block.buf)
+
+	return buf.Bytes()
+}
+
+func TestParseHistory(t *testing.T) {
+	parse := func(at time.Time, includeMD bool) (*elements, error) {
+		coords := make(chan []osm.Node)
+		nodes := make(chan []osm.Node)
+		ways := make(chan []osm.Way)
+		relations := make(chan []osm.Relation)
+		e, done := collect(coords, nodes, ways, relations)
+
+		p := New(bytes.NewReader(historyPBF()), Config{
+			Coords: coords, Nodes: nodes, Ways: ways, Relations: relations,
+			At: at, IncludeMetadata: includeMD,
+		})
+		header, err := p.Header()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !header.HistoricalInformation {
+			t.Errorf("unexpected header %#v", header)
+		}
+		err = p.Parse(context.Background())
+		<-done
+		return e, err
+	}
+
+	if _, err := parse(time.Time{}, false); err == nil {
+		t.Error("expected error for full-history file without At")
+	}
+
+	for _, tc := range []struct {
+		at     time.Time
+		coords []int64
+		way    string
+		rel    bool
+	}{
+		{at: time.Unix(1400000000, 0)},
+		{at: time.Unix(1500000000, 0), coords: []int64{1, 2}, way: "residential", rel: true},
+		{at: time.Unix(1577836800, 0), coords: []int64{1}, way: "primary", rel: true},
+		{at: time.Unix(1700000000, 0), coords: []int64{1, 3}, rel: true},
+	} {
+		t.Run(tc.at.UTC().Format(time.RFC3339), func(t *testing.T) {
+			e, err := parse(tc.at, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(e.coords) != len(tc.coords) {
+				t.Errorf("unexpected coords %v", e.coords)
+			}
+			for _, id := range tc.coords {
+				if nd, ok := e.coords[id]; !ok || nd.Metadata != nil {
+					t.Errorf("unexpected coord %d %#v", id, nd)
+				}
+			}
+			if tc.way == "" && len(e.ways) != 0 {
+				t.Errorf("unexpected ways %v", e.ways)
+			} else if tc.way != "" && (len(e.ways) != 1 || e.ways[10].Tags["highway"] != tc.way) {
+				t.Errorf("unexpected ways %v", e.ways)
+			}
+			if _, ok := e.relations[20]; ok != tc.rel || len(e.relations) > 1 {
+				t.Errorf("unexpected relations %v", e.relations)
+			}
+		})
+	}
+
+	e, err := parse(time.Unix(1600000000, 0), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nd := e.coords[1]; nd.Long != 8 {
+		t.Errorf("unexpected coord of node 1 %#v", nd)
+	}
+	if w := e.ways[10]; w.Metadata == nil || w.Metadata.Version != 2 {
+		t.Errorf("unexpected way %#v", w)
+	}
+}
@@ -0,0 +1,70 @@
+package locpbf
+
+import (
+	"fmt"
+	"time"
+
+	osm "github.com/omniscale/go-osm"
+)
+
+// snapshot selects the versions of a full-history file that were current
+// at a specific time.
+type snapshot struct {
+	nodes     versions[osm.Node]
+	ways      versions[osm.Way]
+	relations versions[osm.Relation]
+}
+
+func newSnapshot(at time.Time) *snapshot {
+	return &snapshot{
+		nodes:     versions[osm.Node]{at: at},
+		ways:      versions[osm.Way]{at: at},
+		relations: versions[osm.Relation]{at: at},
+	}
+}
+
+// versions tracks all versions of the current element ID. The versions need
+// to be added in ascending order.
+type versions[T any] struct {
+	at time.Time
+
+	id      int64
+	started bool
+	// latest version that is not newer then at
+	elem    T
+	found   bool
+	visible bool
+}
+
+// add adds the next version of an element. Returns the element of the
+// previous ID if it was visible at the snapshot time.
+func (v *versions[T]) add(id int64, elem T, md *osm.Metadata, visible bool) (T, bool, error) {
+	var prev T
+	var ok bool
+	if v.started && id != v.id {
+		if id < v.id {
+			return prev, false, fmt.Errorf("full-history file not sorted by ID (%d after %d)", id, v.id)
+		}
+		prev, ok = v.flush()
+	}
+	v.id = id
+	v.started = true
+
+	// elements without metadata are always current
+	if md == nil || !md.Timestamp.After(v.at) {
+		v.elem = elem
+		v.found = true
+		v.visible = visible
+	}
+	return prev, ok, nil
+}
+
+// flush returns the pending element if it was visible at the snapshot time.
+func (v *versions[T]) flush() (T, bool) {
+	elem, ok := v.elem, v.found && v.visible
+	var zero T
+	v.elem = zero
+	v.found = false
+	v.started = false
+	return elem, ok
+}
@@ -0,0 +1,143 @@
+package locpbf
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// protobuf wire types
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+var errInvalidVarint = errors.New("invalid varint")
+
+// message iterates over the fields of an encoded protobuf message.
+type message struct {
+	data []byte
+	pos  int
+
+	// field number and wire type of the current field
+	field int
+	wire  int
+}
+
+// next advances to the next field. Returns false at the end of the message.
+func (m *message) next() (bool, error) {
+	if m.pos >= len(m.data) {
+		return false, nil
+	}
+	key, err := m.uvarint()
+	if err != nil {
+		return false, err
+	}
+	m.field = int(key >> 3)
+	m.wire = int(key & 0x7)
+	return true, nil
+}
+
+func (m *message) uvarint() (uint64, error) {
+	v, n := binary.Uvarint(m.data[m.pos:])
+	if n <= 0 {
+		return 0, errInvalidVarint
+	}
+	m.pos += n
+	return v, nil
+}
+
+func (m *message) svarint() (int64, error) {
+	v, err := m.uvarint()
+	if err != nil {
+		return 0, err
+	}
+	return unzigzag(v), nil
+}
+
+func (m *message) bytes() ([]byte, error) {
+	l, err := m.uvarint()
+	if err != nil {
+		return nil, err
+	}
+	if l > uint64(len(m.data)-m.pos) {
+		return nil, io.ErrUnexpectedEOF
+	}
+	b := m.data[m.pos : m.pos+int(l)]
+	m.pos += int(l)
+	return b, nil
+}
+
+// skip skips the value of the current field.
+func (m *message) skip() error {
+	var n int
+	switch m.wire {
+	case wireVarint:
+		_, err := m.uvarint()
+		return err
+	case wireBytes:
+		_, err := m.bytes()
+		return err
+	case wireFixed64:
+		n = 8
+	case wireFixed32:
+		n = 4
+	default:
+		return fmt.Errorf("unsupported wire type %d", m.wire)
+	}
+	if n > len(m.data)-m.pos {
+		return io.ErrUnexpectedEOF
+	}
+	m.pos += n
+	return nil
+}
+
+// uvarints appends the values of a repeated varint field. Supports packed and
+// unpacked fields.
+func (m *message) uvarints(dst []uint64) ([]uint64, error) {
+	if m.wire == wireVarint {
+		v, err := m.uvarint()
+		return append(dst, v), err
+	}
+	b, err := m.bytes()
+	if err != nil {
+		return nil, err
+	}
+	for len(b) > 0 {
+		v, n := binary.Uvarint(b)
+		if n <= 0 {
+			return nil, errInvalidVarint
+		}
+		dst = append(dst, v)
+		b = b[n:]
+	}
+	return dst, nil
+}
+
+// svarints appends the values of a repeated zigzag encoded varint field.
+func (m *message) svarints(dst []int64) ([]int64, error) {
+	if m.wire == wireVarint {
+		v, err := m.svarint()
+		return append(dst, v), err
+	}
+	b, err := m.bytes()
+	if err != nil {
+		return nil, err
+	}
+	for len(b) > 0 {
+		v, n := binary.Uvarint(b)
+		if n <= 0 {
+			return nil, errInvalidVarint
+		}
+		dst = append(dst, unzigzag(v))
+		b = b[n:]
+	}
+	return dst, nil
+}
+
+func unzigzag(v uint64) int64 {
+	return int64(v>>1) ^ -int64(v&1)
+}
@@ -0,0 +1,14 @@
+/*
+Package merge combines the output of multiple parsers into a single stream of
+nodes, ways and relations.
+
+This is used to read multiple overlapping extracts in one pass. Elements that
+are contained in more then one input are only passed on once. The element
+with the highest version is used if the versions differ.
+
+All inputs need to be sorted by type and ID (nodes before ways before
+relations), which is the case for extracts from Geofabrik, osmium and
+osmconvert. The parsers of the inputs need to send the elements in order
+(e.g. PBF parser with a Concurrency of 1).
+*/
+package merge
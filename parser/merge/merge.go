@@ -0,0 +1,367 @@
+package merge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	osm "github.com/omniscale/go-osm"
+	"github.com/omniscale/imposm3/parser/internal/batch"
+)
+
+type Config struct {
+	// IncludeMetadata indicates whether metadata like timestamps, versions and
+	// user names should be passed on. The versions are always used for
+	// merging, so all inputs should be parsed with metadata.
+	IncludeMetadata bool
+
+	// Nodes specifies the destination for merged nodes. See also Coords below.
+	// For efficiency, multiple nodes are passed in batches.
+	Nodes chan []osm.Node
+	// Ways specifies the destination for merged ways.
+	// For efficiency, multiple ways are passed in batches.
+	Ways chan []osm.Way
+	// Relations specifies the destination for merged relations.
+	// For efficiency, multiple relations are passed in batches.
+	Relations chan []osm.Relation
+
+	// Coords specifies the destination for merged nodes without any tags.
+	// If a Coords channel is specified, then nodes without tags are
+	// not sent to the Nodes channel. However, the Coords channel will receive
+	// all nodes.
+	Coords chan []osm.Node
+
+	// KeepOpen specifies whether the destination channels should be keept open
+	// after Parse(). By default, Nodes, Ways, Relations and Coords channels
+	// are closed after Parse().
+	KeepOpen bool
+
+	// OnFirstWay defines an optional func that gets called when the the first
+	// way is merged. The callback should block until it is safe to fill the
+	// Ways channel. All nodes are sent before the callback is called.
+	OnFirstWay func()
+
+	// OnFirstRelation defines an optional func that gets called when the
+	// the first relation is merged. The callback should block until it is
+	// safe to fill the Relations channel. All ways are sent before the
+	// callback is called.
+	OnFirstRelation func()
+}
+
+// Source is the parser of a single input.
+type Source interface {
+	Parse(ctx context.Context) error
+}
+
+// Input contains the destinations for the parser of a single input. The
+// parser needs to send all nodes to Nodes (i.e. without a separate Coords
+// channel) and it needs to use OnFirstWay and OnFirstRelation as callbacks.
+type Input struct {
+	Nodes           chan []osm.Node
+	Ways            chan []osm.Way
+	Relations       chan []osm.Relation
+	OnFirstWay      func()
+	OnFirstRelation func()
+
+	source Source
+	// done is closed when the source finished parsing
+	done chan struct{}
+}
+
+// SetSource sets the parser for this input.
+func (in *Input) SetSource(s Source) {
+	in.source = s
+}
+
+// Parser merges the elements of multiple inputs.
+type Parser struct {
+	conf   Config
+	inputs []*Input
+	batch  *batch.Sender
+	err    error
+}
+
+// New creates a new merge parser. Config specifies the destinations for the
+// merged elements. Inputs are added with NewInput.
+func New(conf Config) *Parser {
+	return &Parser{
+		conf: conf,
+		batch: &batch.Sender{
+			Coords:          conf.Coords,
+			Nodes:           conf.Nodes,
+			Ways:            conf.Ways,
+			Relations:       conf.Relations,
+			OnFirstWay:      conf.OnFirstWay,
+			OnFirstRelation: conf.OnFirstRelation,
+		},
+	}
+}
+
+// NewInput adds a new input and returns the destinations for its parser.
+// The parser needs to be set with SetSource before Parse is called. Inputs
+// added first take precedence if the same element with the same version is
+// contained in multiple inputs.
+func (p *Parser) NewInput() *Input {
+	in := &Input{
+		Nodes:     make(chan []osm.Node, 4),
+		Ways:      make(chan []osm.Way, 4),
+		Relations: make(chan []osm.Relation, 4),
+		done:      make(chan struct{}),
+	}
+	// nil batches mark the end of all nodes/ways of this input
+	in.OnFirstWay = func() { in.Nodes <- nil }
+	in.OnFirstRelation = func() { in.Ways <- nil }
+	p.inputs = append(p.inputs, in)
+	return in
+}
+
+// Error returns the first error that occurred during Parse calls.
+func (p *Parser) Error() error {
+	return p.err
+}
+
+// Parse parses all inputs and sends the merged nodes, ways and relations
+// into the channels provided to the Parsers Config.
+// Context can be used to cancel the parsing.
+func (p *Parser) Parse(ctx context.Context) (err error) {
+	if p.err != nil {
+		return p.err
+	}
+
+	defer func() {
+		if err != nil {
+			p.err = err
+		}
+	}()
+
+	if !p.conf.KeepOpen {
+		defer p.batch.Close()
+	}
+
+	for i, in := range p.inputs {
+		if in.source == nil {
+			return fmt.Errorf("missing source for input %d", i+1)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	srcErrs := make([]error, len(p.inputs))
+	for i, in := range p.inputs {
+		go func(i int, in *Input) {
+			srcErrs[i] = in.source.Parse(ctx)
+			close(in.done)
+		}(i, in)
+	}
+
+	nodes := make([]*stream[osm.Node], len(p.inputs))
+	ways := make([]*stream[osm.Way], len(p.inputs))
+	rels := make([]*stream[osm.Relation], len(p.inputs))
+	for i, in := range p.inputs {
+		nodes[i] = &stream[osm.Node]{ch: in.Nodes, done: in.done, elem: func(n *osm.Node) *osm.Element { return &n.Element }}
+		ways[i] = &stream[osm.Way]{ch: in.Ways, done: in.done, elem: func(w *osm.Way) *osm.Element { return &w.Element }}
+		rels[i] = &stream[osm.Relation]{ch: in.Relations, done: in.done, elem: func(r *osm.Relation) *osm.Element { return &r.Element }}
+	}
+
+	// Elements that are received after the end of their type are not
+	// merged. The remaining elements are drained in the background, so that
+	// the sources are not blocked.
+	var drained sync.WaitGroup
+	var mu sync.Mutex
+	var unsorted int
+	drainAll := func(drain func() int) {
+		drained.Add(1)
+		go func() {
+			n := drain()
+			mu.Lock()
+			unsorted += n
+			mu.Unlock()
+			drained.Done()
+		}()
+	}
+
+	mergeErr := mergeStreams(nodes, func(nd osm.Node) error {
+		if !p.conf.IncludeMetadata {
+			nd.Metadata = nil
+		}
+		return p.batch.AddNode(ctx, nd)
+	})
+	for _, s := range nodes {
+		drainAll(s.drain)
+	}
+	if mergeErr == nil {
+		mergeErr = mergeStreams(ways, func(w osm.Way) error {
+			if !p.conf.IncludeMetadata {
+				w.Metadata = nil
+			}
+			return p.batch.AddWay(ctx, w)
+		})
+	}
+	for _, s := range ways {
+		drainAll(s.drain)
+	}
+	if mergeErr == nil {
+		mergeErr = mergeStreams(rels, func(r osm.Relation) error {
+			if !p.conf.IncludeMetadata {
+				r.Metadata = nil
+			}
+			return p.batch.AddRelation(ctx, r)
+		})
+	}
+	for _, s := range rels {
+		drainAll(s.drain)
+	}
+	if mergeErr == nil {
+		mergeErr = p.batch.Finish(ctx)
+	}
+
+	if mergeErr != nil {
+		cancel()
+	}
+	drained.Wait()
+	for _, in := range p.inputs {
+		<-in.done
+	}
+
+	if mergeErr != nil {
+		return mergeErr
+	}
+	for i, err := range srcErrs {
+		if err != nil {
+			return fmt.Errorf("parsing input %d: %w", i+1, err)
+		}
+	}
+	if unsorted > 0 {
+		return errors.New("inputs need to be sorted by type (nodes before ways before relations)")
+	}
+	return nil
+}
+
+// mergeStreams calls fn for each element of all streams in the order of the
+// element IDs. Elements with the same ID are only passed once, the element
+// with the highest version wins.
+func mergeStreams[T any](streams []*stream[T], fn func(T) error) error {
+	for {
+		var best *T
+		var bestElem *osm.Element
+		for i, s := range streams {
+			e, err := s.peek()
+			if err != nil {
+				return fmt.Errorf("input %d: %w", i+1, err)
+			}
+			if e == nil {
+				continue
+			}
+			elem := s.elem(e)
+			if best == nil || elem.ID < bestElem.ID ||
+				(elem.ID == bestElem.ID && version(elem) > version(bestElem)) {
+				best, bestElem = e, elem
+			}
+		}
+		if best == nil {
+			return nil
+		}
+
+		result := *best
+		id := bestElem.ID
+		for _, s := range streams {
+			if e, _ := s.peek(); e != nil && s.elem(e).ID == id {
+				s.pop()
+			}
+		}
+		if err := fn(result); err != nil {
+			return err
+		}
+	}
+}
+
+func version(elem *osm.Element) int32 {
+	if elem.Metadata == nil {
+		return 0
+	}
+	return elem.Metadata.Version
+}
+
+// stream returns the elements of a single type from one input.
+type stream[T any] struct {
+	ch   chan []T
+	done chan struct{}
+	elem func(*T) *osm.Element
+
+	buf     []T
+	last    int64
+	started bool
+	end     bool
+}
+
+// peek returns the next element without removing it. Returns nil at the end
+// of the stream.
+func (s *stream[T]) peek() (*T, error) {
+	for len(s.buf) == 0 {
+		if s.end {
+			return nil, nil
+		}
+		s.buf = s.receive()
+	}
+	e := &s.buf[0]
+	if s.started && s.elem(e).ID <= s.last {
+		return nil, fmt.Errorf("not sorted by ID (%d after %d)", s.elem(e).ID, s.last)
+	}
+	return e, nil
+}
+
+// receive returns the next batch. Marks the end of the stream if it
+// received a nil batch, or if the source is done and all batches are
+// received.
+func (s *stream[T]) receive() []T {
+	var elems []T
+	var ok bool
+	select {
+	case elems, ok = <-s.ch:
+	case <-s.done:
+		select {
+		case elems, ok = <-s.ch:
+		default:
+		}
+	}
+	if !ok || elems == nil {
+		s.end = true
+	}
+	return elems
+}
+
+// pop removes the next element. Only valid after a successful peek.
+func (s *stream[T]) pop() {
+	s.last = s.elem(&s.buf[0]).ID
+	s.started = true
+	s.buf = s.buf[1:]
+}
+
+// drain receives all remaining batches till the source is done. Returns the
+// number of received elements.
+func (s *stream[T]) drain() int {
+	n := 0
+	for {
+		select {
+		case elems, ok := <-s.ch:
+			if !ok {
+				return n
+			}
+			n += len(elems)
+		case <-s.done:
+			for {
+				select {
+				case elems, ok := <-s.ch:
+					if !ok {
+						return n
+					}
+					n += len(elems)
+				default:
+					return n
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package merge
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	osm "github.com/omniscale/go-osm"
+	"github.com/omniscale/imposm3/parser/osmxml"
+)
+
+const inputA = `<osm>
+<node id="1" version="1" lat="1" lon="1"/>
+<node id="2" version="3" lat="2" lon="2"><tag k="name" v="new"/></node>
+<node id="4" version="1" lat="4" lon="4"/>
+<way id="10" version="1"><nd ref="1"/><nd ref="2"/></way>
+<relation id="20" version="1"><member type="way" ref="10" role=""/></relation>
+</osm>`
+
+const inputB = `<osm>
+<node id="2" version="2" lat="2" lon="2"><tag k="name" v="old"/></node>
+<node id="3" version="1" lat="3" lon="3"/>
+<way id="10" version="2"><nd ref="1"/><nd ref="2"/><nd ref="3"/></way>
+<way id="11" version="1"><nd ref="3"/><nd ref="4"/></way>
+</osm>`
+
+func parse(t *testing.T, inputs ...string) ([]osm.Node, []osm.Node, []osm.Way, []osm.Relation, error) {
+	t.Helper()
+	conf := Config{
+		Coords:    make(chan []osm.Node),
+		Nodes:     make(chan []osm.Node),
+		Ways:      make(chan []osm.Way),
+		Relations: make(chan []osm.Relation),
+	}
+
+	var coords, nodes []osm.Node
+	var ways []osm.Way
+	var rels []osm.Relation
+	order := []string{}
+	conf.OnFirstWay = func() { order = append(order, "way") }
+	conf.OnFirstRelation = func() { order = append(order, "relation") }
+
+	p := New(conf)
+	for _, input := range inputs {
+		in := p.NewInput()
+		in.SetSource(osmxml.New(strings.NewReader(input), osmxml.Config{
+			Nodes:           in.Nodes,
+			Ways:            in.Ways,
+			Relations:       in.Relations,
+			OnFirstWay:      in.OnFirstWay,
+			OnFirstRelation: in.OnFirstRelation,
+			IncludeMetadata: true,
+		}))
+	}
+
+	done := make(chan struct{})
+	go func() {
+		for conf.Coords != nil || conf.Nodes != nil || conf.Ways != nil || conf.Relations != nil {
+			select {
+			case c, ok := <-conf.Coords:
+				if !ok {
+					conf.Coords = nil
+				}
+				coords = append(coords, c...)
+			case n, ok := <-conf.Nodes:
+				if !ok {
+					conf.Nodes = nil
+				}
+				nodes = append(nodes, n...)
+			case w, ok := <-conf.Ways:
+				if !ok {
+					conf.Ways = nil
+				}
+				ways = append(ways, w...)
+			case r, ok := <-conf.Relations:
+				if !ok {
+					conf.Relations = nil
+				}
+				rels = append(rels, r...)
+			}
+		}
+		close(done)
+	}()
+
+	err := p.Parse(context.Background())
+	<-done
+	if err == nil && strings.Join(order, " ") != "way relation" {
+		t.Errorf("unexpected callback order %v", order)
+	}
+	return coords, nodes, ways, rels, err
+}
+
+func TestMerge(t *testing.T) {
+	coords, nodes, ways, rels, err := parse(t, inputA, inputB)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ids := func(elems []osm.Element) []int64 {
+		var result []int64
+		for _, e := range elems {
+			result = append(result, e.ID)
+		}
+		return result
+	}
+	var coordElems, wayElems []osm.Element
+	for _, c := range coords {
+		coordElems = append(coordElems, c.Element)
+	}
+	for _, w := range ways {
+		wayElems = append(wayElems, w.Element)
+	}
+
+	if got := ids(coordElems); len(got) != 4 || got[0] != 1 || got[1] != 2 || got[2] != 3 || got[3] != 4 {
+		t.Errorf("unexpected coords %v", got)
+	}
+	if len(nodes) != 1 || nodes[0].Tags["name"] != "new" {
+		t.Errorf("expected node with highest version, got %v", nodes)
+	}
+	if nodes[0].Metadata != nil {
+		t.Errorf("unexpected metadata %v", nodes[0].Metadata)
+	}
+	if got := ids(wayElems); len(got) != 2 || got[0] != 10 || got[1] != 11 {
+		t.Errorf("unexpected ways %v", got)
+	}
+	if len(ways[0].Refs) != 3 {
+		t.Errorf("expected way with highest version, got %v", ways[0])
+	}
+	if len(rels) != 1 || rels[0].ID != 20 {
+		t.Errorf("unexpected relations %v", rels)
+	}
+}
+
+func TestMergeUnsorted(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		input string
+		error string
+	}{
+		{"ids", `<osm><node id="2" lat="1" lon="1"/><node id="1" lat="1" lon="1"/></osm>`, "not sorted by ID"},
+		{"types", `<osm><way id="1"/><node id="1" lat="1" lon="1"/></osm>`, "sorted by type"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, _, _, err := parse(t, inputA, tt.input)
+			if err == nil || !strings.Contains(err.Error(), tt.error) {
+				t.Errorf("expected error with %q, got %v", tt.error, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,14 @@
+/*
+Package o5m provides a parser for OpenStreetMap o5m files.
+
+o5m is a compact binary format used by osmconvert and osmfilter. See
+https://wiki.openstreetmap.org/wiki/O5m for the specification.
+
+The parser uses the same configuration and callbacks as the PBF parser from
+github.com/omniscale/go-osm/parser/pbf. Nodes, ways and relations are passed
+back in batches via channels. Like the PBF parser, OnFirstWay and
+OnFirstRelation only work as expected if the file is ordered by type (nodes
+before ways before relations), which is the case for all o5m files created by
+osmconvert.
+*/
+package o5m
@@ -0,0 +1,627 @@
+package o5m
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	osm "github.com/omniscale/go-osm"
+	"github.com/omniscale/imposm3/parser/internal/batch"
+)
+
+// dataset types
+const (
+	typeNode      = 0x10
+	typeWay       = 0x11
+	typeRelation  = 0x12
+	typeBBox      = 0xdb
+	typeTimestamp = 0xdc
+	typeHeader    = 0xe0
+	typeSync      = 0xee
+	typeJump      = 0xef
+	typeEOF       = 0xfe
+	typeReset     = 0xff
+)
+
+const coordScale = 1e7
+
+type Config struct {
+	// IncludeMetadata indicates whether metadata like timestamps, versions and
+	// user names should be parsed.
+	IncludeMetadata bool
+
+	// Nodes specifies the destination for parsed nodes. See also Coords below.
+	// For efficiency, multiple nodes are passed in batches.
+	Nodes chan []osm.Node
+	// Ways specifies the destination for parsed ways.
+	// For efficiency, multiple ways are passed in batches.
+	Ways chan []osm.Way
+	// Relations specifies the destination for parsed relations.
+	// For efficiency, multiple relations are passed in batches.
+	Relations chan []osm.Relation
+
+	// Coords specifies the destination for parsed nodes without any tags. This
+	// can be used for more efficient storage/proceessing of nodes that are
+	// only used as coordinates for ways and relations.
+	// For efficiency, multiple nodes are passed in batches.
+	//
+	// If a Coords channel is specified, then nodes without tags are
+	// not sent to the Nodes channel. However, the Coords channel will receive
+	// all nodes.
+	Coords chan []osm.Node
+
+	// KeepOpen specifies whether the destination channels should be keept open
+	// after Parse(). By default, Nodes, Ways, Relations and Coords channels
+	// are closed after Parse().
+	KeepOpen bool
+
+	// OnFirstWay defines an optional func that gets called when the the first
+	// way is parsed. The callback should block until it is safe to fill the
+	// Ways channel. All nodes parsed before are sent before the callback is
+	// called.
+	OnFirstWay func()
+
+	// OnFirstRelation defines an optional func that gets called when the
+	// the first relation is parsed. The callback should block until it is
+	// safe to fill the Relations channel. All ways parsed before are sent
+	// before the callback is called.
+	OnFirstRelation func()
+}
+
+// Header contains the information from the datasets before the first
+// node, way or relation.
+type Header struct {
+	// Time is the file timestamp, if the file contains a timestamp dataset.
+	Time time.Time
+}
+
+// Parser is a stream based parser for o5m files.
+type Parser struct {
+	conf   Config
+	r      *bufio.Reader
+	header *Header
+	err    error
+	batch  *batch.Sender
+
+	buf     []byte
+	strings stringTable
+	delta   deltas
+}
+
+// deltas contains the previous values of all delta coded fields. All values
+// are reset with each reset dataset.
+type deltas struct {
+	id        int64
+	timestamp int64
+	changeset int64
+	lon       int64
+	lat       int64
+	wayRef    int64
+	memberRef [3]int64
+}
+
+// New creates a new parser for the provided input. Config specifies the
+// destinations for the parsed elements.
+func New(r io.Reader, conf Config) *Parser {
+	return &Parser{
+		conf: conf,
+		r:    bufio.NewReader(r),
+		batch: &batch.Sender{
+			Coords:          conf.Coords,
+			Nodes:           conf.Nodes,
+			Ways:            conf.Ways,
+			Relations:       conf.Relations,
+			OnFirstWay:      conf.OnFirstWay,
+			OnFirstRelation: conf.OnFirstRelation,
+		},
+	}
+}
+
+// Header returns the header information of the o5m file. Can be called
+// before or after Parse().
+func (p *Parser) Header() (*Header, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	if p.header == nil {
+		if p.err = p.parseHeader(); p.err != nil {
+			return nil, p.err
+		}
+	}
+	return p.header, nil
+}
+
+// Error returns the first error that occurred during Header/Parse calls.
+func (p *Parser) Error() error {
+	return p.err
+}
+
+// parseHeader parses all datasets till the first node, way or relation.
+func (p *Parser) parseHeader() error {
+	first, err := p.r.Peek(1)
+	if err != nil {
+		return fmt.Errorf("reading o5m header: %w", err)
+	}
+	if first[0] != typeReset {
+		return errors.New("invalid o5m file, missing reset at start of file")
+	}
+
+	p.header = &Header{}
+	for {
+		next, err := p.r.Peek(1)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("reading o5m header: %w", err)
+		}
+		switch next[0] {
+		case typeNode, typeWay, typeRelation, typeEOF:
+			return nil
+		}
+		typ, data, err := p.readDataset()
+		if err != nil {
+			return err
+		}
+		if err := p.handleHeaderDataset(typ, data); err != nil {
+			return err
+		}
+	}
+}
+
+func (p *Parser) handleHeaderDataset(typ byte, data []byte) error {
+	switch typ {
+	case typeReset:
+		p.reset()
+	case typeHeader:
+		if string(data) == "o5c2" {
+			return errors.New("o5c change files are not supported")
+		}
+		if string(data) != "o5m2" {
+			return fmt.Errorf("unsupported o5m header %q", data)
+		}
+	case typeTimestamp:
+		d := decoder{data: data}
+		ts, err := d.svarint()
+		if err != nil {
+			return fmt.Errorf("parsing file timestamp: %w", err)
+		}
+		p.header.Time = time.Unix(ts, 0)
+	}
+	// bbox, sync, jump and unknown datasets are ignored
+	return nil
+}
+
+// Parse parses the o5m file and sends the parsed nodes, ways and relations
+// into the channels provided to the Parsers Config.
+// Context can be used to cancel the parsing.
+func (p *Parser) Parse(ctx context.Context) (err error) {
+	if p.err != nil {
+		return p.err
+	}
+
+	defer func() {
+		if err != nil {
+			p.err = err
+		}
+	}()
+
+	if !p.conf.KeepOpen {
+		defer p.batch.Close()
+	}
+
+	if p.header == nil {
+		if err := p.parseHeader(); err != nil {
+			return err
+		}
+	}
+
+	for {
+		typ, data, err := p.readDataset()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		switch typ {
+		case typeEOF:
+			return p.batch.Finish(ctx)
+		case typeNode:
+			node, ok, err := p.parseNode(data)
+			if err != nil {
+				return fmt.Errorf("parsing node: %w", err)
+			}
+			if ok {
+				if err := p.batch.AddNode(ctx, node); err != nil {
+					return err
+				}
+			}
+		case typeWay:
+			way, ok, err := p.parseWay(data)
+			if err != nil {
+				return fmt.Errorf("parsing way: %w", err)
+			}
+			if ok {
+				if err := p.batch.AddWay(ctx, way); err != nil {
+					return err
+				}
+			}
+		case typeRelation:
+			rel, ok, err := p.parseRelation(data)
+			if err != nil {
+				return fmt.Errorf("parsing relation: %w", err)
+			}
+			if ok {
+				if err := p.batch.AddRelation(ctx, rel); err != nil {
+					return err
+				}
+			}
+		default:
+			if err := p.handleHeaderDataset(typ, data); err != nil {
+				return err
+			}
+		}
+	}
+
+	return p.batch.Finish(ctx)
+}
+
+// readDataset reads the next dataset. Returns io.EOF at the end of the
+// input. The returned data is only valid till the next call.
+func (p *Parser) readDataset() (byte, []byte, error) {
+	typ, err := p.r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	if typ >= 0xf0 {
+		// single byte datasets without length
+		return typ, nil, nil
+	}
+	length, err := binary.ReadUvarint(p.r)
+	if err != nil {
+		return 0, nil, fmt.Errorf("reading length of dataset 0x%x: %w", typ, unexpectedEOF(err))
+	}
+	if uint64(cap(p.buf)) < length {
+		p.buf = make([]byte, length)
+	}
+	p.buf = p.buf[:length]
+	if _, err := io.ReadFull(p.r, p.buf); err != nil {
+		return 0, nil, fmt.Errorf("reading dataset 0x%x: %w", typ, unexpectedEOF(err))
+	}
+	return typ, p.buf, nil
+}
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+func (p *Parser) reset() {
+	p.delta = deltas{}
+	p.strings.reset()
+}
+
+// parseNode parses a node dataset. Returns false for deleted nodes.
+func (p *Parser) parseNode(data []byte) (osm.Node, bool, error) {
+	d := decoder{data: data, strings: &p.strings}
+	node := osm.Node{}
+
+	var err error
+	if node.ID, err = p.parseID(&d); err != nil {
+		return node, false, err
+	}
+	if err := p.parseInfo(&d, &node.Element); err != nil {
+		return node, false, err
+	}
+	if d.atEnd() {
+		// deleted node without coordinates and tags
+		return node, false, nil
+	}
+
+	lon, err := d.svarint()
+	if err != nil {
+		return node, false, err
+	}
+	lat, err := d.svarint()
+	if err != nil {
+		return node, false, err
+	}
+	p.delta.lon += lon
+	p.delta.lat += lat
+	node.Long = float64(p.delta.lon) / coordScale
+	node.Lat = float64(p.delta.lat) / coordScale
+
+	if node.Tags, err = d.tags(); err != nil {
+		return node, false, err
+	}
+	return node, true, nil
+}
+
+// parseWay parses a way dataset. Returns false for deleted ways.
+func (p *Parser) parseWay(data []byte) (osm.Way, bool, error) {
+	d := decoder{data: data, strings: &p.strings}
+	way := osm.Way{}
+
+	var err error
+	if way.ID, err = p.parseID(&d); err != nil {
+		return way, false, err
+	}
+	if err := p.parseInfo(&d, &way.Element); err != nil {
+		return way, false, err
+	}
+	if d.atEnd() {
+		return way, false, nil
+	}
+
+	refsLen, err := d.uvarint()
+	if err != nil {
+		return way, false, err
+	}
+	refsEnd := d.pos + int(refsLen)
+	if refsEnd > len(d.data) {
+		return way, false, io.ErrUnexpectedEOF
+	}
+	for d.pos < refsEnd {
+		ref, err := d.svarint()
+		if err != nil {
+			return way, false, err
+		}
+		p.delta.wayRef += ref
+		way.Refs = append(way.Refs, p.delta.wayRef)
+	}
+
+	if way.Tags, err = d.tags(); err != nil {
+		return way, false, err
+	}
+	return way, true, nil
+}
+
+// parseRelation parses a relation dataset. Returns false for deleted
+// relations.
+func (p *Parser) parseRelation(data []byte) (osm.Relation, bool, error) {
+	d := decoder{data: data, strings: &p.strings}
+	rel := osm.Relation{}
+
+	var err error
+	if rel.ID, err = p.parseID(&d); err != nil {
+		return rel, false, err
+	}
+	if err := p.parseInfo(&d, &rel.Element); err != nil {
+		return rel, false, err
+	}
+	if d.atEnd() {
+		return rel, false, nil
+	}
+
+	refsLen, err := d.uvarint()
+	if err != nil {
+		return rel, false, err
+	}
+	refsEnd := d.pos + int(refsLen)
+	if refsEnd > len(d.data) {
+		return rel, false, io.ErrUnexpectedEOF
+	}
+	for d.pos < refsEnd {
+		ref, err := d.svarint()
+		if err != nil {
+			return rel, false, err
+		}
+		typeRole, _, err := d.string(false)
+		if err != nil {
+			return rel, false, err
+		}
+		if len(typeRole) == 0 || typeRole[0] < '0' || typeRole[0] > '2' {
+			return rel, false, fmt.Errorf("invalid member type in %q", typeRole)
+		}
+		memberType := typeRole[0] - '0'
+		p.delta.memberRef[memberType] += ref
+		rel.Members = append(rel.Members, osm.Member{
+			ID:   p.delta.memberRef[memberType],
+			Type: osm.MemberType(memberType),
+			Role: typeRole[1:],
+		})
+	}
+
+	if rel.Tags, err = d.tags(); err != nil {
+		return rel, false, err
+	}
+	return rel, true, nil
+}
+
+func (p *Parser) parseID(d *decoder) (int64, error) {
+	id, err := d.svarint()
+	if err != nil {
+		return 0, err
+	}
+	p.delta.id += id
+	return p.delta.id, nil
+}
+
+// parseInfo parses the version and author information. Sets the metadata
+// of elem if IncludeMetadata is enabled.
+func (p *Parser) parseInfo(d *decoder, elem *osm.Element) error {
+	version, err := d.uvarint()
+	if err != nil {
+		return err
+	}
+	if version == 0 {
+		// no version and author information
+		return nil
+	}
+	var md *osm.Metadata
+	if p.conf.IncludeMetadata {
+		md = &osm.Metadata{Version: int32(version)}
+		elem.Metadata = md
+	}
+
+	ts, err := d.svarint()
+	if err != nil {
+		return err
+	}
+	p.delta.timestamp += ts
+	if p.delta.timestamp == 0 {
+		// no author information
+		return nil
+	}
+	cs, err := d.svarint()
+	if err != nil {
+		return err
+	}
+	p.delta.changeset += cs
+
+	uid, user, err := d.string(true)
+	if err != nil {
+		return err
+	}
+	if md != nil {
+		md.Timestamp = time.Unix(p.delta.timestamp, 0)
+		md.Changeset = p.delta.changeset
+		// uid is stored as varint within the first string
+		v, _ := binary.Uvarint([]byte(uid))
+		md.UserID = int32(v)
+		md.UserName = user
+	}
+	return nil
+}
+
+// decoder decodes the fields of a single dataset.
+type decoder struct {
+	data    []byte
+	pos     int
+	strings *stringTable
+}
+
+func (d *decoder) atEnd() bool {
+	return d.pos >= len(d.data)
+}
+
+func (d *decoder) uvarint() (uint64, error) {
+	v, n := binary.Uvarint(d.data[d.pos:])
+	if n <= 0 {
+		return 0, errors.New("invalid varint")
+	}
+	d.pos += n
+	return v, nil
+}
+
+// svarint decodes a signed varint. The least significant bit is the sign.
+func (d *decoder) svarint() (int64, error) {
+	v, err := d.uvarint()
+	if err != nil {
+		return 0, err
+	}
+	return int64(v>>1) ^ -int64(v&1), nil
+}
+
+// string decodes a single string or a string pair, either inline or as a
+// reference to the string table.
+func (d *decoder) string(pair bool) (string, string, error) {
+	if d.atEnd() {
+		return "", "", io.ErrUnexpectedEOF
+	}
+	if d.data[d.pos] != 0 {
+		ref, err := d.uvarint()
+		if err != nil {
+			return "", "", err
+		}
+		e, ok := d.strings.get(ref)
+		if !ok {
+			return "", "", fmt.Errorf("invalid string reference %d", ref)
+		}
+		return e.a, e.b, nil
+	}
+
+	d.pos++ // inline string
+	a, err := d.cString()
+	if err != nil {
+		return "", "", err
+	}
+	var b string
+	if pair {
+		if b, err = d.cString(); err != nil {
+			return "", "", err
+		}
+	}
+	d.strings.add(a, b)
+	return a, b, nil
+}
+
+// cString decodes a zero terminated string.
+func (d *decoder) cString() (string, error) {
+	for i := d.pos; i < len(d.data); i++ {
+		if d.data[i] == 0 {
+			s := string(d.data[d.pos:i])
+			d.pos = i + 1
+			return s, nil
+		}
+	}
+	return "", io.ErrUnexpectedEOF
+}
+
+// tags decodes all remaining key/value pairs of the dataset.
+func (d *decoder) tags() (osm.Tags, error) {
+	var tags osm.Tags
+	for !d.atEnd() {
+		k, v, err := d.string(true)
+		if err != nil {
+			return nil, err
+		}
+		if tags == nil {
+			tags = make(osm.Tags)
+		}
+		tags[k] = v
+	}
+	return tags, nil
+}
+
+const (
+	stringTableSize = 15000
+	// longer strings (or string pairs) are not stored in the string table
+	maxStringLength = 250
+)
+
+type stringPair struct {
+	a, b string
+}
+
+// stringTable stores the last stringTableSize strings. Index 1 refers to
+// the last added string.
+type stringTable struct {
+	entries []stringPair
+	next    int
+}
+
+func (t *stringTable) add(a, b string) {
+	if len(a)+len(b) > maxStringLength {
+		return
+	}
+	if t.entries == nil {
+		t.entries = make([]stringPair, 0, stringTableSize)
+	}
+	if len(t.entries) < stringTableSize {
+		t.entries = append(t.entries, stringPair{a, b})
+	} else {
+		t.entries[t.next] = stringPair{a, b}
+	}
+	t.next = (t.next + 1) % stringTableSize
+}
+
+func (t *stringTable) get(ref uint64) (stringPair, bool) {
+	if ref == 0 || ref > uint64(len(t.entries)) {
+		return stringPair{}, false
+	}
+	idx := (t.next - int(ref) + stringTableSize) % stringTableSize
+	return t.entries[idx], true
+}
+
+func (t *stringTable) reset() {
+	t.entries = t.entries[:0]
+	t.next = 0
+}
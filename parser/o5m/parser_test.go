@@ -0,0 +1,212 @@
+package o5m
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	osm "github.com/omniscale/go-osm"
+)
+
+// encoder writes minimal o5m files for testing.
+type encoder struct {
+	buf bytes.Buffer
+}
+
+func (e *encoder) dataset(typ byte, data []byte) {
+	e.buf.WriteByte(typ)
+	e.buf.Write(binary.AppendUvarint(nil, uint64(len(data))))
+	e.buf.Write(data)
+}
+
+func uvarint(b []byte, v uint64) []byte {
+	return binary.AppendUvarint(b, v)
+}
+
+func svarint(b []byte, v int64) []byte {
+	return binary.AppendUvarint(b, uint64(v<<1)^uint64(v>>63))
+}
+
+func pair(b []byte, k, v string) []byte {
+	b = append(b, 0)
+	b = append(b, k...)
+	b = append(b, 0)
+	b = append(b, v...)
+	return append(b, 0)
+}
+
+func testFile() []byte {
+	e := &encoder{}
+	e.buf.WriteByte(typeReset)
+	e.dataset(typeHeader, []byte("o5m2"))
+	e.dataset(typeTimestamp, svarint(nil, 1577934245))
+
+	// node 10 without version, tagged
+	d := svarint(nil, 10)
+	d = uvarint(d, 0)
+	d = svarint(d, 71234567)
+	d = svarint(d, 513456789)
+	d = pair(d, "amenity", "cafe")
+	e.dataset(typeNode, d)
+
+	// node 12 with version/author, coords delta coded
+	d = svarint(nil, 2)
+	d = uvarint(d, 3)                                        // version
+	d = svarint(d, 1500000000)                               // timestamp
+	d = svarint(d, 42)                                       // changeset
+	d = pair(d, string(binary.AppendUvarint(nil, 7)), "foo") // uid/user
+	d = svarint(d, -10000000)
+	d = svarint(d, 10000000)
+	d = uvarint(d, 2) // reference to amenity=cafe, uid/user is 1
+	e.dataset(typeNode, d)
+
+	// deleted node 13
+	d = svarint(nil, 1)
+	d = uvarint(d, 0)
+	e.dataset(typeNode, d)
+
+	// way 5 with refs 10, 12
+	d = svarint(nil, 5-13)
+	d = uvarint(d, 0)
+	refs := svarint(nil, 10)
+	refs = svarint(refs, 2)
+	d = uvarint(d, uint64(len(refs)))
+	d = append(d, refs...)
+	d = pair(d, "highway", "residential")
+	e.dataset(typeWay, d)
+
+	// reset clears string table and deltas
+	e.buf.WriteByte(typeReset)
+
+	// relation 7 with way 5 and node 10
+	d = svarint(nil, 7)
+	d = uvarint(d, 0)
+	var members []byte
+	members = svarint(members, 5)
+	members = append(members, 0)
+	members = append(members, "1outer"...)
+	members = append(members, 0)
+	members = svarint(members, 10)
+	members = append(members, 0)
+	members = append(members, "0"...)
+	members = append(members, 0)
+	d = uvarint(d, uint64(len(members)))
+	d = append(d, members...)
+	d = pair(d, "type", "multipolygon")
+	e.dataset(typeRelation, d)
+
+	e.buf.WriteByte(typeEOF)
+	return e.buf.Bytes()
+}
+
+func TestParse(t *testing.T) {
+	conf := Config{
+		Coords:          make(chan []osm.Node, 10),
+		Nodes:           make(chan []osm.Node, 10),
+		Ways:            make(chan []osm.Way, 10),
+		Relations:       make(chan []osm.Relation, 10),
+		IncludeMetadata: true,
+	}
+	p := New(bytes.NewReader(testFile()), conf)
+
+	header, err := p.Header()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header.Time.Unix() != 1577934245 {
+		t.Errorf("unexpected header time %v", header.Time)
+	}
+
+	if err := p.Parse(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	var coords, nodes []osm.Node
+	var ways []osm.Way
+	var rels []osm.Relation
+	for c := range conf.Coords {
+		coords = append(coords, c...)
+	}
+	for n := range conf.Nodes {
+		nodes = append(nodes, n...)
+	}
+	for w := range conf.Ways {
+		ways = append(ways, w...)
+	}
+	for r := range conf.Relations {
+		rels = append(rels, r...)
+	}
+
+	if len(coords) != 2 || coords[0].ID != 10 || coords[1].ID != 12 {
+		t.Fatalf("unexpected coords %#v", coords)
+	}
+	if coords[0].Long != 7.1234567 || coords[0].Lat != 51.3456789 {
+		t.Errorf("unexpected coord %#v", coords[0])
+	}
+	if d := coords[1].Long - 6.1234567; d > 1e-9 || d < -1e-9 {
+		t.Errorf("unexpected coord %#v", coords[1])
+	}
+
+	if len(nodes) != 2 {
+		t.Fatalf("unexpected nodes %#v", nodes)
+	}
+	for _, n := range nodes {
+		if n.Tags["amenity"] != "cafe" {
+			t.Errorf("unexpected tags for node %d: %v", n.ID, n.Tags)
+		}
+	}
+	if nodes[0].Metadata != nil {
+		t.Errorf("unexpected metadata %#v", nodes[0].Metadata)
+	}
+	expectedMd := &osm.Metadata{
+		Version:   3,
+		Timestamp: time.Unix(1500000000, 0),
+		Changeset: 42,
+		UserID:    7,
+		UserName:  "foo",
+	}
+	if !reflect.DeepEqual(nodes[1].Metadata, expectedMd) {
+		t.Errorf("unexpected metadata %#v", nodes[1].Metadata)
+	}
+
+	if len(ways) != 1 || ways[0].ID != 5 ||
+		!reflect.DeepEqual(ways[0].Refs, []int64{10, 12}) ||
+		ways[0].Tags["highway"] != "residential" {
+		t.Errorf("unexpected ways %#v", ways)
+	}
+
+	expectedMembers := []osm.Member{
+		{ID: 5, Type: osm.WayMember, Role: "outer"},
+		{ID: 10, Type: osm.NodeMember, Role: ""},
+	}
+	if len(rels) != 1 || rels[0].ID != 7 ||
+		!reflect.DeepEqual(rels[0].Members, expectedMembers) ||
+		rels[0].Tags["type"] != "multipolygon" {
+		t.Errorf("unexpected relations %#v", rels)
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		data  []byte
+		error string
+	}{
+		{"no reset", []byte{typeHeader, 4, 'o', '5', 'm', '2'}, "missing reset"},
+		{"change file", []byte{typeReset, typeHeader, 4, 'o', '5', 'c', '2'}, "not supported"},
+		{"truncated", []byte{typeReset, typeNode, 10, 2}, "unexpected EOF"},
+		{"invalid string ref", []byte{typeReset, typeNode, 4, 2, 0, 0, 0, typeNode, 4, 2, 0, 0, 0, typeNode, 5, 2, 0, 0, 0, 1}, "invalid string reference"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			p := New(bytes.NewReader(tt.data), Config{Nodes: make(chan []osm.Node, 10)})
+			err := p.Parse(context.Background())
+			if err == nil || !strings.Contains(err.Error(), tt.error) {
+				t.Errorf("expected error with %q, got %v", tt.error, err)
+			}
+		})
+	}
+}
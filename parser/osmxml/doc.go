@@ -0,0 +1,10 @@
+/*
+Package osmxml provides a parser for OpenStreetMap XML files (.osm).
+
+The parser uses the same configuration and callbacks as the PBF parser from
+github.com/omniscale/go-osm/parser/pbf. Nodes, ways and relations are passed
+back in batches via channels. Like the PBF parser, OnFirstWay and
+OnFirstRelation only work as expected if the file is ordered by type (nodes
+before ways before relations).
+*/
+package osmxml
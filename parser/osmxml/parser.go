@@ -0,0 +1,359 @@
+package osmxml
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	osm "github.com/omniscale/go-osm"
+	"github.com/omniscale/imposm3/parser/internal/batch"
+)
+
+type Config struct {
+	// IncludeMetadata indicates whether metadata like timestamps, versions and
+	// user names should be parsed.
+	IncludeMetadata bool
+
+	// Nodes specifies the destination for parsed nodes. See also Coords below.
+	// For efficiency, multiple nodes are passed in batches.
+	Nodes chan []osm.Node
+	// Ways specifies the destination for parsed ways.
+	// For efficiency, multiple ways are passed in batches.
+	Ways chan []osm.Way
+	// Relations specifies the destination for parsed relations.
+	// For efficiency, multiple relations are passed in batches.
+	Relations chan []osm.Relation
+
+	// Coords specifies the destination for parsed nodes without any tags. This
+	// can be used for more efficient storage/proceessing of nodes that are
+	// only used as coordinates for ways and relations.
+	// For efficiency, multiple nodes are passed in batches.
+	//
+	// If a Coords channel is specified, then nodes without tags are
+	// not sent to the Nodes channel. However, the Coords channel will receive
+	// all nodes.
+	Coords chan []osm.Node
+
+	// KeepOpen specifies whether the destination channels should be keept open
+	// after Parse(). By default, Nodes, Ways, Relations and Coords channels
+	// are closed after Parse().
+	KeepOpen bool
+
+	// OnFirstWay defines an optional func that gets called when the the first
+	// way is parsed. The callback should block until it is safe to fill the
+	// Ways channel. All nodes parsed before are sent before the callback is
+	// called.
+	OnFirstWay func()
+
+	// OnFirstRelation defines an optional func that gets called when the
+	// the first relation is parsed. The callback should block until it is
+	// safe to fill the Relations channel. All ways parsed before are sent
+	// before the callback is called.
+	OnFirstRelation func()
+}
+
+// Header contains the attributes of the root osm element.
+type Header struct {
+	// Time is the timestamp of the data, if the file contains a timestamp
+	// attribute.
+	Time      time.Time
+	Generator string
+}
+
+// Parser is a stream based parser for OSM XML files.
+type Parser struct {
+	conf    Config
+	decoder *xml.Decoder
+	header  *Header
+	err     error
+	batch   *batch.Sender
+}
+
+// New creates a new parser for the provided input. Config specifies the
+// destinations for the parsed elements. The input needs to be uncompressed.
+func New(r io.Reader, conf Config) *Parser {
+	return &Parser{
+		conf:    conf,
+		decoder: xml.NewDecoder(r),
+		batch: &batch.Sender{
+			Coords:          conf.Coords,
+			Nodes:           conf.Nodes,
+			Ways:            conf.Ways,
+			Relations:       conf.Relations,
+			OnFirstWay:      conf.OnFirstWay,
+			OnFirstRelation: conf.OnFirstRelation,
+		},
+	}
+}
+
+// Header returns the header information from the root osm element. Can be
+// called before or after Parse().
+func (p *Parser) Header() (*Header, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	if p.header == nil {
+		if p.err = p.parseHeader(); p.err != nil {
+			return nil, p.err
+		}
+	}
+	return p.header, nil
+}
+
+// Error returns the first error that occurred during Header/Parse calls.
+func (p *Parser) Error() error {
+	return p.err
+}
+
+func (p *Parser) parseHeader() error {
+	for {
+		token, err := p.decoder.Token()
+		if err == io.EOF {
+			return errors.New("missing osm root element")
+		}
+		if err != nil {
+			return fmt.Errorf("decoding XML header: %w", err)
+		}
+		tok, ok := token.(xml.StartElement)
+		if !ok {
+			// skip XML declaration, comments, etc.
+			continue
+		}
+		if tok.Name.Local != "osm" {
+			return fmt.Errorf("expected osm root element, got %q", tok.Name.Local)
+		}
+		p.header = &Header{}
+		for _, attr := range tok.Attr {
+			switch attr.Name.Local {
+			case "generator":
+				p.header.Generator = attr.Value
+			case "timestamp":
+				p.header.Time, _ = time.Parse(time.RFC3339, attr.Value)
+			}
+		}
+		return nil
+	}
+}
+
+// Parse parses the XML file and sends the parsed nodes, ways and relations
+// into the channels provided to the Parsers Config.
+// Context can be used to cancel the parsing.
+func (p *Parser) Parse(ctx context.Context) (err error) {
+	if p.err != nil {
+		return p.err
+	}
+
+	defer func() {
+		if err != nil {
+			p.err = err
+		}
+	}()
+
+	if !p.conf.KeepOpen {
+		defer p.batch.Close()
+	}
+
+	if p.header == nil {
+		if err := p.parseHeader(); err != nil {
+			return err
+		}
+	}
+
+	var node *osm.Node
+	var way *osm.Way
+	var rel *osm.Relation
+	var tags osm.Tags
+	skip := false
+
+	for {
+		token, err := p.decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("decoding next XML token: %w", err)
+		}
+
+		switch tok := token.(type) {
+		case xml.StartElement:
+			switch tok.Name.Local {
+			case "node":
+				node = &osm.Node{}
+				for _, attr := range tok.Attr {
+					switch attr.Name.Local {
+					case "id":
+						node.ID, _ = strconv.ParseInt(attr.Value, 10, 64)
+					case "lat":
+						node.Lat, _ = strconv.ParseFloat(attr.Value, 64)
+					case "lon":
+						node.Long, _ = strconv.ParseFloat(attr.Value, 64)
+					}
+				}
+				skip = isDeleted(tok.Attr)
+				if p.conf.IncludeMetadata {
+					setElemMetadata(tok.Attr, &node.Element)
+				}
+			case "way":
+				way = &osm.Way{}
+				way.ID = parseID(tok.Attr)
+				skip = isDeleted(tok.Attr)
+				if p.conf.IncludeMetadata {
+					setElemMetadata(tok.Attr, &way.Element)
+				}
+			case "relation":
+				rel = &osm.Relation{}
+				rel.ID = parseID(tok.Attr)
+				skip = isDeleted(tok.Attr)
+				if p.conf.IncludeMetadata {
+					setElemMetadata(tok.Attr, &rel.Element)
+				}
+			case "nd":
+				if way == nil {
+					continue
+				}
+				for _, attr := range tok.Attr {
+					if attr.Name.Local == "ref" {
+						ref, _ := strconv.ParseInt(attr.Value, 10, 64)
+						way.Refs = append(way.Refs, ref)
+					}
+				}
+			case "member":
+				if rel == nil {
+					continue
+				}
+				if member, ok := parseMember(tok.Attr); ok {
+					rel.Members = append(rel.Members, member)
+				}
+			case "tag":
+				var k, v string
+				for _, attr := range tok.Attr {
+					if attr.Name.Local == "k" {
+						k = attr.Value
+					} else if attr.Name.Local == "v" {
+						v = attr.Value
+					}
+				}
+				if tags == nil {
+					tags = make(osm.Tags)
+				}
+				tags[k] = v
+			}
+		case xml.EndElement:
+			switch tok.Name.Local {
+			case "node":
+				if node != nil && !skip {
+					node.Tags = tags
+					if err := p.batch.AddNode(ctx, *node); err != nil {
+						return err
+					}
+				}
+				node = nil
+				tags = nil
+			case "way":
+				if way != nil && !skip {
+					way.Tags = tags
+					if err := p.batch.AddWay(ctx, *way); err != nil {
+						return err
+					}
+				}
+				way = nil
+				tags = nil
+			case "relation":
+				if rel != nil && !skip {
+					rel.Tags = tags
+					if err := p.batch.AddRelation(ctx, *rel); err != nil {
+						return err
+					}
+				}
+				rel = nil
+				tags = nil
+			}
+		}
+	}
+
+	return p.batch.Finish(ctx)
+}
+
+// isDeleted returns whether the element is marked as deleted, either with
+// visible="false" (history files) or with action="delete" (JOSM files).
+func isDeleted(attrs []xml.Attr) bool {
+	for _, attr := range attrs {
+		switch attr.Name.Local {
+		case "visible":
+			if attr.Value == "false" {
+				return true
+			}
+		case "action":
+			if attr.Value == "delete" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func parseID(attrs []xml.Attr) int64 {
+	for _, attr := range attrs {
+		if attr.Name.Local == "id" {
+			id, _ := strconv.ParseInt(attr.Value, 10, 64)
+			return id
+		}
+	}
+	return 0
+}
+
+func parseMember(attrs []xml.Attr) (osm.Member, bool) {
+	member := osm.Member{}
+	for _, attr := range attrs {
+		switch attr.Name.Local {
+		case "type":
+			var ok bool
+			member.Type, ok = memberTypeValues[attr.Value]
+			if !ok {
+				// ignore unknown member types
+				return member, false
+			}
+		case "role":
+			member.Role = attr.Value
+		case "ref":
+			var err error
+			member.ID, err = strconv.ParseInt(attr.Value, 10, 64)
+			if err != nil {
+				// ignore invalid ref
+				return member, false
+			}
+		}
+	}
+	return member, true
+}
+
+func setElemMetadata(attrs []xml.Attr, elem *osm.Element) {
+	elem.Metadata = &osm.Metadata{}
+	for _, attr := range attrs {
+		switch attr.Name.Local {
+		case "version":
+			v, _ := strconv.ParseInt(attr.Value, 10, 64)
+			elem.Metadata.Version = int32(v)
+		case "uid":
+			v, _ := strconv.ParseInt(attr.Value, 10, 64)
+			elem.Metadata.UserID = int32(v)
+		case "user":
+			elem.Metadata.UserName = attr.Value
+		case "changeset":
+			v, _ := strconv.ParseInt(attr.Value, 10, 64)
+			elem.Metadata.Changeset = v
+		case "timestamp":
+			elem.Metadata.Timestamp, _ = time.Parse(time.RFC3339, attr.Value)
+		}
+	}
+}
+
+var memberTypeValues = map[string]osm.MemberType{
+	"node":     osm.NodeMember,
+	"way":      osm.WayMember,
+	"relation": osm.RelationMember,
+}
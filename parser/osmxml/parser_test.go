@@ -0,0 +1,206 @@
+package osmxml
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	osm "github.com/omniscale/go-osm"
+)
+
+const testOSM = `<?xml version='1.0' encoding='UTF-8'?>
+<osm version="0.6" generator="JOSM" timestamp="2020-01-02T03:04:05Z">
+  <bounds minlat="42" minlon="10" maxlat="44" maxlon="12"/>
+  <node id="1" version="2" lat="42" lon="10">
+    <tag k="amenity" v="cafe"/>
+  </node>
+  <node id="2" lat="43" lon="11"/>
+  <node id="3" lat="44" lon="12">
+    <tag k="created_by" v="JOSM"/>
+  </node>
+  <node id="4" lat="44" lon="12" action="delete"/>
+  <way id="10" version="1">
+    <nd ref="1"/>
+    <nd ref="2"/>
+    <nd ref="3"/>
+    <tag k="highway" v="residential"/>
+  </way>
+  <way id="11" visible="false"/>
+  <relation id="20">
+    <member type="way" ref="10" role="outer"/>
+    <member type="node" ref="1" role=""/>
+    <member type="unknown" ref="1" role=""/>
+    <tag k="type" v="multipolygon"/>
+  </relation>
+</osm>
+`
+
+type result struct {
+	coords []osm.Node
+	nodes  []osm.Node
+	ways   []osm.Way
+	rels   []osm.Relation
+}
+
+func parse(t *testing.T, data string, conf Config) (*Parser, result) {
+	r := result{}
+	wg := sync.WaitGroup{}
+	collect := func(f func()) {
+		wg.Add(1)
+		go func() {
+			f()
+			wg.Done()
+		}()
+	}
+	if conf.Coords != nil {
+		collect(func() {
+			for nds := range conf.Coords {
+				r.coords = append(r.coords, nds...)
+			}
+		})
+	}
+	if conf.Nodes != nil {
+		collect(func() {
+			for nds := range conf.Nodes {
+				r.nodes = append(r.nodes, nds...)
+			}
+		})
+	}
+	if conf.Ways != nil {
+		collect(func() {
+			for ws := range conf.Ways {
+				r.ways = append(r.ways, ws...)
+			}
+		})
+	}
+	if conf.Relations != nil {
+		collect(func() {
+			for rs := range conf.Relations {
+				r.rels = append(r.rels, rs...)
+			}
+		})
+	}
+
+	p := New(strings.NewReader(data), conf)
+	if err := p.Parse(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	wg.Wait()
+	return p, r
+}
+
+func TestParse(t *testing.T) {
+	conf := Config{
+		Coords:    make(chan []osm.Node),
+		Nodes:     make(chan []osm.Node),
+		Ways:      make(chan []osm.Way),
+		Relations: make(chan []osm.Relation),
+	}
+	p, r := parse(t, testOSM, conf)
+
+	header, err := p.Header()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header.Generator != "JOSM" || header.Time.Unix() != 1577934245 {
+		t.Errorf("unexpected header %#v", header)
+	}
+
+	if len(r.coords) != 3 {
+		t.Fatalf("expected 3 coords, got %#v", r.coords)
+	}
+	if r.coords[0].ID != 1 || r.coords[0].Lat != 42 || r.coords[0].Long != 10 || r.coords[0].Tags != nil {
+		t.Errorf("unexpected coord %#v", r.coords[0])
+	}
+
+	if len(r.nodes) != 1 {
+		t.Fatalf("expected 1 node, got %#v", r.nodes)
+	}
+	if r.nodes[0].ID != 1 || r.nodes[0].Tags["amenity"] != "cafe" || r.nodes[0].Metadata != nil {
+		t.Errorf("unexpected node %#v", r.nodes[0])
+	}
+
+	if len(r.ways) != 1 {
+		t.Fatalf("expected 1 way, got %#v", r.ways)
+	}
+	if r.ways[0].ID != 10 || len(r.ways[0].Refs) != 3 || r.ways[0].Refs[2] != 3 || r.ways[0].Tags["highway"] != "residential" {
+		t.Errorf("unexpected way %#v", r.ways[0])
+	}
+
+	if len(r.rels) != 1 {
+		t.Fatalf("expected 1 relation, got %#v", r.rels)
+	}
+	rel := r.rels[0]
+	if rel.ID != 20 || rel.Tags["type"] != "multipolygon" || len(rel.Members) != 2 {
+		t.Fatalf("unexpected relation %#v", rel)
+	}
+	if rel.Members[0].Type != osm.WayMember || rel.Members[0].ID != 10 || rel.Members[0].Role != "outer" {
+		t.Errorf("unexpected member %#v", rel.Members[0])
+	}
+	if rel.Members[1].Type != osm.NodeMember || rel.Members[1].ID != 1 {
+		t.Errorf("unexpected member %#v", rel.Members[1])
+	}
+}
+
+func TestParseWithoutCoords(t *testing.T) {
+	conf := Config{
+		Nodes:           make(chan []osm.Node),
+		IncludeMetadata: true,
+	}
+	_, r := parse(t, testOSM, conf)
+
+	if len(r.nodes) != 3 {
+		t.Fatalf("expected 3 nodes, got %#v", r.nodes)
+	}
+	if r.nodes[0].Metadata == nil || r.nodes[0].Metadata.Version != 2 {
+		t.Errorf("unexpected metadata %#v", r.nodes[0].Metadata)
+	}
+}
+
+func TestParseCallbacks(t *testing.T) {
+	for _, tc := range []struct {
+		data     string
+		expected string
+	}{
+		{testOSM, "way:1 relation:1"},
+		// callbacks are also called if there are no ways/relations
+		{`<osm><node id="1" lat="1" lon="1"/></osm>`, "way:1 relation:0"},
+	} {
+		var events []string
+		conf := Config{
+			Coords: make(chan []osm.Node, 8),
+			Ways:   make(chan []osm.Way, 8),
+		}
+		// buffered channels, so we can check how many batches were sent
+		// before each callback
+		conf.OnFirstWay = func() {
+			events = append(events, fmt.Sprintf("way:%d", len(conf.Coords)))
+		}
+		conf.OnFirstRelation = func() {
+			events = append(events, fmt.Sprintf("relation:%d", len(conf.Ways)))
+		}
+
+		p := New(strings.NewReader(tc.data), conf)
+		if err := p.Parse(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if strings.Join(events, " ") != tc.expected {
+			t.Errorf("unexpected callbacks %v", events)
+		}
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, data := range []string{
+		"",
+		"<?xml version='1.0'?><osmChange></osmChange>",
+		"<osm><node id='1'></osm>",
+	} {
+		p := New(strings.NewReader(data), Config{Coords: make(chan []osm.Node, 1)})
+		if err := p.Parse(context.Background()); err == nil {
+			t.Errorf("expected error for %q", data)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package reader
+
+import (
+	"context"
+
+	osm "github.com/omniscale/go-osm"
+	"github.com/omniscale/imposm3/mapping"
+	"github.com/pkg/errors"
+)
+
+// Elements reads all files and calls the callbacks for each node, way and
+// relation in the order of the files. The tags are rewritten and filtered
+// as they are cached by Read. Nodes without tags are skipped. The members
+// of the relations only contain the ID, type and role.
+func Elements(
+	filenames []string,
+	tagmapping *mapping.Mapping,
+	node func(*osm.Node),
+	way func(*osm.Way),
+	relation func(*osm.Relation),
+) error {
+	// unbuffered to keep the order of the files across the channels
+	coords := make(chan []osm.Node)
+	nodes := make(chan []osm.Node)
+	ways := make(chan []osm.Way)
+	relations := make(chan []osm.Relation)
+
+	parser, files, err := openParser(filenames, parserConfig{
+		Coords:          coords,
+		Nodes:           nodes,
+		Ways:            ways,
+		Relations:       relations,
+		IncludeMetadata: true,
+		Ordered:         true,
+	})
+	if err != nil {
+		return err
+	}
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+
+	done := make(chan struct{})
+	go func() {
+		r := tagmapping.TagRewriter()
+		nodeFilter := tagmapping.NodeTagFilter()
+		wayFilter := tagmapping.WayTagFilter()
+		relFilter := tagmapping.RelationTagFilter()
+		for coords != nil || nodes != nil || ways != nil || relations != nil {
+			select {
+			case _, ok := <-coords:
+				if !ok {
+					coords = nil
+				}
+			case nds, ok := <-nodes:
+				if !ok {
+					nodes = nil
+				}
+				for i := range nds {
+					r.Rewrite(&nds[i].Tags)
+					nodeFilter.Filter(&nds[i].Tags)
+					if len(nds[i].Tags) > 0 {
+						node(&nds[i])
+					}
+				}
+			case ws, ok := <-ways:
+				if !ok {
+					ways = nil
+				}
+				for i := range ws {
+					r.Rewrite(&ws[i].Tags)
+					wayFilter.Filter(&ws[i].Tags)
+					way(&ws[i])
+				}
+			case rels, ok := <-relations:
+				if !ok {
+					relations = nil
+				}
+				for i := range rels {
+					r.Rewrite(&rels[i].Tags)
+					relFilter.Filter(&rels[i].Tags)
+					relation(&rels[i])
+				}
+			}
+		}
+		close(done)
+	}()
+
+	if err := parser.Parse(context.Background()); err != nil {
+		return errors.Wrap(err, "parsing input files")
+	}
+	<-done
+	return nil
+}
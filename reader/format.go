@@ -0,0 +1,167 @@
+package reader
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Format of an OSM input file.
+type Format int
+
+const (
+	UnknownFormat Format = iota
+	PBFFormat
+	XMLFormat
+	O5MFormat
+)
+
+func (f Format) String() string {
+	switch f {
+	case PBFFormat:
+		return "PBF"
+	case XMLFormat:
+		return "OSM XML"
+	case O5MFormat:
+		return "o5m"
+	default:
+		return "unknown"
+	}
+}
+
+// osmFile is an opened OSM file. Reads return the uncompressed content.
+type osmFile struct {
+	io.Reader
+	Format Format
+	// Header is set by openParser.
+	Header  *Header
+	buf     *bufio.Reader
+	closers []io.Closer
+}
+
+func (f *osmFile) Close() error {
+	var err error
+	for i := len(f.closers) - 1; i >= 0; i-- {
+		if cerr := f.closers[i].Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// openFile opens an OSM file and detects the format. Gzip and bzip2
+// compressed files are decompressed on the fly. The format is detected from
+// the content and from the file extension as a fallback.
+func openFile(filename string) (*osmFile, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	result := &osmFile{closers: []io.Closer{f}}
+
+	fr := bufio.NewReader(f)
+	var r io.Reader = fr
+	magic, err := fr.Peek(3)
+	if err != nil && err != io.EOF {
+		result.Close()
+		return nil, errors.Wrapf(err, "reading %q", filename)
+	}
+	switch {
+	case bytes.HasPrefix(magic, []byte{0x1f, 0x8b}):
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			result.Close()
+			return nil, errors.Wrapf(err, "reading gzip file %q", filename)
+		}
+		result.closers = append(result.closers, gz)
+		r = gz
+	case bytes.HasPrefix(magic, []byte("BZh")):
+		r = bzip2.NewReader(r)
+	}
+
+	// large enough to peek the complete PBF header block
+	br := bufio.NewReaderSize(r, 64*1024)
+	result.Reader = br
+	result.buf = br
+
+	head, err := br.Peek(64)
+	if err != nil && err != io.EOF {
+		result.Close()
+		return nil, errors.Wrapf(err, "reading %q", filename)
+	}
+	result.Format = detectFormat(head)
+	if result.Format == UnknownFormat {
+		result.Format = formatFromExtension(filename)
+	}
+	if result.Format == UnknownFormat {
+		result.Close()
+		return nil, errors.Errorf("unable to detect format of %q", filename)
+	}
+	return result, nil
+}
+
+// detectFormat returns the format of the uncompressed file content.
+func detectFormat(head []byte) Format {
+	// PBF files start with the size of the BlobHeader, followed by the
+	// BlobHeader with the type of the first block.
+	if len(head) > 4 && bytes.Contains(head[4:], []byte("OSMHeader")) {
+		return PBFFormat
+	}
+	// o5m files start with a reset, followed by the o5m2 header dataset.
+	if bytes.HasPrefix(head, []byte("\xff\xe0\x04o5m2")) {
+		return O5MFormat
+	}
+	head = bytes.TrimPrefix(head, []byte("\xef\xbb\xbf")) // UTF-8 BOM
+	head = bytes.TrimLeft(head, " \t\r\n")
+	if bytes.HasPrefix(head, []byte("<")) {
+		return XMLFormat
+	}
+	return UnknownFormat
+}
+
+func formatFromExtension(filename string) Format {
+	filename = strings.ToLower(filename)
+	filename = strings.TrimSuffix(filename, ".gz")
+	filename = strings.TrimSuffix(filename, ".bz2")
+	switch {
+	case strings.HasSuffix(filename, ".pbf"):
+		return PBFFormat
+	case strings.HasSuffix(filename, ".osm"), strings.HasSuffix(filename, ".xml"):
+		return XMLFormat
+	case strings.HasSuffix(filename, ".o5m"):
+		return O5MFormat
+	}
+	return UnknownFormat
+}
+
+// Header contains the file header information that is relevant for imports.
+type Header struct {
+	Format Format
+	// Time is the timestamp of the data. Time is zero if the file does not
+	// contain a timestamp.
+	Time time.Time
+	// LocationsOnWays is true for PBF files that contain the coordinates of
+	// all nodes in the ways.
+	LocationsOnWays bool
+	// FullHistory is true for PBF files with all versions of each element.
+	FullHistory bool
+}
+
+// ReadHeader returns the header of an OSM file.
+func ReadHeader(filename string) (*Header, error) {
+	f, err := openFile(filename)
+	if err != nil {
+		return nil, errors.Wrap(err, "opening OSM file")
+	}
+	defer f.Close()
+
+	_, header, err := newParser(f, parserConfig{})
+	return header, err
+}
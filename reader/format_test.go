@@ -0,0 +1,82 @@
+package reader
+
+import (
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDetectFormat(t *testing.T) {
+	for _, tt := range []struct {
+		head     string
+		expected Format
+	}{
+		{"\x00\x00\x00\x0d\x0a\x09OSMHeader\x18", PBFFormat},
+		{"<?xml version='1.0' encoding='UTF-8'?>\n<osm>", XMLFormat},
+		{"\xef\xbb\xbf\n  <osm version='0.6'>", XMLFormat},
+		{"\xff\xe0\x04o5m2\xdc", O5MFormat},
+		{"\xff\xe0\x04o5c2", UnknownFormat},
+		{"", UnknownFormat},
+		{"foo", UnknownFormat},
+	} {
+		if f := detectFormat([]byte(tt.head)); f != tt.expected {
+			t.Errorf("unexpected format for %q: %s", tt.head, f)
+		}
+	}
+}
+
+func TestFormatFromExtension(t *testing.T) {
+	for _, tt := range []struct {
+		filename string
+		expected Format
+	}{
+		{"germany.osm.pbf", PBFFormat},
+		{"fixture.osm", XMLFormat},
+		{"fixture.OSM.gz", XMLFormat},
+		{"fixture.osm.bz2", XMLFormat},
+		{"extract.o5m", O5MFormat},
+		{"changes.osc.gz", UnknownFormat},
+	} {
+		if f := formatFromExtension(tt.filename); f != tt.expected {
+			t.Errorf("unexpected format for %q: %s", tt.filename, f)
+		}
+	}
+}
+
+func TestReadHeader(t *testing.T) {
+	dir := t.TempDir()
+
+	xmlFile := filepath.Join(dir, "test.dat")
+	if err := os.WriteFile(xmlFile, []byte(`<osm timestamp="2020-01-02T03:04:05Z"></osm>`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	gzFile := filepath.Join(dir, "test.osm.gz")
+	f, err := os.Create(gzFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gz := gzip.NewWriter(f)
+	gz.Write([]byte(`<osm timestamp="2020-01-02T03:04:05Z"></osm>`))
+	gz.Close()
+	f.Close()
+
+	for _, tt := range []struct {
+		filename string
+		format   Format
+		unix     int64
+	}{
+		{"../vendor/github.com/omniscale/go-osm/parser/pbf/monaco-20150428.osm.pbf", PBFFormat, 1430166062},
+		{xmlFile, XMLFormat, 1577934245},
+		{gzFile, XMLFormat, 1577934245},
+	} {
+		header, err := ReadHeader(tt.filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if header.Format != tt.format || header.Time.Unix() != tt.unix {
+			t.Errorf("unexpected header for %s: %#v", tt.filename, header)
+		}
+	}
+}
@@ -1,13 +1,17 @@
 package reader
 
 import (
+	"bufio"
+	"bytes"
 	"context"
+	"io"
 	"math"
 	"os"
 	"runtime"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	osm "github.com/omniscale/go-osm"
 	"github.com/omniscale/go-osm/parser/pbf"
@@ -16,6 +20,10 @@ import (
 	"github.com/omniscale/imposm3/geom/limit"
 	"github.com/omniscale/imposm3/log"
 	"github.com/omniscale/imposm3/mapping"
+	"github.com/omniscale/imposm3/parser/locpbf"
+	"github.com/omniscale/imposm3/parser/merge"
+	"github.com/omniscale/imposm3/parser/o5m"
+	"github.com/omniscale/imposm3/parser/osmxml"
 	"github.com/omniscale/imposm3/stats"
 	"github.com/pkg/errors"
 )
@@ -50,12 +58,247 @@ func readersForCpus(cpus int) (int64, int64, int64, int64, int64) {
 	return int64(math.Ceil(cpuf * 0.75)), int64(math.Ceil(cpuf * 0.25)), int64(math.Ceil(cpuf * 0.25)), int64(math.Ceil(cpuf * 0.25)), int64(math.Ceil(cpuf * 0.25))
 }
 
-func ReadPbf(
-	filename string,
+// parserConfig contains the destinations and callbacks for the format
+// specific parsers.
+type parserConfig struct {
+	Coords          chan []osm.Node
+	Nodes           chan []osm.Node
+	Ways            chan []osm.Way
+	Relations       chan []osm.Relation
+	OnFirstWay      func()
+	OnFirstRelation func()
+	// IncludeMetadata enables parsing of versions, timestamps, etc.
+	IncludeMetadata bool
+	// Ordered requires that all elements are sent in the order of the file.
+	Ordered bool
+	// LocationsOnWays enables parsing of node locations from PBF files with
+	// locations on ways. The Nodes of the ways are filled in this case.
+	LocationsOnWays bool
+	// At is the snapshot time for full-history files.
+	At time.Time
+}
+
+type parser interface {
+	Parse(ctx context.Context) error
+}
+
+// newParser returns the parser for the format of the opened file.
+func newParser(f *osmFile, conf parserConfig) (parser, *Header, error) {
+	switch f.Format {
+	case PBFFormat:
+		header := peekPBFHeader(f)
+		if header != nil && (header.HistoricalInformation || header.LocationsOnWays && conf.LocationsOnWays) {
+			return newLocPBFParser(f, conf)
+		}
+		return newPBFParser(f, conf, header != nil && header.LocationsOnWays)
+	case XMLFormat:
+		p := osmxml.New(f, osmxml.Config{
+			Coords:          conf.Coords,
+			Nodes:           conf.Nodes,
+			Ways:            conf.Ways,
+			Relations:       conf.Relations,
+			OnFirstWay:      conf.OnFirstWay,
+			OnFirstRelation: conf.OnFirstRelation,
+			IncludeMetadata: conf.IncludeMetadata,
+		})
+		header, err := p.Header()
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "parsing OSM XML header")
+		}
+		return p, &Header{Format: f.Format, Time: header.Time}, nil
+	case O5MFormat:
+		p := o5m.New(f, o5m.Config{
+			Coords:          conf.Coords,
+			Nodes:           conf.Nodes,
+			Ways:            conf.Ways,
+			Relations:       conf.Relations,
+			OnFirstWay:      conf.OnFirstWay,
+			OnFirstRelation: conf.OnFirstRelation,
+			IncludeMetadata: conf.IncludeMetadata,
+		})
+		header, err := p.Header()
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "parsing o5m header")
+		}
+		return p, &Header{Format: f.Format, Time: header.Time}, nil
+	}
+	return nil, nil, errors.Errorf("unsupported format %s", f.Format)
+}
+
+// newLocPBFParser returns the parser for PBF files with locations on ways
+// and for full-history PBF files.
+func newLocPBFParser(f *osmFile, conf parserConfig) (parser, *Header, error) {
+	locConf := locpbf.Config{
+		Coords:          conf.Coords,
+		Nodes:           conf.Nodes,
+		Ways:            conf.Ways,
+		Relations:       conf.Relations,
+		OnFirstWay:      conf.OnFirstWay,
+		OnFirstRelation: conf.OnFirstRelation,
+		IncludeMetadata: conf.IncludeMetadata,
+		SkipLocations:   !conf.LocationsOnWays,
+		At:              conf.At,
+	}
+	if conf.Ordered {
+		locConf.Concurrency = 1
+	}
+	p := locpbf.New(f, locConf)
+	header, err := p.Header()
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "parsing PBF header")
+	}
+	return p, &Header{
+		Format:          f.Format,
+		Time:            header.Time,
+		LocationsOnWays: header.LocationsOnWays,
+		FullHistory:     header.HistoricalInformation,
+	}, nil
+}
+
+// newPBFParser returns the PBF parser from go-osm. locationsOnWays is only
+// passed on to the header, the parser ignores the locations.
+func newPBFParser(f *osmFile, conf parserConfig, locationsOnWays bool) (parser, *Header, error) {
+	pbfConf := pbf.Config{
+		Coords:          conf.Coords,
+		Nodes:           conf.Nodes,
+		Ways:            conf.Ways,
+		Relations:       conf.Relations,
+		OnFirstWay:      conf.OnFirstWay,
+		OnFirstRelation: conf.OnFirstRelation,
+		IncludeMetadata: conf.IncludeMetadata,
+	}
+	if conf.Ordered {
+		// blocks are parsed concurrently and might be sent out of order
+		pbfConf.Concurrency = 1
+	}
+	p := pbf.New(f, pbfConf)
+	header, err := p.Header()
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "parsing PBF header")
+	}
+	return p, &Header{Format: f.Format, Time: header.Time, LocationsOnWays: locationsOnWays}, nil
+}
+
+// peekPBFHeader returns the header block of the PBF file, without consuming
+// any input. Returns nil if the header can't be read.
+func peekPBFHeader(f *osmFile) *locpbf.Header {
+	if f.buf == nil {
+		return nil
+	}
+	head, err := f.buf.Peek(f.buf.Size())
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return nil
+	}
+	header, err := locpbf.New(bytes.NewReader(head), locpbf.Config{}).Header()
+	if err != nil {
+		// invalid headers are reported by the actual parser
+		return nil
+	}
+	return header
+}
+
+// openParser opens all files and returns a single parser for them. Multiple
+// files are merged, elements contained in more then one file are only passed
+// once (see parser/merge). The returned files need to be closed after
+// parsing.
+func openParser(filenames []string, conf parserConfig) (parser, []*osmFile, error) {
+	var files []*osmFile
+	closeAll := func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}
+
+	var m *merge.Parser
+	if len(filenames) > 1 {
+		m = merge.New(merge.Config{
+			Coords:          conf.Coords,
+			Nodes:           conf.Nodes,
+			Ways:            conf.Ways,
+			Relations:       conf.Relations,
+			OnFirstWay:      conf.OnFirstWay,
+			OnFirstRelation: conf.OnFirstRelation,
+			IncludeMetadata: conf.IncludeMetadata,
+		})
+	}
+
+	var p parser
+	for _, filename := range filenames {
+		f, err := openFile(filename)
+		if err != nil {
+			closeAll()
+			return nil, nil, errors.Wrapf(err, "opening OSM file %q", filename)
+		}
+		files = append(files, f)
+
+		fileConf := conf
+		var in *merge.Input
+		if m != nil {
+			// versions are required to select the latest element
+			in = m.NewInput()
+			fileConf = parserConfig{
+				Nodes:           in.Nodes,
+				Ways:            in.Ways,
+				Relations:       in.Relations,
+				OnFirstWay:      in.OnFirstWay,
+				OnFirstRelation: in.OnFirstRelation,
+				IncludeMetadata: true,
+				Ordered:         true,
+				LocationsOnWays: conf.LocationsOnWays,
+				At:              conf.At,
+			}
+		}
+		fp, header, err := newParser(f, fileConf)
+		if err != nil {
+			closeAll()
+			return nil, nil, errors.Wrapf(err, "reading %q", filename)
+		}
+		f.Header = header
+		if header.FullHistory && conf.At.IsZero() {
+			closeAll()
+			return nil, nil, errors.Errorf("%q is a full-history file, a snapshot time is required", filename)
+		}
+		if !header.FullHistory && !conf.At.IsZero() {
+			closeAll()
+			return nil, nil, errors.Errorf("%q is not a full-history PBF file, snapshot time not supported", filename)
+		}
+		if !header.Time.IsZero() {
+			log.Printf("[info] reading %s with data till %v", filename, header.Time.Local())
+		}
+		if in != nil {
+			in.SetSource(fp)
+		} else {
+			p = fp
+		}
+	}
+	if m != nil {
+		p = m
+	}
+	return p, files, nil
+}
+
+// Read reads one or more OSM files into the cache. Supports PBF, OSM XML and
+// o5m files. OSM XML and o5m files can be compressed with gzip or bzip2.
+// Multiple files are merged in a single pass. Elements contained in more then
+// one file are only cached once, the element with the highest version wins.
+// All files need to be sorted by type and ID in this case.
+//
+// Full-history PBF files are imported as a snapshot of the time at. Each
+// element is imported in the version that was current at this time. at needs
+// to be zero for all other files.
+//
+// Ways from PBF files with locations on ways are cached with their
+// coordinates. The coords cache is not filled in this case, unless the coords
+// are required for diff imports, for the limiter or for node members of
+// relations.
+func Read(
+	filenames []string,
 	cache *osmcache.OSMCache,
 	progress *stats.Statistics,
 	tagmapping *mapping.Mapping,
 	limiter *limit.Limiter,
+	diff bool,
+	at time.Time,
 ) error {
 	nodes := make(chan []osm.Node, 4)
 	coords := make(chan []osm.Node, 4)
@@ -67,11 +310,15 @@ func ReadPbf(
 		withLimiter = true
 	}
 
-	config := pbf.Config{
+	config := parserConfig{
 		Coords:    coords,
 		Nodes:     nodes,
 		Ways:      ways,
 		Relations: relations,
+		// ways with coords would not be updated by diffs of moved nodes
+		LocationsOnWays: !diff,
+		At:              at,
+		IncludeMetadata: tagmapping.UsesMetadata(),
 	}
 
 	// wait for all coords/nodes to be processed before continuing with
@@ -97,20 +344,24 @@ func ReadPbf(
 		waysSync.Wait()
 	}
 
-	f, err := os.Open(filename)
+	parser, files, err := openParser(filenames, config)
 	if err != nil {
-		return errors.Wrap(err, "opening PBF file")
+		return err
 	}
-	defer f.Close()
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
 
-	parser := pbf.New(f, config)
-	header, err := parser.Header()
-	if err != nil {
-		return errors.Wrap(err, "parsing PBF header")
+	cacheCoords := diff || withLimiter || needsCoords(tagmapping)
+	for _, f := range files {
+		if !f.Header.LocationsOnWays {
+			cacheCoords = true
+		}
 	}
-
-	if header.Time.Unix() != 0 {
-		log.Printf("[info] reading %s with data till %v", filename, header.Time.Local())
+	if !cacheCoords {
+		log.Printf("[info] ways contain node locations, not caching coords")
 	}
 
 	waitWriter := sync.WaitGroup{}
@@ -121,6 +372,7 @@ func ReadPbf(
 		go func() {
 			var skip, hit int
 
+			r := tagmapping.TagRewriter()
 			m := tagmapping.WayTagFilter()
 			for ws := range ways {
 				if ws == nil {
@@ -132,6 +384,7 @@ func ReadPbf(
 					continue
 				}
 				for i := range ws {
+					r.Rewrite(&ws[i].Tags)
 					m.Filter(&ws[i].Tags)
 					if withLimiter {
 						cached, err := cache.Coords.FirstRefIsCached(ws[i].Refs)
@@ -163,10 +416,12 @@ func ReadPbf(
 		go func() {
 			var skip, hit int
 
+			r := tagmapping.TagRewriter()
 			m := tagmapping.RelationTagFilter()
 			for rels := range relations {
 				numWithTags := 0
 				for i := range rels {
+					r.Rewrite(&rels[i].Tags)
 					m.Filter(&rels[i].Tags)
 					if len(rels[i].Tags) > 0 {
 						numWithTags++
@@ -222,7 +477,9 @@ func ReadPbf(
 						}
 					}
 				}
-				cache.Coords.PutCoords(nds)
+				if cacheCoords {
+					cache.Coords.PutCoords(nds)
+				}
 				progress.AddCoords(len(nds))
 			}
 			waitWriter.Done()
@@ -235,6 +492,7 @@ func ReadPbf(
 		go func() {
 			g := geos.NewGeos()
 			defer g.Finish()
+			r := tagmapping.TagRewriter()
 			m := tagmapping.NodeTagFilter()
 			for nds := range nodes {
 				if nds == nil {
@@ -247,6 +505,7 @@ func ReadPbf(
 				}
 				numWithTags := 0
 				for i := range nds {
+					r.Rewrite(&nds[i].Tags)
 					m.Filter(&nds[i].Tags)
 					if len(nds[i].Tags) > 0 {
 						numWithTags++
@@ -265,9 +524,25 @@ func ReadPbf(
 	}
 	ctx := context.Background()
 	if err := parser.Parse(ctx); err != nil {
-		return errors.Wrap(err, "parsing PBF")
+		if len(files) == 1 {
+			return errors.Wrapf(err, "parsing %s", files[0].Format)
+		}
+		return errors.Wrap(err, "parsing and merging input files")
 	}
 	waitWriter.Wait()
 
 	return nil
 }
+
+// needsCoords returns whether the mapping requires the coords cache, even if
+// all ways contain their node locations. Untagged node members of relations
+// are queried from the coords cache.
+func needsCoords(m *mapping.Mapping) bool {
+	for _, t := range m.Conf.Tables {
+		switch mapping.TableType(t.Type) {
+		case mapping.RelationMemberTable, mapping.GeometryTable:
+			return true
+		}
+	}
+	return false
+}
@@ -1,7 +1,11 @@
 package reader
 
 import (
+	"context"
 	"testing"
+	"time"
+
+	osm "github.com/omniscale/go-osm"
 )
 
 func TestReaderCpus(t *testing.T) {
@@ -30,3 +34,140 @@ func TestReaderCpus(t *testing.T) {
 		t.Fatal(p, r, w, n, c)
 	}
 }
+
+func TestOpenParserMerge(t *testing.T) {
+	count := func(filenames ...string) (int, int, int) {
+		coords := make(chan []osm.Node)
+		ways := make(chan []osm.Way)
+		rels := make(chan []osm.Relation)
+		p, files, err := openParser(filenames, parserConfig{
+			Coords:    coords,
+			Ways:      ways,
+			Relations: rels,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			for _, f := range files {
+				f.Close()
+			}
+		}()
+
+		var nc, nw, nr int
+		done := make(chan struct{})
+		go func() {
+			for coords != nil || ways != nil || rels != nil {
+				select {
+				case c, ok := <-coords:
+					if !ok {
+						coords = nil
+					}
+					nc += len(c)
+				case w, ok := <-ways:
+					if !ok {
+						ways = nil
+					}
+					nw += len(w)
+				case r, ok := <-rels:
+					if !ok {
+						rels = nil
+					}
+					nr += len(r)
+				}
+			}
+			close(done)
+		}()
+		if err := p.Parse(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		<-done
+		return nc, nw, nr
+	}
+
+	monaco := "../vendor/github.com/omniscale/go-osm/parser/pbf/monaco-20150428.osm.pbf"
+	nc, nw, nr := count(monaco)
+	if nc == 0 || nw == 0 || nr == 0 {
+		t.Fatal("no elements parsed", nc, nw, nr)
+	}
+	mc, mw, mr := count(monaco, monaco)
+	if mc != nc || mw != nw || mr != nr {
+		t.Errorf("duplicate elements after merge: %d/%d/%d != %d/%d/%d", mc, mw, mr, nc, nw, nr)
+	}
+}
+
+func TestOpenParserLocationsOnWays(t *testing.T) {
+	parseWay := func(conf parserConfig) (*Header, osm.Way) {
+		ways := make(chan []osm.Way, 1)
+		conf.Ways = ways
+		p, files, err := openParser([]string{"testdata/locations_on_ways.osm.pbf"}, conf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer files[0].Close()
+		if err := p.Parse(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		ws := <-ways
+		if len(ws) != 1 {
+			t.Fatal("expected single way", ws)
+		}
+		return files[0].Header, ws[0]
+	}
+
+	header, way := parseWay(parserConfig{LocationsOnWays: true})
+	if !header.LocationsOnWays {
+		t.Error("expected LocationsOnWays in header")
+	}
+	if len(way.Nodes) != 2 || way.Nodes[1].ID != 2 || way.Nodes[1].Lat != 51.5 {
+		t.Errorf("way without locations %#v", way)
+	}
+
+	// locations are ignored, e.g. for diff imports
+	header, way = parseWay(parserConfig{})
+	if !header.LocationsOnWays {
+		t.Error("expected LocationsOnWays in header")
+	}
+	if len(way.Nodes) != 0 || len(way.Refs) != 2 {
+		t.Errorf("unexpected way %#v", way)
+	}
+
+	header, err := ReadHeader("testdata/locations_on_ways.osm.pbf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !header.LocationsOnWays || header.Format != PBFFormat {
+		t.Errorf("unexpected header %#v", header)
+	}
+}
+
+func TestOpenParserHistory(t *testing.T) {
+	filename := "testdata/history.osh.pbf"
+	if _, _, err := openParser([]string{filename}, parserConfig{}); err == nil {
+		t.Error("expected error for full-history file without snapshot time")
+	}
+	monaco := "../vendor/github.com/omniscale/go-osm/parser/pbf/monaco-20150428.osm.pbf"
+	if _, _, err := openParser([]string{monaco}, parserConfig{At: time.Now()}); err == nil {
+		t.Error("expected error for snapshot time with regular file")
+	}
+
+	ways := make(chan []osm.Way, 2)
+	p, files, err := openParser([]string{filename}, parserConfig{
+		Ways: ways,
+		At:   time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer files[0].Close()
+	if !files[0].Header.FullHistory {
+		t.Error("expected FullHistory in header")
+	}
+	if err := p.Parse(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	ws := <-ways
+	if len(ws) != 1 || ws[0].Tags["highway"] != "primary" || ws[0].Metadata != nil {
+		t.Errorf("unexpected ways %#v", ws)
+	}
+}
@@ -0,0 +1,93 @@
+package reader
+
+import (
+	"context"
+	"time"
+
+	osm "github.com/omniscale/go-osm"
+	"github.com/omniscale/imposm3/cache/binary"
+	"github.com/omniscale/imposm3/mapping"
+	"github.com/pkg/errors"
+)
+
+// BuildTagDict reads all files and returns a tag dictionary with the most
+// common tags of the nodes, ways and relations. Only tags that are cached for
+// the tag mapping are counted. This requires an additional pass over all
+// files, before they are read with Read.
+func BuildTagDict(
+	filenames []string,
+	tagmapping *mapping.Mapping,
+	at time.Time,
+) (*binary.TagDict, error) {
+	coords := make(chan []osm.Node, 4)
+	nodes := make(chan []osm.Node, 4)
+	ways := make(chan []osm.Way, 4)
+	relations := make(chan []osm.Relation, 4)
+
+	parser, files, err := openParser(filenames, parserConfig{
+		Coords:    coords,
+		Nodes:     nodes,
+		Ways:      ways,
+		Relations: relations,
+		At:        at,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+
+	builder := binary.NewTagDictBuilder()
+	done := make(chan struct{})
+	go func() {
+		r := tagmapping.TagRewriter()
+		nodeFilter := tagmapping.NodeTagFilter()
+		wayFilter := tagmapping.WayTagFilter()
+		relFilter := tagmapping.RelationTagFilter()
+		for coords != nil || nodes != nil || ways != nil || relations != nil {
+			select {
+			case _, ok := <-coords:
+				if !ok {
+					coords = nil
+				}
+			case nds, ok := <-nodes:
+				if !ok {
+					nodes = nil
+				}
+				for i := range nds {
+					r.Rewrite(&nds[i].Tags)
+					nodeFilter.Filter(&nds[i].Tags)
+					builder.Add(nds[i].Tags)
+				}
+			case ws, ok := <-ways:
+				if !ok {
+					ways = nil
+				}
+				for i := range ws {
+					r.Rewrite(&ws[i].Tags)
+					wayFilter.Filter(&ws[i].Tags)
+					builder.Add(ws[i].Tags)
+				}
+			case rels, ok := <-relations:
+				if !ok {
+					relations = nil
+				}
+				for i := range rels {
+					r.Rewrite(&rels[i].Tags)
+					relFilter.Filter(&rels[i].Tags)
+					builder.Add(rels[i].Tags)
+				}
+			}
+		}
+		close(done)
+	}()
+
+	if err := parser.Parse(context.Background()); err != nil {
+		return nil, errors.Wrap(err, "parsing input files for tag dictionary")
+	}
+	<-done
+	return builder.TagDict(), nil
+}
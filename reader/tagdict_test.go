@@ -0,0 +1,49 @@
+package reader
+
+import (
+	"testing"
+	"time"
+
+	"github.com/omniscale/imposm3/mapping"
+)
+
+func TestBuildTagDict(t *testing.T) {
+	monaco := "../vendor/github.com/omniscale/go-osm/parser/pbf/monaco-20150428.osm.pbf"
+
+	for _, test := range []struct {
+		mapping string
+		empty   bool
+	}{
+		// only highway and name are cached, both are in the static table
+		{`
+tables:
+  roads:
+    type: linestring
+    columns:
+      - {name: name, type: string, key: name}
+    mapping:
+      highway: [__any__]
+`, true},
+		{`
+tags:
+  load_all: true
+tables:
+  roads:
+    type: linestring
+    mapping:
+      highway: [__any__]
+`, false},
+	} {
+		m, err := mapping.New([]byte(test.mapping))
+		if err != nil {
+			t.Fatal(err)
+		}
+		dict, err := BuildTagDict([]string{monaco}, m, time.Time{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if empty := dict.NumTags() == 0 && dict.NumKeys() == 0; empty != test.empty {
+			t.Errorf("unexpected dictionary with %d tags and %d keys", dict.NumTags(), dict.NumKeys())
+		}
+	}
+}
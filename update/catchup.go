@@ -0,0 +1,202 @@
+package update
+
+import (
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/omniscale/go-osm/replication"
+	"github.com/omniscale/imposm3/log"
+	updatestate "github.com/omniscale/imposm3/update/state"
+	"github.com/pkg/errors"
+)
+
+type replicationLevel struct {
+	// name of the level in the replication URL
+	name     string
+	interval time.Duration
+}
+
+var replicationLevels = []replicationLevel{
+	{"minute", time.Minute},
+	{"hour", time.Hour},
+	{"day", 24 * time.Hour},
+}
+
+var _ replication.Source = &catchupSource{}
+
+// catchupSource downloads replication files and switches to coarser
+// replication intervals (hour, day) from the same server if the imported
+// data is far behind. It switches back to the configured interval once it
+// catched up.
+//
+// The replication URL needs to end with the name of the interval (e.g.
+// https://planet.openstreetmap.org/replication/minute/). Files of coarser
+// intervals are stored in sub-directories of the diffDir, as the sequence
+// numbers are different for each interval.
+type catchupSource struct {
+	diffDir string
+	// replication URL without the name of the interval
+	baseURL string
+	// levels[0] is the configured replication interval
+	levels  []replicationLevel
+	current int
+
+	src       replication.Source
+	sequences chan replication.Sequence
+	stop      chan struct{}
+}
+
+// splitReplicationURL returns the URL without the interval name and all
+// available replication levels, starting with the level of the interval.
+// The URL can be of any level (e.g. .../hour/ for a minutely interval).
+func splitReplicationURL(url string, interval time.Duration) (string, []replicationLevel, error) {
+	trimmed := strings.TrimSuffix(url, "/")
+	idx := strings.LastIndex(trimmed, "/")
+	base, name := trimmed[:idx+1], trimmed[idx+1:]
+
+	found := false
+	for _, l := range replicationLevels {
+		if l.name == name {
+			found = true
+		}
+	}
+	if !found {
+		return "", nil, errors.Errorf("catch-up requires a replication URL ending with minute/, hour/ or day/, got %q", url)
+	}
+	for i, l := range replicationLevels {
+		if l.interval == interval {
+			return base, replicationLevels[i:], nil
+		}
+	}
+	return "", nil, errors.Errorf("catch-up requires a replication interval of 1m, 1h or 24h, got %s", interval)
+}
+
+// newCatchupSource starts to download replication files with the sequence
+// after lastSeq. lastURL is the URL of the last imported sequence and it
+// can be any level of url.
+func newCatchupSource(diffDir, url string, interval time.Duration, lastURL string, lastSeq int) (*catchupSource, error) {
+	base, levels, err := splitReplicationURL(url, interval)
+	if err != nil {
+		return nil, err
+	}
+	c := &catchupSource{
+		diffDir:   diffDir,
+		baseURL:   base,
+		levels:    levels,
+		sequences: make(chan replication.Sequence),
+		stop:      make(chan struct{}),
+	}
+	for i := range levels {
+		if sameURL(lastURL, c.levelURL(i)) {
+			c.current = i
+		}
+	}
+	if c.current != 0 {
+		log.Printf("[info] Continuing catch-up with %s replication", c.levels[c.current].name)
+	}
+	c.src = c.newDownloader(c.current, lastSeq+1)
+	go c.run()
+	return c, nil
+}
+
+// sameURL returns true if both replication URLs are equal, ignoring
+// trailing slashes.
+func sameURL(a, b string) bool {
+	return strings.TrimSuffix(a, "/") == strings.TrimSuffix(b, "/")
+}
+
+func (c *catchupSource) levelURL(level int) string {
+	return c.baseURL + c.levels[level].name + "/"
+}
+
+func (c *catchupSource) levelDir(level int) string {
+	if level == 0 {
+		return c.diffDir
+	}
+	return filepath.Join(c.diffDir, c.levels[level].name)
+}
+
+func (c *catchupSource) newDownloader(level, seq int) replication.Source {
+	return newDownloader(c.levelDir(level), c.levelURL(level), seq, c.levels[level].interval)
+}
+
+// URL returns the replication URL of the sequence.
+func (c *catchupSource) URL(seq replication.Sequence) string {
+	for i := len(c.levels) - 1; i > 0; i-- {
+		if strings.HasPrefix(seq.Filename, c.levelDir(i)+string(filepath.Separator)) {
+			return c.levelURL(i)
+		}
+	}
+	return c.levelURL(0)
+}
+
+// levelFor returns the coarsest level that is at least two intervals behind.
+func (c *catchupSource) levelFor(behind time.Duration) int {
+	for i := len(c.levels) - 1; i > 0; i-- {
+		if behind >= 2*c.levels[i].interval {
+			return i
+		}
+	}
+	return 0
+}
+
+func (c *catchupSource) Sequences() <-chan replication.Sequence {
+	return c.sequences
+}
+
+func (c *catchupSource) Stop() {
+	close(c.stop)
+}
+
+func (c *catchupSource) run() {
+	defer close(c.sequences)
+	for {
+		select {
+		case <-c.stop:
+			c.src.Stop()
+			return
+		case seq, ok := <-c.src.Sequences():
+			if !ok {
+				return
+			}
+			select {
+			case c.sequences <- seq:
+			case <-c.stop:
+				c.src.Stop()
+				return
+			}
+			if seq.Error != nil || seq.Filename == "" {
+				continue
+			}
+			if level := c.levelFor(time.Since(seq.Time)); level != c.current {
+				c.switchLevel(level, seq.Time)
+			}
+		}
+	}
+}
+
+// switchLevel starts downloading from another level, with the first sequence
+// that contains changes after t. Sequences of different levels overlap, as
+// the estimated sequence can be older then t.
+func (c *catchupSource) switchLevel(level int, t time.Time) {
+	l := c.levels[level]
+	seq, err := updatestate.EstimateSequence(c.levelURL(level), l.interval, t)
+	if err != nil {
+		log.Printf("[warn] Unable to switch to %s replication: %v", l.name, err)
+		return
+	}
+	log.Printf("[info] Switching to %s replication with #%d (%s behind)",
+		l.name, seq+1, time.Since(t).Truncate(time.Second))
+
+	old := c.src
+	old.Stop()
+	// discard sequences that were already downloaded
+	go func() {
+		for range old.Sequences() {
+		}
+	}()
+
+	c.current = level
+	c.src = c.newDownloader(level, seq+1)
+}
@@ -0,0 +1,152 @@
+package update
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/omniscale/go-osm/replication"
+	diffstate "github.com/omniscale/go-osm/state"
+)
+
+func TestSplitReplicationURL(t *testing.T) {
+	for _, tc := range []struct {
+		url      string
+		interval time.Duration
+		base     string
+		levels   int
+		err      bool
+	}{
+		{"https://planet.openstreetmap.org/replication/minute/", time.Minute, "https://planet.openstreetmap.org/replication/", 3, false},
+		{"https://planet.openstreetmap.org/replication/hour", time.Hour, "https://planet.openstreetmap.org/replication/", 2, false},
+		{"https://planet.openstreetmap.org/replication/hour/", time.Minute, "https://planet.openstreetmap.org/replication/", 3, false},
+		{"file:///srv/replication/day/", 24 * time.Hour, "file:///srv/replication/", 1, false},
+		{"https://download.geofabrik.de/europe/germany-updates/", time.Hour * 24, "", 0, true},
+		{"https://planet.openstreetmap.org/replication/minute/", 2 * time.Minute, "", 0, true},
+	} {
+		base, levels, err := splitReplicationURL(tc.url, tc.interval)
+		if tc.err {
+			if err == nil {
+				t.Errorf("expected error for %q", tc.url)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("unexpected error for %q: %v", tc.url, err)
+			continue
+		}
+		if base != tc.base || len(levels) != tc.levels || levels[0].interval != tc.interval {
+			t.Errorf("unexpected result for %q: %q %v", tc.url, base, levels)
+		}
+	}
+}
+
+func TestCheckStateURL(t *testing.T) {
+	minute := "https://planet.openstreetmap.org/replication/minute/"
+	for _, tc := range []struct {
+		stateURL string
+		ok       bool
+	}{
+		{"", true},
+		{minute, true},
+		{"https://planet.openstreetmap.org/replication/minute", true},
+		{"https://planet.openstreetmap.org/replication/hour/", false},
+	} {
+		if err := checkStateURL(tc.stateURL, minute); (err == nil) != tc.ok {
+			t.Errorf("checkStateURL(%q): unexpected error %v", tc.stateURL, err)
+		}
+	}
+}
+
+func TestCatchupSource(t *testing.T) {
+	src, err := ioutil.TempDir("", "imposm3-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(src)
+	diffDir, err := ioutil.TempDir("", "imposm3-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(diffDir)
+
+	now := time.Now().UTC().Truncate(time.Minute)
+	addSeq := func(level string, seq int, tm time.Time) {
+		base := filepath.Join(src, level,
+			fmt.Sprintf("%03d", seq/1000000), fmt.Sprintf("%03d", seq/1000%1000), fmt.Sprintf("%03d", seq%1000))
+		if err := os.MkdirAll(filepath.Dir(base), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(base+".osc.gz", []byte("diff"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := diffstate.WriteFile(base+".state.txt", &diffstate.DiffState{Sequence: seq, Time: tm}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	minuteTime := func(seq int) time.Time { return now.Add(-time.Duration(10000-seq+1) * time.Minute) }
+	hourTime := func(seq int) time.Time { return now.Add(-30*time.Minute - time.Duration(500-seq)*time.Hour) }
+
+	// minutely sequence 10000 is one minute and hourly sequence 500 is 30
+	// minutes behind
+	addSeq("minute", 9001, minuteTime(9001))
+	for seq := 9912; seq <= 10000; seq++ {
+		addSeq("minute", seq, minuteTime(seq))
+	}
+	for seq := 484; seq <= 500; seq++ {
+		addSeq("hour", seq, hourTime(seq))
+	}
+	for level, seq := range map[string]int{"minute": 10000, "hour": 500} {
+		state := &diffstate.DiffState{Sequence: seq, Time: minuteTime(seq)}
+		if level == "hour" {
+			state.Time = hourTime(seq)
+		}
+		if err := diffstate.WriteFile(filepath.Join(src, level, "state.txt"), state); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	url := "file://" + filepath.ToSlash(src) + "/minute/"
+	c, err := newCatchupSource(diffDir, url, time.Minute, url, 9000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop()
+
+	next := func() replication.Sequence {
+		select {
+		case seq := <-c.Sequences():
+			if seq.Error != nil {
+				t.Fatal(seq.Error)
+			}
+			return seq
+		case <-time.After(10 * time.Second):
+			t.Fatal("timeout while waiting for sequence")
+		}
+		return replication.Sequence{}
+	}
+
+	check := func(seq replication.Sequence, level string, want int) {
+		t.Helper()
+		if seq.Sequence != want {
+			t.Fatalf("got sequence %d, want %d", seq.Sequence, want)
+		}
+		if got := c.URL(seq); got != "file://"+filepath.ToSlash(src)+"/"+level+"/" {
+			t.Errorf("unexpected URL %q for %d", got, want)
+		}
+	}
+
+	// 16h behind, switch to hourly
+	check(next(), "minute", 9001)
+	// 9001 is 969 minutes behind hourly 500, ceil(969/60) = 17 hours
+	for seq := 484; seq <= 499; seq++ {
+		check(next(), "hour", seq)
+	}
+	// 90 minutes behind, switch back to minutely
+	for seq := 9912; seq <= 10000; seq++ {
+		check(next(), "minute", seq)
+	}
+}
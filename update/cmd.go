@@ -1,12 +1,13 @@
 package update
 
 import (
+	"context"
 	"fmt"
+	"net/http"
 	"os"
 	"os/signal"
 	"path/filepath"
 	"strconv"
-	"strings"
 	"syscall"
 	"time"
 
@@ -15,32 +16,49 @@ import (
 	"github.com/omniscale/go-osm/state"
 	diffstate "github.com/omniscale/go-osm/state"
 	"github.com/omniscale/imposm3/cache"
+	"github.com/omniscale/imposm3/cache/query"
 	"github.com/omniscale/imposm3/config"
 	"github.com/omniscale/imposm3/database"
 	"github.com/omniscale/imposm3/expire"
 	"github.com/omniscale/imposm3/geom/limit"
 	"github.com/omniscale/imposm3/log"
 	"github.com/omniscale/imposm3/mapping"
+	"github.com/omniscale/imposm3/update/filesource"
+	updatestate "github.com/omniscale/imposm3/update/state"
 	"github.com/pkg/errors"
 )
 
-const LastStateFilename = "last.state.txt"
+const LastStateFilename = updatestate.LastStateFilename
 
 func Diff(baseOpts config.Base, files []string) {
 	if baseOpts.Quiet {
 		log.SetMinLevel(log.LInfo)
 	}
 
-	nextSeq, err := sequenceFromFiles(
-		files,
-		filepath.Join(baseOpts.DiffDir, LastStateFilename),
-		baseOpts.ForceDiffImport,
-	)
-	if err != nil {
-		log.Fatalf("[error] Checking diff files: %v", err)
+	lastStateFile := filepath.Join(baseOpts.DiffDir, LastStateFilename)
+
+	var nextSeq <-chan replication.Sequence
+	if baseOpts.WatchDir != "" {
+		if len(files) != 0 {
+			log.Fatal("[fatal] -watch does not accept change files as arguments")
+		}
+		var stop func()
+		var err error
+		nextSeq, stop, err = watchSequences(baseOpts.WatchDir, lastStateFile, baseOpts.ForceDiffImport)
+		if err != nil {
+			log.Fatalf("[error] Watching diff files: %v", err)
+		}
+		defer stop()
+		log.Printf("[info] Watching %s for new change files", baseOpts.WatchDir)
+	} else {
+		var err error
+		nextSeq, err = sequenceFromFiles(files, lastStateFile, baseOpts.ForceDiffImport)
+		if err != nil {
+			log.Fatalf("[error] Checking diff files: %v", err)
+		}
 	}
 
-	if err := diffImportLoop(baseOpts, nextSeq); err != nil {
+	if err := diffImportLoop(baseOpts, nextSeq, nil); err != nil {
 		log.Fatalf("[error] Importing diffs: %v", err)
 	}
 }
@@ -64,21 +82,63 @@ func Run(baseOpts config.Base) {
 	}
 	log.Printf("[info] Starting replication from %s with %s interval", replicationURL, baseOpts.ReplicationInterval)
 
-	downloader := diff.NewDownloader(
-		baseOpts.DiffDir,
-		replicationURL,
-		s.Sequence+1,
-		baseOpts.ReplicationInterval,
-	)
+	var downloader replication.Source
+	var seqURL func(replication.Sequence) string
+	if baseOpts.ReplicationCatchup {
+		catchup, err := newCatchupSource(
+			baseOpts.DiffDir,
+			replicationURL,
+			baseOpts.ReplicationInterval,
+			s.URL,
+			s.Sequence,
+		)
+		if err != nil {
+			log.Fatal("[fatal] Unable to start catch-up replication: ", err)
+		}
+		downloader = catchup
+		seqURL = catchup.URL
+	} else {
+		if err := checkStateURL(s.URL, replicationURL); err != nil {
+			log.Fatal("[fatal] ", err)
+		}
+		downloader = newDownloader(
+			baseOpts.DiffDir,
+			replicationURL,
+			s.Sequence+1,
+			baseOpts.ReplicationInterval,
+		)
+	}
 	nextSeq := downloader.Sequences()
 	defer downloader.Stop()
 
-	if err := diffImportLoop(baseOpts, nextSeq); err != nil {
+	if err := diffImportLoop(baseOpts, nextSeq, seqURL); err != nil {
 		log.Fatalf("[error] Importing diffs: %v", err)
 	}
 }
 
-func diffImportLoop(baseOpts config.Base, nextSeq <-chan replication.Sequence) error {
+// checkStateURL returns an error if last.state.txt is from another
+// replication than replicationURL, e.g. from the hour replication of a
+// catch-up. The sequences of different replications do not match.
+func checkStateURL(stateURL, replicationURL string) error {
+	if stateURL == "" || sameURL(stateURL, replicationURL) {
+		return nil
+	}
+	return errors.Errorf("last.state.txt is from %s and not from %s, "+
+		"rerun with -catchup to continue with %s", stateURL, replicationURL, replicationURL)
+}
+
+// newDownloader returns a downloader for the replication files. Files are
+// copied from the local directory for file:// URLs.
+func newDownloader(diffDir, url string, seq int, interval time.Duration) replication.Source {
+	if filesource.IsFileURL(url) {
+		return filesource.NewDownloader(diffDir, url, seq, interval)
+	}
+	return diff.NewDownloader(diffDir, url, seq, interval)
+}
+
+// diffImportLoop imports all sequences from nextSeq. seqURL returns the
+// replication URL for last.state.txt, the URL is kept if seqURL is nil.
+func diffImportLoop(baseOpts config.Base, nextSeq <-chan replication.Sequence, seqURL func(replication.Sequence) string) error {
 	var geometryLimiter *limit.Limiter
 	if baseOpts.LimitTo != "" {
 		var err error
@@ -94,6 +154,12 @@ func diffImportLoop(baseOpts config.Base, nextSeq <-chan replication.Sequence) e
 		logReadLimitTo()
 	}
 
+	lock, err := cache.LockDir(baseOpts.CacheDir)
+	if err != nil {
+		return errors.Wrapf(err, "locking cache")
+	}
+	defer lock.Unlock()
+
 	osmCache := cache.NewOSMCache(baseOpts.CacheDir)
 	if err := osmCache.Open(); err != nil {
 		return errors.Wrapf(err, "opening OSM cache")
@@ -106,6 +172,18 @@ func diffImportLoop(baseOpts config.Base, nextSeq <-chan replication.Sequence) e
 	}
 	defer diffCache.Close()
 
+	if baseOpts.HTTPQuery != "" {
+		srv := &http.Server{Addr: baseOpts.HTTPQuery, Handler: query.NewHandler(osmCache, diffCache)}
+		go func() {
+			if err := srv.ListenAndServe(); err != http.ErrServerClosed {
+				log.Println("[error] Cache query server:", err)
+			}
+		}()
+		// wait for running queries before the caches are closed
+		defer srv.Shutdown(context.Background())
+		log.Printf("[info] Serving cache queries on %s", baseOpts.HTTPQuery)
+	}
+
 	sigc := make(chan os.Signal, 1)
 	signal.Notify(sigc, syscall.SIGTERM, syscall.SIGINT, syscall.SIGHUP)
 
@@ -147,6 +225,7 @@ func diffImportLoop(baseOpts config.Base, nextSeq <-chan replication.Sequence) e
 		geometryLimiter: geometryLimiter,
 		tagmapping:      tagmapping,
 		tilelist:        tilelist,
+		seqURL:          seqURL,
 
 		sigc: sigc,
 	}
@@ -170,6 +249,9 @@ type updater struct {
 	commitEachDiff bool
 
 	lastDiff replication.Sequence
+	// lastState is the last imported sequence with a state, zero if
+	// lastDiff and all previous diffs were imported without a state
+	lastState replication.Sequence
 
 	db              database.FullDB
 	osmCache        *cache.OSMCache
@@ -177,6 +259,7 @@ type updater struct {
 	geometryLimiter *limit.Limiter
 	tagmapping      *mapping.Mapping
 	tilelist        *expire.TileList
+	seqURL          func(replication.Sequence) string
 
 	sigc chan os.Signal
 }
@@ -189,6 +272,7 @@ func (u *updater) flush() error {
 	defer func() {
 		// reset to prevent commit without import
 		u.lastDiff = replication.Sequence{}
+		u.lastState = replication.Sequence{}
 	}()
 
 	if u.tilelist != nil {
@@ -208,8 +292,16 @@ func (u *updater) flush() error {
 	}
 	u.diffCache.Flush()
 
+	if u.lastState.Sequence == 0 {
+		// change files without a state file don't update the last state
+		return nil
+	}
 	var lastStateFile = filepath.Join(u.baseOpts.DiffDir, LastStateFilename)
-	if err := markImported(u.lastDiff, lastStateFile); err != nil {
+	var url string
+	if u.seqURL != nil {
+		url = u.seqURL(u.lastState)
+	}
+	if err := markImported(u.lastState, lastStateFile, url); err != nil {
 		log.Println("[error] Unable to write last state:", err)
 	}
 
@@ -244,7 +336,10 @@ func (u *updater) importLoop(nextSeq <-chan replication.Sequence) error {
 				return nil
 			}
 
-			u.lastDiff = seq // for last.state.txt update in Flush
+			u.lastDiff = seq
+			if seq.Sequence != 0 {
+				u.lastState = seq // for last.state.txt update in Flush
+			}
 			if err := u.importDiff(seq); err != nil {
 				return err
 			}
@@ -329,8 +424,7 @@ func sequenceFromFiles(files []string, lastStateFile string, force bool) (<-chan
 
 	for i, oscFile := range files {
 		var state *diffstate.DiffState
-		if strings.HasSuffix(oscFile, ".osc.gz") {
-			stateFile := oscFile[:len(oscFile)-len(".osc.gz")] + ".state.txt"
+		if stateFile := stateFilename(oscFile); stateFile != "" {
 			var err error
 			state, err = diffstate.ParseFile(stateFile)
 			if err != nil && !os.IsNotExist(err) {
@@ -366,17 +460,22 @@ func sequenceFromFiles(files []string, lastStateFile string, force bool) (<-chan
 	return c, nil
 }
 
-func markImported(seq replication.Sequence, lastStateFile string) error {
+// markImported writes the sequence to lastStateFile. The URL of the
+// previous state is kept if url is empty.
+func markImported(seq replication.Sequence, lastStateFile string, url string) error {
 	state := &diffstate.DiffState{
 		Time:     seq.Time,
 		Sequence: seq.Sequence,
+		URL:      url,
 	}
-	lastState, err := diffstate.ParseFile(lastStateFile)
-	if err == nil {
-		state.URL = lastState.URL
+	if url == "" {
+		lastState, err := diffstate.ParseFile(lastStateFile)
+		if err == nil {
+			state.URL = lastState.URL
+		}
 	}
 
-	err = diffstate.WriteFile(lastStateFile, state)
+	err := diffstate.WriteFile(lastStateFile, state)
 	if err != nil {
 		return errors.Wrapf(err, "unable to write last state")
 	}
@@ -0,0 +1,246 @@
+// Package filesource provides a replication source for OSM diff files from a
+// local directory tree.
+//
+// The directory tree needs to have the same layout as the replication
+// directories from planet.openstreetmap.org (e.g. 000/123/456.osc.gz and
+// 000/123/456.state.txt, with the current state.txt in the root). This is
+// useful for networks without internet access, where a separate job mirrors
+// the replication files.
+package filesource
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/omniscale/go-osm/replication"
+	"github.com/omniscale/go-osm/state"
+)
+
+// IsFileURL returns true if url is a file:// URL.
+func IsFileURL(url string) bool {
+	return strings.HasPrefix(url, "file://")
+}
+
+// Dir returns the local directory of a file:// URL.
+func Dir(url string) string {
+	return filepath.FromSlash(strings.TrimPrefix(url, "file://"))
+}
+
+// CurrentState returns the state.txt from the root of the directory tree.
+func CurrentState(url string) (*state.DiffState, error) {
+	return state.ParseFile(filepath.Join(Dir(url), "state.txt"))
+}
+
+type notAvailable struct {
+	filename string
+}
+
+func (e *notAvailable) Error() string {
+	return fmt.Sprintf("File not available: %s", e.filename)
+}
+
+// N = AAA*1000000 + BBB*1000 + CCC
+func seqPath(seq int) string {
+	c := seq % 1000
+	b := seq / 1000 % 1000
+	a := seq / 1000000
+
+	return filepath.Join(fmt.Sprintf("%03d", a), fmt.Sprintf("%03d", b), fmt.Sprintf("%03d", c))
+}
+
+const (
+	fileExt  = ".osc.gz"
+	stateExt = ".state.txt"
+)
+
+var _ replication.Source = &downloader{}
+
+type downloader struct {
+	src          string
+	dest         string
+	lastSequence int
+	interval     time.Duration
+	errWaittime  time.Duration
+	naWaittime   time.Duration
+	sequences    chan replication.Sequence
+	ctx          context.Context
+	cancel       context.CancelFunc
+}
+
+// NewDownloader starts a background downloader for OSM diff files (.osc.gz)
+// from a local directory tree. It behaves like the downloader from
+// github.com/omniscale/go-osm/replication/diff, but it copies the files from
+// the directory of the file:// url instead of fetching them via HTTP. Diffs
+// are copied to diffDir. seq is the first sequence that should be copied.
+// Diffs are copied as fast as possible until the first diff is missing.
+// After that, it uses the interval to estimate when a new diff should
+// appear.
+func NewDownloader(diffDir, url string, seq int, interval time.Duration) replication.Source {
+	dl := newDownloader(diffDir, url, seq, interval)
+	go dl.fetchNextLoop()
+	return dl
+}
+
+func newDownloader(diffDir, url string, seq int, interval time.Duration) *downloader {
+	var naWaittime time.Duration
+	switch {
+	case interval >= 24*time.Hour:
+		naWaittime = 5 * time.Minute
+	case interval >= time.Hour:
+		naWaittime = 60 * time.Second
+	default:
+		naWaittime = 10 * time.Second
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &downloader{
+		src:          Dir(url),
+		dest:         diffDir,
+		lastSequence: seq - 1, // we want to start with seq, so lastSequence is -1
+		interval:     interval,
+		errWaittime:  60 * time.Second,
+		naWaittime:   naWaittime,
+		sequences:    make(chan replication.Sequence, 4),
+		ctx:          ctx,
+		cancel:       cancel,
+	}
+}
+
+func (d *downloader) Sequences() <-chan replication.Sequence {
+	return d.sequences
+}
+
+func (d *downloader) Stop() {
+	d.cancel()
+}
+
+// copy copies the file for seq into the diffDir. Files are written to a
+// temporary file first, so that other processes never see partial files.
+func (d *downloader) copy(seq int, ext string) error {
+	dest := filepath.Join(d.dest, seqPath(seq)+ext)
+	src := filepath.Join(d.src, seqPath(seq)+ext)
+
+	if _, err := os.Stat(dest); err == nil {
+		return nil
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &notAvailable{src}
+		}
+		return err
+	}
+	defer in.Close()
+
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+
+	tmpDest := fmt.Sprintf("%s~%d", dest, os.Getpid())
+	out, err := os.Create(tmpDest)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	if _, err := io.Copy(out, in); err != nil {
+		os.Remove(tmpDest)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(tmpDest)
+		return err
+	}
+	return os.Rename(tmpDest, dest)
+}
+
+// copyTillSuccess tries to copy the file till it is available, returns true
+// if available on first try.
+func (d *downloader) copyTillSuccess(seq int, ext string) bool {
+	for tries := 0; ; tries++ {
+		if d.ctx.Err() != nil {
+			return false
+		}
+		err := d.copy(seq, ext)
+		if err == nil {
+			return tries == 0
+		}
+		if _, ok := err.(*notAvailable); ok {
+			d.wait(d.naWaittime)
+		} else {
+			select {
+			case d.sequences <- replication.Sequence{Sequence: seq, Error: err}:
+			case <-d.ctx.Done():
+				return false
+			}
+			d.wait(d.errWaittime)
+		}
+	}
+}
+
+func (d *downloader) wait(duration time.Duration) {
+	select {
+	case <-d.ctx.Done():
+	case <-time.After(duration):
+	}
+}
+
+func stateTime(filename string) (time.Time, error) {
+	s, err := state.ParseFile(filename)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return s.Time, nil
+}
+
+func (d *downloader) fetchNextLoop() {
+	defer close(d.sequences)
+
+	lastTime, err := stateTime(filepath.Join(d.dest, seqPath(d.lastSequence)+stateExt))
+	for {
+		nextSeq := d.lastSequence + 1
+		if err == nil {
+			nextDiffTime := lastTime.Add(d.interval)
+			if nextDiffTime.After(time.Now()) {
+				// we catched up and the next diff file is in the future.
+				// wait till last diff time + interval, before checking next
+				d.wait(time.Until(lastTime.Add(d.interval + 2*time.Second)))
+			}
+		}
+		// the state file is written after the diff file, copy it first to
+		// make sure the diff is complete
+		d.copyTillSuccess(nextSeq, stateExt)
+		noWait := d.copyTillSuccess(nextSeq, fileExt)
+		if d.ctx.Err() != nil {
+			return
+		}
+		d.lastSequence = nextSeq
+		base := filepath.Join(d.dest, seqPath(d.lastSequence))
+		lastTime, err = stateTime(base + stateExt)
+
+		// assume it's the latest if we had to wait for this seq
+		latest := true
+		if noWait {
+			_, statErr := os.Stat(filepath.Join(d.src, seqPath(nextSeq+1)+stateExt))
+			latest = statErr != nil
+		}
+
+		select {
+		case d.sequences <- replication.Sequence{
+			Sequence:      d.lastSequence,
+			Filename:      base + fileExt,
+			StateFilename: base + stateExt,
+			Time:          lastTime,
+			Latest:        latest,
+		}:
+		case <-d.ctx.Done():
+			return
+		}
+	}
+}
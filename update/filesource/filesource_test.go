@@ -0,0 +1,117 @@
+package filesource
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/omniscale/go-osm/replication"
+	"github.com/omniscale/go-osm/state"
+)
+
+func TestSeqPath(t *testing.T) {
+	for seq, want := range map[int]string{
+		0:       "000/000/000",
+		123:     "000/000/123",
+		1234567: "001/234/567",
+	} {
+		if got := seqPath(seq); got != filepath.FromSlash(want) {
+			t.Errorf("seqPath(%d) = %q, want %q", seq, got, want)
+		}
+	}
+}
+
+func TestDownloader(t *testing.T) {
+	src, err := ioutil.TempDir("", "imposm3-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(src)
+	dest, err := ioutil.TempDir("", "imposm3-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dest)
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	addSeq := func(seq int) {
+		base := filepath.Join(src, seqPath(seq))
+		if err := os.MkdirAll(filepath.Dir(base), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(base+fileExt, []byte("diff"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		s := &state.DiffState{Sequence: seq, Time: start.Add(time.Duration(seq) * time.Minute)}
+		if err := state.WriteFile(base+stateExt, s); err != nil {
+			t.Fatal(err)
+		}
+		if err := state.WriteFile(filepath.Join(src, "state.txt"), s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for seq := 1; seq <= 3; seq++ {
+		addSeq(seq)
+	}
+
+	url := "file://" + filepath.ToSlash(src) + "/"
+	s, err := CurrentState(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Sequence != 3 {
+		t.Errorf("unexpected current sequence %d", s.Sequence)
+	}
+
+	dl := newDownloader(dest, url, 2, time.Minute)
+	dl.naWaittime = 10 * time.Millisecond
+	go dl.fetchNextLoop()
+	defer dl.Stop()
+
+	next := func() replication.Sequence {
+		select {
+		case seq := <-dl.Sequences():
+			if seq.Error != nil {
+				t.Fatal(seq.Error)
+			}
+			return seq
+		case <-time.After(5 * time.Second):
+			t.Fatal("timeout while waiting for sequence")
+		}
+		return replication.Sequence{}
+	}
+
+	check := func(seq replication.Sequence, want int, latest bool) {
+		t.Helper()
+		if seq.Sequence != want || seq.Latest != latest {
+			t.Errorf("got sequence %d (latest %v), want %d (latest %v)", seq.Sequence, seq.Latest, want, latest)
+		}
+		if !seq.Time.Equal(start.Add(time.Duration(want) * time.Minute)) {
+			t.Errorf("unexpected time %v for %d", seq.Time, want)
+		}
+		if seq.Filename != filepath.Join(dest, seqPath(want)+fileExt) {
+			t.Errorf("unexpected filename %q", seq.Filename)
+		}
+		if _, err := os.Stat(seq.Filename); err != nil {
+			t.Error(err)
+		}
+	}
+
+	check(next(), 2, false)
+	check(next(), 3, true)
+
+	addSeq(4)
+	check(next(), 4, true)
+
+	dl.Stop()
+	select {
+	case _, ok := <-dl.Sequences():
+		if ok {
+			t.Error("sequences not closed after Stop")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout while waiting for Stop")
+	}
+}
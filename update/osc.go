@@ -0,0 +1,84 @@
+package update
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// oscFile is an opened change file. Reads return the uncompressed content.
+type oscFile struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (f *oscFile) Close() error {
+	var err error
+	for i := len(f.closers) - 1; i >= 0; i-- {
+		if cerr := f.closers[i].Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// openOSC opens an OSM change file. The file can be uncompressed or
+// compressed with gzip or bzip2. The compression is detected from the
+// content and from the file extension as a fallback.
+func openOSC(filename string) (*oscFile, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	result := &oscFile{closers: []io.Closer{f}}
+
+	br := bufio.NewReader(f)
+	magic, err := br.Peek(3)
+	if err != nil && err != io.EOF {
+		result.Close()
+		return nil, errors.Wrapf(err, "reading %q", filename)
+	}
+
+	lower := strings.ToLower(filename)
+	switch {
+	case bytes.HasPrefix(magic, []byte{0x1f, 0x8b}):
+		lower = ".gz"
+	case bytes.HasPrefix(magic, []byte("BZh")):
+		lower = ".bz2"
+	case bytes.HasPrefix(magic, []byte("<")), bytes.HasPrefix(magic, []byte("\xef\xbb\xbf")):
+		lower = ".osc"
+	}
+
+	switch {
+	case strings.HasSuffix(lower, ".gz"):
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			result.Close()
+			return nil, errors.Wrapf(err, "reading gzip file %q", filename)
+		}
+		result.closers = append(result.closers, gz)
+		result.Reader = gz
+	case strings.HasSuffix(lower, ".bz2"):
+		result.Reader = bzip2.NewReader(br)
+	default:
+		result.Reader = br
+	}
+	return result, nil
+}
+
+// stateFilename returns the name of the .state.txt file for the change file.
+// Returns an empty string for unknown extensions.
+func stateFilename(oscFilename string) string {
+	for _, ext := range []string{".osc.gz", ".osc.bz2", ".osc"} {
+		if strings.HasSuffix(oscFilename, ext) {
+			return oscFilename[:len(oscFilename)-len(ext)] + ".state.txt"
+		}
+	}
+	return ""
+}
@@ -0,0 +1,81 @@
+package update
+
+import (
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const testOSC = `<?xml version="1.0" encoding="UTF-8"?>
+<osmChange version="0.6"><create><node id="1" version="1" lat="1" lon="2"/></create></osmChange>
+`
+
+func TestOpenOSC(t *testing.T) {
+	dir, err := ioutil.TempDir("", "imposm3-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	gz := &bytes.Buffer{}
+	w := gzip.NewWriter(gz)
+	w.Write([]byte(testOSC))
+	w.Close()
+
+	files := map[string][]byte{
+		"plain.osc":       []byte(testOSC),
+		"gzip.osc.gz":     gz.Bytes(),
+		"gzip-no-ext.osc": gz.Bytes(),
+		"bzip2.osc.bz2":   bzip2Fixture(t),
+	}
+	for name, content := range files {
+		filename := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(filename, content, 0644); err != nil {
+			t.Fatal(err)
+		}
+		f, err := openOSC(filename)
+		if err != nil {
+			t.Fatal(name, err)
+		}
+		b, err := ioutil.ReadAll(f)
+		f.Close()
+		if err != nil {
+			t.Fatal(name, err)
+		}
+		if string(b) != testOSC {
+			t.Errorf("unexpected content of %s: %q", name, b)
+		}
+	}
+}
+
+// bzip2Fixture returns testOSC compressed with bzip2. compress/bzip2 only
+// supports decompression.
+func bzip2Fixture(t *testing.T) []byte {
+	b, err := ioutil.ReadFile("testdata/plain.osc.bz2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ioutil.ReadAll(bzip2.NewReader(bytes.NewReader(b))); err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestStateFilename(t *testing.T) {
+	for _, tc := range []struct {
+		osc, state string
+	}{
+		{"diffs/000/001/123.osc.gz", "diffs/000/001/123.state.txt"},
+		{"diffs/000/001/123.osc.bz2", "diffs/000/001/123.state.txt"},
+		{"hotfix.osc", "hotfix.state.txt"},
+		{"hotfix.xml", ""},
+	} {
+		if s := stateFilename(tc.osc); s != tc.state {
+			t.Errorf("unexpected state file %q for %q, expected %q", s, tc.osc, tc.state)
+		}
+	}
+}
@@ -2,7 +2,6 @@ package update
 
 import (
 	"context"
-	"os"
 
 	"github.com/pkg/errors"
 
@@ -32,18 +31,16 @@ func importDiffFile(
 ) error {
 	diffs := make(chan osm.Diff)
 	config := diff.Config{
-		Diffs: diffs,
+		Diffs:           diffs,
+		IncludeMetadata: tagmapping.UsesMetadata(),
 	}
 
-	f, err := os.Open(oscFile)
+	f, err := openOSC(oscFile)
 	if err != nil {
 		return errors.Wrap(err, "opening diff file")
 	}
 	defer f.Close()
-	parser, err := diff.NewGZIP(f, config)
-	if err != nil {
-		return errors.Wrap(err, "initializing diff parser")
-	}
+	parser := diff.New(f, config)
 
 	db.EnableGeneralizeUpdates()
 
@@ -63,6 +60,7 @@ func importDiffFile(
 	parseProgress := stats.NewStatsReporter()
 	defer parseProgress.Stop()
 
+	tagRewriter := tagmapping.TagRewriter()
 	relTagFilter := tagmapping.RelationTagFilter()
 	wayTagFilter := tagmapping.WayTagFilter()
 	nodeTagFilter := tagmapping.NodeTagFilter()
@@ -120,12 +118,15 @@ func importDiffFile(
 
 	for elem := range diffs {
 		if elem.Rel != nil {
+			tagRewriter.Rewrite(&elem.Rel.Tags)
 			relTagFilter.Filter(&elem.Rel.Tags)
 			parseProgress.AddRelations(1)
 		} else if elem.Way != nil {
+			tagRewriter.Rewrite(&elem.Way.Tags)
 			wayTagFilter.Filter(&elem.Way.Tags)
 			parseProgress.AddWays(1)
 		} else if elem.Node != nil {
+			tagRewriter.Rewrite(&elem.Node.Tags)
 			nodeTagFilter.Filter(&elem.Node.Tags)
 			if len(elem.Node.Tags) > 0 {
 				parseProgress.AddNodes(1)
@@ -0,0 +1,56 @@
+/*
+Package state provides the replication state of imports and of replication
+URLs. It is used by the import and the update packages.
+*/
+package state
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/omniscale/go-osm/state"
+	"github.com/omniscale/imposm3/update/filesource"
+	"github.com/pkg/errors"
+)
+
+// LastStateFilename is the name of the state file of the last imported
+// sequence in the diff directory.
+const LastStateFilename = "last.state.txt"
+
+// CurrentState returns the latest state of the replication URL.
+func CurrentState(url string) (*state.DiffState, error) {
+	if filesource.IsFileURL(url) {
+		return filesource.CurrentState(url)
+	}
+	resp, err := http.Get(url + "state.txt")
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != 200 {
+		return nil, errors.New(fmt.Sprintf("invalid response: %v", resp))
+	}
+	defer resp.Body.Close()
+	return state.Parse(resp.Body)
+}
+
+// EstimateSequence returns the sequence of the replication URL that
+// contains the changes till timestamp. The estimation counts backwards from
+// the current state, the returned sequence can be older as some sequences
+// contain more then one interval.
+func EstimateSequence(url string, interval time.Duration, timestamp time.Time) (int, error) {
+	state, err := CurrentState(url)
+	if err != nil {
+		// discard first error and try a second time before failing
+		time.Sleep(time.Second * 2)
+		state, err = CurrentState(url)
+		if err != nil {
+			return 0, errors.Wrap(err, "fetching current state")
+		}
+	}
+
+	behind := state.Time.Sub(timestamp)
+	// Sequence unit depends on replication interval (minute, hour, day).
+	return state.Sequence - int(math.Ceil(behind.Minutes()/interval.Minutes())), nil
+}
@@ -0,0 +1,225 @@
+package update
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/omniscale/go-osm/replication"
+	diffstate "github.com/omniscale/go-osm/state"
+	"github.com/omniscale/imposm3/log"
+	"github.com/pkg/errors"
+	"gopkg.in/fsnotify.v1"
+)
+
+// watchSettle is the duration a change file needs to stay unmodified before
+// it is considered complete.
+const watchSettle = 2 * time.Second
+
+// isChangeFile returns true if name is a change file and not a temporary or
+// partial file (e.g. .123.osc.gz, 123.osc.gz.tmp or 123.osc.gz.part).
+func isChangeFile(name string) bool {
+	base := filepath.Base(name)
+	if strings.HasPrefix(base, ".") || strings.HasPrefix(base, "~") || strings.HasSuffix(base, "~") {
+		return false
+	}
+	return stateFilename(base) != ""
+}
+
+// dirWatcher sends all change files of a directory in sequence order. It
+// sends existing files first and then all new files as soon as they are
+// complete.
+type dirWatcher struct {
+	dir     string
+	settle  time.Duration
+	force   bool
+	lastSeq int // -1 if unknown
+
+	watcher *fsnotify.Watcher
+	// last modification of all incomplete change files
+	pending map[string]time.Time
+	// sequence of all sent change files, 0 for files without a state file
+	done map[string]int
+
+	out  chan replication.Sequence
+	stop chan struct{}
+}
+
+// watchSequences returns all new change files from dir. Files that are
+// already imported according to lastStateFile are skipped, unless force is
+// true. The returned function stops the watcher.
+func watchSequences(dir, lastStateFile string, force bool) (<-chan replication.Sequence, func(), error) {
+	w, err := newDirWatcher(dir, lastStateFile, force, watchSettle)
+	if err != nil {
+		return nil, nil, err
+	}
+	go w.run()
+	return w.out, w.Stop, nil
+}
+
+func newDirWatcher(dir, lastStateFile string, force bool, settle time.Duration) (*dirWatcher, error) {
+	w := &dirWatcher{
+		dir:     dir,
+		settle:  settle,
+		force:   force,
+		lastSeq: -1,
+		pending: make(map[string]time.Time),
+		done:    make(map[string]int),
+		out:     make(chan replication.Sequence),
+		stop:    make(chan struct{}),
+	}
+
+	lastState, err := diffstate.ParseFile(lastStateFile)
+	if err != nil && !force {
+		log.Printf("[info] Unable to read last state, will not check if already imported: %v", err)
+	}
+	if lastState != nil {
+		w.lastSeq = lastState.Sequence
+	}
+
+	w.watcher, err = fsnotify.NewWatcher()
+	if err != nil {
+		return nil, errors.Wrap(err, "creating file watcher")
+	}
+	// watch before listing, to not miss any file created in-between
+	if err := w.watcher.Add(dir); err != nil {
+		w.watcher.Close()
+		return nil, errors.Wrapf(err, "watching %s", dir)
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		w.watcher.Close()
+		return nil, errors.Wrapf(err, "listing %s", dir)
+	}
+	for _, fi := range files {
+		if fi.Mode().IsRegular() && isChangeFile(fi.Name()) {
+			w.pending[filepath.Join(dir, fi.Name())] = fi.ModTime()
+		}
+	}
+	return w, nil
+}
+
+// Stop stops the watcher. The sequence channel is not closed.
+func (w *dirWatcher) Stop() {
+	close(w.stop)
+}
+
+func (w *dirWatcher) run() {
+	defer w.watcher.Close()
+
+	ticker := time.NewTicker(w.settle / 2)
+	defer ticker.Stop()
+
+	for {
+		if !w.sendCompleted(time.Now()) {
+			return
+		}
+		select {
+		case <-w.stop:
+			return
+		case evt := <-w.watcher.Events:
+			w.handleEvent(evt)
+		case err := <-w.watcher.Errors:
+			select {
+			case w.out <- replication.Sequence{Error: errors.Wrapf(err, "watching %s", w.dir)}:
+			case <-w.stop:
+				return
+			}
+		case <-ticker.C:
+		}
+	}
+}
+
+func (w *dirWatcher) handleEvent(evt fsnotify.Event) {
+	name := evt.Name
+	if strings.HasSuffix(name, ".state.txt") {
+		// state files are written after the change file, wait till it is
+		// complete as well
+		for osc := range w.pending {
+			if stateFilename(osc) == name {
+				w.pending[osc] = time.Now()
+			}
+		}
+		return
+	}
+	if _, ok := w.done[name]; ok || !isChangeFile(name) {
+		return
+	}
+	if evt.Op&(fsnotify.Remove|fsnotify.Rename) != 0 {
+		delete(w.pending, name)
+		return
+	}
+	if evt.Op&(fsnotify.Create|fsnotify.Write) != 0 {
+		w.pending[name] = time.Now()
+	}
+}
+
+// sendCompleted sends all change files that were not modified within the
+// settle duration. Returns false if the watcher was stopped.
+func (w *dirWatcher) sendCompleted(now time.Time) bool {
+	w.pruneDone()
+
+	var seqs []replication.Sequence
+	for name, modified := range w.pending {
+		if now.Sub(modified) < w.settle {
+			continue
+		}
+		delete(w.pending, name)
+		w.done[name] = 0
+
+		seq := replication.Sequence{Filename: name}
+		stateFile := stateFilename(name)
+		state, err := diffstate.ParseFile(stateFile)
+		if err != nil && !os.IsNotExist(err) {
+			seq.Error = errors.Wrapf(err, "reading state %s", stateFile)
+		} else if state != nil {
+			w.done[name] = state.Sequence
+			if state.Sequence <= w.lastSeq && !w.force {
+				log.Printf("[info] Skipping %d (%v), already imported", state.Sequence, state.Time)
+				continue
+			}
+			seq.Sequence = state.Sequence
+			seq.Time = state.Time
+			seq.StateFilename = stateFile
+		}
+		seqs = append(seqs, seq)
+	}
+	if len(seqs) == 0 {
+		return true
+	}
+
+	sort.Slice(seqs, func(i, j int) bool {
+		if seqs[i].Sequence != seqs[j].Sequence {
+			return seqs[i].Sequence < seqs[j].Sequence
+		}
+		return seqs[i].Filename < seqs[j].Filename
+	})
+	seqs[len(seqs)-1].Latest = len(w.pending) == 0
+
+	for _, seq := range seqs {
+		select {
+		case w.out <- seq:
+		case <-w.stop:
+			return false
+		}
+		if seq.Sequence > w.lastSeq {
+			w.lastSeq = seq.Sequence
+		}
+	}
+	return true
+}
+
+// pruneDone removes all change files up to lastSeq from done. Later events
+// for these files are skipped by their state, unless force is set. Files
+// without a state file are kept.
+func (w *dirWatcher) pruneDone() {
+	for name, seq := range w.done {
+		if seq != 0 && seq <= w.lastSeq {
+			delete(w.done, name)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package update
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/omniscale/go-osm/replication"
+	diffstate "github.com/omniscale/go-osm/state"
+	"gopkg.in/fsnotify.v1"
+)
+
+func TestIsChangeFile(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		want bool
+	}{
+		{"123.osc.gz", true},
+		{"/tmp/diffs/123.osc.bz2", true},
+		{"123.osc", true},
+		{"123.osc.gz.tmp", false},
+		{"123.osc.gz.part", false},
+		{".123.osc.gz", false},
+		{"123.osc.gz~", false},
+		{"123.state.txt", false},
+		{"123.pbf", false},
+	} {
+		if got := isChangeFile(tc.name); got != tc.want {
+			t.Errorf("isChangeFile(%q) = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestDirWatcher(t *testing.T) {
+	dir, err := ioutil.TempDir("", "imposm3-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	writeSeq := func(name string, seq int) {
+		if err := ioutil.WriteFile(filepath.Join(dir, name+".osc.gz"), []byte("test"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if seq == 0 {
+			return
+		}
+		if err := diffstate.WriteFile(filepath.Join(dir, name+".state.txt"), &diffstate.DiffState{Sequence: seq}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	lastStateFile := filepath.Join(dir, "last.state.txt")
+	if err := diffstate.WriteFile(lastStateFile, &diffstate.DiffState{Sequence: 10}); err != nil {
+		t.Fatal(err)
+	}
+	writeSeq("010", 10)
+	writeSeq("012", 12)
+	writeSeq("011", 11)
+	if err := ioutil.WriteFile(filepath.Join(dir, "013.osc.gz.tmp"), []byte("test"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := newDirWatcher(dir, lastStateFile, false, 50*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go w.run()
+	defer w.Stop()
+
+	next := func() replication.Sequence {
+		select {
+		case seq := <-w.out:
+			if seq.Error != nil {
+				t.Fatal(seq.Error)
+			}
+			return seq
+		case <-time.After(5 * time.Second):
+			t.Fatal("timeout while waiting for change file")
+		}
+		return replication.Sequence{}
+	}
+
+	for _, want := range []int{11, 12} {
+		seq := next()
+		if seq.Sequence != want {
+			t.Fatalf("got sequence %d, want %d", seq.Sequence, want)
+		}
+		if seq.Filename != filepath.Join(dir, fmt.Sprintf("%03d.osc.gz", want)) {
+			t.Errorf("unexpected filename %q", seq.Filename)
+		}
+	}
+
+	// rename temp file after it was written, as downloaders do
+	writeSeq("013", 13)
+	writeSeq("009", 9)
+	if err := os.Remove(filepath.Join(dir, "013.osc.gz")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(filepath.Join(dir, "013.osc.gz.tmp"), filepath.Join(dir, "013.osc.gz")); err != nil {
+		t.Fatal(err)
+	}
+	seq := next()
+	if seq.Sequence != 13 || !seq.Latest {
+		t.Errorf("unexpected sequence %#v", seq)
+	}
+
+	writeSeq("plain", 0)
+	seq = next()
+	if seq.Sequence != 0 || seq.Filename != filepath.Join(dir, "plain.osc.gz") {
+		t.Errorf("unexpected sequence %#v", seq)
+	}
+}
+
+func TestDirWatcherDone(t *testing.T) {
+	dir, err := ioutil.TempDir("", "imposm3-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for seq := 1; seq <= 3; seq++ {
+		name := filepath.Join(dir, fmt.Sprintf("%03d", seq))
+		if err := ioutil.WriteFile(name+".osc.gz", []byte("test"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := diffstate.WriteFile(name+".state.txt", &diffstate.DiffState{Sequence: seq}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "plain.osc.gz"), []byte("test"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := newDirWatcher(dir, filepath.Join(dir, "last.state.txt"), false, time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.watcher.Close()
+	w.out = make(chan replication.Sequence, 10)
+
+	now := time.Now().Add(time.Second)
+	w.sendCompleted(now)
+	if len(w.out) != 4 || w.lastSeq != 3 {
+		t.Fatalf("unexpected sequences %d, last %d", len(w.out), w.lastSeq)
+	}
+	w.sendCompleted(now)
+	// all files up to lastSeq are pruned, only the file without a state is kept
+	if len(w.done) != 1 || w.done[filepath.Join(dir, "plain.osc.gz")] != 0 {
+		t.Errorf("unexpected done files %v", w.done)
+	}
+
+	// late events for pruned files are skipped by their state
+	w.handleEvent(fsnotify.Event{Name: filepath.Join(dir, "002.osc.gz"), Op: fsnotify.Write})
+	w.handleEvent(fsnotify.Event{Name: filepath.Join(dir, "plain.osc.gz"), Op: fsnotify.Write})
+	w.sendCompleted(now.Add(time.Second))
+	if len(w.out) != 4 {
+		t.Errorf("unexpected sequences %d", len(w.out))
+	}
+}